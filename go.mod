@@ -5,15 +5,30 @@ go 1.23
 toolchain go1.23.2
 
 require (
+	github.com/fclairamb/ftpserverlib v0.25.0
 	github.com/msteinert/pam v1.2.0
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/sirupsen/logrus v1.9.3
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	github.com/spf13/afero v1.11.0
+	golang.org/x/image v0.18.0
+	golang.org/x/text v0.19.0
 )
 
 require gopkg.in/yaml.v2 v2.4.0
 
-require github.com/yuin/goldmark v1.7.8
+require (
+	github.com/yuin/goldmark v1.7.8
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/crypto v0.28.0
+	golang.org/x/net v0.21.0 // indirect
+)
 
 require (
-	golang.org/x/sys v0.6.0 // indirect
+	github.com/fclairamb/go-log v0.5.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
 )
@@ -1,29 +1,78 @@
 package main
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"time"
 
 	"path"
 	"path/filepath"
 	"simple_file_server/pkg"
+	"simple_file_server/pkg/acme"
 	"simple_file_server/pkg/auth"
+	"simple_file_server/pkg/childcount"
+	"simple_file_server/pkg/chunked"
+	"simple_file_server/pkg/contactsheet"
+	"simple_file_server/pkg/descriptions"
+	"simple_file_server/pkg/dirsize"
+	"simple_file_server/pkg/dirtemplate"
+	"simple_file_server/pkg/events"
+	"simple_file_server/pkg/expiry"
+	"simple_file_server/pkg/extract"
+	"simple_file_server/pkg/favorites"
+	"simple_file_server/pkg/ftp"
 	"simple_file_server/pkg/logger"
+	"simple_file_server/pkg/metadata"
+	"simple_file_server/pkg/metrics"
+	"simple_file_server/pkg/preview"
+	"simple_file_server/pkg/quota"
+	"simple_file_server/pkg/recent"
+	"simple_file_server/pkg/rewrite"
+	"simple_file_server/pkg/search"
+	"simple_file_server/pkg/selftest"
+	"simple_file_server/pkg/share"
+	"simple_file_server/pkg/tags"
+	"simple_file_server/pkg/tempsweep"
+	"simple_file_server/pkg/uploadlink"
+	"simple_file_server/pkg/webhook"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"unicode/utf8"
 
+	"github.com/skip2/go-qrcode"
 	"github.com/yuin/goldmark"
 	"gopkg.in/yaml.v2"
 )
 
 var baseDir string
 
+// config - configuration loaded at startup
+var config pkg.Config
+
+// listingExcludes - compiled web-server.listing_exclude patterns
+var listingExcludes pkg.ExcludeRules
+
+// rewriteRules - compiled rewrites
+var rewriteRules []rewrite.Rule
+
 // setup - function for setting up the configuration
 func setup() (pkg.Config, error) {
     // Parsing command line arguments
@@ -33,21 +82,32 @@ func setup() (pkg.Config, error) {
     // Reading and parsing the configuration file
     var config pkg.Config
     if _, err := os.Stat(*configPath); os.IsNotExist(err) {
-        return config, fmt.Errorf("configuration file not found: %s", *configPath)
-    }
-    // Reading the configuration file
-    configFile, err := os.ReadFile(*configPath)
-    if err != nil {
-        return config, fmt.Errorf("error opening configuration file: %v", err)
-    }
-    // Parsing the configuration file
-    err = yaml.Unmarshal(configFile, &config)
-    if err != nil {
-        return config, fmt.Errorf("error parsing configuration file: %v", err)
+        // The configuration may come entirely from environment variables
+        if !pkg.HasEnv() {
+            return config, fmt.Errorf("configuration file not found: %s", *configPath)
+        }
+    } else {
+        // Reading the configuration file
+        configFile, err := os.ReadFile(*configPath)
+        if err != nil {
+            return config, fmt.Errorf("error opening configuration file: %v", err)
+        }
+        // Parsing the configuration file
+        err = yaml.Unmarshal(configFile, &config)
+        if err != nil {
+            return config, fmt.Errorf("error parsing configuration file: %v", err)
+        }
     }
 
+    // Environment variables override the configuration file
+    applied := config.ApplyEnv()
+
     // Setting up logging
     logger.LogSetup(config.Logging)
+    if len(applied) > 0 {
+        sort.Strings(applied)
+        logger.Logger.Printf("Configuration overridden by environment: %s", strings.Join(applied, ", "))
+    }
 
     return config, nil
 
@@ -56,16 +116,358 @@ func setup() (pkg.Config, error) {
 
 func main() {
     // Setting up configuration
-    config, err := setup()
+    var err error
+    config, err = setup()
     if err != nil {
         logger.Logger.Fatalf("Error setting up configuration: %v", err)
     }
+    applyConfig()
+
+    // Background jobs
+    auth.StartSessionReaper(config.Auth.SessionReapInterval)
+    if config.Auth.HtpasswdFile != "" {
+        reloadOnHangup()
+    }
+    search.SetupIndex(config.Search, baseDir)
+    expiry.Start(config.Expiry, baseDir, expirySkip())
+    tempsweep.Start(config.Upload.TempSweep, os.TempDir())
+    handler := newHandler()
+
+    // Read-only FTP access for tools that only speak FTP
+    if config.FTP.Enabled {
+        if config.FTP.Port == "" {
+            config.FTP.Port = "2121"
+        }
+        err := ftp.Start(config.FTP, ftp.Options{
+            Base:          baseDir,
+            CertFile:      config.WebServer.SSLCert,
+            KeyFile:       config.WebServer.SSLKey,
+            Authenticate:  auth.Authenticate,
+            Visible:       ftpVisible,
+            SymlinkPolicy: config.WebServer.SymlinkPolicy,
+        })
+        if err != nil {
+            logger.Logger.Fatalf("Error starting FTP server: %v", err)
+        }
+        logger.Logger.Printf("FTP server started on port %s", config.FTP.Port)
+    }
+
+    addr := ":" + config.WebServer.Port
+
+    logger.Logger.Printf("Server started at %s://localhost%s\n", config.WebServer.Protocol, addr)
+
+    server := &http.Server{Addr: addr, Handler: handler}
+    listen := server.ListenAndServe
+    if config.WebServer.Protocol == "https" && config.WebServer.ACME.Enabled {
+        // Certificates are obtained and renewed automatically
+        manager, err := acme.NewManager(config.WebServer.ACME)
+        if err != nil {
+            logger.Logger.Fatalf("Invalid ACME configuration: %v", err)
+        }
+        challengePort := config.WebServer.ACME.HTTPPort
+        if challengePort == "" {
+            challengePort = "80"
+        }
+        // Serve the HTTP-01 challenge, redirecting other plain HTTP requests to HTTPS
+        go func() {
+            logger.Logger.Fatal(http.ListenAndServe(":"+challengePort, manager.HTTPHandler(nil)))
+        }()
+        logger.Logger.Printf("ACME enabled for domains: %s", strings.Join(config.WebServer.ACME.Domains, ", "))
+        server.TLSConfig = manager.TLSConfig()
+        listen = func() error { return server.ListenAndServeTLS("", "") }
+    } else if config.WebServer.Protocol == "https" {
+        if config.WebServer.SSLCert == "" || config.WebServer.SSLKey == "" {
+            logger.Logger.Fatal("For HTTPS, ssl_cert_file and ssl_key_file must be specified in the configuration")
+        }
+        listen = func() error { return server.ListenAndServeTLS(config.WebServer.SSLCert, config.WebServer.SSLKey) }
+    }
+
+    stop := make(chan os.Signal, 1)
+    signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+    if err := runServer(server, listen, stop); err != nil {
+        logger.Logger.Fatal(err)
+    }
+    logger.Logger.Printf("Server stopped")
+}
+
+// shutdownTimeout - time given to the requests in progress when the server stops
+const shutdownTimeout = 30 * time.Second
+
+// runServer - serves with listen until a signal arrives on stop, then lets the requests
+// in progress finish and stops the session reaper. It returns nil after a graceful
+// shutdown and the error of listen otherwise.
+func runServer(server *http.Server, listen func() error, stop <-chan os.Signal) error {
+    served := make(chan error, 1)
+    go func() {
+        served <- listen()
+    }()
+    select {
+    case err := <-served:
+        if errors.Is(err, http.ErrServerClosed) {
+            err = nil
+        }
+        auth.StopSessionReaper()
+        return err
+    case sig := <-stop:
+        logger.Logger.Printf("Received %s, shutting down", sig)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+    defer cancel()
+    err := server.Shutdown(ctx)
+    auth.StopSessionReaper()
+    if err != nil {
+        return fmt.Errorf("error shutting down the server: %w", err)
+    }
+    // ListenAndServe returns ErrServerClosed as soon as Shutdown is called
+    if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+        return err
+    }
+    return nil
+}
+
+// applyConfig - fills in the defaults of the loaded configuration and sets up the
+// packages that depend on it, stopping the server on invalid settings
+func applyConfig() {
+    var err error
     // Setting the base directory
     baseDir = config.WebServer.BaseDir
     logger.Logger.Printf("Base directory: %s", baseDir)
+    if config.WebServer.AutocompleteLimit <= 0 {
+        config.WebServer.AutocompleteLimit = 20
+    }
+    if config.WebServer.MaxTreeDepth <= 0 {
+        config.WebServer.MaxTreeDepth = 10
+    }
+    if config.Upload.FieldName == "" {
+        config.Upload.FieldName = "uploadFiles"
+    }
+    if config.PWA.Name == "" {
+        config.PWA.Name = "File Manager"
+    }
+    if config.PWA.ShortName == "" {
+        config.PWA.ShortName = config.PWA.Name
+    }
+    if len(config.PWA.Icons) == 0 {
+        config.PWA.Icons = []pkg.PWAIcon{{Src: "/static/icons/favicon-48x48.png", Sizes: "48x48", Type: "image/png"}}
+    }
+    if config.Preview.MaxSize <= 0 {
+        config.Preview.MaxSize = 5
+    }
+    if config.Preview.MaxRows <= 0 {
+        config.Preview.MaxRows = 100
+    }
+    if config.Preview.FallbackEncoding == "" {
+        config.Preview.FallbackEncoding = preview.EncodingLatin1
+    }
+    if err := preview.SetFallbackEncoding(config.Preview.FallbackEncoding); err != nil {
+        logger.Logger.Fatalf("Invalid preview fallback_encoding: %v", err)
+    }
+    if config.Archive.MaxDepth <= 0 {
+        config.Archive.MaxDepth = 32
+    }
+    if config.Archive.MaxGlobMatches <= 0 {
+        config.Archive.MaxGlobMatches = 1000
+    }
+    if config.Search.MaxResults <= 0 {
+        config.Search.MaxResults = 100
+    }
+    if config.Search.MaxScanned <= 0 {
+        config.Search.MaxScanned = 100000
+    }
+
+    if config.WebServer.SymlinkPolicy == "" {
+        config.WebServer.SymlinkPolicy = pkg.SymlinkContain
+    }
+    if err := pkg.ValidSymlinkPolicy(config.WebServer.SymlinkPolicy); err != nil {
+        logger.Logger.Fatalf("Invalid symlink_policy: %v", err)
+    }
+    if config.Upload.Extract.MaxSize <= 0 {
+        config.Upload.Extract.MaxSize = 1024
+    }
+    if config.Upload.Extract.MaxEntries <= 0 {
+        config.Upload.Extract.MaxEntries = 10000
+    }
+    if config.Upload.Extract.Symlinks == "" {
+        config.Upload.Extract.Symlinks = extract.SymlinksSkip
+    }
+    if err := extract.ValidSymlinks(config.Upload.Extract.Symlinks); err != nil {
+        logger.Logger.Fatalf("Invalid upload.extract.symlinks: %v", err)
+    }
+    if config.Upload.Extract.Unsupported == "" {
+        config.Upload.Extract.Unsupported = extract.UnsupportedReject
+    }
+    if err := extract.ValidUnsupported(config.Upload.Extract.Unsupported); err != nil {
+        logger.Logger.Fatalf("Invalid upload.extract.unsupported: %v", err)
+    }
+    if err := auth.ValidSessionLimitPolicy(config.Auth.SessionLimitPolicy); err != nil {
+        logger.Logger.Fatalf("Invalid session_limit_policy: %v", err)
+    }
+    if err := pkg.ValidGroupBy(config.WebServer.GroupBy); err != nil {
+        logger.Logger.Fatalf("Invalid group_by: %v", err)
+    }
+    listingExcludes, err = pkg.CompileExcludes(config.WebServer.ListingExclude)
+    if err != nil {
+        logger.Logger.Fatalf("Invalid listing_exclude: %v", err)
+    }
+    rewriteRules, err = rewrite.Compile(config.Rewrites)
+    if err != nil {
+        logger.Logger.Fatalf("Invalid rewrites: %v", err)
+    }
+
+    if config.WebServer.FSRetry.Attempts > 1 && config.WebServer.FSRetry.Delay <= 0 {
+        config.WebServer.FSRetry.Delay = 50 * time.Millisecond
+    }
+    pkg.SetRetry(config.WebServer.FSRetry.Attempts, config.WebServer.FSRetry.Delay)
+
+    if err := pkg.SetTrustedProxies(config.WebServer.TrustedProxies); err != nil {
+        logger.Logger.Fatalf("Invalid trusted_proxies: %v", err)
+    }
+    uploadLimiter = pkg.NewConcurrencyLimiter(config.Upload.MaxConcurrentPerIP)
+    level, err := pkg.ParseCompressionLevel(config.Archive.CompressionLevel)
+    if err != nil {
+        logger.Logger.Fatalf("Invalid archive compression_level: %v", err)
+    }
+    archiveLevel = level
+    maxUploadSize, err = pkg.ParseSize(config.WebServer.MaxUploadSize, defaultMaxUploadSize)
+    if err != nil {
+        logger.Logger.Fatalf("Invalid max_upload_size: %v", err)
+    }
+    archiveFormat, err = pkg.ParseArchiveFormat(config.Archive.Format)
+    if err != nil {
+        logger.Logger.Fatalf("Invalid archive format: %v", err)
+    }
+    readmeDepth, err = pkg.ParseReadmeDepth(config.WebServer.ReadmeDepth)
+    if err != nil {
+        logger.Logger.Fatalf("Invalid readme_depth: %v", err)
+    }
+
+    // Setting up authentication
+    if config.Auth.SessionDuration == 0 {
+        config.Auth.SessionDuration = 24 * time.Hour
+    }
+    if config.Auth.SessionDuration < 0 {
+        logger.Logger.Fatalf("Invalid session_duration: %s (must be positive)", config.Auth.SessionDuration)
+    }
+    logger.Logger.Printf("Session duration: %s", config.Auth.SessionDuration)
+    if err := auth.Setup(config.Auth); err != nil {
+        logger.Logger.Fatalf("Invalid auth configuration: %v", err)
+    }
+    if config.Auth.SessionReapInterval <= 0 {
+        config.Auth.SessionReapInterval = 10 * time.Minute
+    }
+    if err := share.Setup(config.Shares, config.WebServer.IsCaseSensitive()); err != nil {
+        logger.Logger.Fatalf("Invalid shares: %v", err)
+    }
+
+    // Setting up the recent uploads feed
+    recent.Setup(config.Recent)
+
+    // Setting up the per-user upload quotas
+    quota.Setup(config.Upload.Quota)
+
+    // Setting up the per-user favorites
+    favorites.Setup(config.Favorites)
+
+    // Setting up the file tags
+    if config.Tags.Enabled {
+        tags.Setup(config.Tags, baseDir)
+    }
+
+    // Defaults of the contact sheets of image folders
+    if config.ContactSheet.Columns <= 0 {
+        config.ContactSheet.Columns = 6
+    }
+    if config.ContactSheet.CellSize <= 0 {
+        config.ContactSheet.CellSize = 160
+    }
+    if config.ContactSheet.MaxImages <= 0 {
+        config.ContactSheet.MaxImages = 100
+    }
+    if config.ContactSheet.Format == "" {
+        config.ContactSheet.Format = contactsheet.FormatJPEG
+    }
+    if err := contactsheet.ValidFormat(config.ContactSheet.Format); err != nil {
+        logger.Logger.Fatalf("Invalid contact_sheet format: %v", err)
+    }
+    if config.ContactSheet.Quality <= 0 || config.ContactSheet.Quality > 100 {
+        config.ContactSheet.Quality = 85
+    }
+
+    // Setting up the live activity stream
+    events.Setup(config.Events)
+    if config.Events.KeepAlive <= 0 {
+        config.Events.KeepAlive = 30 * time.Second
+    }
+
+    // Setting up the entry counts of listed directories
+    childcount.Setup(config.ChildCount)
+
+    // Setting up the recursive directory sizes
+    dirsize.Setup(config.DirSize)
+
+    // Setting up the automatic deletion of old files
+    if err := expiry.Validate(config.Expiry); err != nil {
+        logger.Logger.Fatalf("Invalid expiry: %v", err)
+    }
+    if config.Expiry.MaxAge > 0 {
+        logger.Logger.Printf("File expiry enabled: max age %s, dry run: %t", config.Expiry.MaxAge, config.Expiry.DryRun)
+    }
+
+    // Setting up the upload webhook
+    webhook.Setup(config.Webhook, config.WebServer.Secret)
+    if config.Webhook.URL != "" {
+        logger.Logger.Printf("Upload webhook enabled: %s", config.Webhook.URL)
+    }
+
+    // Multipart uploads spill to the temp directory, which is swept for abandoned files
+    if config.Upload.TempDir != "" {
+        if err := os.MkdirAll(config.Upload.TempDir, 0700); err != nil {
+            logger.Logger.Fatalf("Error creating upload temp directory: %v", err)
+        }
+        os.Setenv("TMPDIR", config.Upload.TempDir)
+    }
+
+    // Setting up the chunked uploads
+    if config.Upload.Chunked.Enabled {
+        if err := chunked.Setup(config.Upload.Chunked); err != nil {
+            logger.Logger.Fatalf("Error setting up chunked uploads: %v", err)
+        }
+    }
+
+    // Setting up the signed upload links
+    if config.Upload.Links.Enabled {
+        generated, err := uploadlink.Setup(config.WebServer.Secret)
+        if err != nil {
+            logger.Logger.Fatalf("Error setting up upload links: %v", err)
+        }
+        if generated {
+            logger.Logger.Warn("No secret configured: upload links stop working when the server restarts")
+        }
+        if config.Upload.Links.DefaultExpiry <= 0 {
+            config.Upload.Links.DefaultExpiry = 24 * time.Hour
+        }
+        if config.Upload.Links.MaxExpiry <= 0 {
+            config.Upload.Links.MaxExpiry = 7 * 24 * time.Hour
+        }
+    }
+
+    // The banner is rendered once; raw HTML in it is omitted
+    var banner template.HTML
+    if config.WebServer.Banner != "" {
+        var err error
+        banner, err = renderMarkdown([]byte(config.WebServer.Banner))
+        if err != nil {
+            logger.Logger.Fatalf("Invalid banner: %v", err)
+        }
+    }
 
     // Defining custom functions for templates
     funcMap := template.FuncMap{
+        // Function returning the configured banner, shared by every page
+        "banner": func() template.HTML {
+            return banner
+        },
         "splitPath": func(p string) []string {
             return strings.Split(strings.Trim(p, "/"), "/")
         },
@@ -114,64 +516,424 @@ func main() {
             if info == nil {
                 return ""
             }
-            size := info.Size()
-            // Formatting size to a readable format
-            const unit = 1024
-            if size < unit {
-                return fmt.Sprintf("%d B", size)
-            }
-            div, exp := int64(unit), 0
-            for n := size / unit; n >= unit; n /= unit {
-                div *= unit
-                exp++
-            }
-            return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+            return pkg.ReadableSize(info.Size())
         },
+        // Function to get the readable form of a size in bytes
+        "humanSize": pkg.ReadableSize,
+        // Function to escape a path for use in a link
+        "escapePath": pkg.EscapePath,
     }
 
     // Parsing all templates
-    pkg.Templates = template.Must(template.New("").Funcs(funcMap).ParseGlob("templates/*.html"))
+    templates, err := template.New("").Funcs(funcMap).ParseGlob("templates/*.html")
+    if err != nil {
+        logger.Logger.Fatalf("Error loading templates from templates/: %v", err)
+    }
+    if missing := pkg.MissingTemplates(templates, pkg.RequiredTemplates); len(missing) > 0 {
+        logger.Logger.Fatalf("Missing required templates in templates/: %s", strings.Join(missing, ", "))
+    }
+    pkg.Templates = templates
+    if config.WebServer.DirectoryTemplates {
+        dirtemplate.Setup(funcMap)
+    }
+
+    // Checking the base directory and templates before serving requests
+    if config.SelfTest.Enabled {
+        runSelfTest()
+    }
+
+    // Answer for denied paths
+    switch config.WebServer.DenyResponse {
+    case "":
+        denyStatus = 0
+    case "403":
+        denyStatus = http.StatusForbidden
+    case "404":
+        denyStatus = http.StatusNotFound
+    default:
+        logger.Logger.Fatalf("Invalid deny_response: %q (expected 403 or 404)", config.WebServer.DenyResponse)
+    }
+    if config.WebServer.MaxURLLength == 0 {
+        config.WebServer.MaxURLLength = 8192
+    }
+}
+
+// newHandler - builds the routes of the server from the configuration, wrapped in the
+// access log and the configured request filters
+func newHandler() http.Handler {
+    mux := http.NewServeMux()
 
-    fs := http.FileServer(http.Dir("./static"))
-    http.Handle("/static/", http.StripPrefix("/static/", fs))
+    // Directory listings of the static assets are disabled unless configured
+    var staticFS http.FileSystem = http.Dir("./static")
+    if !config.WebServer.StaticListing {
+        staticFS = pkg.NoListingFileSystem{FS: staticFS}
+    }
+    fs := http.FileServer(staticFS)
+    mux.Handle("/static/", http.StripPrefix("/static/", fs))
 
     // Routes without authentication
-    http.HandleFunc("/login", auth.LoginHandler)
-    http.HandleFunc("/logout", auth.LogoutHandler)
-    http.HandleFunc("/check-session", auth.CheckSessionHandler)
-    http.HandleFunc("/", fileHandler)
-    http.HandleFunc("/download", downloadHandler)
+    mux.HandleFunc("/login", auth.LoginHandler)
+    mux.HandleFunc("/logout", auth.LogoutHandler)
+    mux.HandleFunc("/check-session", auth.CheckSessionHandler)
+    mux.HandleFunc("/", fileHandler)
+    mux.HandleFunc("/download", downloadHandler)
+    mux.HandleFunc("/zip-member", zipMemberHandler)
+    mux.HandleFunc("/recent", recentHandler)
+    mux.HandleFunc("/search", searchHandler)
+    mux.HandleFunc("/stat", statHandler)
+    if config.Events.Enabled {
+        mux.HandleFunc("/events", eventsHandler)
+    }
+    mux.HandleFunc("/diff", diffHandler)
+    mux.HandleFunc("/qr", qrHandler)
+    if config.ContactSheet.Enabled {
+        mux.HandleFunc("/contact-sheet", contactSheetHandler)
+    }
+    mux.HandleFunc("/manifest.json", manifestHandler)
+    if config.PWA.ServiceWorker {
+        mux.HandleFunc("/sw.js", serviceWorkerHandler)
+    }
     
     // Routes with authorization for actions
     protected := http.NewServeMux()
     protected.HandleFunc("/upload", uploadHandler)
     protected.HandleFunc("/delete", deleteHandler)
+    protected.HandleFunc("/rename", renameHandler)
     protected.HandleFunc("/create-folder", createFolderHandler)
+    protected.HandleFunc("/create-tree", createTreeHandler)
+    protected.HandleFunc("/favorite", favoriteHandler)
+    protected.HandleFunc("/api/batch", batchHandler)
+    if config.Upload.Chunked.Enabled {
+        protected.HandleFunc("/upload-chunk", uploadChunkHandler)
+    }
+    if config.Upload.Links.Enabled {
+        protected.HandleFunc("/upload-link", uploadLinkHandler)
+        mux.HandleFunc("/drop", dropHandler)
+    }
+    if config.WebServer.Descriptions {
+        protected.HandleFunc("/describe", describeHandler)
+    }
+    if config.Tags.Enabled {
+        protected.HandleFunc("/tag", tagHandler)
+        mux.HandleFunc("/tags", tagsHandler)
+    }
 
     // Apply authorization only to upload, delete, and create actions
-    http.Handle("/upload", auth.AuthMiddlewareForActions(protected))
-    http.Handle("/delete", auth.AuthMiddlewareForActions(protected))
-    http.Handle("/create-folder", auth.AuthMiddlewareForActions(protected))
+    mux.Handle("/upload", auth.AuthMiddlewareForActions(protected))
+    mux.Handle("/delete", auth.AuthMiddlewareForActions(protected))
+    mux.Handle("/rename", auth.AuthMiddlewareForActions(protected))
+    mux.Handle("/create-folder", auth.AuthMiddlewareForActions(protected))
+    mux.Handle("/create-tree", auth.AuthMiddlewareForActions(protected))
+    mux.Handle("/favorite", auth.AuthMiddlewareForActions(protected))
+    mux.Handle("/api/batch", auth.AuthMiddlewareForActions(protected))
+    if config.Upload.Chunked.Enabled {
+        mux.Handle("/upload-chunk", auth.AuthMiddlewareForActions(protected))
+    }
+    if config.Upload.Links.Enabled {
+        mux.Handle("/upload-link", auth.AuthMiddlewareForActions(protected))
+    }
+    if config.WebServer.Descriptions {
+        mux.Handle("/describe", auth.AuthMiddlewareForActions(protected))
+    }
+    if config.Tags.Enabled {
+        mux.Handle("/tag", auth.AuthMiddlewareForActions(protected))
+    }
 
-    addr := ":" + config.WebServer.Port
+    // Administrative routes
+    mux.Handle("/admin/config", auth.AdminMiddleware(http.HandlerFunc(adminConfigHandler)))
+    mux.Handle("/admin/status", auth.AdminMiddleware(http.HandlerFunc(adminStatusHandler)))
+    mux.Handle("/admin/revoke-sessions", auth.AdminMiddleware(http.HandlerFunc(auth.RevokeSessionsHandler)))
+    if config.WebServer.Metrics {
+        mux.HandleFunc("/metrics", metrics.Handler)
+    }
 
-    logger.Logger.Printf("Server started at %s://localhost%s\n", config.WebServer.Protocol, addr)
+    // Every request passes through the access log
+    var handler http.Handler = mux
+    if config.WebServer.CollapseSlashes {
+        handler = collapseSlashes(handler)
+    }
+    if config.WebServer.MaxURLLength > 0 {
+        handler = limitURLLength(config.WebServer.MaxURLLength, handler)
+    }
+    handler = logger.AccessLog(handler)
+    return handler
+}
 
-    if config.WebServer.Protocol == "https" {
-        if config.WebServer.SSLCert == "" || config.WebServer.SSLKey == "" {
-            logger.Logger.Fatal("For HTTPS, ssl_cert_file and ssl_key_file must be specified in the configuration")
+// readmeDepth - deepest directory rendering its README.md (-1 for every directory)
+var readmeDepth int
+
+// listingData - data passed to the directory listing template
+type listingData struct {
+    Path          string
+    FullPath      string
+    Files         []os.DirEntry
+    // Groups - the files split into labeled sections, a single unlabeled one without group_by
+    Groups        []pkg.FileGroup
+    ParentDir     string
+    ModTimes      map[string]time.Time
+    IsLoggedIn    bool
+    ReadmeHTML    template.HTML
+    ThemeColor    string
+    ServiceWorker bool
+    // ShowDescriptions - enables the description column, Descriptions maps entry names to their text
+    ShowDescriptions bool
+    Descriptions     map[string]string
+    // Favorites - directories pinned by the logged-in user, IsFavorite - whether the current one is pinned
+    Favorites  []string
+    IsFavorite bool
+    // Metadata - values of the sidecar metadata files, keyed by entry name
+    Metadata map[string]map[string]string
+    // DirSizes - known recursive sizes of the directories, keyed by name
+    DirSizes map[string]int64
+    // ChildCounts - number of entries of the directories, keyed by name
+    ChildCounts map[string]childcount.Count
+    // Tags - user-defined tags of the entries, keyed by name
+    Tags map[string][]string
+    // LinkBase - absolute URL of the server root, prefixed to paths for "copy link"
+    LinkBase string
+    // ListToken - listing token passed on to the subdirectory links
+    ListToken string
+    // Sort - order of the listing, toggled by the column header links
+    Sort pkg.SortOrder
+}
+
+// reloadOnHangup - reads the htpasswd file again whenever the process receives SIGHUP
+func reloadOnHangup() {
+    hangup := make(chan os.Signal, 1)
+    signal.Notify(hangup, syscall.SIGHUP)
+    go func() {
+        for range hangup {
+            if err := auth.ReloadHtpasswd(); err != nil {
+                logger.Logger.Errorf("Error reloading htpasswd file, keeping the previous entries: %v", err)
+                continue
+            }
+            logger.Logger.Infof("Reloaded htpasswd file on SIGHUP")
         }
-        logger.Logger.Fatal(http.ListenAndServeTLS(addr, config.WebServer.SSLCert, config.WebServer.SSLKey, nil))
-    } else {
-        logger.Logger.Fatal(http.ListenAndServe(addr, nil))
+    }()
+}
+
+// runSelfTest - runs the startup checks, logging each result; with abort_on_failure a
+// failed check stops the server
+func runSelfTest() {
+    writable := !share.WriteDenied("/", share.OpUpload, false)
+    files, _ := os.ReadDir(baseDir)
+    pages := []selftest.Page{
+        {Name: "index.html", Data: listingData{
+            Path:     "/",
+            FullPath: baseDir,
+            Files:    files,
+            Groups:   pkg.GroupFiles(files, config.WebServer.GroupBy),
+            ModTimes: make(map[string]time.Time),
+        }},
+        {Name: "login.html"},
+    }
+    checks := selftest.Run(baseDir, writable, pkg.Templates, pages)
+    for _, check := range checks {
+        if check.Err != nil {
+            logger.Logger.Errorf("Self-test: %s failed: %v", check.Name, check.Err)
+        } else {
+            logger.Logger.Infof("Self-test: %s passed", check.Name)
+        }
+    }
+    if !writable {
+        logger.Logger.Infof("Self-test: write to base directory skipped, uploads are denied")
+    }
+    failed := selftest.Failed(checks)
+    if len(failed) > 0 && config.SelfTest.AbortOnFailure {
+        logger.Logger.Fatalf("Self-test failed: %d of %d checks", len(failed), len(checks))
+    }
+}
+
+// limitURLLength - rejects requests whose path, as sent by the client, is longer than
+// max bytes with 414 URI Too Long before routing
+func limitURLLength(max int, next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if length := len(r.URL.EscapedPath()); length > max {
+            http.Error(w, fmt.Sprintf("URI too long: paths are limited to %d bytes", max), http.StatusRequestURITooLong)
+            logger.WithRequest(r).Warnf("Request path of %d bytes rejected from IP: %s", length, r.RemoteAddr)
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// collapseSlashes - normalizes request paths containing repeated slashes before routing.
+// GET and HEAD requests are redirected once to the canonical path (with a trailing slash
+// for directories, so that fileHandler does not redirect again); other methods are
+// rewritten in place to keep their body.
+func collapseSlashes(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        canonical := pkg.CollapseSlashes(r.URL.Path)
+        if canonical == r.URL.Path {
+            next.ServeHTTP(w, r)
+            return
+        }
+        if r.Method != "GET" && r.Method != "HEAD" {
+            r.URL.Path = canonical
+            r.URL.RawPath = ""
+            next.ServeHTTP(w, r)
+            return
+        }
+        if !strings.HasSuffix(canonical, "/") {
+            if info, err := os.Stat(filepath.Join(baseDir, canonical)); err == nil && info.IsDir() {
+                canonical += "/"
+            }
+        }
+        target := &url.URL{Path: canonical, RawQuery: r.URL.RawQuery}
+        http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+    })
+}
+
+// denyStatus - status of the responses to denied paths set by deny_response, 0 for
+// the status of each check
+var denyStatus int
+
+// denyPath - refuses a request for a path rejected by the traversal guard or an
+// access rule with the status of the check, or with the configured deny_response.
+// A 404 answer is the same as for a missing path, revealing nothing about it.
+func denyPath(w http.ResponseWriter, r *http.Request, status int, message string) {
+    switch denyStatus {
+    case http.StatusNotFound:
+        http.NotFound(w, r)
+        return
+    case http.StatusForbidden:
+        status = http.StatusForbidden
+    }
+    http.Error(w, message, status)
+}
+
+// authorizeShare - applies the share policies to the paths of a request. When access
+// is refused the response is written and false is returned.
+func authorizeShare(w http.ResponseWriter, r *http.Request, user string, paths ...string) bool {
+    for _, p := range paths {
+        switch share.Authorize(p, user) {
+        case share.Login:
+            if pkg.WantsJSON(r) {
+                http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            } else {
+                http.Redirect(w, r, "/login", http.StatusSeeOther)
+            }
+            return false
+        case share.Deny:
+            denyPath(w, r, http.StatusForbidden, "Forbidden")
+            logger.WithRequest(r).Warnf("Share access denied to %s for IP: %s, User: %s", p, r.RemoteAddr, logger.User(user))
+            return false
+        }
+    }
+    return true
+}
+
+// authorizeWrite - refuses with 403 an operation that a share denies in the slash
+// separated path or, for symlinked destinations, in the resolved full path. With subtree
+// the shares below the path count too.
+func authorizeWrite(w http.ResponseWriter, r *http.Request, user, op string, subtree bool, rel, fullPath string) bool {
+    resolved := rel
+    if fromBase, err := filepath.Rel(baseDir, fullPath); err == nil {
+        resolved = "/" + filepath.ToSlash(fromBase)
+    }
+    for _, p := range []string{rel, resolved} {
+        if share.WriteDenied(p, op, subtree) {
+            denyPath(w, r, http.StatusForbidden, "Forbidden: "+op+" is not allowed for "+p)
+            logger.WithRequest(r).Warnf("Write (%s) denied to %s for IP: %s, User: %s", op, p, r.RemoteAddr, logger.User(user))
+            return false
+        }
+    }
+    return true
+}
+
+// ftpVisible - reports whether the FTP user may see the path: sidecar files are hidden
+// and shares apply as they do on the web
+func ftpVisible(p, user string) bool {
+    switch path.Base(p) {
+    case descriptions.FileName, dirtemplate.FileName, prebuiltIndexName, tags.FileName:
+        return false
+    }
+    return share.Authorize(p, user) == share.Allow
+}
+
+// formPath - returns a path form value with repeated slashes collapsed, so that
+// "//a//b/" resolves like "/a/b/" and is never redirected to as a protocol-relative URL
+func formPath(r *http.Request, name string) string {
+    return pkg.CollapseSlashes(r.FormValue(name))
+}
+
+// formPaths - returns all values of a path form field with repeated slashes collapsed;
+// the form must already be parsed
+func formPaths(r *http.Request, name string) []string {
+    values := make([]string, 0, len(r.Form[name]))
+    for _, value := range r.Form[name] {
+        values = append(values, pkg.CollapseSlashes(value))
+    }
+    return values
+}
+
+// resolvePath - resolves a user-supplied slash separated path below the base directory.
+// Traversal attempts, including symlinks leading outside of it unless the symlink
+// policy is follow, are logged and answered with 400 Bad Request.
+func resolvePath(w http.ResponseWriter, r *http.Request, p string) (string, bool) {
+    fullPath, err := pkg.ResolvePath(baseDir, p)
+    if errors.Is(err, pkg.ErrSymlinkEscape) && config.WebServer.SymlinkPolicy == pkg.SymlinkFollow {
+        return fullPath, true
+    }
+    if err != nil {
+        denyPath(w, r, http.StatusBadRequest, "Invalid path")
+        logger.WithRequest(r).Warnf("Path traversal attempt: %q: %v from IP: %s, User: %s", p, err, r.RemoteAddr, logger.User(auth.SessionUsername(r)))
+        return "", false
+    }
+    return fullPath, true
+}
+
+// resolvePaths - resolves every path with resolvePath and returns them cleaned
+func resolvePaths(w http.ResponseWriter, r *http.Request, paths []string) ([]string, bool) {
+    cleaned := make([]string, 0, len(paths))
+    for _, p := range paths {
+        if _, ok := resolvePath(w, r, p); !ok {
+            return nil, false
+        }
+        cleaned = append(cleaned, path.Clean("/"+p))
+    }
+    return cleaned, true
+}
+
+// safeDestination - resolves the slash separated destination of a write under the
+// symlink policy. When it is rejected the response is written and false is returned.
+func safeDestination(w http.ResponseWriter, r *http.Request, user, rel string) (string, bool) {
+    fullPath, err := pkg.SafeDestination(baseDir, rel, config.WebServer.SymlinkPolicy)
+    if err != nil {
+        if errors.Is(err, pkg.ErrSymlinkEscape) || errors.Is(err, pkg.ErrSymlinkDenied) {
+            denyPath(w, r, http.StatusForbidden, "Forbidden: "+err.Error())
+            logger.WithRequest(r).Warnf("Symlink destination rejected: %s (%v) from IP: %s, User: %s", rel, err, r.RemoteAddr, logger.User(user))
+        } else {
+            http.Error(w, "Error resolving destination", http.StatusInternalServerError)
+            logger.Logger.Errorf("Error resolving destination %s: %v from IP: %s, User: %s", rel, err, r.RemoteAddr, logger.User(user))
+        }
+        return "", false
     }
+    return fullPath, true
 }
 
 func fileHandler(w http.ResponseWriter, r *http.Request) {
     clientIP := r.RemoteAddr
     reqPath := r.URL.Path
-    fullPath := filepath.Join(baseDir, reqPath)
-    info, err := os.Stat(fullPath)
+
+    // Aliases such as /latest/ are served from their target; shares apply to the target
+    if rewritten, ok, err := rewrite.Apply(rewriteRules, baseDir, reqPath); ok {
+        if err != nil {
+            http.NotFound(w, r)
+            logger.Logger.Warnf("Error resolving rewrite of %s: %v from IP: %s", reqPath, err, clientIP)
+            return
+        }
+        logger.Logger.Debugf("Rewrote %s to %s", reqPath, rewritten)
+        reqPath = rewritten
+    }
+    if !authorizeShare(w, r, auth.SessionUsername(r), reqPath) {
+        return
+    }
+    fullPath, ok := resolvePath(w, r, reqPath)
+    if !ok {
+        return
+    }
+    info, err := pkg.Stat(fullPath)
     if err != nil {
         http.NotFound(w, r)
         logger.Logger.Printf("Path not found: %s from IP: %s", fullPath, clientIP)
@@ -187,242 +949,703 @@ func fileHandler(w http.ResponseWriter, r *http.Request) {
     }
 
     if info.IsDir() {
-        if !strings.HasSuffix(reqPath, "/") {
-            http.Redirect(w, r, reqPath+"/", http.StatusMovedPermanently)
+        // Directories of a share with a list_token are listed to anonymous visitors only with the token
+        listToken := r.URL.Query().Get("token")
+        if !isLoggedIn && !share.ListTokenValid(reqPath, listToken) {
+            denyPath(w, r, http.StatusForbidden, "Forbidden: a valid token is required to list this directory")
+            if listToken != "" {
+                logger.WithRequest(r).Warnf("Invalid listing token for %s from IP: %s", reqPath, clientIP)
+            }
             return
         }
 
-        files, err := os.ReadDir(fullPath)
-        if err != nil {
-            http.Error(w, "Error reading directory", http.StatusInternalServerError)
-            logger.Logger.Warnf("Error reading directory: %v from IP: %s", err, clientIP)
+        // Only the rendered README, e.g. for loading it with AJAX
+        if r.URL.Query().Get("readme") == "1" {
+            readme, ok := renderReadme(fullPath, reqPath)
+            if !ok {
+                http.NotFound(w, r)
+                return
+            }
+            w.Header().Set("Content-Type", "text/html; charset=utf-8")
+            io.WriteString(w, string(readme))
             return
         }
 
-        var parentDir string
-        if reqPath != "/" {
-            parentDir = path.Clean("/" + path.Join(reqPath, ".."))
+        if !strings.HasSuffix(reqPath, "/") {
+            // JSON clients get the listing directly instead of a redirect
+            if !pkg.WantsJSON(r) && !r.URL.Query().Has("prefix") {
+                target := r.URL.Path + "/"
+                if r.URL.RawQuery != "" {
+                    // Keep e.g. the listing token
+                    target += "?" + r.URL.RawQuery
+                }
+                http.Redirect(w, r, target, http.StatusMovedPermanently)
+                return
+            }
+            reqPath += "/"
         }
 
-        // Initialize the data struct with an additional field for ReadmeHTML
-        data := struct {
-            Path       string
-            FullPath   string
-            Files      []os.DirEntry
-            ParentDir  string
-            ModTimes   map[string]time.Time
-            IsLoggedIn bool
-            ReadmeHTML template.HTML // New field
-        }{
-            Path:       reqPath,
-            FullPath:   fullPath,
-            Files:      files,
-            ParentDir:  parentDir,
-            ModTimes:   make(map[string]time.Time),
-            IsLoggedIn: isLoggedIn,
-            ReadmeHTML: "", // Initialize to empty
+        // A pre-generated index replaces the live listing of plain JSON requests
+        if config.WebServer.PrebuiltIndex && pkg.WantsJSON(r) && !hasListingOptions(r.URL.Query()) {
+            if servePrebuiltIndex(w, r, fullPath) {
+                return
+            }
         }
 
-        for _, file := range files {
+        files, err := pkg.ReadDir(fullPath)
+        if err != nil {
+            if os.IsPermission(err) {
+                denyPath(w, r, http.StatusForbidden, "Permission denied: the server cannot read this directory")
+                logger.WithRequest(r).Warnf("Permission denied reading directory: %s from IP: %s, User: %s", fullPath, clientIP, logger.User(auth.SessionUsername(r)))
+                return
+            }
+            http.Error(w, "Error reading directory", http.StatusInternalServerError)
+            logger.Logger.Warnf("Error reading directory: %v from IP: %s", err, clientIP)
+            return
+        }
+
+        if config.WebServer.PrebuiltIndex {
+            files = pkg.ExcludeName(files, prebuiltIndexName)
+        }
+
+        // Entries matching web-server.listing_exclude are hidden, not denied
+        files = listingExcludes.Filter(files, reqPath)
+
+        // The tags index is metadata, not an entry of the directory
+        if config.Tags.Enabled {
+            files = pkg.ExcludeName(files, tags.FileName)
+        }
+
+        // The descriptions sidecar is metadata, not an entry of the directory
+        var descs map[string]string
+        if config.WebServer.Descriptions {
+            files = pkg.ExcludeName(files, descriptions.FileName)
+            descs, err = descriptions.Load(fullPath)
+            if err != nil {
+                logger.Logger.Warnf("Error loading descriptions in %s: %v", fullPath, err)
+            }
+        }
+
+        // Sidecar metadata files are attached to their entries instead of being listed
+        var meta map[string]map[string]string
+        if config.WebServer.MetadataSuffix != "" {
+            meta, files = metadata.Load(fullPath, files, config.WebServer.MetadataSuffix, logger.Logger.Warnf)
+        }
+
+        // Filter entries by extension, e.g. ?ext=pdf,txt (directories are kept unless dirs=0)
+        if exts := pkg.ParseExtensions(r.URL.Query().Get("ext")); len(exts) > 0 {
+            files = pkg.FilterByExtension(files, exts, r.URL.Query().Get("dirs") != "0")
+        }
+
+        // Filter entries by name pattern, e.g. ?glob=*.log
+        if pattern := r.URL.Query().Get("glob"); pattern != "" {
+            if err := pkg.ValidGlob(pattern); err != nil {
+                http.Error(w, "Invalid glob pattern", http.StatusBadRequest)
+                return
+            }
+            files = pkg.FilterByGlob(files, pattern)
+        }
+
+        // Filter entries by tag, e.g. ?tag=invoice
+        if tag := listingTag(r); tag != "" {
+            files = filterByTag(files, reqPath, tag)
+        }
+
+        // Sort entries by ?sort=name|size|modtime&order=asc|desc, remembered in a cookie
+        sortOrder := listingSort(w, r)
+        pkg.SortFiles(files, sortOrder)
+
+        // Autocomplete entries by name prefix, e.g. ?prefix=doc&ignore_case=1
+        if query := r.URL.Query(); query.Has("prefix") {
+            files = pkg.FilterByPrefix(files, query.Get("prefix"), query.Get("ignore_case") == "1", config.WebServer.AutocompleteLimit)
+            pkg.RenderJSON(w, http.StatusOK, listingResponse(reqPath, decorateEntries(r, reqPath, listingEntries(files, descs, meta, nil, nil))))
+            return
+        }
+
+        if pkg.WantsJSON(r) {
+            entries := decorateEntries(r, reqPath, listingEntries(files, descs, meta, listingDirSizes(fullPath, files), listingChildCounts(fullPath, reqPath, files)))
+            pagination := pkg.ParsePagination(r.URL.Query())
+            if pagination != nil {
+                entries = pagination.Paginate(entries)
+            }
+            response := listingResponse(reqPath, entries)
+            response.Pagination = pagination
+            response.Groups = pkg.GroupEntries(entries, config.WebServer.GroupBy)
+            pkg.RenderJSON(w, http.StatusOK, response)
+            return
+        }
+
+        var parentDir string
+        if reqPath != "/" {
+            parentDir = path.Clean("/" + path.Join(reqPath, ".."))
+        }
+
+        // Initialize the data struct with an additional field for ReadmeHTML
+        data := listingData{
+            Path:          reqPath,
+            FullPath:      fullPath,
+            Files:         files,
+            Groups:        pkg.GroupFiles(files, config.WebServer.GroupBy),
+            ParentDir:     parentDir,
+            ModTimes:      make(map[string]time.Time),
+            IsLoggedIn:    isLoggedIn,
+            ReadmeHTML:    "", // Initialize to empty
+            ThemeColor:    config.PWA.ThemeColor,
+            ServiceWorker: config.PWA.ServiceWorker,
+
+            ShowDescriptions: config.WebServer.Descriptions,
+            Descriptions:     descs,
+            Metadata:         meta,
+            DirSizes:         listingDirSizes(fullPath, files),
+            ChildCounts:      listingChildCounts(fullPath, reqPath, files),
+            Tags:             listingTags(reqPath, files),
+            LinkBase:         strings.TrimSuffix(fileURL(r, "/"), "/"),
+            ListToken:        listToken,
+            Sort:             sortOrder,
+        }
+        if user := auth.SessionUsername(r); user != "" {
+            data.Favorites = favorites.List(user)
+            data.IsFavorite = favorites.IsFavorite(user, reqPath)
+        }
+
+        for _, file := range files {
             fileInfo, err := file.Info()
             if err == nil {
                 data.ModTimes[file.Name()] = fileInfo.ModTime()
             }
         }
 
-        // Check if readme.md exists in the directory
-        readmePath := filepath.Join(fullPath, "README.md")
-        if _, err := os.Stat(readmePath); err == nil {
-            // readme.md exists, read and convert it
-            content, err := os.ReadFile(readmePath)
-            if err == nil {
+        data.ReadmeHTML, _ = renderReadme(fullPath, reqPath)
+
+        // A directory may provide its own listing template
+        if config.WebServer.DirectoryTemplates {
+            tmpl, err := dirtemplate.Lookup(fullPath)
+            if err != nil {
+                logger.Logger.Warnf("Error loading listing template in %s: %v", fullPath, err)
+            } else if tmpl != nil {
                 var buf bytes.Buffer
-                if err := goldmark.Convert(content, &buf); err == nil {
-                    data.ReadmeHTML = template.HTML(buf.String())
-                } else {
-                    logger.Logger.Warnf("Error converting Markdown to HTML: %v", err)
+                if err := tmpl.Execute(&buf, data); err == nil {
+                    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+                    buf.WriteTo(w)
+                    return
                 }
-            } else {
-                logger.Logger.Warnf("Error reading readme.md: %v", err)
+                logger.Logger.Warnf("Error rendering listing template in %s: %v", fullPath, err)
             }
         }
 
         pkg.RenderTemplate(w, "index.html", data)
     } else {
-        logger.Logger.Infof("File served: %s to IP: %s", fullPath, clientIP)
-        http.ServeFile(w, r, fullPath)
+        if r.URL.Query().Get("preview") == "1" && preview.IsTabular(fullPath) {
+            tablePreview(w, r, reqPath, fullPath, info, isLoggedIn)
+            return
+        }
+        if r.URL.Query().Get("render") == "1" && isMarkdown(fullPath) {
+            markdownPreview(w, r, reqPath, fullPath, info, isLoggedIn)
+            return
+        }
+        // Audio and video play in the browser; ServeContent answers the range requests of players
+        if contentType, ok := pkg.MediaType(fullPath); ok {
+            w.Header().Set("Content-Type", contentType)
+            w.Header().Set("Content-Disposition", pkg.ContentDisposition("inline", info.Name()))
+        }
+        // Markdown and CSV files advertise their rendered preview for clients to discover
+        if alternate := previewURL(reqPath, fullPath, info); alternate != "" {
+            w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="alternate"; type="text/html"`, alternate))
+        }
+        logger.WithRequest(r).Infof("File served: %s to IP: %s", fullPath, clientIP)
+        serveFile(w, r, fullPath)
     }
 }
 
-// downloadHandler - handler for file download requests
-func downloadHandler(w http.ResponseWriter, r *http.Request) {
-    clientIP := r.RemoteAddr
-    r.ParseForm()
-    items := r.Form["items"]
-    if len(items) == 0 {
-        http.Error(w, "No files selected for download", http.StatusBadRequest)
-        return
+// previewURL - returns the URL of the rendered HTML preview of the file, or "" when
+// its type has none or it exceeds preview.max_size
+func previewURL(reqPath, fullPath string, info os.FileInfo) string {
+    if info.Size() > int64(config.Preview.MaxSize)<<20 {
+        return ""
+    }
+    switch {
+    case preview.IsTabular(fullPath):
+        return pkg.EscapePath(reqPath) + "?preview=1"
+    case isMarkdown(fullPath):
+        return pkg.EscapePath(reqPath) + "?render=1"
     }
+    return ""
+}
 
-    var files []string
-    for _, item := range items {
-        fullPath := filepath.Join(baseDir, item)
-        info, err := os.Stat(fullPath)
-        if err != nil {
-            logger.Logger.Errorf("error accessing item: %v from IP: %s", err, clientIP)
+// sortCookieName - cookie remembering the sort order chosen for listings
+const sortCookieName = "sfs_sort"
+
+// listingSort - returns the sort order requested by the sort and order query
+// parameters, storing it in a cookie so that it applies to the following listings.
+// Without parameters the cookie, then the default order, is used.
+func listingSort(w http.ResponseWriter, r *http.Request) pkg.SortOrder {
+    query := r.URL.Query()
+    if query.Has("sort") {
+        if order, ok := pkg.ParseSort(query.Get("sort"), query.Get("order")); ok {
+            http.SetCookie(w, &http.Cookie{
+                Name:     sortCookieName,
+                Value:    order.String(),
+                Path:     "/",
+                MaxAge:   365 * 24 * 60 * 60,
+                HttpOnly: true,
+                SameSite: http.SameSiteLaxMode,
+            })
+            return order
+        }
+    }
+    if cookie, err := r.Cookie(sortCookieName); err == nil {
+        if order, ok := pkg.ParseSortValue(cookie.Value); ok {
+            return order
+        }
+    }
+    return pkg.DefaultSort
+}
+
+// expirySkip - returns the entries the expiry sweep must keep: sidecar and configuration
+// files, directories pinned as favorites and the configured exclusions
+func expirySkip() func(rel string, entry fs.DirEntry) bool {
+    keep := make(map[string]bool)
+    for _, name := range []string{flag.Lookup("config").Value.String(), config.Logging.LogFile, config.Logging.AccessLogFile,
+        config.Favorites.File, config.Recent.File, config.Tags.File, config.WebServer.SSLCert, config.WebServer.SSLKey} {
+        if name == "" {
             continue
         }
-        if !info.IsDir() {
-            files = append(files, item)
+        if abs, err := filepath.Abs(name); err == nil {
+            keep[abs] = true
+        }
+    }
+    absBase, _ := filepath.Abs(baseDir)
+    return func(rel string, entry fs.DirEntry) bool {
+        switch entry.Name() {
+        case descriptions.FileName, dirtemplate.FileName, prebuiltIndexName, tags.FileName:
+            return true
+        }
+        if keep[filepath.Join(absBase, filepath.FromSlash(rel))] {
+            return true
         }
+        if entry.IsDir() && favorites.Pinned(rel+"/") {
+            return true
+        }
+        return expiry.Excluded(rel, config.Expiry.Exclude)
     }
+}
 
-    if len(files) == 0 {
-        http.Error(w, "No files selected for download", http.StatusBadRequest)
+// renderReadme - converts the README.md of the directory to HTML, unless readme_depth
+// excludes the directory. Raw HTML in the Markdown is not rendered, so the result is
+// safe to embed. It reports whether a README was rendered.
+func renderReadme(fullPath, reqPath string) (template.HTML, bool) {
+    if readmeDepth >= 0 && pkg.PathDepth(reqPath) > readmeDepth {
+        return "", false
+    }
+    readmePath := filepath.Join(fullPath, "README.md")
+    if _, err := pkg.Stat(readmePath); err != nil {
+        return "", false
+    }
+    content, err := preview.ReadText(readmePath, int64(config.Preview.MaxSize)<<20)
+    if err != nil {
+        logger.Logger.Warnf("Error reading readme.md: %v", err)
+        return "", false
+    }
+    html, err := renderMarkdown([]byte(content))
+    if err != nil {
+        logger.Logger.Warnf("Error converting Markdown to HTML: %v", err)
+        return "", false
+    }
+    return html, true
+}
+
+// renderMarkdown - converts Markdown to HTML; raw HTML in the source is omitted
+func renderMarkdown(content []byte) (template.HTML, error) {
+    var buf bytes.Buffer
+    if err := goldmark.Convert(content, &buf); err != nil {
+        return "", err
+    }
+    return template.HTML(buf.String()), nil
+}
+
+// isMarkdown - reports whether the file has a Markdown extension
+func isMarkdown(name string) bool {
+    switch strings.ToLower(filepath.Ext(name)) {
+    case ".md", ".markdown":
+        return true
+    }
+    return false
+}
+
+// markdownPreview - renders a Markdown file as an HTML page (?render=1); the file
+// itself is still downloaded raw without the parameter
+func markdownPreview(w http.ResponseWriter, r *http.Request, reqPath, fullPath string, info os.FileInfo, isLoggedIn bool) {
+    content, err := preview.ReadText(fullPath, int64(config.Preview.MaxSize)<<20)
+    switch {
+    case errors.Is(err, preview.ErrTooLarge):
+        http.Error(w, "File is too large to render", http.StatusRequestEntityTooLarge)
+        return
+    case errors.Is(err, preview.ErrNotText):
+        http.Error(w, "File is not UTF-8 text", http.StatusUnsupportedMediaType)
+        return
+    case err != nil:
+        http.Error(w, "Error reading file", http.StatusInternalServerError)
+        logger.Logger.Warnf("Error reading %s for rendering: %v", fullPath, err)
+        return
+    }
+    html, err := renderMarkdown([]byte(content))
+    if err != nil {
+        http.Error(w, "Error rendering file", http.StatusInternalServerError)
+        logger.Logger.Warnf("Error converting %s to HTML: %v", fullPath, err)
         return
     }
 
-    if len(files) == 1 {
-        fullPath := filepath.Join(baseDir, files[0])
-        logger.Logger.Infof("File downloaded: %s by IP: %s", fullPath, clientIP)
-        http.ServeFile(w, r, fullPath)
-    } else {
-        w.Header().Set("Content-Type", "application/zip")
-        w.Header().Set("Content-Disposition", "attachment; filename=\"files.zip\"")
-        zipWriter := zip.NewWriter(w)
-        defer zipWriter.Close()
+    parentDir := path.Dir(reqPath)
+    if parentDir != "/" {
+        parentDir += "/"
+    }
+    data := struct {
+        Path       string
+        ParentDir  string
+        Name       string
+        HTML       template.HTML
+        IsLoggedIn bool
+    }{
+        Path:       reqPath,
+        ParentDir:  parentDir,
+        Name:       info.Name(),
+        HTML:       html,
+        IsLoggedIn: isLoggedIn,
+    }
+    logger.WithRequest(r).Infof("File rendered: %s to IP: %s", fullPath, r.RemoteAddr)
+    pkg.RenderTemplate(w, "markdown.html", data)
+}
 
-        for _, file := range files {
-            fullPath := filepath.Join(baseDir, file)
-            err := addFileToZip(zipWriter, fullPath, file)
-            if err != nil {
-                logger.Logger.Errorf("error adding file to ZIP: %v", err)
-            }
+// prebuiltIndexName - pre-generated JSON listing of a directory, served when prebuilt_index is enabled
+const prebuiltIndexName = ".index.json"
+
+// listingOptions - query parameters filtering, sorting or paginating a listing
+var listingOptions = []string{"ext", "glob", "tag", "prefix", "sort", "order", "page", "perPage"}
+
+// hasListingOptions - reports whether the query changes the listing, which a
+// pre-generated index cannot reflect
+func hasListingOptions(query url.Values) bool {
+    for _, option := range listingOptions {
+        if query.Has(option) {
+            return true
         }
     }
+    return false
 }
 
-// addFileToZip - function for adding a file to a ZIP archive
-func addFileToZip(zipWriter *zip.Writer, filepath string, relPath string) error {
-    fileToZip, err := os.Open(filepath)
+// servePrebuiltIndex - serves the .index.json of the directory as its JSON listing.
+// It returns false, to fall back to the live listing, when there is no index.
+func servePrebuiltIndex(w http.ResponseWriter, r *http.Request, dir string) bool {
+    indexPath := filepath.Join(dir, prebuiltIndexName)
+    file, err := pkg.Open(indexPath)
     if err != nil {
-        return err
+        if !os.IsNotExist(err) {
+            logger.Logger.Warnf("Error opening prebuilt index %s: %v", indexPath, err)
+        }
+        return false
     }
-    defer fileToZip.Close()
+    defer file.Close()
+    info, err := file.Stat()
+    if err != nil || !info.Mode().IsRegular() {
+        return false
+    }
+    w.Header().Set("Content-Type", "application/json")
+    http.ServeContent(w, r, prebuiltIndexName, info.ModTime(), file)
+    return true
+}
 
-    info, err := fileToZip.Stat()
-    if err != nil {
-        return err
+// listingResponse - returns the JSON listing envelope with the server time and the
+// suggested refresh interval for polling clients
+func listingResponse(reqPath string, entries []pkg.ListingEntry) pkg.ListingResponse {
+    return pkg.ListingResponse{
+        Path:            reqPath,
+        Entries:         entries,
+        ServerTime:      time.Now().UTC().Truncate(time.Second),
+        RefreshInterval: int(config.WebServer.RefreshInterval / time.Second),
+    }
+}
+
+// listingEntries - converts directory entries into listing entries with their descriptions and metadata
+func listingEntries(files []os.DirEntry, descs map[string]string, meta map[string]map[string]string, sizes map[string]int64, counts map[string]childcount.Count) []pkg.ListingEntry {
+    entries := pkg.NewListingEntries(files)
+    for i := range entries {
+        entries[i].Description = descs[entries[i].Name]
+        entries[i].Metadata = meta[entries[i].Name]
+        if size, ok := sizes[entries[i].Name]; ok {
+            entries[i].RecursiveSize = &size
+        }
+        if count, ok := counts[entries[i].Name]; ok {
+            entries[i].ChildCount = &count.N
+            entries[i].ChildCountMore = count.More
+        }
     }
+    return entries
+}
 
-    if info.IsDir() {
-        // Skip directories
+// listingTag - returns the ?tag= filter, empty when tags are disabled
+func listingTag(r *http.Request) string {
+    if !config.Tags.Enabled {
+        return ""
+    }
+    return strings.TrimSpace(r.URL.Query().Get("tag"))
+}
+
+// filterByTag - keeps the entries of the directory dirPath carrying the tag
+func filterByTag(files []os.DirEntry, dirPath, tag string) []os.DirEntry {
+    filtered := make([]os.DirEntry, 0, len(files))
+    for _, file := range files {
+        if tags.Has(path.Join(dirPath, file.Name()), tag) {
+            filtered = append(filtered, file)
+        }
+    }
+    return filtered
+}
+
+// listingTags - returns the tags of the entries of the directory dirPath, keyed by name
+func listingTags(dirPath string, files []os.DirEntry) map[string][]string {
+    if !config.Tags.Enabled {
         return nil
     }
+    result := make(map[string][]string)
+    for _, file := range files {
+        if t := tags.Get(path.Join(dirPath, file.Name())); len(t) > 0 {
+            result[file.Name()] = t
+        }
+    }
+    return result
+}
 
-    header, err := zip.FileInfoHeader(info)
-    if err != nil {
-        return err
+// decorateEntries - fills in the absolute URLs and the tags of listing entries of the directory dirPath
+func decorateEntries(r *http.Request, dirPath string, entries []pkg.ListingEntry) []pkg.ListingEntry {
+    for i := range entries {
+        entryPath := path.Join(dirPath, entries[i].Name)
+        if entries[i].IsDir {
+            entries[i].URL = fileURL(r, entryPath+"/")
+        } else {
+            entries[i].URL = fileURL(r, entryPath)
+        }
+        if config.Tags.Enabled {
+            entries[i].Tags = tags.Get(entryPath)
+        }
     }
-    header.Name = relPath
-    header.Method = zip.Deflate
+    return entries
+}
 
-    writer, err := zipWriter.CreateHeader(header)
-    if err != nil {
-        return err
+// listingDirSizes - returns the known recursive sizes of the directories among files,
+// keyed by name, when dir_size.in_listing is set. Unknown sizes are computed in the
+// background for a later listing.
+func listingDirSizes(fullPath string, files []os.DirEntry) map[string]int64 {
+    if !config.DirSize.Enabled || !config.DirSize.InListing {
+        return nil
+    }
+    sizes := make(map[string]int64)
+    for _, file := range files {
+        if !file.IsDir() {
+            continue
+        }
+        if result, status := dirsize.Lookup(filepath.Join(fullPath, file.Name())); status != dirsize.StatusPending {
+            sizes[file.Name()] = result.Size
+        }
     }
+    return sizes
+}
 
-    _, err = io.Copy(writer, fileToZip)
-    return err
+// listingChildCounts - returns the number of entries of the directories among files,
+// keyed by name, when child_count is enabled. Sidecar files and entries hidden by
+// listing_exclude are not counted.
+func listingChildCounts(fullPath, dirPath string, files []os.DirEntry) map[string]childcount.Count {
+    if !config.ChildCount.Enabled {
+        return nil
+    }
+    counts := make(map[string]childcount.Count)
+    for _, file := range files {
+        if !file.IsDir() {
+            continue
+        }
+        sub := path.Join(dirPath, file.Name())
+        count, err := childcount.Get(filepath.Join(fullPath, file.Name()), func(entry os.DirEntry) bool {
+            switch entry.Name() {
+            case descriptions.FileName, dirtemplate.FileName, prebuiltIndexName, tags.FileName:
+                return true
+            }
+            if config.WebServer.MetadataSuffix != "" && strings.HasSuffix(entry.Name(), config.WebServer.MetadataSuffix) {
+                return true
+            }
+            return listingExcludes.Match(path.Join(sub, entry.Name()), entry.IsDir())
+        })
+        if err != nil {
+            logger.Logger.Debugf("Error counting entries of %s: %v", sub, err)
+            continue
+        }
+        counts[file.Name()] = count
+    }
+    return counts
 }
 
-// uploadHandler - handler for file upload requests
-func uploadHandler(w http.ResponseWriter, r *http.Request) {
-    clientIP := r.RemoteAddr
-    user := r.Header.Get("X-User")
-    if r.Method != "POST" {
+// manifestHandler - serves the web app manifest used to install the file manager as a PWA
+func manifestHandler(w http.ResponseWriter, r *http.Request) {
+    manifest := struct {
+        pkg.PWA
+        StartURL string `json:"start_url"`
+        Scope    string `json:"scope"`
+        Display  string `json:"display"`
+    }{
+        PWA:      config.PWA,
+        StartURL: "/",
+        Scope:    "/",
+        Display:  "standalone",
+    }
+    w.Header().Set("Cache-Control", "public, max-age=3600")
+    w.Header().Set("Content-Type", "application/manifest+json")
+    if err := json.NewEncoder(w).Encode(manifest); err != nil {
+        logger.Logger.Errorf("Error encoding manifest: %v", err)
+    }
+}
+
+// serviceWorkerHandler - serves the service worker from the root so that its scope covers the whole site
+func serviceWorkerHandler(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/javascript")
+    w.Header().Set("Cache-Control", "no-cache")
+    http.ServeFile(w, r, "static/js/sw.js")
+}
+
+// eventsHandler - streams the file activity to a logged-in user as server-sent events,
+// leaving out paths the user may not access. The stream ends when the client
+// disconnects or the session ends.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != "GET" {
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
         return
     }
-
-    err := r.ParseMultipartForm(100 << 20) // 100 MB
-    if err != nil {
-        http.Error(w, "Error parsing form", http.StatusBadRequest)
+    user := auth.SessionUsername(r)
+    if user == "" {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
         return
     }
-
-    reqPath := r.FormValue("currentPath")
-    fullDestPath := filepath.Join(baseDir, reqPath)
-
-    err = os.MkdirAll(fullDestPath, os.ModePerm)
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+        return
+    }
+    stream, unsubscribe, err := events.Subscribe()
     if err != nil {
-        http.Error(w, "Error creating directory", http.StatusInternalServerError)
-        logger.Logger.Errorf("Error creating directory: %v from IP: %s, User: %s", err, clientIP, user)
+        http.Error(w, "Too many event subscribers", http.StatusServiceUnavailable)
+        logger.WithRequest(r).Warnf("Event subscriber limit reached for IP: %s, User: %s", r.RemoteAddr, logger.User(user))
         return
     }
+    defer unsubscribe()
+    logger.WithRequest(r).Infof("Event stream opened by IP: %s, User: %s", r.RemoteAddr, logger.User(user))
 
-    files := r.MultipartForm.File["uploadFiles"]
-    for _, fileHeader := range files {
-        file, err := fileHeader.Open()
-        if err != nil {
-            http.Error(w, "Error getting file", http.StatusBadRequest)
-            logger.Logger.Errorf("Error getting file: %v from IP: %s, User: %s", err, clientIP, user)
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("X-Accel-Buffering", "no")
+    w.WriteHeader(http.StatusOK)
+    flusher.Flush()
+
+    keepAlive := time.NewTicker(config.Events.KeepAlive)
+    defer keepAlive.Stop()
+    for {
+        select {
+        case <-r.Context().Done():
+            logger.WithRequest(r).Infof("Event stream closed by IP: %s, User: %s", r.RemoteAddr, logger.User(user))
             return
+        case <-keepAlive.C:
+            if auth.SessionUsername(r) != user {
+                return
+            }
+            if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+                return
+            }
+            flusher.Flush()
+        case event := <-stream:
+            if share.Authorize(event.Path, user) != share.Allow || (event.To != "" && share.Authorize(event.To, user) != share.Allow) {
+                continue
+            }
+            data, err := json.Marshal(event)
+            if err != nil {
+                continue
+            }
+            if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+                return
+            }
+            flusher.Flush()
         }
-        defer file.Close()
+    }
+}
 
-        dstPath := filepath.Join(fullDestPath, fileHeader.Filename)
-        dst, err := os.Create(dstPath)
-        if err != nil {
-            http.Error(w, "Error saving file", http.StatusInternalServerError)
-            logger.Logger.Errorf("Error saving file: %v from IP: %s, User: %s", err, clientIP, user)
-            return
+// recentHandler - shows the most recent uploads as HTML or JSON
+func recentHandler(w http.ResponseWriter, r *http.Request) {
+    n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+    // Uploads to shares the viewer cannot access are left out of the feed
+    user := auth.SessionUsername(r)
+    events := make([]recent.Event, 0)
+    for _, event := range recent.Last(0) {
+        if share.Authorize(event.Path, user) != share.Allow {
+            continue
         }
-        defer dst.Close()
+        events = append(events, event)
+        if n > 0 && len(events) == n {
+            break
+        }
+    }
 
-        _, err = io.Copy(dst, file)
-        if err != nil {
-            http.Error(w, "Error saving file", http.StatusInternalServerError)
-            logger.Logger.Errorf("Error saving file: %v from IP: %s, User: %s", err, clientIP, user)
-            return
+    if pkg.WantsJSON(r) {
+        type eventWithURL struct {
+            recent.Event
+            URL string `json:"url"`
         }
-        logger.Logger.Infof("File uploaded: %s by IP: %s, User: %s", dstPath, clientIP, user)
+        withURLs := make([]eventWithURL, 0, len(events))
+        for _, event := range events {
+            withURLs = append(withURLs, eventWithURL{Event: event, URL: fileURL(r, event.Path)})
+        }
+        pkg.RenderJSON(w, http.StatusOK, withURLs)
+        return
     }
 
-    http.Redirect(w, r, reqPath, http.StatusSeeOther)
+    isLoggedIn := false
+    if cookie, err := r.Cookie(auth.SessionCookieName); err == nil {
+        isLoggedIn = auth.IsValidSessionToken(cookie.Value)
+    }
+    data := struct {
+        Events     []recent.Event
+        IsLoggedIn bool
+    }{
+        Events:     events,
+        IsLoggedIn: isLoggedIn,
+    }
+    pkg.RenderTemplate(w, "recent.html", data)
 }
 
-// createFolderHandler - handler for creating directories
-func createFolderHandler(w http.ResponseWriter, r *http.Request) {
-    clientIP := r.RemoteAddr
-    user := r.Header.Get("X-User")
-    if r.Method != "POST" {
+// tagsHandler - returns the tags of the file or folder ?path= as JSON
+func tagsHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != "GET" {
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
         return
     }
-
-    reqPath := r.FormValue("currentPath")
-    folderName := r.FormValue("folderName")
-    if folderName == "" {
-        http.Error(w, "Folder name is required", http.StatusBadRequest)
+    reqPath := path.Clean("/" + r.URL.Query().Get("path"))
+    if !authorizeShare(w, r, auth.SessionUsername(r), reqPath) {
         return
     }
-
-    fullPath := filepath.Join(baseDir, reqPath, folderName)
-
-    err := os.Mkdir(fullPath, os.ModePerm)
-    if err != nil {
-        http.Error(w, "Error creating folder", http.StatusInternalServerError)
-        logger.Logger.Errorf("Error creating folder: %v from IP: %s, User: %s", err, clientIP, user)
+    fullPath, ok := resolvePath(w, r, r.URL.Query().Get("path"))
+    if !ok {
         return
     }
-    logger.Logger.Infof("Folder created: %s by IP: %s, User: %s", fullPath, clientIP, user)
-
-    http.Redirect(w, r, reqPath, http.StatusSeeOther)
+    if _, err := os.Lstat(fullPath); err != nil {
+        http.NotFound(w, r)
+        return
+    }
+    pkg.RenderJSON(w, http.StatusOK, struct {
+        Path string   `json:"path"`
+        Tags []string `json:"tags"`
+    }{
+        Path: reqPath,
+        Tags: tags.Get(reqPath),
+    })
 }
 
-// deleteHandler - handler for deleting files and directories
-func deleteHandler(w http.ResponseWriter, r *http.Request) {
+// tagHandler - replaces the tags of the file or folder path with the comma separated
+// tags; empty tags remove them
+func tagHandler(w http.ResponseWriter, r *http.Request) {
     clientIP := r.RemoteAddr
     user := r.Header.Get("X-User")
     if r.Method != "POST" {
@@ -430,32 +1653,2641 @@ func deleteHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    r.ParseForm()
-    items := r.Form["items"]
-    if len(items) == 0 {
-        http.Error(w, "No items selected for deletion", http.StatusBadRequest)
+    reqPath := path.Clean("/" + formPath(r, "path"))
+    if reqPath == "/" {
+        http.Error(w, "Invalid path", http.StatusBadRequest)
+        return
+    }
+    if !authorizeShare(w, r, user, reqPath) {
+        return
+    }
+    fullPath, ok := resolvePath(w, r, formPath(r, "path"))
+    if !ok {
+        return
+    }
+    if _, err := os.Lstat(fullPath); err != nil {
+        http.NotFound(w, r)
+        return
+    }
+    list, err := tags.Parse(r.FormValue("tags"))
+    if err != nil {
+        http.Error(w, "Invalid tags: "+err.Error(), http.StatusBadRequest)
         return
     }
 
-    for _, item := range items {
-        fullPath := filepath.Join(baseDir, item)
-        err := logAndRemoveAll(fullPath, clientIP, user)
-        if err != nil {
-            http.Error(w, "Error deleting item", http.StatusInternalServerError)
-            logger.Logger.Errorf("Error deleting item: %v from IP: %s, User: %s", err, clientIP, user)
-            return
-        }
-        logger.Logger.Infof("Item deleted: %s by IP: %s, User: %s", fullPath, clientIP, user)
+    if err := tags.Set(reqPath, list); err != nil {
+        http.Error(w, "Error saving tags", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error saving tags: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+        return
     }
+    logger.WithRequest(r).Infof("Tags set on %s: %s by IP: %s, User: %s", reqPath, strings.Join(list, ","), clientIP, logger.User(user))
 
-    reqPath := r.FormValue("currentPath")
+    if pkg.WantsJSON(r) {
+        pkg.RenderJSON(w, http.StatusOK, struct {
+            Path string   `json:"path"`
+            Tags []string `json:"tags"`
+        }{
+            Path: reqPath,
+            Tags: list,
+        })
+        return
+    }
+    http.Redirect(w, r, pkg.CollapseSlashes(path.Dir(reqPath)+"/"), http.StatusSeeOther)
+}
+
+// statHandler - returns the details of the file or folder ?path= as JSON, including the
+// recursive size of a folder when dir_size is enabled
+func statHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != "GET" && r.Method != "HEAD" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    reqPath := path.Clean("/" + r.URL.Query().Get("path"))
+    user := auth.SessionUsername(r)
+    if !authorizeShare(w, r, user, reqPath) {
+        return
+    }
+
+    fullPath, ok := resolvePath(w, r, r.URL.Query().Get("path"))
+    if !ok {
+        return
+    }
+    info, err := pkg.Stat(fullPath)
+    if err != nil {
+        http.NotFound(w, r)
+        return
+    }
+
+    response := struct {
+        Path    string    `json:"path"`
+        Name    string    `json:"name"`
+        IsDir   bool      `json:"isDir"`
+        Size    int64     `json:"size"`
+        ModTime time.Time `json:"modTime"`
+        // Recursive - size of the subtree of a directory, RecursiveStatus - whether it is up to date
+        Recursive       *dirsize.Result `json:"recursive,omitempty"`
+        RecursiveStatus dirsize.Status  `json:"recursiveStatus,omitempty"`
+    }{
+        Path:    reqPath,
+        Name:    info.Name(),
+        IsDir:   info.IsDir(),
+        ModTime: info.ModTime(),
+    }
+    if !info.IsDir() {
+        response.Size = info.Size()
+    } else if config.DirSize.Enabled {
+        result, status := dirsize.Lookup(fullPath)
+        response.RecursiveStatus = status
+        if status != dirsize.StatusPending {
+            response.Recursive = &result
+        }
+    }
+    pkg.RenderJSON(w, http.StatusOK, response)
+}
+
+// searchHandler - finds files and folders by name below ?path= and returns them
+// ranked by relevance and recency as JSON
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != "GET" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    query := strings.TrimSpace(r.URL.Query().Get("q"))
+    tag := listingTag(r)
+    if query == "" && tag == "" {
+        http.Error(w, "Search query is required", http.StatusBadRequest)
+        return
+    }
+    reqPath := path.Clean("/" + r.URL.Query().Get("path"))
+    user := auth.SessionUsername(r)
+    if !authorizeShare(w, r, user, reqPath) {
+        return
+    }
+
+    dir, ok := resolvePath(w, r, r.URL.Query().Get("path"))
+    if !ok {
+        return
+    }
+    if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+        http.NotFound(w, r)
+        return
+    }
+
+    // Hide sidecar files and shares the user cannot access
+    hidden := func(p, name string, isDir bool) bool {
+        if name == descriptions.FileName || name == tags.FileName {
+            return true
+        }
+        if listingExcludes.Match(p, isDir) {
+            return true
+        }
+        return share.Authorize(p, user) != share.Allow
+    }
+    var results []search.Result
+    truncated := false
+    if search.IndexReady() {
+        results = search.FindIndexed(reqPath, query, hidden)
+    } else {
+        skip := func(p string, entry fs.DirEntry) bool {
+            return hidden(p, entry.Name(), entry.IsDir())
+        }
+        var err error
+        results, truncated, err = search.Find(dir, reqPath, query, config.Search.MaxScanned, skip)
+        if err != nil {
+            http.Error(w, "Error searching files", http.StatusInternalServerError)
+            logger.Logger.Errorf("Error searching %s: %v from IP: %s", dir, err, r.RemoteAddr)
+            return
+        }
+    }
+    if config.Tags.Enabled {
+        // Narrow the results down to ?tag= and attach the tags of the rest
+        tagged := results[:0]
+        for _, result := range results {
+            if tag != "" && !tags.Has(result.Path, tag) {
+                continue
+            }
+            result.Tags = tags.Get(result.Path)
+            tagged = append(tagged, result)
+        }
+        results = tagged
+    }
+    results = search.Rank(results, config.Search.MaxResults)
+    for i := range results {
+        if results[i].IsDir {
+            results[i].URL = fileURL(r, results[i].Path+"/")
+        } else {
+            results[i].URL = fileURL(r, results[i].Path)
+        }
+    }
+
+    pkg.RenderJSON(w, http.StatusOK, struct {
+        Query     string          `json:"query"`
+        Tag       string          `json:"tag,omitempty"`
+        Path      string          `json:"path"`
+        Results   []search.Result `json:"results"`
+        Truncated bool            `json:"truncated"`
+    }{
+        Query:     query,
+        Tag:       tag,
+        Path:      reqPath,
+        Results:   results,
+        Truncated: truncated,
+    })
+}
+
+// Bounds of the contact sheet layout requested with ?columns= and ?cell=
+const (
+    maxSheetColumns = 20
+    minSheetCell    = 32
+    maxSheetCell    = 512
+)
+
+// sheetParam - reads a positive integer query parameter clamped to [lo, hi], or def when absent
+func sheetParam(query url.Values, name string, def, lo, hi int) (int, error) {
+    value := query.Get(name)
+    if value == "" {
+        return def, nil
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil {
+        return 0, err
+    }
+    return min(max(n, lo), hi), nil
+}
+
+// contactSheetHandler - returns a grid image of the thumbnails of the images in the
+// folder ?path=, with optional ?columns=, ?cell= and ?format=jpeg|png overrides
+func contactSheetHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != "GET" && r.Method != "HEAD" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    query := r.URL.Query()
+    reqPath := path.Clean("/" + query.Get("path"))
+    if !authorizeShare(w, r, auth.SessionUsername(r), reqPath) {
+        return
+    }
+    dir, ok := resolvePath(w, r, query.Get("path"))
+    if !ok {
+        return
+    }
+    if info, err := pkg.Stat(dir); err != nil || !info.IsDir() {
+        http.NotFound(w, r)
+        return
+    }
+
+    opts := contactsheet.Options{
+        MaxImages: config.ContactSheet.MaxImages,
+        Format:    config.ContactSheet.Format,
+        Quality:   config.ContactSheet.Quality,
+    }
+    var err error
+    if opts.Columns, err = sheetParam(query, "columns", config.ContactSheet.Columns, 1, maxSheetColumns); err != nil {
+        http.Error(w, "Invalid columns", http.StatusBadRequest)
+        return
+    }
+    if opts.Cell, err = sheetParam(query, "cell", config.ContactSheet.CellSize, minSheetCell, maxSheetCell); err != nil {
+        http.Error(w, "Invalid cell size", http.StatusBadRequest)
+        return
+    }
+    if format := query.Get("format"); format != "" {
+        if contactsheet.ValidFormat(format) != nil {
+            http.Error(w, "Invalid format", http.StatusBadRequest)
+            return
+        }
+        opts.Format = format
+    }
+
+    data, modTime, err := contactsheet.Render(dir, opts)
+    if errors.Is(err, contactsheet.ErrNoImages) {
+        http.Error(w, "No images in this folder", http.StatusNotFound)
+        return
+    } else if err != nil {
+        http.Error(w, "Error generating contact sheet", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error generating contact sheet of %s: %v", dir, err)
+        return
+    }
+    name := "contact-sheet.jpg"
+    if opts.Format == contactsheet.FormatPNG {
+        name = "contact-sheet.png"
+    }
+    w.Header().Set("Cache-Control", "private, max-age=300")
+    http.ServeContent(w, r, name, modTime, bytes.NewReader(data))
+}
+
+// QR code sizes in pixels
+const (
+    defaultQRSize = 256
+    minQRSize     = 64
+    maxQRSize     = 1024
+)
+
+// fileURL - returns the absolute URL of the unescaped server path, based on public_url
+// when configured (e.g. behind a reverse proxy) and on the request otherwise
+func fileURL(r *http.Request, p string) string {
+    return pkg.FileURL(r, config.WebServer.PublicURL, p)
+}
+
+// qrHandler - returns a PNG QR code of the download URL of a path (?path=) or of an
+// upload link (?token=), with an optional size in pixels (?size=)
+func qrHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != "GET" && r.Method != "HEAD" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    query := r.URL.Query()
+
+    size := defaultQRSize
+    if value := query.Get("size"); value != "" {
+        var err error
+        size, err = strconv.Atoi(value)
+        if err != nil {
+            http.Error(w, "Invalid size", http.StatusBadRequest)
+            return
+        }
+        size = min(max(size, minQRSize), maxQRSize)
+    }
+
+    var target string
+    switch {
+    case query.Get("token") != "":
+        token := query.Get("token")
+        if !config.Upload.Links.Enabled {
+            http.NotFound(w, r)
+            return
+        }
+        if _, err := uploadlink.Verify(token, time.Now()); err != nil {
+            http.Error(w, "Forbidden: invalid upload link", http.StatusForbidden)
+            return
+        }
+        target = fileURL(r, "/drop") + "?token=" + url.QueryEscape(token)
+    case query.Get("path") != "":
+        reqPath := path.Clean("/" + query.Get("path"))
+        if !authorizeShare(w, r, auth.SessionUsername(r), reqPath) {
+            return
+        }
+        fullPath, ok := resolvePath(w, r, query.Get("path"))
+        if !ok {
+            return
+        }
+        info, err := pkg.Stat(fullPath)
+        if err != nil {
+            http.NotFound(w, r)
+            return
+        }
+        if info.IsDir() && reqPath != "/" {
+            reqPath += "/"
+        }
+        target = fileURL(r, reqPath)
+    default:
+        http.Error(w, "A path or token is required", http.StatusBadRequest)
+        return
+    }
+
+    png, err := qrcode.Encode(target, qrcode.Medium, size)
+    if err != nil {
+        http.Error(w, "Error generating QR code", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error generating QR code for %s: %v", target, err)
+        return
+    }
+    w.Header().Set("Content-Type", "image/png")
+    w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+    w.Header().Set("Cache-Control", "private, max-age=300")
+    w.Write(png)
+}
+
+// diffContextLines - number of unchanged lines shown around every change
+const diffContextLines = 3
+
+// diffHandler - compares two text files (?a=&b=) and returns the unified diff as plain
+// text, or as HTML with ?format=html
+func diffHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != "GET" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    query := r.URL.Query()
+    if query.Get("a") == "" || query.Get("b") == "" {
+        http.Error(w, "Two files (a and b) are required", http.StatusBadRequest)
+        return
+    }
+    paths, ok := resolvePaths(w, r, []string{query.Get("a"), query.Get("b")})
+    if !ok {
+        return
+    }
+    pathA, pathB := paths[0], paths[1]
+    user := auth.SessionUsername(r)
+    if !authorizeShare(w, r, user, pathA, pathB) {
+        return
+    }
+
+    maxSize := int64(config.Preview.MaxSize) << 20
+    texts := make([]string, 2)
+    for i, p := range []string{pathA, pathB} {
+        fullPath, ok := resolvePath(w, r, p)
+        if !ok {
+            return
+        }
+        text, err := preview.ReadText(fullPath, maxSize)
+        if err != nil {
+            switch {
+            case os.IsNotExist(err):
+                http.Error(w, "File not found: "+p, http.StatusNotFound)
+            case errors.Is(err, preview.ErrTooLarge):
+                http.Error(w, "File is too large to compare: "+p, http.StatusRequestEntityTooLarge)
+            case errors.Is(err, preview.ErrNotText):
+                http.Error(w, "Only UTF-8 text files can be compared: "+p, http.StatusUnsupportedMediaType)
+            case os.IsPermission(err):
+                http.Error(w, "Permission denied: "+p, http.StatusForbidden)
+            default:
+                http.Error(w, "Error reading file", http.StatusInternalServerError)
+                logger.Logger.Errorf("Error reading %s for diff: %v from IP: %s", p, err, r.RemoteAddr)
+            }
+            return
+        }
+        texts[i] = text
+    }
+
+    diff, err := preview.UnifiedDiff(texts[0], texts[1], pathA, pathB, diffContextLines)
+    if err != nil {
+        http.Error(w, "Error comparing files", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error comparing %s and %s: %v", pathA, pathB, err)
+        return
+    }
+    logger.WithRequest(r).Infof("Files compared: %s and %s to IP: %s", pathA, pathB, r.RemoteAddr)
+
+    if query.Get("format") != "html" {
+        w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+        w.Header().Set("X-Content-Type-Options", "nosniff")
+        io.WriteString(w, diff)
+        return
+    }
+    data := struct {
+        A, B       string
+        Lines      []preview.DiffLine
+        IsLoggedIn bool
+    }{
+        A:          pathA,
+        B:          pathB,
+        Lines:      preview.DiffLines(diff),
+        IsLoggedIn: user != "",
+    }
+    pkg.RenderTemplate(w, "diff.html", data)
+}
+
+// tablePreview - renders the first rows of a CSV/TSV file as an HTML table
+func tablePreview(w http.ResponseWriter, r *http.Request, reqPath, fullPath string, info os.FileInfo, isLoggedIn bool) {
+    if info.Size() > int64(config.Preview.MaxSize)<<20 {
+        http.Error(w, "File is too large to preview", http.StatusRequestEntityTooLarge)
+        return
+    }
+
+    content, err := preview.ReadText(fullPath, int64(config.Preview.MaxSize)<<20)
+    switch {
+    case errors.Is(err, preview.ErrTooLarge):
+        http.Error(w, "File is too large to preview", http.StatusRequestEntityTooLarge)
+        return
+    case errors.Is(err, preview.ErrNotText):
+        http.Error(w, "File is not text", http.StatusUnsupportedMediaType)
+        return
+    case err != nil:
+        http.NotFound(w, r)
+        return
+    }
+
+    var delimiter rune
+    if config.Preview.Delimiter != "" {
+        delimiter = []rune(config.Preview.Delimiter)[0]
+    }
+    table, err := preview.ParseTable(fullPath, strings.NewReader(content), delimiter, config.Preview.MaxRows)
+    if err != nil {
+        http.Error(w, "Error parsing file", http.StatusUnprocessableEntity)
+        logger.Logger.Warnf("Error parsing %s for preview: %v", fullPath, err)
+        return
+    }
+
+    parentDir := path.Dir(reqPath)
+    if parentDir != "/" {
+        parentDir += "/"
+    }
+    data := struct {
+        Path       string
+        ParentDir  string
+        Name       string
+        Table      *preview.Table
+        IsLoggedIn bool
+    }{
+        Path:       reqPath,
+        ParentDir:  parentDir,
+        Name:       info.Name(),
+        Table:      table,
+        IsLoggedIn: isLoggedIn,
+    }
+    logger.WithRequest(r).Infof("File previewed: %s to IP: %s", fullPath, r.RemoteAddr)
+    pkg.RenderTemplate(w, "preview.html", data)
+}
+
+// serveFile - serves the file from an open handle. Once the file is opened the
+// transfer completes even if the file is deleted concurrently, while requests
+// arriving after the deletion get 404.
+func serveFile(w http.ResponseWriter, r *http.Request, fullPath string) {
+    file, err := pkg.Open(fullPath)
+    if err != nil {
+        if os.IsNotExist(err) {
+            http.NotFound(w, r)
+            return
+        }
+        if os.IsPermission(err) {
+            http.Error(w, "Permission denied: the server cannot read this file", http.StatusForbidden)
+            logger.WithRequest(r).Warnf("Permission denied opening file: %s from IP: %s, User: %s", fullPath, r.RemoteAddr, logger.User(auth.SessionUsername(r)))
+            return
+        }
+        http.Error(w, "Error opening file", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error opening file: %v from IP: %s", err, r.RemoteAddr)
+        return
+    }
+    defer file.Close()
+
+    info, err := file.Stat()
+    if err != nil || info.IsDir() {
+        http.NotFound(w, r)
+        return
+    }
+    done := metrics.DownloadStarted()
+    defer done()
+    http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// downloadHandler - handler for file download requests
+func downloadHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    r.ParseForm()
+    pattern := r.FormValue("glob")
+    var items []string
+    if pattern != "" {
+        var ok bool
+        if items, ok = globItems(w, r, pattern); !ok {
+            return
+        }
+    } else {
+        items = formPaths(r, "items")
+    }
+    if len(items) == 0 {
+        http.Error(w, "No files selected for download", http.StatusBadRequest)
+        return
+    }
+    format := archiveFormat
+    if value := r.FormValue("format"); value != "" {
+        var err error
+        if format, err = pkg.ParseArchiveFormat(value); err != nil {
+            http.Error(w, "Invalid archive format (expected zip or tar.gz)", http.StatusBadRequest)
+            return
+        }
+    }
+    items, ok := resolvePaths(w, r, items)
+    if !ok {
+        return
+    }
+    if !authorizeShare(w, r, auth.SessionUsername(r), items...) {
+        return
+    }
+
+    var files []string
+    hasDir := false
+    for _, item := range items {
+        fullPath := filepath.Join(baseDir, item)
+        info, err := pkg.Stat(fullPath)
+        if err != nil {
+            logger.Logger.Errorf("error accessing item: %v from IP: %s", err, clientIP)
+            continue
+        }
+        if !info.IsDir() {
+            files = append(files, item)
+            continue
+        }
+        hasDir = true
+        dirFiles, err := dirItems(w, r, item)
+        if err != nil {
+            logger.Logger.Errorf("error walking directory: %v from IP: %s", err, clientIP)
+            continue
+        }
+        files = append(files, dirFiles...)
+    }
+    // A file may be selected both itself and through its directory
+    files = uniqueStrings(files)
+
+    if len(files) == 0 {
+        http.Error(w, "No files selected for download", http.StatusBadRequest)
+        return
+    }
+
+    // Glob and directory downloads are always archived so that scripts get the same
+    // format for any number of files
+    if len(files) == 1 && pattern == "" && !hasDir {
+        fullPath := filepath.Join(baseDir, files[0])
+        logger.WithRequest(r).Infof("File downloaded: %s by IP: %s", fullPath, clientIP)
+        w.Header().Set("Content-Disposition", pkg.ContentDisposition("attachment", path.Base(files[0])))
+        serveFile(w, r, fullPath)
+    } else {
+        done := metrics.DownloadStarted()
+        defer done()
+        if format == pkg.ArchiveTarGz {
+            writeTarGz(w, r, files)
+            return
+        }
+        w.Header().Set("Content-Type", "application/zip")
+        w.Header().Set("Content-Disposition", pkg.ContentDisposition("attachment", "files.zip"))
+        zipWriter := pkg.NewZipWriter(w, archiveLevel)
+        defer zipWriter.Close()
+
+        for _, file := range files {
+            fullPath := filepath.Join(baseDir, file)
+            err := addFileToZip(zipWriter, fullPath, strings.TrimPrefix(file, "/"))
+            if os.IsPermission(err) {
+                // Unreadable files are left out instead of aborting the archive
+                logger.WithRequest(r).Warnf("Skipping unreadable file in ZIP: %s from IP: %s, User: %s", fullPath, clientIP, logger.User(auth.SessionUsername(r)))
+            } else if err != nil {
+                logger.Logger.Errorf("error adding file to ZIP: %v", err)
+            }
+        }
+    }
+}
+
+// zipMemberHandler - sends a single member of a ZIP archive as an attachment, e.g.
+// /zip-member?archive=/dist/release.zip&entry=docs/README.md. Stored members support
+// range requests; compressed members are streamed whole.
+func zipMemberHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    if r.Method != "GET" && r.Method != "HEAD" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    query := r.URL.Query()
+    archivePath, entryName := query.Get("archive"), query.Get("entry")
+    if archivePath == "" || entryName == "" {
+        http.Error(w, "archive and entry are required", http.StatusBadRequest)
+        return
+    }
+    // Member names are matched as given, so names leaving the archive (zip slip) are refused
+    if !validMemberName(entryName) {
+        http.Error(w, "Invalid entry name", http.StatusBadRequest)
+        logger.WithRequest(r).Warnf("Invalid ZIP entry name %q in %s from IP: %s, User: %s", entryName, archivePath, clientIP, logger.User(auth.SessionUsername(r)))
+        return
+    }
+    fullPath, ok := resolvePath(w, r, archivePath)
+    if !ok {
+        return
+    }
+    archivePath = path.Clean("/" + archivePath)
+    if !authorizeShare(w, r, auth.SessionUsername(r), archivePath) {
+        return
+    }
+
+    file, err := pkg.Open(fullPath)
+    if err != nil {
+        if os.IsNotExist(err) {
+            http.NotFound(w, r)
+            return
+        }
+        http.Error(w, "Error opening archive", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error opening archive: %v from IP: %s", err, clientIP)
+        return
+    }
+    defer file.Close()
+    info, err := file.Stat()
+    if err != nil || info.IsDir() {
+        http.NotFound(w, r)
+        return
+    }
+    archive, err := zip.NewReader(file, info.Size())
+    if err != nil {
+        http.Error(w, "Not a ZIP archive", http.StatusUnsupportedMediaType)
+        return
+    }
+    var member *zip.File
+    for _, f := range archive.File {
+        if f.Name == entryName {
+            member = f
+            break
+        }
+    }
+    if member == nil || member.FileInfo().IsDir() {
+        http.Error(w, "Entry not found in archive", http.StatusNotFound)
+        return
+    }
+
+    contentType := mime.TypeByExtension(path.Ext(entryName))
+    if contentType == "" {
+        contentType = "application/octet-stream"
+    }
+    w.Header().Set("Content-Type", contentType)
+    w.Header().Set("Content-Disposition", pkg.ContentDisposition("attachment", path.Base(entryName)))
+    logger.WithRequest(r).Infof("ZIP member downloaded: %s from %s by IP: %s", entryName, fullPath, clientIP)
+    done := metrics.DownloadStarted()
+    defer done()
+
+    if member.Method == zip.Store {
+        offset, err := member.DataOffset()
+        if err == nil {
+            section := io.NewSectionReader(file, offset, int64(member.UncompressedSize64))
+            http.ServeContent(w, r, path.Base(entryName), member.Modified, section)
+            return
+        }
+    }
+    reader, err := member.Open()
+    if err != nil {
+        http.Error(w, "Error reading entry", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error reading ZIP entry %s of %s: %v", entryName, fullPath, err)
+        return
+    }
+    defer reader.Close()
+    w.Header().Set("Content-Length", strconv.FormatUint(member.UncompressedSize64, 10))
+    if !member.Modified.IsZero() {
+        w.Header().Set("Last-Modified", member.Modified.UTC().Format(http.TimeFormat))
+    }
+    if r.Method == "HEAD" {
+        return
+    }
+    if _, err := io.Copy(w, reader); err != nil {
+        // The checksum is verified at the end, after the content was sent
+        logger.Logger.Errorf("Error sending ZIP entry %s of %s: %v", entryName, fullPath, err)
+    }
+}
+
+// validMemberName - reports whether the ZIP entry name is a relative slash separated
+// path without "." or ".." elements
+func validMemberName(name string) bool {
+    if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+        return false
+    }
+    for _, part := range strings.Split(strings.TrimSuffix(name, "/"), "/") {
+        if part == "" || part == "." || part == ".." {
+            return false
+        }
+    }
+    return true
+}
+
+// uniqueStrings - returns the values without repetitions, in their first order
+func uniqueStrings(values []string) []string {
+    seen := make(map[string]bool, len(values))
+    unique := values[:0]
+    for _, value := range values {
+        if !seen[value] {
+            seen[value] = true
+            unique = append(unique, value)
+        }
+    }
+    return unique
+}
+
+// archiveSkip - leaves description files and shares the user cannot access out of
+// the archive of the directory dirPath
+func archiveSkip(dirPath, user string) func(rel string, entry fs.DirEntry) bool {
+    return func(rel string, entry fs.DirEntry) bool {
+        if entry.Name() == descriptions.FileName {
+            return true
+        }
+        return share.Authorize(path.Join(dirPath, rel), user) != share.Allow
+    }
+}
+
+// dirItems - returns the files below the directory dirPath, walked up to
+// archive.max_depth levels. Symlinks resolving outside the base directory are never
+// followed; directories left out are logged and flagged with X-Archive-Truncated.
+func dirItems(w http.ResponseWriter, r *http.Request, dirPath string) ([]string, error) {
+    user := auth.SessionUsername(r)
+    var items []string
+    opts := pkg.WalkOptions{Root: baseDir, MaxDepth: config.Archive.MaxDepth, FollowSymlinks: config.Archive.FollowSymlinks}
+    dir := filepath.Join(baseDir, filepath.FromSlash(dirPath))
+    truncated, err := pkg.WalkFiles(dir, opts, archiveSkip(dirPath, user), func(rel, full string) error {
+        items = append(items, path.Join(dirPath, rel))
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    if len(truncated) > 0 {
+        logger.WithRequest(r).Warnf("Download of %s truncated: %s from IP: %s, User: %s", dirPath, strings.Join(truncated, "; "), r.RemoteAddr, logger.User(user))
+        w.Header().Set("X-Archive-Truncated", "true")
+    }
+    logger.WithRequest(r).Infof("Directory %s archived with %d files from IP: %s, User: %s", dirPath, len(items), r.RemoteAddr, logger.User(user))
+    return items, nil
+}
+
+// globItems - expands the glob pattern in the directory given by the path form value
+// (recursively with recursive=1) into the matching files. It writes the error
+// response and returns false when the pattern is invalid or matches nothing.
+func globItems(w http.ResponseWriter, r *http.Request, pattern string) ([]string, bool) {
+    dirPath := path.Clean("/" + formPath(r, "path"))
+    user := auth.SessionUsername(r)
+    if !authorizeShare(w, r, user, dirPath) {
+        return nil, false
+    }
+    dir, ok := resolvePath(w, r, formPath(r, "path"))
+    if !ok {
+        return nil, false
+    }
+    if info, err := pkg.Stat(dir); err != nil || !info.IsDir() {
+        http.NotFound(w, r)
+        return nil, false
+    }
+
+    opts := pkg.WalkOptions{Root: baseDir, FollowSymlinks: config.Archive.FollowSymlinks}
+    if r.FormValue("recursive") == "1" {
+        opts.MaxDepth = config.Archive.MaxDepth
+    }
+    matches, truncated, err := pkg.GlobFiles(dir, pattern, opts, config.Archive.MaxGlobMatches, archiveSkip(dirPath, user))
+    switch {
+    case errors.Is(err, pkg.ErrInvalidGlob):
+        http.Error(w, "Invalid glob pattern", http.StatusBadRequest)
+        return nil, false
+    case errors.Is(err, pkg.ErrTooManyMatches):
+        http.Error(w, fmt.Sprintf("Pattern matches more than %d files", config.Archive.MaxGlobMatches), http.StatusRequestEntityTooLarge)
+        return nil, false
+    case err != nil:
+        http.Error(w, "Error matching files", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error matching %s in %s: %v from IP: %s", pattern, dir, err, r.RemoteAddr)
+        return nil, false
+    case len(matches) == 0:
+        http.Error(w, "No files match the pattern", http.StatusNotFound)
+        return nil, false
+    }
+
+    if len(truncated) > 0 {
+        // The archive is still sent, without the directories that were not entered
+        logger.WithRequest(r).Warnf("Glob %s in %s truncated: %s from IP: %s, User: %s", pattern, dirPath, strings.Join(truncated, "; "), r.RemoteAddr, logger.User(user))
+        w.Header().Set("X-Archive-Truncated", "true")
+    }
+
+    items := make([]string, 0, len(matches))
+    for _, match := range matches {
+        items = append(items, path.Join(dirPath, match))
+    }
+    logger.WithRequest(r).Infof("Glob %s in %s matched %d files from IP: %s, User: %s", pattern, dirPath, len(items), r.RemoteAddr, logger.User(user))
+    return items, true
+}
+
+// writeTarGz - sends the files as a gzip compressed tar archive
+func writeTarGz(w http.ResponseWriter, r *http.Request, files []string) {
+    clientIP := r.RemoteAddr
+    gzipWriter, err := pkg.NewGzipWriter(w, archiveLevel)
+    if err != nil {
+        http.Error(w, "Error creating archive", http.StatusInternalServerError)
+        logger.Logger.Errorf("error creating gzip writer: %v", err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/gzip")
+    w.Header().Set("Content-Disposition", pkg.ContentDisposition("attachment", "files.tar.gz"))
+    defer gzipWriter.Close()
+    tarWriter := tar.NewWriter(gzipWriter)
+    defer tarWriter.Close()
+
+    for _, file := range files {
+        fullPath := filepath.Join(baseDir, file)
+        err := addFileToTar(tarWriter, fullPath, strings.TrimPrefix(file, "/"))
+        if os.IsPermission(err) {
+            logger.WithRequest(r).Warnf("Skipping unreadable file in tar.gz: %s from IP: %s, User: %s", fullPath, clientIP, logger.User(auth.SessionUsername(r)))
+        } else if err != nil {
+            logger.Logger.Errorf("error adding file to tar.gz: %v", err)
+        }
+    }
+}
+
+// archiveLevel - compression level of the generated archives
+var archiveLevel int
+
+// archiveFormat - format of multi-file downloads without a format parameter
+var archiveFormat string
+
+// addFileToZip - function for adding a file to a ZIP archive
+func addFileToZip(zipWriter *zip.Writer, filepath string, relPath string) error {
+    fileToZip, err := pkg.Open(filepath)
+    if err != nil {
+        return err
+    }
+    defer fileToZip.Close()
+
+    info, err := fileToZip.Stat()
+    if err != nil {
+        return err
+    }
+
+    if info.IsDir() {
+        // Skip directories
+        return nil
+    }
+
+    header, err := pkg.ZipHeader(info, relPath, config.Archive.IncludeOwnership)
+    if err != nil {
+        return err
+    }
+    header.Method = pkg.ZipMethod(archiveLevel)
+
+    writer, err := zipWriter.CreateHeader(header)
+    if err != nil {
+        return err
+    }
+
+    _, err = io.Copy(writer, fileToZip)
+    return err
+}
+
+// addFileToTar - adds a file to a tar archive
+func addFileToTar(tarWriter *tar.Writer, filepath string, relPath string) error {
+    fileToTar, err := pkg.Open(filepath)
+    if err != nil {
+        return err
+    }
+    defer fileToTar.Close()
+
+    info, err := fileToTar.Stat()
+    if err != nil {
+        return err
+    }
+    if info.IsDir() {
+        return nil
+    }
+
+    header, err := pkg.TarHeader(info, relPath, config.Archive.IncludeOwnership)
+    if err != nil {
+        return err
+    }
+    if err := tarWriter.WriteHeader(header); err != nil {
+        return err
+    }
+    _, err = io.CopyN(tarWriter, fileToTar, header.Size)
+    return err
+}
+
+// uploadedFiles - returns the uploaded files from the configured field, or from
+// every field when any_field is enabled
+func uploadedFiles(form *multipart.Form) []*multipart.FileHeader {
+    if !config.Upload.AnyField {
+        return form.File[config.Upload.FieldName]
+    }
+
+    // Iterate the fields in a stable order
+    fields := make([]string, 0, len(form.File))
+    for field := range form.File {
+        fields = append(fields, field)
+    }
+    sort.Strings(fields)
+
+    var files []*multipart.FileHeader
+    for _, field := range fields {
+        files = append(files, form.File[field]...)
+    }
+    return files
+}
+
+// requireContentLength - rejects an upload without a Content-Length header with 411
+// Length Required when upload.require_content_length is set, before reading its body
+func requireContentLength(w http.ResponseWriter, r *http.Request) bool {
+    if !config.Upload.RequireContentLength || r.ContentLength >= 0 {
+        return true
+    }
+    http.Error(w, "Length required: uploads must declare their size in Content-Length", http.StatusLengthRequired)
+    logger.WithRequest(r).Warnf("Upload without Content-Length rejected from IP: %s", r.RemoteAddr)
+    return false
+}
+
+// defaultMaxUploadSize - upload size limit when max_upload_size is unset
+const defaultMaxUploadSize = 100 << 20
+
+// maxUploadSize - largest accepted upload request body, in bytes
+var maxUploadSize int64
+
+// limitUploadSize - rejects an upload declaring a body larger than max_upload_size with
+// 413 before reading it, and caps the body of the others at the limit
+func limitUploadSize(w http.ResponseWriter, r *http.Request) bool {
+    if r.ContentLength > maxUploadSize {
+        uploadTooLarge(w, r)
+        return false
+    }
+    r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+    return true
+}
+
+// uploadTooLarge - answers an upload over max_upload_size with 413
+func uploadTooLarge(w http.ResponseWriter, r *http.Request) {
+    http.Error(w, "Request entity too large: uploads are limited to "+pkg.FormatSize(maxUploadSize), http.StatusRequestEntityTooLarge)
+    logger.WithRequest(r).Warnf("Upload over the size limit of %s rejected from IP: %s, User: %s", pkg.FormatSize(maxUploadSize), r.RemoteAddr, logger.User(r.Header.Get("X-User")))
+}
+
+// uploadLimiter - caps the number of concurrent uploads per client IP
+var uploadLimiter *pkg.ConcurrencyLimiter
+
+// uploadResult - outcome of saving a single uploaded file
+type uploadResult struct {
+    Name   string `json:"name"`
+    Size   int64  `json:"size"`
+    Error  string `json:"error,omitempty"`
+    // Extracted - contents of an archive extracted instead of being stored
+    Extracted *extract.Result `json:"extracted,omitempty"`
+    status    int
+}
+
+// saveUpload - writes the uploaded file to the slash separated destination below the
+// base directory. A partially written file is removed on failure; the returned status
+// is the HTTP status describing the failure.
+func saveUpload(fileHeader *multipart.FileHeader, rel string) (string, int64, int, error) {
+    dstPath, err := pkg.SafeDestination(baseDir, rel, config.WebServer.SymlinkPolicy)
+    if err != nil {
+        // An existing file may be a symlink pointing outside the base directory
+        return filepath.Join(baseDir, filepath.FromSlash(rel)), 0, http.StatusForbidden, err
+    }
+    file, err := fileHeader.Open()
+    if err != nil {
+        return dstPath, 0, http.StatusBadRequest, err
+    }
+    defer file.Close()
+
+    dst, err := os.Create(dstPath)
+    if err != nil {
+        if pkg.IsDiskFull(err) {
+            return dstPath, 0, http.StatusInsufficientStorage, err
+        }
+        return dstPath, 0, http.StatusInternalServerError, err
+    }
+
+    written, err := io.Copy(dst, file)
+    if closeErr := dst.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        // Do not leave a partial file behind
+        os.Remove(dstPath)
+        if pkg.IsDiskFull(err) {
+            return dstPath, written, http.StatusInsufficientStorage, err
+        }
+        return dstPath, written, http.StatusInternalServerError, err
+    }
+    return dstPath, written, http.StatusOK, nil
+}
+
+// checkUploadType - applies upload.allowed_types to the content read from r and
+// returns the message rejecting the file named name, or an empty string when it passes
+func checkUploadType(name string, r io.Reader) (string, error) {
+    if len(config.Upload.AllowedTypes) == 0 {
+        return "", nil
+    }
+    detected, err := pkg.SniffType(r)
+    if err != nil {
+        return "", err
+    }
+    if expected, mismatch := pkg.ExtensionMismatch(name, detected); mismatch {
+        return fmt.Sprintf("File content (%s) does not match its extension (expected %s)", detected, expected), nil
+    }
+    if !pkg.MatchType(detected, config.Upload.AllowedTypes) {
+        return fmt.Sprintf("File type not allowed: %s", detected), nil
+    }
+    return "", nil
+}
+
+// uploadTypeError - applies upload.allowed_types to an uploaded file
+func uploadTypeError(fileHeader *multipart.FileHeader) (string, error) {
+    if len(config.Upload.AllowedTypes) == 0 {
+        return "", nil
+    }
+    file, err := fileHeader.Open()
+    if err != nil {
+        return "", err
+    }
+    defer file.Close()
+    return checkUploadType(fileHeader.Filename, file)
+}
+
+// detectArchive - identifies the uploaded file as a tar or tar.gz archive by its content
+func detectArchive(fileHeader *multipart.FileHeader) (ok, gzipped bool, detected string, err error) {
+    file, err := fileHeader.Open()
+    if err != nil {
+        return false, false, "", err
+    }
+    defer file.Close()
+    return extract.Detect(file)
+}
+
+// extractUpload - extracts the uploaded tar or tar.gz archive into the slash separated
+// directory rel below the base directory. The returned status and message describe a failure.
+func extractUpload(fileHeader *multipart.FileHeader, rel string, gzipped bool) (extract.Result, int, string, error) {
+    file, err := fileHeader.Open()
+    if err != nil {
+        return extract.Result{}, http.StatusBadRequest, uploadErrorMessage(http.StatusBadRequest), err
+    }
+    defer file.Close()
+
+    result, err := extract.Tar(file, gzipped, baseDir, rel, extract.Options{
+        MaxSize:           int64(config.Upload.Extract.MaxSize) << 20,
+        MaxEntries:        config.Upload.Extract.MaxEntries,
+        Symlinks:          config.Upload.Extract.Symlinks,
+        PreserveModes:     config.Upload.Extract.PreserveModes,
+        DestinationPolicy: config.WebServer.SymlinkPolicy,
+    })
+    switch {
+    case err == nil:
+        return result, http.StatusOK, "", nil
+    case errors.Is(err, extract.ErrUnsafePath), errors.Is(err, extract.ErrSymlink):
+        return result, http.StatusBadRequest, "Invalid archive: " + err.Error(), err
+    case errors.Is(err, extract.ErrTooLarge), errors.Is(err, extract.ErrTooManyEntries):
+        return result, http.StatusRequestEntityTooLarge, "Invalid archive: " + err.Error(), err
+    case errors.Is(err, gzip.ErrHeader), errors.Is(err, tar.ErrHeader), errors.Is(err, io.ErrUnexpectedEOF):
+        return result, http.StatusBadRequest, "Invalid archive: the file is corrupt or not a tar archive", err
+    case pkg.IsDiskFull(err):
+        return result, http.StatusInsufficientStorage, uploadErrorMessage(http.StatusInsufficientStorage), err
+    }
+    return result, http.StatusInternalServerError, "Error extracting archive", err
+}
+
+// uploadErrorMessage - returns the message reported to the client for a failed file
+func uploadErrorMessage(status int) string {
+    switch status {
+    case http.StatusBadRequest:
+        return "Error getting file"
+    case http.StatusForbidden:
+        return "Forbidden: the destination is a symlink outside the base directory"
+    case http.StatusInsufficientStorage:
+        return "Insufficient storage: the disk is full"
+    default:
+        return "Error saving file"
+    }
+}
+
+// writeUploadSummary - reports the outcome of every file of an upload, as JSON for
+// API clients and as plain text otherwise
+func writeUploadSummary(w http.ResponseWriter, r *http.Request, status int, results []uploadResult) {
+    if pkg.WantsJSON(r) {
+        pkg.RenderJSON(w, status, struct {
+            Files []uploadResult `json:"files"`
+        }{
+            Files: results,
+        })
+        return
+    }
+    var buf bytes.Buffer
+    for _, result := range results {
+        if result.Error != "" {
+            fmt.Fprintf(&buf, "%s: %s\n", result.Name, result.Error)
+        } else if result.Extracted != nil {
+            fmt.Fprintf(&buf, "%s: extracted %d files (%s)\n", result.Name, result.Extracted.Files, pkg.ReadableSize(result.Size))
+        } else {
+            fmt.Fprintf(&buf, "%s: uploaded (%s)\n", result.Name, pkg.ReadableSize(result.Size))
+        }
+    }
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    w.Header().Set("X-Content-Type-Options", "nosniff")
+    w.WriteHeader(status)
+    buf.WriteTo(w)
+}
+
+// uploadHandler - handler for file upload requests
+func uploadHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    user := r.Header.Get("X-User")
+    if r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if !requireContentLength(w, r) {
+        return
+    }
+
+    ip := pkg.ClientIP(r)
+    if !uploadLimiter.Acquire(ip) {
+        http.Error(w, "Too many concurrent uploads", http.StatusTooManyRequests)
+        logger.WithRequest(r).Warnf("Concurrent upload limit reached for IP: %s, User: %s", ip, logger.User(user))
+        return
+    }
+    defer uploadLimiter.Release(ip)
+    defer tempsweep.Begin()()
+
+    if !limitUploadSize(w, r) {
+        return
+    }
+    err := r.ParseMultipartForm(100 << 20) // 100 MB kept in memory, the rest in temporary files
+    if err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            uploadTooLarge(w, r)
+            return
+        }
+        if pkg.IsDiskFull(err) {
+            http.Error(w, "Insufficient storage: the disk is full", http.StatusInsufficientStorage)
+            logger.WithRequest(r).WithField("alert", "disk_full").Errorf("Disk full while receiving upload from IP: %s, User: %s", clientIP, logger.User(user))
+            return
+        }
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+
+    reqPath := formPath(r, "currentPath")
+    if _, ok := resolvePath(w, r, reqPath); !ok {
+        return
+    }
+    if !authorizeShare(w, r, user, reqPath) {
+        return
+    }
+    fullDestPath, ok := safeDestination(w, r, user, reqPath)
+    if !ok {
+        return
+    }
+
+    // Archives are unpacked into the destination when extraction is requested, e.g. extract=1
+    extractArchives := false
+    if config.Upload.Extract.Enabled {
+        extractArchives, _ = strconv.ParseBool(r.FormValue("extract"))
+    }
+    // Extracted archives may create subdirectories, so read-only shares below count too
+    if !authorizeWrite(w, r, user, share.OpUpload, extractArchives, reqPath, fullDestPath) {
+        return
+    }
+
+    files := uploadedFiles(r.MultipartForm)
+    if len(files) == 0 {
+        http.Error(w, "No files found in the upload", http.StatusBadRequest)
+        logger.Logger.Warnf("Upload without files from IP: %s, User: %s", clientIP, logger.User(user))
+        return
+    }
+
+    // Book the upload against the user's quota; bytes that are not stored are returned
+    var reserved, stored int64
+    for _, fileHeader := range files {
+        reserved += fileHeader.Size
+    }
+    if ok, remaining := quota.Reserve(user, reserved); !ok {
+        http.Error(w, fmt.Sprintf("Upload quota exceeded: %s remaining, the upload needs %s", pkg.ReadableSize(remaining), pkg.ReadableSize(reserved)), http.StatusInsufficientStorage)
+        logger.WithRequest(r).Warnf("Upload quota exceeded for IP: %s, User: %s", clientIP, logger.User(user))
+        return
+    }
+    defer func() {
+        quota.Refund(user, reserved-stored)
+    }()
+
+    err = os.MkdirAll(fullDestPath, os.ModePerm)
+    if err != nil {
+        http.Error(w, "Error creating directory", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error creating directory: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+        return
+    }
+
+    var results []uploadResult
+    failed := 0
+    for _, fileHeader := range files {
+        isTar, gzipped, detected := false, false, ""
+        if extractArchives {
+            isTar, gzipped, detected, err = detectArchive(fileHeader)
+            if err == nil && !isTar && config.Upload.Extract.Unsupported == extract.UnsupportedReject {
+                err = fmt.Errorf("unsupported archive type: %s", detected)
+            }
+            if err != nil {
+                status, message := http.StatusUnsupportedMediaType, "Unsupported archive type: "+detected+" (expected tar or tar.gz)"
+                if detected == "" {
+                    status, message = http.StatusBadRequest, uploadErrorMessage(http.StatusBadRequest)
+                }
+                logger.WithRequest(r).Warnf("Extraction refused: %s: %v from IP: %s, User: %s", fileHeader.Filename, err, clientIP, logger.User(user))
+                if !config.Upload.ContinueOnError {
+                    http.Error(w, message, status)
+                    return
+                }
+                failed++
+                results = append(results, uploadResult{Name: fileHeader.Filename, Error: message, status: status})
+                continue
+            }
+        }
+        if isTar {
+            result, status, message, err := extractUpload(fileHeader, reqPath, gzipped)
+            if err != nil {
+                logger.WithRequest(r).Errorf("Error extracting archive: %s into %s: %v from IP: %s, User: %s", fileHeader.Filename, reqPath, err, clientIP, logger.User(user))
+                if !config.Upload.ContinueOnError {
+                    http.Error(w, message, status)
+                    return
+                }
+                failed++
+                results = append(results, uploadResult{Name: fileHeader.Filename, Error: message, status: status})
+                continue
+            }
+            logger.WithRequest(r).Infof("Archive extracted: %s into %s (%d files, %d dirs) by IP: %s, User: %s", fileHeader.Filename, reqPath, result.Files, result.Dirs, clientIP, logger.User(user))
+            results = append(results, uploadResult{Name: fileHeader.Filename, Size: result.Size, Extracted: &result})
+            search.Update(reqPath)
+            stored += result.Size
+            continue
+        }
+
+        // The content must be of an allowed type and agree with the extension
+        message, err := uploadTypeError(fileHeader)
+        if err != nil || message != "" {
+            status := http.StatusUnsupportedMediaType
+            if err != nil {
+                status, message = http.StatusBadRequest, uploadErrorMessage(http.StatusBadRequest)
+            }
+            logger.WithRequest(r).Warnf("Upload refused: %s: %s from IP: %s, User: %s", fileHeader.Filename, message, clientIP, logger.User(user))
+            if !config.Upload.ContinueOnError {
+                http.Error(w, message, status)
+                return
+            }
+            failed++
+            results = append(results, uploadResult{Name: fileHeader.Filename, Error: message, status: status})
+            continue
+        }
+
+        dstPath, written, status, err := saveUpload(fileHeader, path.Join("/", reqPath, fileHeader.Filename))
+        if err != nil {
+            entry := logger.WithRequest(r)
+            if status == http.StatusInsufficientStorage {
+                entry = entry.WithField("alert", "disk_full")
+            }
+            entry.Errorf("Error saving file: %s: %v from IP: %s, User: %s", dstPath, err, clientIP, logger.User(user))
+            if !config.Upload.ContinueOnError {
+                http.Error(w, uploadErrorMessage(status), status)
+                return
+            }
+            // Keep going with the remaining files and report the failure in the summary
+            failed++
+            results = append(results, uploadResult{Name: fileHeader.Filename, Error: uploadErrorMessage(status), status: status})
+            continue
+        }
+        logger.WithRequest(r).Infof("File uploaded: %s by IP: %s, User: %s", dstPath, clientIP, logger.User(user))
+        results = append(results, uploadResult{Name: fileHeader.Filename, Size: written})
+        stored += written
+
+        recent.Add(recent.Event{
+            Path: path.Join("/", reqPath, fileHeader.Filename),
+            User: user,
+            Size: written,
+            Time: time.Now(),
+        })
+
+        events.Publish(events.Event{Type: events.TypeUpload, Path: path.Join("/", reqPath, fileHeader.Filename), User: user, Size: written})
+        search.Update(path.Join("/", reqPath, fileHeader.Filename))
+        webhook.NotifyUpload(webhook.UploadEvent{
+            Path:      path.Join("/", reqPath, fileHeader.Filename),
+            URL:       fileURL(r, path.Join("/", reqPath, fileHeader.Filename)),
+            Size:      written,
+            User:      user,
+            Timestamp: time.Now(),
+        })
+    }
+
+    if failed > 0 {
+        status := http.StatusMultiStatus
+        if failed == len(results) {
+            // Nothing was saved: report the failure of the first file
+            status = results[0].status
+        }
+        writeUploadSummary(w, r, status, results)
+        return
+    }
+    if config.Upload.ContinueOnError && pkg.WantsJSON(r) {
+        writeUploadSummary(w, r, http.StatusOK, results)
+        return
+    }
+
+    http.Redirect(w, r, reqPath, http.StatusSeeOther)
+}
+
+// chunkErrorStatus - returns the HTTP status describing a failed chunk operation
+func chunkErrorStatus(err error) int {
+    switch {
+    case errors.Is(err, chunked.ErrChecksum):
+        return http.StatusUnprocessableEntity
+    case errors.Is(err, chunked.ErrInvalidID), errors.Is(err, chunked.ErrInvalidChecksum), errors.Is(err, chunked.ErrMissingChunk):
+        return http.StatusBadRequest
+    case pkg.IsDiskFull(err):
+        return http.StatusInsufficientStorage
+    }
+    return http.StatusInternalServerError
+}
+
+// uploadChunkHandler - receives an upload in chunks. Every chunk is posted with the
+// form values id, index and checksum (hex SHA-256, optional) and the file field chunk;
+// the final request with complete=1, id, total, name, currentPath and the checksum of
+// the whole file assembles the chunks into the destination. Checksum mismatches are
+// rejected with 422.
+func uploadChunkHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    user := r.Header.Get("X-User")
+    if r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if !requireContentLength(w, r) {
+        return
+    }
+    defer tempsweep.Begin()()
+
+    if !limitUploadSize(w, r) {
+        return
+    }
+    if err := r.ParseMultipartForm(100 << 20); err != nil { // 100 MB kept in memory
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            uploadTooLarge(w, r)
+            return
+        }
+        if pkg.IsDiskFull(err) {
+            http.Error(w, "Insufficient storage: the disk is full", http.StatusInsufficientStorage)
+            return
+        }
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+    id := r.FormValue("id")
+    if !chunked.ValidID(id) {
+        http.Error(w, "Invalid upload id", http.StatusBadRequest)
+        return
+    }
+
+    if complete, _ := strconv.ParseBool(r.FormValue("complete")); !complete {
+        index, err := strconv.Atoi(r.FormValue("index"))
+        if err != nil {
+            http.Error(w, "Invalid chunk index", http.StatusBadRequest)
+            return
+        }
+        file, _, err := r.FormFile("chunk")
+        if err != nil {
+            http.Error(w, "No chunk found in the request", http.StatusBadRequest)
+            return
+        }
+        defer file.Close()
+        size, err := chunked.SaveChunk(user, id, index, file, r.FormValue("checksum"))
+        if err != nil {
+            status := chunkErrorStatus(err)
+            http.Error(w, "Error saving chunk: "+err.Error(), status)
+            logger.WithRequest(r).Warnf("Chunk %d of upload %s rejected: %v from IP: %s, User: %s", index, id, err, clientIP, logger.User(user))
+            return
+        }
+        pkg.RenderJSON(w, http.StatusOK, struct {
+            ID    string `json:"id"`
+            Index int    `json:"index"`
+            Size  int64  `json:"size"`
+        }{
+            ID:    id,
+            Index: index,
+            Size:  size,
+        })
+        return
+    }
+
+    reqPath := formPath(r, "currentPath")
+    name := r.FormValue("name")
+    if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+        http.Error(w, "Invalid file name", http.StatusBadRequest)
+        return
+    }
+    total, err := strconv.Atoi(r.FormValue("total"))
+    if err != nil {
+        http.Error(w, "Invalid chunk count", http.StatusBadRequest)
+        return
+    }
+    if _, ok := resolvePath(w, r, reqPath+"/"+name); !ok {
+        return
+    }
+    if !authorizeShare(w, r, user, reqPath) {
+        return
+    }
+    fullDestPath, ok := safeDestination(w, r, user, reqPath)
+    if !ok {
+        return
+    }
+    if !authorizeWrite(w, r, user, share.OpUpload, false, reqPath, fullDestPath) {
+        return
+    }
+
+    size, err := chunked.Size(user, id, total)
+    if err != nil {
+        http.Error(w, "Error assembling upload: "+err.Error(), chunkErrorStatus(err))
+        return
+    }
+    if ok, remaining := quota.Reserve(user, size); !ok {
+        http.Error(w, fmt.Sprintf("Upload quota exceeded: %s remaining, the upload needs %s", pkg.ReadableSize(remaining), pkg.ReadableSize(size)), http.StatusInsufficientStorage)
+        logger.WithRequest(r).Warnf("Upload quota exceeded for IP: %s, User: %s", clientIP, logger.User(user))
+        return
+    }
+    var stored int64
+    defer func() {
+        quota.Refund(user, size-stored)
+    }()
+
+    if err := os.MkdirAll(fullDestPath, os.ModePerm); err != nil {
+        http.Error(w, "Error creating directory", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error creating directory: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+        return
+    }
+    rel := path.Join("/", reqPath, name)
+    dstPath, err := pkg.SafeDestination(baseDir, rel, config.WebServer.SymlinkPolicy)
+    if err != nil {
+        http.Error(w, uploadErrorMessage(http.StatusForbidden), http.StatusForbidden)
+        return
+    }
+    if len(config.Upload.AllowedTypes) > 0 {
+        head, err := chunked.Open(user, id)
+        if err != nil {
+            http.Error(w, "Error assembling upload: "+err.Error(), chunkErrorStatus(err))
+            return
+        }
+        message, err := checkUploadType(name, head)
+        head.Close()
+        if err != nil {
+            http.Error(w, "Error assembling upload", http.StatusInternalServerError)
+            return
+        }
+        if message != "" {
+            chunked.Discard(user, id)
+            http.Error(w, message, http.StatusUnsupportedMediaType)
+            logger.WithRequest(r).Warnf("Upload refused: %s: %s from IP: %s, User: %s", name, message, clientIP, logger.User(user))
+            return
+        }
+    }
+    written, err := chunked.Assemble(user, id, total, dstPath, r.FormValue("checksum"))
+    if err != nil {
+        status := chunkErrorStatus(err)
+        http.Error(w, "Error assembling upload: "+err.Error(), status)
+        logger.WithRequest(r).Errorf("Error assembling upload %s into %s: %v from IP: %s, User: %s", id, dstPath, err, clientIP, logger.User(user))
+        return
+    }
+    stored = written
+    logger.WithRequest(r).Infof("File uploaded: %s (%d chunks) by IP: %s, User: %s", dstPath, total, clientIP, logger.User(user))
+
+    recent.Add(recent.Event{
+        Path: rel,
+        User: user,
+        Size: written,
+        Time: time.Now(),
+    })
+
+    events.Publish(events.Event{Type: events.TypeUpload, Path: rel, User: user, Size: written})
+    search.Update(rel)
+    webhook.NotifyUpload(webhook.UploadEvent{
+        Path:      rel,
+        URL:       fileURL(r, rel),
+        Size:      written,
+        User:      user,
+        Timestamp: time.Now(),
+    })
+    writeUploadSummary(w, r, http.StatusOK, []uploadResult{{Name: name, Size: written}})
+}
+
+// uploadLinkHandler - creates a signed link allowing uploads into a folder without an
+// account. The form values are path, expires (a duration, e.g. 48h) and max_size (MB).
+func uploadLinkHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    user := r.Header.Get("X-User")
+    if r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    dirPath := path.Clean("/" + formPath(r, "path"))
+    if !authorizeShare(w, r, user, dirPath) {
+        return
+    }
+    fullPath, ok := resolvePath(w, r, formPath(r, "path"))
+    if !ok {
+        return
+    }
+    info, err := os.Stat(fullPath)
+    if err != nil || !info.IsDir() {
+        http.Error(w, "Directory not found", http.StatusNotFound)
+        return
+    }
+
+    expiry := config.Upload.Links.DefaultExpiry
+    if value := r.FormValue("expires"); value != "" {
+        expiry, err = time.ParseDuration(value)
+        if err != nil || expiry <= 0 {
+            http.Error(w, "Invalid expiry", http.StatusBadRequest)
+            return
+        }
+    }
+    if expiry > config.Upload.Links.MaxExpiry {
+        http.Error(w, fmt.Sprintf("Expiry exceeds the maximum of %s", config.Upload.Links.MaxExpiry), http.StatusBadRequest)
+        return
+    }
+    maxSize := config.Upload.Links.MaxSize
+    if value := r.FormValue("max_size"); value != "" {
+        maxSize, err = strconv.Atoi(value)
+        if err != nil || maxSize < 0 {
+            http.Error(w, "Invalid size limit", http.StatusBadRequest)
+            return
+        }
+    }
+
+    link := uploadlink.Token{
+        Path:    dirPath,
+        Expires: time.Now().Add(expiry).Truncate(time.Second),
+        MaxSize: int64(maxSize) << 20,
+        User:    user,
+    }
+    token, err := uploadlink.Sign(link)
+    if err != nil {
+        http.Error(w, "Error creating upload link", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error signing upload link: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+        return
+    }
+    logger.WithRequest(r).Infof("Upload link created for %s until %s by IP: %s, User: %s", dirPath, link.Expires.Format(time.RFC3339), clientIP, logger.User(user))
+
+    pkg.RenderJSON(w, http.StatusOK, struct {
+        URL     string    `json:"url"`
+        Path    string    `json:"path"`
+        Expires time.Time `json:"expires"`
+        MaxSize int64     `json:"maxSize"`
+    }{
+        URL:     fileURL(r, "/drop") + "?token=" + url.QueryEscape(token),
+        Path:    dirPath,
+        Expires: link.Expires,
+        MaxSize: link.MaxSize,
+    })
+}
+
+// dropHandler - serves upload links: GET shows the upload form, POST stores the files
+// in the folder of the link. The link may not be used for another folder.
+func dropHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    if r.Method != "GET" && r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    rawToken := r.URL.Query().Get("token")
+    link, err := uploadlink.Verify(rawToken, time.Now())
+    if err != nil {
+        if errors.Is(err, uploadlink.ErrExpired) {
+            http.Error(w, "Forbidden: the upload link has expired", http.StatusForbidden)
+        } else {
+            http.Error(w, "Forbidden: invalid upload link", http.StatusForbidden)
+        }
+        logger.WithRequest(r).Warnf("Rejected upload link: %v from IP: %s", err, clientIP)
+        return
+    }
+
+    if r.Method == "GET" {
+        pkg.RenderTemplate(w, "drop.html", struct {
+            Token     string
+            Path      string
+            Expires   time.Time
+            MaxSize   int64
+            FieldName string
+        }{
+            Token:     rawToken,
+            Path:      link.Path,
+            Expires:   link.Expires,
+            MaxSize:   link.MaxSize,
+            FieldName: config.Upload.FieldName,
+        })
+        return
+    }
+    if !requireContentLength(w, r) {
+        return
+    }
+
+    ip := pkg.ClientIP(r)
+    if !uploadLimiter.Acquire(ip) {
+        http.Error(w, "Too many concurrent uploads", http.StatusTooManyRequests)
+        logger.WithRequest(r).Warnf("Concurrent upload limit reached for IP: %s", ip)
+        return
+    }
+    defer uploadLimiter.Release(ip)
+    defer tempsweep.Begin()()
+
+    // The server-wide limit applies on top of the limit of the link
+    if !limitUploadSize(w, r) {
+        return
+    }
+    if link.MaxSize > 0 {
+        // Leave room for the multipart framing around the files
+        if r.ContentLength > link.MaxSize+1<<20 {
+            http.Error(w, "Upload exceeds the size limit of the link", http.StatusRequestEntityTooLarge)
+            return
+        }
+        r.Body = http.MaxBytesReader(w, r.Body, link.MaxSize+1<<20)
+    }
+    if err := r.ParseMultipartForm(100 << 20); err != nil {
+        var maxBytesErr *http.MaxBytesError
+        if errors.As(err, &maxBytesErr) {
+            if maxBytesErr.Limit == maxUploadSize {
+                uploadTooLarge(w, r)
+                return
+            }
+            http.Error(w, "Upload exceeds the size limit of the link", http.StatusRequestEntityTooLarge)
+            return
+        }
+        http.Error(w, "Error parsing form", http.StatusBadRequest)
+        return
+    }
+    if current := formPath(r, "currentPath"); current != "" && path.Clean("/"+current) != link.Path {
+        http.Error(w, "Forbidden: the upload link is not valid for this folder", http.StatusForbidden)
+        logger.WithRequest(r).Warnf("Upload link for %s used for %s from IP: %s", link.Path, current, clientIP)
+        return
+    }
+
+    files := uploadedFiles(r.MultipartForm)
+    if len(files) == 0 {
+        http.Error(w, "No files found in the upload", http.StatusBadRequest)
+        return
+    }
+    var total int64
+    for _, fileHeader := range files {
+        total += fileHeader.Size
+    }
+    if link.MaxSize > 0 && total > link.MaxSize {
+        http.Error(w, "Upload exceeds the size limit of the link", http.StatusRequestEntityTooLarge)
+        return
+    }
+    for _, fileHeader := range files {
+        message, err := uploadTypeError(fileHeader)
+        if err != nil {
+            http.Error(w, uploadErrorMessage(http.StatusBadRequest), http.StatusBadRequest)
+            return
+        }
+        if message != "" {
+            http.Error(w, message, http.StatusUnsupportedMediaType)
+            logger.WithRequest(r).Warnf("Upload via link refused: %s: %s from IP: %s", fileHeader.Filename, message, clientIP)
+            return
+        }
+    }
+
+    fullDestPath, ok := safeDestination(w, r, link.User, link.Path)
+    if !ok {
+        return
+    }
+    if !authorizeWrite(w, r, link.User, share.OpUpload, false, link.Path, fullDestPath) {
+        return
+    }
+
+    // Uploads through a link count against the quota of the user who created it
+    var stored int64
+    if ok, remaining := quota.Reserve(link.User, total); !ok {
+        http.Error(w, fmt.Sprintf("Upload quota exceeded: %s remaining, the upload needs %s", pkg.ReadableSize(remaining), pkg.ReadableSize(total)), http.StatusInsufficientStorage)
+        logger.WithRequest(r).Warnf("Upload quota exceeded for upload link from IP: %s, link created by User: %s", clientIP, logger.User(link.User))
+        return
+    }
+    defer func() {
+        quota.Refund(link.User, total-stored)
+    }()
+
+    if err := os.MkdirAll(fullDestPath, os.ModePerm); err != nil {
+        http.Error(w, "Error creating directory", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error creating directory: %v from IP: %s", err, clientIP)
+        return
+    }
+
+    var results []uploadResult
+    for _, fileHeader := range files {
+        dstPath, written, status, err := saveUpload(fileHeader, path.Join(link.Path, fileHeader.Filename))
+        if err != nil {
+            logger.WithRequest(r).Errorf("Error saving file: %s: %v from IP: %s", dstPath, err, clientIP)
+            http.Error(w, uploadErrorMessage(status), status)
+            return
+        }
+        logger.WithRequest(r).Infof("File uploaded via link: %s by IP: %s, link created by User: %s", dstPath, clientIP, logger.User(link.User))
+        results = append(results, uploadResult{Name: fileHeader.Filename, Size: written})
+        stored += written
+
+        recent.Add(recent.Event{
+            Path: path.Join(link.Path, fileHeader.Filename),
+            User: link.User,
+            Size: written,
+            Time: time.Now(),
+        })
+
+        events.Publish(events.Event{Type: events.TypeUpload, Path: path.Join(link.Path, fileHeader.Filename), User: link.User, Size: written})
+        search.Update(path.Join(link.Path, fileHeader.Filename))
+        webhook.NotifyUpload(webhook.UploadEvent{
+            Path:      path.Join(link.Path, fileHeader.Filename),
+            URL:       fileURL(r, path.Join(link.Path, fileHeader.Filename)),
+            Size:      written,
+            User:      link.User,
+            Timestamp: time.Now(),
+        })
+    }
+    writeUploadSummary(w, r, http.StatusOK, results)
+}
+
+// createTreeRequest - represents a request to bulk-create a directory structure
+type createTreeRequest struct {
+    Path string        `json:"path"`
+    Tree []pkg.DirNode `json:"tree"`
+}
+
+// maxTreeDirs - maximum number of directories created by a single tree request
+const maxTreeDirs = 1000
+
+// createTreeHandler - handler for creating a whole directory structure from JSON
+func createTreeHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    user := r.Header.Get("X-User")
+    if r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req createTreeRequest
+    if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+        http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+        return
+    }
+    if strings.Contains("/"+filepath.ToSlash(req.Path)+"/", "/../") {
+        denyPath(w, r, http.StatusBadRequest, "Invalid path")
+        logger.WithRequest(r).Warnf("Path traversal attempt: %s from IP: %s, User: %s", req.Path, clientIP, logger.User(user))
+        return
+    }
+    paths, err := pkg.TreePaths(req.Tree, config.WebServer.MaxTreeDepth, maxTreeDirs)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        logger.WithRequest(r).Warnf("Rejected directory tree: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+        return
+    }
+
+    if _, ok := resolvePath(w, r, req.Path); !ok {
+        return
+    }
+    basePath := path.Clean("/" + req.Path)
+    if !authorizeShare(w, r, user, basePath) {
+        return
+    }
+    result := struct {
+        Created  []string `json:"created"`
+        Existing []string `json:"existing"`
+    }{
+        Created:  []string{},
+        Existing: []string{},
+    }
+    for _, p := range paths {
+        relPath := path.Join(basePath, p)
+        fullPath, ok := safeDestination(w, r, user, relPath)
+        if !ok {
+            return
+        }
+        if !authorizeWrite(w, r, user, share.OpCreate, false, relPath, fullPath) {
+            return
+        }
+        if info, err := os.Stat(fullPath); err == nil {
+            if !info.IsDir() {
+                http.Error(w, "A file with the same name already exists: "+relPath, http.StatusConflict)
+                return
+            }
+            result.Existing = append(result.Existing, relPath)
+            continue
+        }
+        if err := os.MkdirAll(fullPath, os.ModePerm); err != nil {
+            http.Error(w, "Error creating folder", http.StatusInternalServerError)
+            logger.Logger.Errorf("Error creating folder: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+            return
+        }
+        result.Created = append(result.Created, relPath)
+        logger.WithRequest(r).Infof("Folder created: %s by IP: %s, User: %s", fullPath, clientIP, logger.User(user))
+        events.Publish(events.Event{Type: events.TypeCreateFolder, Path: relPath, User: user})
+        search.Update(relPath)
+    }
+
+    pkg.RenderJSON(w, http.StatusOK, result)
+}
+
+// createFolderHandler - handler for creating directories
+func createFolderHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    user := r.Header.Get("X-User")
+    if r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    // SPA clients post {"currentPath": ..., "folderName": ...} and get a JSON result
+    var request struct {
+        CurrentPath string `json:"currentPath"`
+        FolderName  string `json:"folderName"`
+    }
+    jsonMode := pkg.WantsJSON(r)
+    if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+        jsonMode = true
+        if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&request); err != nil {
+            folderResult(w, r, true, http.StatusBadRequest, "", "Invalid JSON body")
+            return
+        }
+        request.CurrentPath = pkg.CollapseSlashes(request.CurrentPath)
+    } else {
+        request.CurrentPath = formPath(r, "currentPath")
+        request.FolderName = r.FormValue("folderName")
+    }
+    reqPath, folderName := request.CurrentPath, request.FolderName
+    if folderName == "" {
+        folderResult(w, r, jsonMode, http.StatusBadRequest, "", "Folder name is required")
+        return
+    }
+    // The name is a single path element: "..", "a/b" and the like are refused
+    if err := pkg.ValidName(folderName); err != nil {
+        folderResult(w, r, jsonMode, http.StatusBadRequest, "", "Invalid folder name: "+err.Error())
+        return
+    }
+    if _, ok := resolvePath(w, r, reqPath+"/"+folderName); !ok {
+        return
+    }
+    created := path.Join("/", reqPath, folderName)
+
+    if !authorizeShare(w, r, user, created) {
+        return
+    }
+    fullPath, ok := safeDestination(w, r, user, created)
+    if !ok {
+        return
+    }
+    if !authorizeWrite(w, r, user, share.OpCreate, false, created, fullPath) {
+        return
+    }
+
+    // On case-insensitive filesystems "Docs" would collide with an existing "docs"
+    if !config.WebServer.IsCaseSensitive() {
+        existing, err := pkg.FindNameFold(filepath.Dir(fullPath), folderName)
+        if err != nil && !os.IsNotExist(err) {
+            folderResult(w, r, jsonMode, http.StatusInternalServerError, created, "Error creating folder")
+            logger.Logger.Errorf("Error reading directory: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+            return
+        }
+        if existing != "" {
+            folderResult(w, r, jsonMode, http.StatusConflict, created, "An entry with the same name already exists: "+existing)
+            return
+        }
+    }
+
+    err := os.Mkdir(fullPath, os.ModePerm)
+    if err != nil {
+        if os.IsExist(err) {
+            folderResult(w, r, jsonMode, http.StatusConflict, created, "An entry with the same name already exists: "+folderName)
+            return
+        }
+        folderResult(w, r, jsonMode, http.StatusInternalServerError, created, "Error creating folder")
+        logger.Logger.Errorf("Error creating folder: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+        return
+    }
+    logger.WithRequest(r).Infof("Folder created: %s by IP: %s, User: %s", fullPath, clientIP, logger.User(user))
+    events.Publish(events.Event{Type: events.TypeCreateFolder, Path: created, User: user})
+    search.Update(created)
+
+    if jsonMode {
+        folderResult(w, r, true, http.StatusCreated, created, "")
+        return
+    }
+    http.Redirect(w, r, reqPath, http.StatusSeeOther)
+}
+
+// folderResult - reports the outcome of a folder creation, as JSON with the created
+// path, its URL and the status for API clients and as plain text otherwise
+func folderResult(w http.ResponseWriter, r *http.Request, jsonMode bool, status int, created, message string) {
+    if !jsonMode {
+        http.Error(w, message, status)
+        return
+    }
+    result := struct {
+        Path   string `json:"path,omitempty"`
+        URL    string `json:"url,omitempty"`
+        Status int    `json:"status"`
+        Error  string `json:"error,omitempty"`
+    }{
+        Path:   created,
+        Status: status,
+        Error:  message,
+    }
+    if status == http.StatusCreated {
+        result.URL = fileURL(r, created+"/")
+    }
+    pkg.RenderJSON(w, status, result)
+}
+
+// favoriteHandler - pins a directory for the user, or unpins it when already pinned
+func favoriteHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    user := r.Header.Get("X-User")
+    if r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    dirPath := path.Clean("/" + r.FormValue("path"))
+    if !authorizeShare(w, r, user, dirPath) {
+        return
+    }
+    fullPath, ok := resolvePath(w, r, r.FormValue("path"))
+    if !ok {
+        return
+    }
+    info, err := os.Stat(fullPath)
+    if err != nil || !info.IsDir() {
+        http.Error(w, "Directory not found", http.StatusNotFound)
+        return
+    }
+    // Listing paths end with a slash
+    if dirPath != "/" {
+        dirPath += "/"
+    }
+
+    pinned := favorites.Toggle(user, dirPath)
+    if pinned {
+        logger.WithRequest(r).Infof("Favorite added: %s by IP: %s, User: %s", dirPath, clientIP, logger.User(user))
+    } else {
+        logger.WithRequest(r).Infof("Favorite removed: %s by IP: %s, User: %s", dirPath, clientIP, logger.User(user))
+    }
+
+    if pkg.WantsJSON(r) {
+        pkg.RenderJSON(w, http.StatusOK, struct {
+            Path   string `json:"path"`
+            Pinned bool   `json:"pinned"`
+        }{
+            Path:   dirPath,
+            Pinned: pinned,
+        })
+        return
+    }
+    // Return to the page the request came from, staying on this server
+    redirect := dirPath
+    if current := r.FormValue("currentPath"); current != "" {
+        redirect = path.Clean("/" + current)
+        if redirect != "/" && strings.HasSuffix(current, "/") {
+            redirect += "/"
+        }
+    }
+    http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// describeHandler - handler for setting the description of a file or folder
+func describeHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    user := r.Header.Get("X-User")
+    if r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    reqPath := formPath(r, "currentPath")
+    name := r.FormValue("name")
+    description := strings.TrimSpace(r.FormValue("description"))
+    if err := pkg.ValidName(name); err != nil || name == descriptions.FileName {
+        http.Error(w, "Invalid name", http.StatusBadRequest)
+        return
+    }
+
+    if !authorizeShare(w, r, user, reqPath) {
+        return
+    }
+    dir, ok := safeDestination(w, r, user, reqPath)
+    if !ok {
+        return
+    }
+    if _, err := os.Lstat(filepath.Join(dir, name)); err != nil {
+        http.NotFound(w, r)
+        return
+    }
+
+    if utf8.RuneCountInString(description) > descriptions.MaxLength {
+        http.Error(w, fmt.Sprintf("Description exceeds %d characters", descriptions.MaxLength), http.StatusBadRequest)
+        return
+    }
+
+    if err := descriptions.Set(dir, name, description); err != nil {
+        http.Error(w, "Error saving description", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error saving description: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+        return
+    }
+    logger.WithRequest(r).Infof("Description set: %s by IP: %s, User: %s", filepath.Join(dir, name), clientIP, logger.User(user))
+
+    if pkg.WantsJSON(r) {
+        pkg.RenderJSON(w, http.StatusOK, struct {
+            Name        string `json:"name"`
+            Description string `json:"description"`
+        }{
+            Name:        name,
+            Description: description,
+        })
+        return
+    }
+    http.Redirect(w, r, reqPath, http.StatusSeeOther)
+}
+
+// renameHandler - handler for renaming a file or folder within its directory
+func renameHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    user := r.Header.Get("X-User")
+    if r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    reqPath := formPath(r, "currentPath")
+    oldName, newName := r.FormValue("oldName"), r.FormValue("newName")
+    if pkg.ValidName(oldName) != nil || pkg.ValidName(newName) != nil {
+        http.Error(w, "Invalid name", http.StatusBadRequest)
+        return
+    }
+    switch newName {
+    case descriptions.FileName, dirtemplate.FileName, prebuiltIndexName, tags.FileName:
+        http.Error(w, "Invalid name", http.StatusBadRequest)
+        return
+    }
+    srcPath, ok := resolvePath(w, r, reqPath+"/"+oldName)
+    if !ok {
+        return
+    }
+    if _, ok := resolvePath(w, r, reqPath+"/"+newName); !ok {
+        return
+    }
+    from, to := path.Join("/", reqPath, oldName), path.Join("/", reqPath, newName)
+
+    if !authorizeShare(w, r, user, from, to) {
+        return
+    }
+    dstPath, ok := safeDestination(w, r, user, to)
+    if !ok {
+        return
+    }
+    if !authorizeWrite(w, r, user, share.OpDelete, true, from, srcPath) || !authorizeWrite(w, r, user, share.OpUpload, false, to, dstPath) {
+        return
+    }
+    if _, err := os.Lstat(srcPath); err != nil {
+        http.NotFound(w, r)
+        return
+    }
+    if oldName == newName {
+        http.Redirect(w, r, reqPath, http.StatusSeeOther)
+        return
+    }
+
+    // On case-insensitive filesystems only a change of case may reuse the same name
+    existing := ""
+    if _, err := os.Lstat(dstPath); err == nil {
+        existing = newName
+    }
+    if !config.WebServer.IsCaseSensitive() {
+        found, err := pkg.FindNameFold(filepath.Dir(dstPath), newName)
+        if err != nil {
+            http.Error(w, "Error renaming item", http.StatusInternalServerError)
+            logger.Logger.Errorf("Error reading directory: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+            return
+        }
+        existing = found
+    }
+    if existing != "" && existing != oldName {
+        http.Error(w, "An entry with the same name already exists: "+existing, http.StatusConflict)
+        return
+    }
+
+    if err := os.Rename(srcPath, dstPath); err != nil {
+        http.Error(w, "Error renaming item", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error renaming %s to %s: %v from IP: %s, User: %s", srcPath, dstPath, err, clientIP, logger.User(user))
+        return
+    }
+    moveMetadata(from, to)
+    logger.WithRequest(r).Infof("Item renamed: %s to %s by IP: %s, User: %s", srcPath, dstPath, clientIP, logger.User(user))
+    events.Publish(events.Event{Type: events.TypeRename, Path: from, To: to, User: user})
+    search.Update(from, to)
+
+    if pkg.WantsJSON(r) {
+        pkg.RenderJSON(w, http.StatusOK, struct {
+            Path string `json:"path"`
+            To   string `json:"to"`
+        }{
+            Path: from,
+            To:   to,
+        })
+        return
+    }
+    http.Redirect(w, r, reqPath, http.StatusSeeOther)
+}
+
+// deleteHandler - handler for deleting files and directories
+func deleteHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    user := r.Header.Get("X-User")
+    if r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    r.ParseForm()
+    items := formPaths(r, "items")
+    if len(items) == 0 {
+        http.Error(w, "No items selected for deletion", http.StatusBadRequest)
+        return
+    }
+    items, ok := resolvePaths(w, r, items)
+    if !ok {
+        return
+    }
+    // The base directory itself is never deleted, e.g. items=/ or items=/docs/..
+    for _, item := range items {
+        if item == "/" {
+            http.Error(w, "The root directory cannot be deleted", http.StatusBadRequest)
+            logger.WithRequest(r).Warnf("Deletion of the root directory refused from IP: %s, User: %s", clientIP, logger.User(user))
+            return
+        }
+    }
+    if !authorizeShare(w, r, user, items...) {
+        return
+    }
+    for _, item := range items {
+        if !authorizeWrite(w, r, user, share.OpDelete, true, item, filepath.Join(baseDir, item)) {
+            return
+        }
+    }
+
+    // Recently modified files are protected unless the deletion is forced
+    if window := config.WebServer.DeleteProtection; window > 0 && r.FormValue("force") != "1" {
+        since := time.Now().Add(-window)
+        for _, item := range items {
+            recent, err := pkg.RecentlyModified(filepath.Join(baseDir, item), since, 1)
+            if err != nil {
+                http.Error(w, "Error deleting item", http.StatusInternalServerError)
+                logger.Logger.Errorf("Error checking item before deletion: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+                return
+            }
+            if len(recent) > 0 {
+                rel, _ := filepath.Rel(baseDir, recent[0])
+                http.Error(w, fmt.Sprintf("Locked: /%s was modified within the last %s; pass force=1 to delete it anyway", filepath.ToSlash(rel), window), http.StatusLocked)
+                logger.WithRequest(r).Warnf("Deletion of recently modified file refused: %s from IP: %s, User: %s", recent[0], clientIP, logger.User(user))
+                return
+            }
+        }
+    }
+
+    for _, item := range items {
+        fullPath := filepath.Join(baseDir, item)
+        err := logAndRemoveAll(fullPath, clientIP, user)
+        if err != nil {
+            http.Error(w, "Error deleting item", http.StatusInternalServerError)
+            logger.Logger.Errorf("Error deleting item: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+            return
+        }
+        logger.WithRequest(r).Infof("Item deleted: %s by IP: %s, User: %s", fullPath, clientIP, logger.User(user))
+        events.Publish(events.Event{Type: events.TypeDelete, Path: path.Clean("/" + item), User: user})
+        search.Update(item)
+        if config.WebServer.Descriptions {
+            if err := descriptions.Set(filepath.Dir(fullPath), filepath.Base(fullPath), ""); err != nil {
+                logger.Logger.Warnf("Error removing description of %s: %v", fullPath, err)
+            }
+        }
+        if config.Tags.Enabled {
+            if err := tags.Remove(item); err != nil {
+                logger.Logger.Warnf("Error removing tags of %s: %v", item, err)
+            }
+        }
+    }
+
+    reqPath := formPath(r, "currentPath")
     http.Redirect(w, r, reqPath, http.StatusSeeOther)
 }
 
+// Operations of /api/batch
+const (
+    batchDelete = "delete"
+    batchMove   = "move"
+    batchCopy   = "copy"
+    batchMkdir  = "mkdir"
+)
+
+// maxBatchOperations - largest number of operations of a batch
+const maxBatchOperations = 1000
+
+// batchOperation - single operation of a batch: path is the item to delete, move or
+// copy, or the folder to create; to is the new path of a moved or copied item
+type batchOperation struct {
+    Op    string `json:"op"`
+    Path  string `json:"path"`
+    To    string `json:"to,omitempty"`
+    Force bool   `json:"force,omitempty"`
+}
+
+// batchResult - outcome of an operation of a batch
+type batchResult struct {
+    Op     string `json:"op"`
+    Path   string `json:"path"`
+    To     string `json:"to,omitempty"`
+    Status int    `json:"status,omitempty"`
+    Error  string `json:"error,omitempty"`
+    // Skipped - not attempted because an earlier operation of a transactional batch failed
+    Skipped bool `json:"skipped,omitempty"`
+    // RolledBack - undone because a later operation of a transactional batch failed
+    RolledBack bool `json:"rolledBack,omitempty"`
+}
+
+// batchRecorder - captures the response of an operation run through the checks and
+// handlers of the individual endpoints
+type batchRecorder struct {
+    header http.Header
+    status int
+    body   bytes.Buffer
+}
+
+func newBatchRecorder() *batchRecorder {
+    return &batchRecorder{header: make(http.Header)}
+}
+
+func (rec *batchRecorder) Header() http.Header {
+    return rec.header
+}
+
+func (rec *batchRecorder) Write(b []byte) (int, error) {
+    if rec.status == 0 {
+        rec.status = http.StatusOK
+    }
+    return rec.body.Write(b)
+}
+
+func (rec *batchRecorder) WriteHeader(status int) {
+    if rec.status == 0 {
+        rec.status = status
+    }
+}
+
+// failed - reports whether the recorded response is an error
+func (rec *batchRecorder) failed() bool {
+    return rec.status >= http.StatusBadRequest
+}
+
+// message - returns the error message of the recorded response, plain or JSON
+func (rec *batchRecorder) message() string {
+    var result struct {
+        Error string `json:"error"`
+    }
+    if strings.HasPrefix(rec.header.Get("Content-Type"), "application/json") && json.Unmarshal(rec.body.Bytes(), &result) == nil {
+        return result.Error
+    }
+    return strings.TrimSpace(rec.body.String())
+}
+
+// batchSubRequest - copy of the batch request posting the body to a single-operation
+// handler; the user set by the authentication middleware is kept
+func batchSubRequest(r *http.Request, contentType, body string) *http.Request {
+    sub := r.Clone(r.Context())
+    sub.Method = "POST"
+    sub.URL.RawQuery = ""
+    sub.Body = io.NopCloser(strings.NewReader(body))
+    sub.ContentLength = int64(len(body))
+    sub.Header.Set("Content-Type", contentType)
+    sub.Header.Set("Accept", "application/json")
+    sub.Form, sub.PostForm, sub.MultipartForm = nil, nil, nil
+    return sub
+}
+
+// batchHandler - runs a JSON array of delete, move, copy and mkdir operations in order
+// and reports the result of each. With transactional=1 the first failure stops the
+// batch and the operations done so far are undone as far as possible.
+func batchHandler(w http.ResponseWriter, r *http.Request) {
+    clientIP := r.RemoteAddr
+    user := r.Header.Get("X-User")
+    if r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var operations []batchOperation
+    if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&operations); err != nil {
+        http.Error(w, "Invalid JSON body: expected an array of operations", http.StatusBadRequest)
+        return
+    }
+    if len(operations) == 0 {
+        http.Error(w, "No operations", http.StatusBadRequest)
+        return
+    }
+    if len(operations) > maxBatchOperations {
+        http.Error(w, fmt.Sprintf("Too many operations (at most %d)", maxBatchOperations), http.StatusRequestEntityTooLarge)
+        return
+    }
+    transactional := r.URL.Query().Get("transactional") == "1"
+
+    // Deleted items of a transactional batch are kept as hard links until it succeeds
+    var staging string
+    defer func() {
+        if staging != "" {
+            os.RemoveAll(staging)
+        }
+    }()
+
+    results := make([]batchResult, len(operations))
+    undo := make([]func() error, len(operations))
+    failed := -1
+    for i, op := range operations {
+        results[i] = batchResult{Op: op.Op, Path: op.Path, To: op.To}
+        if failed >= 0 && transactional {
+            results[i].Skipped = true
+            continue
+        }
+        if transactional && op.Op == batchDelete && staging == "" {
+            var err error
+            if staging, err = newBatchStaging(); err != nil {
+                http.Error(w, "Error preparing the batch", http.StatusInternalServerError)
+                logger.Logger.Errorf("Error creating batch staging directory: %v from IP: %s, User: %s", err, clientIP, logger.User(user))
+                return
+            }
+        }
+        rec := newBatchRecorder()
+        undo[i] = runBatchOperation(rec, r, user, op, staging, i)
+        results[i].Status = rec.status
+        if rec.failed() {
+            results[i].Error = rec.message()
+            undo[i] = nil
+            if failed < 0 {
+                failed = i
+            }
+        }
+    }
+
+    rolledBack := false
+    if failed >= 0 && transactional {
+        rolledBack = true
+        for i := failed - 1; i >= 0; i-- {
+            if undo[i] == nil {
+                continue
+            }
+            if err := undo[i](); err != nil {
+                rolledBack = false
+                logger.Logger.Errorf("Error rolling back batch %s of %s: %v from IP: %s, User: %s", operations[i].Op, operations[i].Path, err, clientIP, logger.User(user))
+                continue
+            }
+            results[i].RolledBack = true
+        }
+    }
+
+    summary := struct {
+        Results    []batchResult `json:"results"`
+        Succeeded  int           `json:"succeeded"`
+        Failed     int           `json:"failed"`
+        Skipped    int           `json:"skipped"`
+        RolledBack bool          `json:"rolledBack,omitempty"`
+    }{Results: results, RolledBack: rolledBack}
+    for _, result := range results {
+        switch {
+        case result.Skipped:
+            summary.Skipped++
+        case result.Error != "":
+            summary.Failed++
+        case !result.RolledBack:
+            summary.Succeeded++
+        }
+    }
+    logger.WithRequest(r).Infof("Batch of %d operations: %d succeeded, %d failed, %d skipped by IP: %s, User: %s", len(operations), summary.Succeeded, summary.Failed, summary.Skipped, clientIP, logger.User(user))
+
+    status := http.StatusOK
+    if failed >= 0 {
+        status = http.StatusMultiStatus
+    }
+    pkg.RenderJSON(w, status, summary)
+}
+
+// newBatchStaging - creates the directory keeping the deleted items of a transactional
+// batch until it ends. It is created next to base_dir, outside of the served tree, and
+// must be on the filesystem of base_dir since the items are hard linked into it and
+// renamed back on rollback.
+func newBatchStaging() (string, error) {
+    absBase, err := filepath.Abs(baseDir)
+    if err != nil {
+        return "", err
+    }
+    parent := filepath.Dir(absBase)
+    if parent == absBase {
+        return "", errors.New("base_dir has no parent directory for the batch staging directory")
+    }
+    staging, err := os.MkdirTemp(parent, ".sfs-batch-")
+    if err != nil {
+        return "", err
+    }
+    var baseStat, stagingStat syscall.Stat_t
+    if err := syscall.Stat(absBase, &baseStat); err != nil {
+        os.Remove(staging)
+        return "", err
+    }
+    if err := syscall.Stat(staging, &stagingStat); err != nil || stagingStat.Dev != baseStat.Dev {
+        os.Remove(staging)
+        return "", fmt.Errorf("%s is not on the filesystem of base_dir", parent)
+    }
+    return staging, nil
+}
+
+// runBatchOperation - runs an operation of a batch, writing its outcome to rec, and
+// returns the function undoing it. Deletions and folder creations go through the
+// handlers of /delete and /create-folder.
+func runBatchOperation(rec *batchRecorder, r *http.Request, user string, op batchOperation, staging string, index int) func() error {
+    switch op.Op {
+    case batchMkdir:
+        created := path.Clean("/" + op.Path)
+        body, _ := json.Marshal(map[string]string{"currentPath": path.Dir(created), "folderName": path.Base(created)})
+        createFolderHandler(rec, batchSubRequest(r, "application/json", string(body)))
+        return func() error {
+            defer search.Update(created)
+            return os.Remove(filepath.Join(baseDir, filepath.FromSlash(created)))
+        }
+    case batchDelete:
+        fullPath, ok := resolvePath(rec, r, op.Path)
+        if !ok {
+            return nil
+        }
+        // A hard linked copy restores the item on rollback
+        var backup string
+        if staging != "" {
+            if _, err := os.Lstat(fullPath); err == nil {
+                backup = filepath.Join(staging, strconv.Itoa(index))
+                // The links are only ever renamed back, so their targets are kept as they are
+                if err := pkg.CopyTree(fullPath, backup, true, baseDir, pkg.SymlinkFollow); err != nil {
+                    http.Error(rec, "Error preparing deletion", http.StatusInternalServerError)
+                    logger.Logger.Errorf("Error staging %s for deletion: %v from IP: %s, User: %s", fullPath, err, r.RemoteAddr, logger.User(user))
+                    return nil
+                }
+            }
+        }
+        form := url.Values{"items": {op.Path}, "currentPath": {"/"}}
+        if op.Force {
+            form.Set("force", "1")
+        }
+        deleteHandler(rec, batchSubRequest(r, "application/x-www-form-urlencoded", form.Encode()))
+        if rec.status == http.StatusSeeOther {
+            rec.status = http.StatusOK
+        }
+        if backup == "" {
+            return nil
+        }
+        return func() error {
+            defer search.Update(op.Path)
+            return os.Rename(backup, fullPath)
+        }
+    case batchMove, batchCopy:
+        return batchTransfer(rec, r, user, op)
+    }
+    http.Error(rec, "Unknown operation (expected delete, move, copy or mkdir)", http.StatusBadRequest)
+    return nil
+}
+
+// batchTransfer - moves or copies an item of a batch to its new path, which must not
+// exist, and returns the function undoing it
+func batchTransfer(rec *batchRecorder, r *http.Request, user string, op batchOperation) func() error {
+    if op.To == "" {
+        http.Error(rec, "Destination is required", http.StatusBadRequest)
+        return nil
+    }
+    srcPath, ok := resolvePath(rec, r, op.Path)
+    if !ok {
+        return nil
+    }
+    if _, ok := resolvePath(rec, r, op.To); !ok {
+        return nil
+    }
+    from, to := path.Clean("/"+op.Path), path.Clean("/"+op.To)
+    if from == "/" || to == "/" || from == to || strings.HasPrefix(to, from+"/") {
+        http.Error(rec, "Invalid destination", http.StatusBadRequest)
+        return nil
+    }
+    if !authorizeShare(rec, r, user, from, to) {
+        return nil
+    }
+    dstPath, ok := safeDestination(rec, r, user, to)
+    if !ok {
+        return nil
+    }
+    if op.Op == batchMove && !authorizeWrite(rec, r, user, share.OpDelete, true, from, srcPath) {
+        return nil
+    }
+    if !authorizeWrite(rec, r, user, share.OpUpload, false, to, dstPath) {
+        return nil
+    }
+    if _, err := os.Lstat(srcPath); err != nil {
+        http.Error(rec, "Not found: "+from, http.StatusNotFound)
+        return nil
+    }
+    if _, err := os.Lstat(dstPath); err == nil {
+        http.Error(rec, "An entry with the same name already exists: "+to, http.StatusConflict)
+        return nil
+    }
+
+    if op.Op == batchCopy {
+        err := pkg.CopyTree(srcPath, dstPath, false, baseDir, config.WebServer.SymlinkPolicy)
+        if errors.Is(err, pkg.ErrSymlinkEscape) || errors.Is(err, pkg.ErrSymlinkDenied) {
+            denyPath(rec, r, http.StatusForbidden, "Forbidden: the item contains a symlink that cannot be copied: "+err.Error())
+            logger.WithRequest(r).Warnf("Copy of %s to %s rejected: %v from IP: %s, User: %s", srcPath, dstPath, err, r.RemoteAddr, logger.User(user))
+            return nil
+        }
+        if err != nil {
+            http.Error(rec, "Error copying item", http.StatusInternalServerError)
+            logger.Logger.Errorf("Error copying %s to %s: %v from IP: %s, User: %s", srcPath, dstPath, err, r.RemoteAddr, logger.User(user))
+            return nil
+        }
+        rec.WriteHeader(http.StatusCreated)
+        logger.WithRequest(r).Infof("Item copied: %s to %s by IP: %s, User: %s", srcPath, dstPath, r.RemoteAddr, logger.User(user))
+        search.Update(to)
+        return func() error {
+            defer search.Update(to)
+            return os.RemoveAll(dstPath)
+        }
+    }
+
+    if err := os.Rename(srcPath, dstPath); err != nil {
+        http.Error(rec, "Error moving item", http.StatusInternalServerError)
+        logger.Logger.Errorf("Error moving %s to %s: %v from IP: %s, User: %s", srcPath, dstPath, err, r.RemoteAddr, logger.User(user))
+        return nil
+    }
+    moveMetadata(from, to)
+    rec.WriteHeader(http.StatusOK)
+    logger.WithRequest(r).Infof("Item moved: %s to %s by IP: %s, User: %s", srcPath, dstPath, r.RemoteAddr, logger.User(user))
+    events.Publish(events.Event{Type: events.TypeRename, Path: from, To: to, User: user})
+    search.Update(from, to)
+    return func() error {
+        if err := os.Rename(dstPath, srcPath); err != nil {
+            return err
+        }
+        moveMetadata(to, from)
+        search.Update(to, from)
+        return nil
+    }
+}
+
+// moveMetadata - carries the description and tags of a moved item over to its new path
+func moveMetadata(from, to string) {
+    if config.WebServer.Descriptions {
+        fromDir := filepath.Join(baseDir, filepath.FromSlash(path.Dir(from)))
+        if descs, err := descriptions.Load(fromDir); err == nil && descs[path.Base(from)] != "" {
+            toDir := filepath.Join(baseDir, filepath.FromSlash(path.Dir(to)))
+            if err := descriptions.Set(toDir, path.Base(to), descs[path.Base(from)]); err != nil {
+                logger.Logger.Warnf("Error moving description of %s: %v", from, err)
+            } else if err := descriptions.Set(fromDir, path.Base(from), ""); err != nil {
+                logger.Logger.Warnf("Error removing description of %s: %v", from, err)
+            }
+        }
+    }
+    if config.Tags.Enabled {
+        if err := tags.Rename(from, to); err != nil {
+            logger.Logger.Warnf("Error moving tags of %s: %v", from, err)
+        }
+    }
+}
+
+// adminConfigHandler - returns the effective configuration with sensitive fields redacted
+func adminConfigHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != "GET" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    pkg.RenderJSON(w, http.StatusOK, config.Redacted())
+    logger.WithRequest(r).Infof("Configuration requested by IP: %s, User: %s", r.RemoteAddr, logger.User(r.Header.Get("X-User")))
+}
+
+// adminStatusHandler - returns runtime counters of the server
+func adminStatusHandler(w http.ResponseWriter, r *http.Request) {
+    status := struct {
+        ActiveDownloads int64  `json:"activeDownloads"`
+        TotalDownloads  int64  `json:"totalDownloads"`
+        Uptime          string `json:"uptime"`
+    }{
+        ActiveDownloads: metrics.ActiveDownloads(),
+        TotalDownloads:  metrics.TotalDownloads(),
+        Uptime:          metrics.Uptime().Round(time.Second).String(),
+    }
+    pkg.RenderJSON(w, http.StatusOK, status)
+}
+
 // logAndRemoveAll - recursive function to log and remove all files and directories
 func logAndRemoveAll(path, clientIP, user string) error {
-    info, err := os.Stat(path)
+    info, err := os.Lstat(path)
     if err != nil {
+        if os.IsNotExist(err) {
+            // Already removed by a concurrent request
+            return nil
+        }
         return err
     }
 
@@ -473,6 +4305,6 @@ func logAndRemoveAll(path, clientIP, user string) error {
         }
     }
 
-    logger.Logger.Infof("Deleting: %s by IP: %s, User: %s", path, clientIP, user)
+    logger.Logger.Infof("Deleting: %s by IP: %s, User: %s", path, clientIP, logger.User(user))
     return os.RemoveAll(path)
 }
\ No newline at end of file
@@ -0,0 +1,3267 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/auth"
+	"simple_file_server/pkg/dirsize"
+	"simple_file_server/pkg/events"
+	"simple_file_server/pkg/extract"
+	"simple_file_server/pkg/logger"
+	"simple_file_server/pkg/search"
+	"simple_file_server/pkg/webhook"
+
+	"github.com/sirupsen/logrus"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPassword - password of every user in the htpasswd file of the test server
+const testPassword = "password"
+
+// testUsers - users of the test server; admin is also its administrator
+var testUsers = []string{"alice", "bob", "admin"}
+
+func TestMain(m *testing.M) {
+	logger.Logger = logrus.New()
+	logger.Logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestServer - configures the server for a temporary base directory and returns its
+// routes. The users of testUsers log in through an htpasswd file; configure, when set,
+// adjusts the configuration before it is applied.
+func newTestServer(t *testing.T, configure func(cfg *pkg.Config)) http.Handler {
+	t.Helper()
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base")
+	if err := os.Mkdir(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha1.Sum([]byte(testPassword))
+	var htpasswd strings.Builder
+	for _, user := range testUsers {
+		htpasswd.WriteString(user + ":{SHA}" + base64.StdEncoding.EncodeToString(sum[:]) + "\n")
+	}
+	htpasswdFile := filepath.Join(dir, "htpasswd")
+	writeFile(t, htpasswdFile, htpasswd.String())
+
+	config = pkg.Config{}
+	config.WebServer.BaseDir = base
+	config.Auth.HtpasswdFile = htpasswdFile
+	config.Auth.Backends = []string{auth.BackendHtpasswd}
+	config.Auth.Admins = []string{"admin"}
+	if configure != nil {
+		configure(&config)
+	}
+	applyConfig()
+	t.Cleanup(func() {
+		for _, user := range testUsers {
+			auth.RevokeUserSessions(user)
+		}
+	})
+	return newHandler()
+}
+
+// writeFile - creates the file and its parent directories with the given content
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve - sends the request through the handler, with the session cookie when set
+func serve(h http.Handler, r *http.Request, session *http.Cookie) *httptest.ResponseRecorder {
+	if session != nil {
+		r.AddCookie(session)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// get - sends a GET request for the target
+func get(h http.Handler, target string, session *http.Cookie) *httptest.ResponseRecorder {
+	return serve(h, httptest.NewRequest("GET", target, nil), session)
+}
+
+// postForm - sends a url-encoded POST request with the form
+func postForm(h http.Handler, target string, form url.Values, session *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return serve(h, r, session)
+}
+
+// multipartBody - encodes the form fields and the files, keyed by file name, under the
+// default upload field and returns the body with its content type
+func multipartBody(t *testing.T, fields map[string]string, files map[string]string) ([]byte, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := mw.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		part, err := mw.CreateFormFile("uploadFiles", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(part, content)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return body.Bytes(), mw.FormDataContentType()
+}
+
+// postFiles - sends a multipart POST request with the form fields and the files
+func postFiles(t *testing.T, h http.Handler, target string, fields map[string]string, files map[string]string, session *http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	body, contentType := multipartBody(t, fields, files)
+	r := httptest.NewRequest("POST", target, bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	return serve(h, r, session)
+}
+
+// login - logs the user in and returns the session cookie
+func login(t *testing.T, h http.Handler, user string) *http.Cookie {
+	t.Helper()
+	w := postForm(h, "/login", url.Values{"username": {user}, "password": {testPassword}}, nil)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("login of %s: status %d, want %d", user, w.Code, http.StatusSeeOther)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == auth.SessionCookieName {
+			return cookie
+		}
+	}
+	t.Fatalf("login of %s set no session cookie", user)
+	return nil
+}
+
+func TestSessionDuration(t *testing.T) {
+	for _, tt := range []struct {
+		configured, want time.Duration
+	}{{0, 24 * time.Hour}, {time.Hour, time.Hour}} {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.Auth.SessionDuration = tt.configured
+		})
+		if config.Auth.SessionDuration != tt.want {
+			t.Errorf("session_duration %s: effective %s, want %s", tt.configured, config.Auth.SessionDuration, tt.want)
+		}
+		cookie := login(t, h, "alice")
+		if d := time.Until(cookie.Expires) - tt.want; d < -2*time.Second || d > 2*time.Second {
+			t.Errorf("session_duration %s: cookie expires %s, want about %s from now", tt.configured, cookie.Expires, tt.want)
+		}
+	}
+
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Auth.SessionDuration = 100 * time.Millisecond
+	})
+	cookie := login(t, h, "alice")
+	if w := get(h, "/check-session", cookie); w.Code != http.StatusOK {
+		t.Fatalf("fresh session: status %d, want %d", w.Code, http.StatusOK)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if w := get(h, "/check-session", cookie); w.Code != http.StatusUnauthorized {
+		t.Errorf("expired session: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLoginIssuesDistinctSessions(t *testing.T) {
+	h := newTestServer(t, nil)
+
+	first := login(t, h, "alice")
+	second := login(t, h, "alice")
+	if first.Value == second.Value {
+		t.Fatalf("two logins share the session token %s", first.Value)
+	}
+	for _, cookie := range []*http.Cookie{first, second} {
+		if len(cookie.Value) != 64 {
+			t.Errorf("session token %q has length %d, want 64", cookie.Value, len(cookie.Value))
+		}
+		if w := get(h, "/check-session", cookie); w.Code != http.StatusOK {
+			t.Errorf("check-session with %s: status %d, want %d", cookie.Value, w.Code, http.StatusOK)
+		}
+	}
+
+	w := postForm(h, "/login", url.Values{"username": {"alice"}, "password": {"wrong"}}, nil)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == auth.SessionCookieName && cookie.Value != "" {
+			t.Errorf("failed login set the session cookie %s", cookie.Value)
+		}
+	}
+}
+
+func TestUploadNotifiesWebhook(t *testing.T) {
+	type delivery struct {
+		body      []byte
+		signature string
+	}
+	received := make(chan delivery, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- delivery{body: body, signature: r.Header.Get(webhook.SignatureHeader)}
+	}))
+	defer hook.Close()
+
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.Secret = "webhook-secret"
+		cfg.Webhook.URL = hook.URL
+	})
+	session := login(t, h, "alice")
+	if err := os.Mkdir(filepath.Join(baseDir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := postFiles(t, h, "/upload", map[string]string{"currentPath": "/docs"}, map[string]string{"a.txt": "hello"}, session)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("upload: status %d, want %d: %s", w.Code, http.StatusSeeOther, w.Body)
+	}
+
+	select {
+	case got := <-received:
+		var event webhook.UploadEvent
+		if err := json.Unmarshal(got.body, &event); err != nil {
+			t.Fatalf("webhook payload %s: %v", got.body, err)
+		}
+		if event.Path != "/docs/a.txt" || event.User != "alice" || event.Size != 5 {
+			t.Errorf("webhook event %+v, want /docs/a.txt of alice with 5 bytes", event)
+		}
+		if want := webhook.Sign(got.body, "webhook-secret"); got.signature != want {
+			t.Errorf("webhook signature %q, want %q", got.signature, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called after the upload")
+	}
+}
+
+func TestAdminConfigIsRedacted(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.Secret = "server-secret"
+	})
+
+	if w := get(h, "/admin/config", login(t, h, "alice")); w.Code != http.StatusForbidden {
+		t.Errorf("config for a non-admin: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	w := get(h, "/admin/config", login(t, h, "admin"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("config for the admin: status %d, want %d", w.Code, http.StatusOK)
+	}
+	if strings.Contains(w.Body.String(), "server-secret") {
+		t.Errorf("config response exposes the secret: %s", w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "[REDACTED]") {
+		t.Errorf("config response does not mask the secret: %s", w.Body)
+	}
+	if config.WebServer.Secret != "server-secret" {
+		t.Errorf("redaction changed the live secret to %q", config.WebServer.Secret)
+	}
+}
+
+// listing - fetches the JSON listing of the target
+func listing(t *testing.T, h http.Handler, target string, session *http.Cookie) pkg.ListingResponse {
+	t.Helper()
+	r := httptest.NewRequest("GET", target, nil)
+	r.Header.Set("Accept", "application/json")
+	w := serve(h, r, session)
+	if w.Code != http.StatusOK {
+		t.Fatalf("listing of %s: status %d, want %d: %s", target, w.Code, http.StatusOK, w.Body)
+	}
+	var response pkg.ListingResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("listing of %s: %v", target, err)
+	}
+	return response
+}
+
+// entryNames - names of the listing entries in order
+func entryNames(entries []pkg.ListingEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func TestListingFiltersByExtension(t *testing.T) {
+	h := newTestServer(t, nil)
+	for _, name := range []string{"a.pdf", "b.TXT", "c.go", "sub/d.pdf"} {
+		writeFile(t, filepath.Join(baseDir, name), "x")
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"sub", "a.pdf", "b.TXT", "c.go"}},
+		{"?ext=pdf,txt", []string{"sub", "a.pdf", "b.TXT"}},
+		{"?ext=.PDF&dirs=0", []string{"a.pdf"}},
+	}
+	for _, tt := range tests {
+		got := entryNames(listing(t, h, "/"+tt.query, nil).Entries)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("listing %q: %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestListingPrefixAutocomplete(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.AutocompleteLimit = 2
+	})
+	for _, name := range []string{"doc1.txt", "Doc2.txt", "doc3.txt", "notes.txt"} {
+		writeFile(t, filepath.Join(baseDir, name), "x")
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"?prefix=doc", 2},
+		{"?prefix=Doc", 1},
+		{"?prefix=DOC&ignore_case=1", 2},
+		{"?prefix=zzz", 0},
+	}
+	for _, tt := range tests {
+		got := listing(t, h, "/"+tt.query, nil).Entries
+		if len(got) != tt.want {
+			t.Errorf("autocomplete %q: %v, want %d entries", tt.query, entryNames(got), tt.want)
+		}
+		for _, entry := range got {
+			if !strings.HasPrefix(strings.ToLower(entry.Name), "doc") {
+				t.Errorf("autocomplete %q returned %s", tt.query, entry.Name)
+			}
+		}
+	}
+}
+
+func TestStaticDirectoryListing(t *testing.T) {
+	tests := []struct {
+		listing bool
+		want    int
+	}{
+		{false, http.StatusNotFound},
+		{true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.WebServer.StaticListing = tt.listing
+		})
+		if w := get(h, "/static/css/", nil); w.Code != tt.want {
+			t.Errorf("static listing %t: status %d, want %d", tt.listing, w.Code, tt.want)
+		}
+		if w := get(h, "/static/css/materialize.min.css", nil); w.Code != http.StatusOK {
+			t.Errorf("static listing %t: file status %d, want %d", tt.listing, w.Code, http.StatusOK)
+		}
+	}
+}
+
+// postJSON - sends a POST request with the JSON body
+func postJSON(h http.Handler, target, body string, session *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "application/json")
+	return serve(h, r, session)
+}
+
+func TestCreateTree(t *testing.T) {
+	h := newTestServer(t, nil)
+	session := login(t, h, "alice")
+	if err := os.MkdirAll(filepath.Join(baseDir, "proj", "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := postJSON(h, "/create-tree", `{"path":"/proj","tree":[{"name":"src","children":[{"name":"lib"}]},{"name":"docs"}]}`, session)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create-tree: status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var result struct {
+		Created  []string `json:"created"`
+		Existing []string `json:"existing"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(result.Created, ","); got != "/proj/src/lib,/proj/docs" {
+		t.Errorf("created %s, want /proj/src/lib,/proj/docs", got)
+	}
+	if got := strings.Join(result.Existing, ","); got != "/proj/src" {
+		t.Errorf("existing %s, want /proj/src", got)
+	}
+	if info, err := os.Stat(filepath.Join(baseDir, "proj", "src", "lib")); err != nil || !info.IsDir() {
+		t.Errorf("nested folder was not created: %v", err)
+	}
+
+	for _, body := range []string{
+		`{"path":"/proj","tree":[{"name":".."}]}`,
+		`{"path":"/proj","tree":[{"name":"a/b"}]}`,
+		`{"path":"/../outside","tree":[{"name":"x"}]}`,
+		`not json`,
+	} {
+		if w := postJSON(h, "/create-tree", body, session); w.Code != http.StatusBadRequest {
+			t.Errorf("create-tree %s: status %d, want %d", body, w.Code, http.StatusBadRequest)
+		}
+	}
+	if w := postJSON(h, "/create-tree", `{"path":"/","tree":[{"name":"x"}]}`, nil); w.Code != http.StatusSeeOther {
+		t.Errorf("create-tree without a session: status %d, want %d", w.Code, http.StatusSeeOther)
+	}
+}
+
+func TestDeleteMissingItem(t *testing.T) {
+	h := newTestServer(t, nil)
+	session := login(t, h, "alice")
+	writeFile(t, filepath.Join(baseDir, "gone.txt"), "x")
+
+	for i := 0; i < 2; i++ {
+		w := postForm(h, "/delete", url.Values{"items": {"/gone.txt"}, "currentPath": {"/"}}, session)
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("delete %d: status %d, want %d: %s", i+1, w.Code, http.StatusSeeOther, w.Body)
+		}
+	}
+	if w := get(h, "/gone.txt", nil); w.Code != http.StatusNotFound {
+		t.Errorf("deleted file: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+// getJSON - fetches the target as JSON and decodes it into v
+func getJSON(t *testing.T, h http.Handler, target string, session *http.Cookie, v any) {
+	t.Helper()
+	r := httptest.NewRequest("GET", target, nil)
+	r.Header.Set("Accept", "application/json")
+	w := serve(h, r, session)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status %d, want %d: %s", target, w.Code, http.StatusOK, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("%s: %v: %s", target, err, w.Body)
+	}
+}
+
+func TestRecentUploadsFeed(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Recent.Size = 2
+	})
+	session := login(t, h, "alice")
+	for _, name := range []string{"one.txt", "two.txt", "three.txt"} {
+		if w := postFiles(t, h, "/upload", map[string]string{"currentPath": "/"}, map[string]string{name: name}, session); w.Code != http.StatusSeeOther {
+			t.Fatalf("upload of %s: status %d: %s", name, w.Code, w.Body)
+		}
+	}
+
+	var feed []struct {
+		Path string `json:"path"`
+		User string `json:"user"`
+		URL  string `json:"url"`
+	}
+	getJSON(t, h, "/recent", nil, &feed)
+	if len(feed) != 2 || feed[0].Path != "/three.txt" || feed[1].Path != "/two.txt" {
+		t.Fatalf("recent feed %+v, want /three.txt and /two.txt", feed)
+	}
+	if feed[0].User != "alice" || !strings.HasSuffix(feed[0].URL, "/three.txt") {
+		t.Errorf("recent entry %+v, want the upload of alice with its URL", feed[0])
+	}
+
+	getJSON(t, h, "/recent?n=1", nil, &feed)
+	if len(feed) != 1 {
+		t.Errorf("recent feed with n=1 has %d entries", len(feed))
+	}
+	if w := get(h, "/recent", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "three.txt") {
+		t.Errorf("recent page: status %d, missing the last upload", w.Code)
+	}
+}
+
+func TestUploadFieldName(t *testing.T) {
+	tests := []struct {
+		field    string
+		anyField bool
+		want     int
+	}{
+		{"", false, http.StatusSeeOther},
+		{"documents", false, http.StatusBadRequest},
+		{"documents", true, http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.Upload.FieldName = tt.field
+			cfg.Upload.AnyField = tt.anyField
+		})
+		session := login(t, h, "alice")
+		w := postFiles(t, h, "/upload", map[string]string{"currentPath": "/"}, map[string]string{"a.txt": "a", "b.txt": "b"}, session)
+		if w.Code != tt.want {
+			t.Errorf("field %q, any %t: status %d, want %d: %s", tt.field, tt.anyField, w.Code, tt.want, w.Body)
+			continue
+		}
+		if tt.want != http.StatusSeeOther {
+			continue
+		}
+		for _, name := range []string{"a.txt", "b.txt"} {
+			if _, err := os.Stat(filepath.Join(baseDir, name)); err != nil {
+				t.Errorf("field %q, any %t: %s was not saved: %v", tt.field, tt.anyField, name, err)
+			}
+		}
+	}
+}
+
+func TestDirectoryWithoutTrailingSlash(t *testing.T) {
+	h := newTestServer(t, nil)
+	writeFile(t, filepath.Join(baseDir, "docs", "a.txt"), "x")
+
+	if got := entryNames(listing(t, h, "/docs", nil).Entries); strings.Join(got, ",") != "a.txt" {
+		t.Errorf("JSON listing of /docs: %v, want [a.txt]", got)
+	}
+	w := get(h, "/docs", nil)
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/docs/" {
+		t.Errorf("HTML request for /docs: status %d to %q, want %d to /docs/", w.Code, w.Header().Get("Location"), http.StatusMovedPermanently)
+	}
+}
+
+func TestCreateFolderCaseInsensitive(t *testing.T) {
+	for _, sensitive := range []bool{true, false} {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.WebServer.FilesystemCaseSensitive = &sensitive
+		})
+		session := login(t, h, "alice")
+		if err := os.Mkdir(filepath.Join(baseDir, "docs"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		want := http.StatusCreated
+		if !sensitive {
+			want = http.StatusConflict
+		}
+		w := postJSON(h, "/create-folder", `{"currentPath":"/","folderName":"Docs"}`, session)
+		if w.Code != want {
+			t.Errorf("case sensitive %t: status %d, want %d: %s", sensitive, w.Code, want, w.Body)
+		}
+		if w := postJSON(h, "/create-folder", `{"currentPath":"/","folderName":"docs"}`, session); w.Code != http.StatusConflict {
+			t.Errorf("case sensitive %t: existing folder: status %d, want %d", sensitive, w.Code, http.StatusConflict)
+		}
+	}
+}
+
+func TestUploadDiskFull(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full to simulate a full disk")
+	}
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.SymlinkPolicy = pkg.SymlinkFollow
+	})
+	session := login(t, h, "alice")
+	// Writes through the link fail with ENOSPC
+	if err := os.Symlink("/dev/full", filepath.Join(baseDir, "full.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := postFiles(t, h, "/upload", map[string]string{"currentPath": "/"}, map[string]string{"full.txt": "data"}, session)
+	if w.Code != http.StatusInsufficientStorage {
+		t.Fatalf("upload to a full disk: status %d, want %d: %s", w.Code, http.StatusInsufficientStorage, w.Body)
+	}
+	if _, err := os.Lstat(filepath.Join(baseDir, "full.txt")); !os.IsNotExist(err) {
+		t.Errorf("partial upload was left behind: %v", err)
+	}
+}
+
+func TestArchiveMetadata(t *testing.T) {
+	for _, ownership := range []bool{false, true} {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.Archive.IncludeOwnership = ownership
+		})
+		script := filepath.Join(baseDir, "run.sh")
+		writeFile(t, script, "#!/bin/sh\n")
+		writeFile(t, filepath.Join(baseDir, "notes.txt"), "x")
+		modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		if err := os.Chmod(script, 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(script, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		var stat syscall.Stat_t
+		if err := syscall.Stat(script, &stat); err != nil {
+			t.Fatal(err)
+		}
+
+		w := get(h, "/download?items=/run.sh&items=/notes.txt&format=zip", nil)
+		zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		if err != nil {
+			t.Fatalf("zip download: %v", err)
+		}
+		for _, file := range zr.File {
+			if file.Name != "run.sh" {
+				continue
+			}
+			if file.Mode().Perm() != 0750 || !file.Modified.Equal(modified) {
+				t.Errorf("zip entry: mode %v modified %v, want -rwxr-x--- %v", file.Mode(), file.Modified, modified)
+			}
+		}
+
+		w = get(h, "/download?items=/run.sh&items=/notes.txt&format=tar.gz", nil)
+		gz, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatalf("tar.gz download: %v", err)
+		}
+		tr := tar.NewReader(gz)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Name != "run.sh" {
+				continue
+			}
+			if header.Mode&0777 != 0750 || !header.ModTime.Equal(modified) {
+				t.Errorf("tar entry: mode %o modified %v, want 750 %v", header.Mode, header.ModTime, modified)
+			}
+			switch {
+			case !ownership && (header.Uid != 0 || header.Gid != 0 || header.Uname != "" || header.Gname != ""):
+				t.Errorf("tar entry keeps the owner %d:%d (%s:%s) without include_ownership", header.Uid, header.Gid, header.Uname, header.Gname)
+			case ownership && (header.Uid != int(stat.Uid) || header.Gid != int(stat.Gid)):
+				t.Errorf("tar entry owner %d:%d, want %d:%d", header.Uid, header.Gid, stat.Uid, stat.Gid)
+			}
+		}
+	}
+}
+
+func TestLoginFailureDelay(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Auth.FailureDelay = delay
+	})
+
+	started := time.Now()
+	login(t, h, "alice")
+	if elapsed := time.Since(started); elapsed >= delay {
+		t.Errorf("successful login took %s, want no delay", elapsed)
+	}
+
+	// Parallel attempts from one client are each delayed, none is refused
+	const attempts = 4
+	var wg sync.WaitGroup
+	codes := make([]int, attempts)
+	started = time.Now()
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := postForm(h, "/login", url.Values{"username": {"alice"}, "password": {"wrong"}}, nil)
+			codes[i] = w.Code
+		}(i)
+	}
+	wg.Wait()
+	if elapsed := time.Since(started); elapsed < delay {
+		t.Errorf("failed logins took %s, want at least %s", elapsed, delay)
+	}
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("failed login %d: status %d, want %d", i+1, code, http.StatusOK)
+		}
+	}
+}
+
+func TestPreviewTable(t *testing.T) {
+	h := newTestServer(t, nil)
+	writeFile(t, filepath.Join(baseDir, "people.csv"), "name;city\n\"Smith; J\";Oslo\nDoe;Rome\n")
+
+	w := get(h, "/people.csv?preview=1", nil)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("preview: status %d, type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, cell := range []string{"<th>name</th>", "<td>Smith; J</td>", "<td>Rome</td>"} {
+		if !strings.Contains(w.Body.String(), cell) {
+			t.Errorf("preview is missing %s", cell)
+		}
+	}
+	if w := get(h, "/people.csv", nil); strings.Contains(w.Body.String(), "<td>") {
+		t.Error("download without preview=1 was rendered as a table")
+	}
+}
+
+func TestDirectoryTemplate(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.WebServer.DirectoryTemplates = enabled
+		})
+		writeFile(t, filepath.Join(baseDir, "gallery", "a.jpg"), "x")
+		writeFile(t, filepath.Join(baseDir, "gallery", ".listing.html"), `<ul>{{range .Files}}<li class="custom">{{.Name}}</li>{{end}}</ul>`)
+
+		w := get(h, "/gallery/", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("directory templates %t: status %d", enabled, w.Code)
+		}
+		if got := strings.Contains(w.Body.String(), `<li class="custom">a.jpg</li>`); got != enabled {
+			t.Errorf("directory templates %t: custom listing rendered %t: %s", enabled, got, w.Body)
+		}
+	}
+}
+
+func TestManifestAndServiceWorker(t *testing.T) {
+	for _, worker := range []bool{false, true} {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.PWA.Name = "Team Files"
+			cfg.PWA.ServiceWorker = worker
+		})
+		var manifest map[string]any
+		getJSON(t, h, "/manifest.json", nil, &manifest)
+		if manifest["name"] != "Team Files" || manifest["start_url"] != "/" {
+			t.Errorf("manifest %v, want the configured name and start_url /", manifest)
+		}
+		if _, ok := manifest["service_worker"]; ok {
+			t.Error("manifest exposes the service_worker option")
+		}
+
+		want := http.StatusNotFound
+		if worker {
+			want = http.StatusOK
+		}
+		if w := get(h, "/sw.js", nil); w.Code != want {
+			t.Errorf("service worker %t: /sw.js status %d, want %d", worker, w.Code, want)
+		}
+	}
+}
+
+func TestDownloadCounters(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.Metrics = true
+	})
+	writeFile(t, filepath.Join(baseDir, "a.txt"), "a")
+	admin := login(t, h, "admin")
+
+	type status struct {
+		ActiveDownloads int64 `json:"activeDownloads"`
+		TotalDownloads  int64 `json:"totalDownloads"`
+	}
+	var before, after status
+	getJSON(t, h, "/admin/status", admin, &before)
+	for _, target := range []string{"/a.txt", "/download?items=/a.txt"} {
+		if w := get(h, target, nil); w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", target, w.Code)
+		}
+	}
+	getJSON(t, h, "/admin/status", admin, &after)
+	if after.TotalDownloads-before.TotalDownloads != 2 || after.ActiveDownloads != 0 {
+		t.Errorf("status after two downloads: %+v, before %+v", after, before)
+	}
+
+	w := get(h, "/metrics", nil)
+	if want := fmt.Sprintf("sfs_downloads_total %d\n", after.TotalDownloads); !strings.Contains(w.Body.String(), want) {
+		t.Errorf("metrics missing %q: %s", want, w.Body)
+	}
+	if w := get(h, "/admin/status", login(t, h, "alice")); w.Code != http.StatusForbidden {
+		t.Errorf("status for a non-admin: %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestUploadConcurrencyPerIP(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Upload.MaxConcurrentPerIP = 1
+		cfg.WebServer.TrustedProxies = []string{"192.0.2.1"}
+	})
+	session := login(t, h, "alice")
+	upload := func(client string, body io.Reader, size int64, contentType string) int {
+		r := httptest.NewRequest("POST", "/upload", body)
+		r.ContentLength = size
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set("X-Forwarded-For", client)
+		return serve(h, r, session).Code
+	}
+
+	// The first upload stays in progress until the rest of its body is sent
+	body, contentType := multipartBody(t, map[string]string{"currentPath": "/"}, map[string]string{"slow.txt": "slow"})
+	pr, pw := io.Pipe()
+	done := make(chan int)
+	go func() {
+		done <- upload("10.0.0.1", pr, int64(len(body)), contentType)
+	}()
+	if _, err := pw.Write(body[:10]); err != nil {
+		t.Fatal(err)
+	}
+
+	quick, contentType := multipartBody(t, map[string]string{"currentPath": "/"}, map[string]string{"quick.txt": "quick"})
+	if code := upload("10.0.0.1", bytes.NewReader(quick), int64(len(quick)), contentType); code != http.StatusTooManyRequests {
+		t.Errorf("second upload from the same client: status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := upload("10.0.0.2", bytes.NewReader(quick), int64(len(quick)), contentType); code != http.StatusSeeOther {
+		t.Errorf("upload from another client: status %d, want %d", code, http.StatusSeeOther)
+	}
+
+	pw.Write(body[10:])
+	pw.Close()
+	if code := <-done; code != http.StatusSeeOther {
+		t.Errorf("slow upload: status %d, want %d", code, http.StatusSeeOther)
+	}
+	if code := upload("10.0.0.1", bytes.NewReader(quick), int64(len(quick)), contentType); code != http.StatusSeeOther {
+		t.Errorf("upload after the first finished: status %d, want %d", code, http.StatusSeeOther)
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	h := newTestServer(t, nil)
+	alice := []*http.Cookie{login(t, h, "alice"), login(t, h, "alice")}
+	bob := login(t, h, "bob")
+	admin := login(t, h, "admin")
+
+	if w := postForm(h, "/admin/revoke-sessions", url.Values{"username": {"bob"}}, alice[0]); w.Code != http.StatusForbidden {
+		t.Errorf("revoke by a non-admin: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := postForm(h, "/admin/revoke-sessions", url.Values{}, admin); w.Code != http.StatusBadRequest {
+		t.Errorf("revoke without a user: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w := postForm(h, "/admin/revoke-sessions", url.Values{"username": {"alice"}}, admin)
+	var result struct {
+		Username string `json:"username"`
+		Revoked  int    `json:"revoked"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("revoke: status %d: %s", w.Code, w.Body)
+	}
+	if result.Username != "alice" || result.Revoked != 2 {
+		t.Errorf("revoke result %+v, want 2 sessions of alice", result)
+	}
+	for _, session := range alice {
+		if w := get(h, "/check-session", session); w.Code != http.StatusUnauthorized {
+			t.Errorf("revoked session: status %d, want %d", w.Code, http.StatusUnauthorized)
+		}
+	}
+	if w := get(h, "/check-session", bob); w.Code != http.StatusOK {
+		t.Errorf("session of another user: status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestCollapseSlashes(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.CollapseSlashes = true
+	})
+	writeFile(t, filepath.Join(baseDir, "docs", "reports", "a.txt"), "x")
+
+	redirects := map[string]string{
+		"//docs///reports":      "/docs/reports/",
+		"/docs//reports//a.txt": "/docs/reports/a.txt",
+		"//docs//?format=json":  "/docs/?format=json",
+	}
+	for target, want := range redirects {
+		w := get(h, target, nil)
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != want {
+			t.Errorf("GET %s: status %d to %q, want %d to %q", target, w.Code, w.Header().Get("Location"), http.StatusMovedPermanently, want)
+		}
+	}
+
+	session := login(t, h, "alice")
+	w := postForm(h, "//create-folder", url.Values{"currentPath": {"//docs//"}, "folderName": {"new"}}, session)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("POST //create-folder: status %d, want %d: %s", w.Code, http.StatusSeeOther, w.Body)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "docs", "new")); err != nil {
+		t.Errorf("folder was not created on the canonical path: %v", err)
+	}
+}
+
+func TestUnreadableDirectory(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	h := newTestServer(t, nil)
+	locked := filepath.Join(baseDir, "locked")
+	writeFile(t, filepath.Join(locked, "a.txt"), "x")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+
+	if w := get(h, "/locked/", nil); w.Code != http.StatusForbidden {
+		t.Errorf("unreadable directory: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestDescriptions(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.Descriptions = true
+	})
+	session := login(t, h, "alice")
+	writeFile(t, filepath.Join(baseDir, "docs", "report.pdf"), "x")
+
+	describe := func(description string) int {
+		return postForm(h, "/describe", url.Values{"currentPath": {"/docs"}, "name": {"report.pdf"}, "description": {description}}, session).Code
+	}
+	descriptionOf := func() string {
+		for _, entry := range listing(t, h, "/docs/", nil).Entries {
+			if entry.Name == "report.pdf" {
+				return entry.Description
+			}
+		}
+		t.Fatal("report.pdf is missing from the listing")
+		return ""
+	}
+
+	if code := describe("Quarterly report"); code >= 400 {
+		t.Fatalf("describe: status %d", code)
+	}
+	if got := descriptionOf(); got != "Quarterly report" {
+		t.Errorf("description %q, want %q", got, "Quarterly report")
+	}
+	if code := describe(strings.Repeat("x", 1001)); code != http.StatusBadRequest {
+		t.Errorf("description over the limit: status %d, want %d", code, http.StatusBadRequest)
+	}
+	for _, entry := range listing(t, h, "/docs/", nil).Entries {
+		if strings.HasPrefix(entry.Name, ".") {
+			t.Errorf("sidecar %s is listed", entry.Name)
+		}
+	}
+
+	// The description goes away with the file
+	postForm(h, "/delete", url.Values{"items": {"/docs/report.pdf"}, "currentPath": {"/docs"}}, session)
+	writeFile(t, filepath.Join(baseDir, "docs", "report.pdf"), "x")
+	if got := descriptionOf(); got != "" {
+		t.Errorf("description of a recreated file %q, want none", got)
+	}
+}
+
+func TestShareAuthentication(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Shares = []pkg.Share{
+			{Path: "/members", RequireAuth: true},
+			{Path: "/members/team", AllowedUsers: []string{"alice"}},
+		}
+	})
+	for _, name := range []string{"public/a.txt", "members/b.txt", "members/team/c.txt"} {
+		writeFile(t, filepath.Join(baseDir, name), "x")
+	}
+	sessions := map[string]*http.Cookie{"": nil, "alice": login(t, h, "alice"), "bob": login(t, h, "bob")}
+
+	tests := []struct {
+		target string
+		user   string
+		want   int
+	}{
+		{"/public/a.txt", "", http.StatusOK},
+		{"/members/b.txt", "", http.StatusSeeOther},
+		{"/members/b.txt", "bob", http.StatusOK},
+		{"/members/team/c.txt", "bob", http.StatusForbidden},
+		{"/members/team/c.txt", "alice", http.StatusOK},
+		{"/download?items=/members/team/c.txt", "bob", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if w := get(h, tt.target, sessions[tt.user]); w.Code != tt.want {
+			t.Errorf("GET %s as %q: status %d, want %d", tt.target, tt.user, w.Code, tt.want)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/members/", nil)
+	r.Header.Set("Accept", "application/json")
+	if w := serve(h, r, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous JSON listing of a share: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := postFiles(t, h, "/upload", map[string]string{"currentPath": "/members/team"}, map[string]string{"d.txt": "x"}, sessions["bob"]); w.Code != http.StatusForbidden {
+		t.Errorf("upload by a user outside the share: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestArchiveCompressionLevel(t *testing.T) {
+	content := strings.Repeat("compressible ", 10000)
+	tests := []struct {
+		level  string
+		method uint16
+	}{
+		{"", zip.Deflate},
+		{"store", zip.Store},
+		{"0", zip.Store},
+		{"9", zip.Deflate},
+	}
+	for _, tt := range tests {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.Archive.CompressionLevel = tt.level
+		})
+		writeFile(t, filepath.Join(baseDir, "a.txt"), content)
+		writeFile(t, filepath.Join(baseDir, "b.txt"), content)
+
+		w := get(h, "/download?items=/a.txt&items=/b.txt&format=zip", nil)
+		zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		if err != nil {
+			t.Fatalf("level %q: %v", tt.level, err)
+		}
+		for _, file := range zr.File {
+			stored := file.CompressedSize64 >= uint64(len(content))
+			if file.Method != tt.method || stored != (tt.method == zip.Store) {
+				t.Errorf("level %q: %s has method %d and %d of %d bytes, want method %d", tt.level, file.Name, file.Method, file.CompressedSize64, len(content), tt.method)
+			}
+		}
+	}
+}
+
+func TestUploadContinueOnError(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Upload.ContinueOnError = true
+	})
+	session := login(t, h, "alice")
+	outside := t.TempDir()
+	// Writing through a link leaving the base directory fails with 403
+	if err := os.Symlink(filepath.Join(outside, "target.txt"), filepath.Join(baseDir, "bad.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	body, contentType := multipartBody(t, map[string]string{"currentPath": "/"}, map[string]string{"good.txt": "good", "bad.txt": "bad"})
+	r := httptest.NewRequest("POST", "/upload", bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set("Accept", "application/json")
+	w := serve(h, r, session)
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("partially failed upload: status %d, want %d: %s", w.Code, http.StatusMultiStatus, w.Body)
+	}
+	var summary struct {
+		Files []struct {
+			Name  string `json:"name"`
+			Size  int64  `json:"size"`
+			Error string `json:"error"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	results := make(map[string]string)
+	for _, file := range summary.Files {
+		results[file.Name] = file.Error
+	}
+	if len(results) != 2 || results["good.txt"] != "" || results["bad.txt"] == "" {
+		t.Errorf("upload summary %+v, want good.txt saved and bad.txt failed", summary.Files)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "good.txt")); err != nil {
+		t.Errorf("good.txt was not saved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "target.txt")); !os.IsNotExist(err) {
+		t.Errorf("bad.txt was written outside the base directory: %v", err)
+	}
+
+	// A body cut short is rejected without leaving a file behind
+	body, contentType = multipartBody(t, map[string]string{"currentPath": "/"}, map[string]string{"cut.txt": strings.Repeat("x", 1000)})
+	r = httptest.NewRequest("POST", "/upload", bytes.NewReader(body[:len(body)/2]))
+	r.Header.Set("Content-Type", contentType)
+	if w := serve(h, r, session); w.Code != http.StatusBadRequest {
+		t.Errorf("truncated upload: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "cut.txt")); !os.IsNotExist(err) {
+		t.Errorf("truncated upload left cut.txt: %v", err)
+	}
+}
+
+func TestListingPagination(t *testing.T) {
+	h := newTestServer(t, nil)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		writeFile(t, filepath.Join(baseDir, name+".txt"), "x")
+	}
+
+	tests := []struct {
+		query string
+		want  string
+		page  pkg.Pagination
+	}{
+		{"?page=2&perPage=2", "c.txt,d.txt", pkg.Pagination{Page: 2, PerPage: 2, Total: 5, TotalPages: 3}},
+		{"?page=9&perPage=2", "e.txt", pkg.Pagination{Page: 3, PerPage: 2, Total: 5, TotalPages: 3}},
+		{"?page=x&perPage=-1", "a.txt,b.txt,c.txt,d.txt,e.txt", pkg.Pagination{Page: 1, PerPage: 50, Total: 5, TotalPages: 1}},
+	}
+	for _, tt := range tests {
+		response := listing(t, h, "/"+tt.query, nil)
+		if got := strings.Join(entryNames(response.Entries), ","); got != tt.want {
+			t.Errorf("listing %q: %s, want %s", tt.query, got, tt.want)
+		}
+		if response.Pagination == nil || *response.Pagination != tt.page {
+			t.Errorf("listing %q: pagination %+v, want %+v", tt.query, response.Pagination, tt.page)
+		}
+	}
+	if response := listing(t, h, "/", nil); response.Pagination != nil {
+		t.Errorf("listing without page parameters has pagination %+v", response.Pagination)
+	}
+}
+
+// searchResponse - JSON answer of /search
+type searchResponse struct {
+	Results   []search.Result `json:"results"`
+	Truncated bool            `json:"truncated"`
+}
+
+// resultPaths - paths of the search results in order
+func resultPaths(results []search.Result) string {
+	paths := make([]string, 0, len(results))
+	for _, result := range results {
+		paths = append(paths, result.Path)
+	}
+	return strings.Join(paths, ",")
+}
+
+func TestSearchRanking(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Shares = []pkg.Share{{Path: "/private", RequireAuth: true}}
+	})
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"docs/Report", "docs/reports-2024.pdf", "docs/old-report.txt", "docs/new-report.txt", "private/report"} {
+		writeFile(t, filepath.Join(baseDir, name), "x")
+	}
+	if err := os.Chtimes(filepath.Join(baseDir, "docs", "old-report.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	var response searchResponse
+	getJSON(t, h, "/search?q=REPORT", nil, &response)
+	if got, want := resultPaths(response.Results), "/docs/Report,/docs/reports-2024.pdf,/docs/new-report.txt,/docs/old-report.txt"; got != want {
+		t.Errorf("ranked results %s, want %s", got, want)
+	}
+	if len(response.Results) > 0 && response.Results[0].Score != 3 {
+		t.Errorf("exact match has score %d, want 3", response.Results[0].Score)
+	}
+
+	getJSON(t, h, "/search?q=report&path=/private", login(t, h, "alice"), &response)
+	if got := resultPaths(response.Results); got != "/private/report" {
+		t.Errorf("results in a share for a user: %s, want /private/report", got)
+	}
+	if w := get(h, "/search", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("search without a query: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUploadSymlinkPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		current string
+		escape  bool
+		want    int
+	}{
+		{pkg.SymlinkContain, "/", true, http.StatusForbidden},
+		{pkg.SymlinkContain, "/", false, http.StatusSeeOther},
+		{pkg.SymlinkDeny, "/", false, http.StatusForbidden},
+		{pkg.SymlinkFollow, "/", true, http.StatusSeeOther},
+		{pkg.SymlinkContain, "/outside", true, http.StatusBadRequest},
+		{pkg.SymlinkFollow, "/outside", true, http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.WebServer.SymlinkPolicy = tt.policy
+		})
+		session := login(t, h, "alice")
+		outside := t.TempDir()
+		if err := os.Symlink(outside, filepath.Join(baseDir, "outside")); err != nil {
+			t.Fatal(err)
+		}
+		// The uploaded name is a link to a file outside or inside the base directory
+		target := filepath.Join(outside, "a.txt")
+		if !tt.escape {
+			target = filepath.Join(baseDir, "inner.txt")
+			writeFile(t, target, "old")
+		}
+		if tt.current == "/" {
+			if err := os.Symlink(target, filepath.Join(baseDir, "a.txt")); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		w := postFiles(t, h, "/upload", map[string]string{"currentPath": tt.current}, map[string]string{"a.txt": "x"}, session)
+		if w.Code != tt.want {
+			t.Errorf("policy %s, upload of a.txt into %s (escaping %t): status %d, want %d: %s", tt.policy, tt.current, tt.escape, w.Code, tt.want, w.Body)
+		}
+		_, err := os.Stat(filepath.Join(outside, "a.txt"))
+		if written := err == nil; written != (tt.escape && tt.want == http.StatusSeeOther) {
+			t.Errorf("policy %s, upload of a.txt into %s: written outside the base directory: %t", tt.policy, tt.current, written)
+		}
+	}
+}
+
+func TestFavoriteToggle(t *testing.T) {
+	h := newTestServer(t, nil)
+	alice, bob := login(t, h, "alice"), login(t, h, "bob")
+	writeFile(t, filepath.Join(baseDir, "projects", "a.txt"), "x")
+
+	toggle := func(session *http.Cookie, p string) (int, bool) {
+		r := httptest.NewRequest("POST", "/favorite", strings.NewReader(url.Values{"path": {p}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", "application/json")
+		w := serve(h, r, session)
+		var result struct {
+			Path   string `json:"path"`
+			Pinned bool   `json:"pinned"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result.Pinned
+	}
+
+	if code, pinned := toggle(alice, "/projects"); code != http.StatusOK || !pinned {
+		t.Fatalf("pin: status %d, pinned %t", code, pinned)
+	}
+	links := func(session *http.Cookie) int {
+		return strings.Count(get(h, "/", session).Body.String(), `href="/projects/"`)
+	}
+	if links(alice) <= links(bob) {
+		t.Error("pinned folder is not shown above the listing of its user only")
+	}
+	if code, pinned := toggle(alice, "/projects"); code != http.StatusOK || pinned {
+		t.Errorf("unpin: status %d, pinned %t", code, pinned)
+	}
+	if code, _ := toggle(alice, "/projects/a.txt"); code != http.StatusNotFound {
+		t.Errorf("pin a file: status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestReadmeDepth(t *testing.T) {
+	tests := []struct {
+		depth string
+		want  map[string]bool
+	}{
+		{"", map[string]bool{"/": true, "/a/": true, "/a/b/": true}},
+		{"root", map[string]bool{"/": true, "/a/": false, "/a/b/": false}},
+		{"1", map[string]bool{"/": true, "/a/": true, "/a/b/": false}},
+	}
+	for _, tt := range tests {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.WebServer.ReadmeDepth = tt.depth
+		})
+		for dir := range tt.want {
+			writeFile(t, filepath.Join(baseDir, filepath.FromSlash(dir), "README.md"), "# Readme heading\n")
+		}
+		for dir, want := range tt.want {
+			if got := strings.Contains(get(h, dir, nil).Body.String(), "<h1>Readme heading</h1>"); got != want {
+				t.Errorf("readme_depth %q: README of %s rendered %t, want %t", tt.depth, dir, got, want)
+			}
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Preview.MaxSize = 1
+	})
+	writeFile(t, filepath.Join(baseDir, "v1.txt"), "one\ntwo\nthree\n")
+	writeFile(t, filepath.Join(baseDir, "v2.txt"), "one\n2\nthree\n")
+	writeFile(t, filepath.Join(baseDir, "image.bin"), "a\x00b")
+	writeFile(t, filepath.Join(baseDir, "large.txt"), strings.Repeat("x", 2<<20))
+
+	w := get(h, "/diff?a=/v1.txt&b=/v2.txt", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "-two\n+2\n") {
+		t.Errorf("diff: status %d: %q", w.Code, w.Body)
+	}
+	if w := get(h, "/diff?a=/v1.txt&b=/v2.txt&format=html", nil); w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("HTML diff: status %d, type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	tests := map[string]int{
+		"/diff?a=/v1.txt":                 http.StatusBadRequest,
+		"/diff?a=/v1.txt&b=/missing.txt":  http.StatusNotFound,
+		"/diff?a=/v1.txt&b=/image.bin":    http.StatusUnsupportedMediaType,
+		"/diff?a=/v1.txt&b=/large.txt":    http.StatusRequestEntityTooLarge,
+		"/diff?a=/v1.txt&b=/../etc/hosts": http.StatusBadRequest,
+	}
+	for target, want := range tests {
+		if w := get(h, target, nil); w.Code != want {
+			t.Errorf("%s: status %d, want %d", target, w.Code, want)
+		}
+	}
+}
+
+func TestReadmeFragment(t *testing.T) {
+	h := newTestServer(t, nil)
+	writeFile(t, filepath.Join(baseDir, "docs", "README.md"), "# Title\n\n<script>alert(1)</script>\n")
+	if err := os.Mkdir(filepath.Join(baseDir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := get(h, "/docs/?readme=1", nil)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("readme fragment: status %d, type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "<h1>Title</h1>") || strings.Contains(body, "<html") {
+		t.Errorf("readme fragment is not the rendered README alone: %s", body)
+	}
+	if strings.Contains(body, "<script>") {
+		t.Errorf("raw HTML of the README was rendered: %s", body)
+	}
+	if w := get(h, "/empty/?readme=1", nil); w.Code != http.StatusNotFound {
+		t.Errorf("directory without README: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestSidecarMetadata(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.MetadataSuffix = ".meta.json"
+	})
+	writeFile(t, filepath.Join(baseDir, "photo.jpg"), "x")
+	writeFile(t, filepath.Join(baseDir, "photo.jpg.meta.json"), `{"camera":"X100","iso":200,"tags":["a"]}`)
+	writeFile(t, filepath.Join(baseDir, "orphan.meta.json"), `{"a":"b"}`)
+	writeFile(t, filepath.Join(baseDir, "broken.txt"), "x")
+	writeFile(t, filepath.Join(baseDir, "broken.txt.meta.json"), `not json`)
+
+	entries := make(map[string]pkg.ListingEntry)
+	for _, entry := range listing(t, h, "/", nil).Entries {
+		entries[entry.Name] = entry
+	}
+	if _, ok := entries["photo.jpg.meta.json"]; ok {
+		t.Error("sidecar with a matching entry is listed")
+	}
+	if _, ok := entries["broken.txt.meta.json"]; !ok {
+		t.Error("unparsable sidecar is hidden")
+	}
+	if _, ok := entries["orphan.meta.json"]; !ok {
+		t.Error("sidecar without an entry is hidden")
+	}
+	want := map[string]string{"camera": "X100", "iso": "200", "tags": `["a"]`}
+	if got := entries["photo.jpg"].Metadata; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("metadata %v, want %v", got, want)
+	}
+}
+
+func TestUploadQuota(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Upload.Quota.MaxSize = 1
+		cfg.Upload.Quota.Users = map[string]int{"bob": 0}
+	})
+	alice, bob := login(t, h, "alice"), login(t, h, "bob")
+	half := strings.Repeat("x", 600<<10)
+	upload := func(session *http.Cookie, name string) *httptest.ResponseRecorder {
+		return postFiles(t, h, "/upload", map[string]string{"currentPath": "/"}, map[string]string{name: half}, session)
+	}
+
+	if w := upload(alice, "a.bin"); w.Code != http.StatusSeeOther {
+		t.Fatalf("upload within the quota: status %d: %s", w.Code, w.Body)
+	}
+	w := upload(alice, "b.bin")
+	if w.Code != http.StatusInsufficientStorage || !strings.Contains(w.Body.String(), "remaining") {
+		t.Errorf("upload over the quota: status %d, want %d: %s", w.Code, http.StatusInsufficientStorage, w.Body)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "b.bin")); !os.IsNotExist(err) {
+		t.Errorf("upload over the quota was stored: %v", err)
+	}
+	// Another login of the same user shares the quota; a user without a quota is not limited
+	if w := upload(login(t, h, "alice"), "c.bin"); w.Code != http.StatusInsufficientStorage {
+		t.Errorf("upload from another session of alice: status %d, want %d", w.Code, http.StatusInsufficientStorage)
+	}
+	for _, name := range []string{"d.bin", "e.bin"} {
+		if w := upload(bob, name); w.Code != http.StatusSeeOther {
+			t.Errorf("upload of %s by an unlimited user: status %d", name, w.Code)
+		}
+	}
+}
+
+func TestFormPathsCollapseSlashes(t *testing.T) {
+	h := newTestServer(t, nil)
+	session := login(t, h, "alice")
+	writeFile(t, filepath.Join(baseDir, "docs", "old.txt"), "x")
+
+	w := postFiles(t, h, "/upload", map[string]string{"currentPath": "//docs//"}, map[string]string{"a.txt": "x"}, session)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/docs/" {
+		t.Errorf("upload to //docs//: status %d to %q, want %d to /docs/", w.Code, w.Header().Get("Location"), http.StatusSeeOther)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "docs", "a.txt")); err != nil {
+		t.Errorf("upload to //docs// was not stored in /docs: %v", err)
+	}
+
+	w = postForm(h, "/delete", url.Values{"items": {"//docs///old.txt"}, "currentPath": {"//docs//"}}, session)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/docs/" {
+		t.Errorf("delete of //docs///old.txt: status %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "docs", "old.txt")); !os.IsNotExist(err) {
+		t.Errorf("//docs///old.txt was not deleted: %v", err)
+	}
+}
+
+func TestListingGroups(t *testing.T) {
+	files := []string{"photo.jpg", "notes.txt", "song.mp3", "README", "backup.zip", "sub/x"}
+	tests := []struct {
+		groupBy string
+		want    string
+	}{
+		{"type", "Folders[sub] Images[photo.jpg] Documents[notes.txt] Audio[song.mp3] Archives[backup.zip] Others[README]"},
+		{"extension", "Folders[sub] jpg[photo.jpg] mp3[song.mp3] txt[notes.txt] zip[backup.zip] Others[README]"},
+	}
+	for _, tt := range tests {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.WebServer.GroupBy = tt.groupBy
+		})
+		for _, name := range files {
+			writeFile(t, filepath.Join(baseDir, name), "x")
+		}
+		var sections []string
+		for _, group := range listing(t, h, "/", nil).Groups {
+			sections = append(sections, group.Label+"["+strings.Join(entryNames(group.Entries), ",")+"]")
+		}
+		if got := strings.Join(sections, " "); !strings.EqualFold(got, tt.want) {
+			t.Errorf("group_by %s: %s, want %s", tt.groupBy, got, tt.want)
+		}
+	}
+}
+
+// zipNames - names of the entries of the ZIP archive in the response
+func zipNames(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("response is not a ZIP archive (status %d): %v", w.Code, err)
+	}
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestGlobDownload(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Archive.MaxGlobMatches = 3
+	})
+	for _, name := range []string{"logs/a.log", "logs/b.log", "logs/c.txt", "logs/old/d.log", "logs/old/e.log"} {
+		writeFile(t, filepath.Join(baseDir, name), "x")
+	}
+
+	if got := strings.Join(zipNames(t, get(h, "/download?glob=*.log&path=/logs", nil)), ","); got != "logs/a.log,logs/b.log" {
+		t.Errorf("glob download: %s", got)
+	}
+	if got := strings.Join(zipNames(t, get(h, "/download?glob=c.*&path=/logs", nil)), ","); got != "logs/c.txt" {
+		t.Errorf("glob download of a single match: %s", got)
+	}
+	if got := strings.Join(entryNames(listing(t, h, "/logs/?glob=*.txt", nil).Entries), ","); got != "c.txt" {
+		t.Errorf("glob listing: %s", got)
+	}
+
+	tests := map[string]int{
+		"/download?glob=*.log&path=/logs&recursive=1": http.StatusRequestEntityTooLarge,
+		"/download?glob=*.pdf&path=/logs":             http.StatusNotFound,
+		"/download?glob=../*.log&path=/logs":          http.StatusBadRequest,
+		"/download?glob=old/*.log&path=/logs":         http.StatusBadRequest,
+	}
+	for target, want := range tests {
+		if w := get(h, target, nil); w.Code != want {
+			t.Errorf("%s: status %d, want %d", target, w.Code, want)
+		}
+	}
+}
+
+// uploadLink - creates an upload link for the folder as the user and returns its URL
+func uploadLink(t *testing.T, h http.Handler, session *http.Cookie, form url.Values) string {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/upload-link", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := serve(h, r, session)
+	var link struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &link); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("upload-link: status %d: %s", w.Code, w.Body)
+	}
+	return link.URL
+}
+
+func TestUploadLinks(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.Secret = "link-secret"
+		cfg.WebServer.MaxUploadSize = "2MB"
+		cfg.Upload.Links.Enabled = true
+		cfg.Upload.Quota.MaxSize = 2
+	})
+	alice := login(t, h, "alice")
+	for _, dir := range []string{"inbox", "other"} {
+		if err := os.Mkdir(filepath.Join(baseDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := uploadLink(t, h, alice, url.Values{"path": {"/inbox"}, "max_size": {"1"}})
+	unlimited := uploadLink(t, h, alice, url.Values{"path": {"/inbox"}, "max_size": {"0"}})
+	if !strings.Contains(link, "/drop?token=") {
+		t.Fatalf("upload link %q", link)
+	}
+
+	if w := get(h, link, nil); w.Code != http.StatusOK {
+		t.Errorf("drop page: status %d", w.Code)
+	}
+	if w := postFiles(t, h, link, map[string]string{"currentPath": "/inbox"}, map[string]string{"a.txt": "hello"}, nil); w.Code != http.StatusOK {
+		t.Errorf("upload through the link: status %d: %s", w.Code, w.Body)
+	}
+	if data, err := os.ReadFile(filepath.Join(baseDir, "inbox", "a.txt")); err != nil || string(data) != "hello" {
+		t.Errorf("uploaded file: %q, %v", data, err)
+	}
+
+	big := strings.Repeat("x", 1500<<10)
+	tests := []struct {
+		name   string
+		target string
+		fields map[string]string
+		file   string
+		want   int
+	}{
+		{"tampered token", link + "x", nil, "b", http.StatusForbidden},
+		{"other folder", link, map[string]string{"currentPath": "/other"}, "b", http.StatusForbidden},
+		{"over the link limit", link, nil, big, http.StatusRequestEntityTooLarge},
+		{"over max_upload_size", unlimited, nil, big + big, http.StatusRequestEntityTooLarge},
+		{"within the quota", unlimited, nil, big, http.StatusOK},
+		{"over the quota", unlimited, nil, big, http.StatusInsufficientStorage},
+	}
+	for i, tt := range tests {
+		name := fmt.Sprintf("file%d.txt", i)
+		w := postFiles(t, h, tt.target, tt.fields, map[string]string{name: tt.file}, nil)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+		_, err := os.Stat(filepath.Join(baseDir, "inbox", name))
+		if stored := err == nil; stored != (tt.want == http.StatusOK) {
+			t.Errorf("%s: file stored %t", tt.name, stored)
+		}
+	}
+	if w := postFiles(t, h, "/upload", map[string]string{"currentPath": "/"}, map[string]string{"c.txt": big}, alice); w.Code != http.StatusInsufficientStorage {
+		t.Errorf("upload by the link creator after the link used the quota: status %d, want %d", w.Code, http.StatusInsufficientStorage)
+	}
+}
+
+func TestMarkdownRender(t *testing.T) {
+	h := newTestServer(t, nil)
+	writeFile(t, filepath.Join(baseDir, "README.md"), "# Readme heading\n")
+	session := login(t, h, "alice")
+
+	raw := get(h, "/README.md", session)
+	if raw.Code != http.StatusOK || raw.Body.String() != "# Readme heading\n" {
+		t.Fatalf("GET README.md = %d %q, want the raw file", raw.Code, raw.Body.String())
+	}
+	if link := raw.Header().Get("Link"); !strings.Contains(link, "/README.md?render=1") {
+		t.Errorf("Link = %q, want the rendered preview", link)
+	}
+
+	rendered := get(h, "/README.md?render=1", session)
+	if rendered.Code != http.StatusOK || !strings.HasPrefix(rendered.Header().Get("Content-Type"), "text/html") ||
+		!strings.Contains(rendered.Body.String(), "<h1>Readme heading</h1>") {
+		t.Fatalf("GET README.md?render=1 = %d %s, want the rendered HTML", rendered.Code, rendered.Header().Get("Content-Type"))
+	}
+}
+
+func TestSortCookie(t *testing.T) {
+	h := newTestServer(t, nil)
+	writeFile(t, filepath.Join(baseDir, "a.txt"), "xxx")
+	writeFile(t, filepath.Join(baseDir, "b.txt"), "x")
+	writeFile(t, filepath.Join(baseDir, "c.txt"), "xx")
+
+	w := get(h, "/?sort=size&order=desc", nil)
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sortCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("sorting the listing set no sort cookie")
+	}
+
+	tests := []struct {
+		target string
+		cookie *http.Cookie
+		want   string
+	}{
+		{"/", nil, "a.txt,b.txt,c.txt"},
+		{"/", cookie, "a.txt,c.txt,b.txt"},
+		{"/?sort=name&order=desc", cookie, "c.txt,b.txt,a.txt"},
+		{"/", &http.Cookie{Name: sortCookieName, Value: "bogus"}, "a.txt,b.txt,c.txt"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(entryNames(listing(t, h, tt.target, tt.cookie).Entries), ","); got != tt.want {
+			t.Errorf("listing %s with cookie %v: %s, want %s", tt.target, tt.cookie, got, tt.want)
+		}
+	}
+}
+
+func TestListingSort(t *testing.T) {
+	h := newTestServer(t, nil)
+	now := time.Now()
+	for i, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		path := filepath.Join(baseDir, name)
+		writeFile(t, path, strings.Repeat("x", []int{3, 1, 2}[i]))
+		modTime := now.Add(-time.Duration([]int{2, 3, 1}[i]) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(baseDir, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/?sort=name", "dir,a.txt,b.txt,c.txt"},
+		{"/?sort=name&order=desc", "dir,c.txt,b.txt,a.txt"},
+		{"/?sort=size&order=asc", "dir,b.txt,c.txt,a.txt"},
+		{"/?sort=size&order=desc", "dir,a.txt,c.txt,b.txt"},
+		{"/?sort=modtime&order=asc", "dir,b.txt,a.txt,c.txt"},
+		{"/?sort=modtime&order=desc", "dir,c.txt,a.txt,b.txt"},
+		{"/?sort=owner", "dir,a.txt,b.txt,c.txt"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(entryNames(listing(t, h, tt.target, nil).Entries), ","); got != tt.want {
+			t.Errorf("listing %s: %s, want %s", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestSessionLimit(t *testing.T) {
+	for _, policy := range []string{auth.SessionLimitEvict, auth.SessionLimitReject} {
+		t.Run(policy, func(t *testing.T) {
+			h := newTestServer(t, func(cfg *pkg.Config) {
+				cfg.Auth.MaxSessionsPerUser = 2
+				cfg.Auth.SessionLimitPolicy = policy
+			})
+			first, second := login(t, h, "alice"), login(t, h, "alice")
+
+			w := postForm(h, "/login", url.Values{"username": {"alice"}, "password": {testPassword}}, nil)
+			want := map[*http.Cookie]int{first: http.StatusUnauthorized, second: http.StatusOK}
+			if policy == auth.SessionLimitReject {
+				if w.Code != http.StatusForbidden {
+					t.Errorf("%s: third login status %d, want %d", policy, w.Code, http.StatusForbidden)
+				}
+				want[first] = http.StatusOK
+			} else if w.Code != http.StatusSeeOther {
+				t.Errorf("%s: third login status %d, want %d", policy, w.Code, http.StatusSeeOther)
+			}
+			for session, status := range want {
+				if w := get(h, "/check-session", session); w.Code != status {
+					t.Errorf("%s: session check status %d, want %d", policy, w.Code, status)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionLimitRotation(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Auth.MaxSessionsPerUser = 2
+		cfg.Auth.SessionLimitPolicy = auth.SessionLimitReject
+	})
+	first, second := login(t, h, "alice"), login(t, h, "alice")
+
+	// A login sent with one of the sessions replaces it instead of exceeding the limit
+	w := postForm(h, "/login", url.Values{"username": {"alice"}, "password": {testPassword}}, first)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("login replacing a session: status %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	for session, status := range map[*http.Cookie]int{first: http.StatusUnauthorized, second: http.StatusOK} {
+		if w := get(h, "/check-session", session); w.Code != status {
+			t.Errorf("session check status %d, want %d", w.Code, status)
+		}
+	}
+	if w := postForm(h, "/login", url.Values{"username": {"alice"}, "password": {testPassword}}, nil); w.Code != http.StatusForbidden {
+		t.Errorf("login beyond the limit: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestActionRoutesRequirePost(t *testing.T) {
+	h := newTestServer(t, nil)
+	session := login(t, h, "alice")
+	for _, route := range []string{"/upload", "/delete", "/create-folder"} {
+		for _, method := range []string{"GET", "HEAD"} {
+			w := serve(h, httptest.NewRequest(method, route, nil), session)
+			if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+				t.Errorf("%s %s: status %d, Allow %q, want %d and POST", method, route, w.Code, w.Header().Get("Allow"), http.StatusMethodNotAllowed)
+			}
+		}
+		if w := get(h, route, nil); w.Code != http.StatusSeeOther {
+			t.Errorf("GET %s without a session: status %d, want %d", route, w.Code, http.StatusSeeOther)
+		}
+	}
+}
+
+func TestBanner(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.Banner = "**Maintenance** tonight <script>alert(1)</script>"
+	})
+	for _, target := range []string{"/", "/login"} {
+		body := get(h, target, nil).Body.String()
+		if !strings.Contains(body, "<strong>Maintenance</strong> tonight") {
+			t.Errorf("%s does not show the banner", target)
+		}
+		if strings.Contains(body, "<script>alert(1)</script>") {
+			t.Errorf("%s renders raw HTML of the banner", target)
+		}
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	h := newTestServer(t, nil)
+	writeFile(t, filepath.Join(baseDir, "docs", "a b.txt"), "x")
+
+	w := get(h, "/qr?path=/docs/a%20b.txt&size=128", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("QR code: status %d, type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("QR code is not a PNG: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 128 || bounds.Dy() != 128 {
+		t.Errorf("QR code is %v, want 128x128", bounds)
+	}
+	// The encoding is deterministic, so the URL is checked against its own QR code
+	want, err := qrcode.Encode("http://example.com/docs/a%20b.txt", qrcode.Medium, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.Body.Bytes(), want) {
+		t.Error("QR code does not encode the download URL of the file")
+	}
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/qr", http.StatusBadRequest},
+		{"/qr?path=/docs/a.txt&size=x", http.StatusBadRequest},
+		{"/qr?path=/missing.txt", http.StatusNotFound},
+		{"/qr?path=../../etc/passwd", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := get(h, tt.target, nil); w.Code != tt.status {
+			t.Errorf("GET %s: status %d, want %d", tt.target, w.Code, tt.status)
+		}
+	}
+}
+
+// tarGz - returns a tar.gz archive of the files by name
+func tarGz(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, content)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestUploadExtract(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Upload.Extract.Enabled = true
+	})
+	session := login(t, h, "alice")
+	fields := map[string]string{"currentPath": "/docs", "extract": "1"}
+
+	archive := tarGz(t, map[string]string{"a.txt": "alpha", "sub/b.txt": "beta"})
+	if w := postFiles(t, h, "/upload", fields, map[string]string{"tree.tar.gz": archive}, session); w.Code != http.StatusSeeOther {
+		t.Fatalf("upload with extraction: status %d: %s", w.Code, w.Body)
+	}
+	for name, want := range map[string]string{"a.txt": "alpha", "sub/b.txt": "beta"} {
+		if got, err := os.ReadFile(filepath.Join(baseDir, "docs", name)); err != nil || string(got) != want {
+			t.Errorf("extracted %s = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "docs", "tree.tar.gz")); !os.IsNotExist(err) {
+		t.Errorf("extracted archive was stored too: %v", err)
+	}
+
+	for _, name := range []string{"/etc/evil.txt", "../evil.txt"} {
+		archive := tarGz(t, map[string]string{name: "x"})
+		if w := postFiles(t, h, "/upload", fields, map[string]string{"evil.tar.gz": archive}, session); w.Code != http.StatusBadRequest {
+			t.Errorf("archive with the entry %s: status %d, want %d", name, w.Code, http.StatusBadRequest)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "evil.txt")); !os.IsNotExist(err) {
+		t.Errorf("entry escaping the destination was written: %v", err)
+	}
+}
+
+func TestDownloadDepthLimit(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Archive.MaxDepth = 1
+		cfg.Archive.FollowSymlinks = true
+	})
+	for _, name := range []string{"tree/a.txt", "tree/b/c.txt", "tree/b/d/e.txt"} {
+		writeFile(t, filepath.Join(baseDir, name), "x")
+	}
+	if err := os.Symlink("..", filepath.Join(baseDir, "tree", "b", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := get(h, "/download?items=/tree&format=zip", nil)
+	if got := strings.Join(zipNames(t, w), ","); got != "tree/a.txt,tree/b/c.txt" {
+		t.Errorf("archive of a deep tree with a cycle: %s", got)
+	}
+	if w.Header().Get("X-Archive-Truncated") != "true" {
+		t.Error("truncated archive is not flagged")
+	}
+}
+
+func TestListingRefreshMetadata(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.RefreshInterval = 30 * time.Second
+	})
+	before := time.Now().Add(-time.Second)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/json")
+	w := serve(h, r, nil)
+	var envelope struct {
+		ServerTime      string `json:"server_time"`
+		RefreshInterval int    `json:"refresh_interval"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	serverTime, err := time.Parse(time.RFC3339, envelope.ServerTime)
+	if err != nil || serverTime.Before(before) || serverTime.After(time.Now()) {
+		t.Errorf("server_time %q, %v, want the current time", envelope.ServerTime, err)
+	}
+	if envelope.RefreshInterval != 30 {
+		t.Errorf("refresh_interval %d, want 30", envelope.RefreshInterval)
+	}
+}
+
+func TestPrebuiltIndex(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.WebServer.PrebuiltIndex = enabled
+		})
+		writeFile(t, filepath.Join(baseDir, "data", "live.txt"), "x")
+		writeFile(t, filepath.Join(baseDir, "data", prebuiltIndexName), `{"path":"/data/","entries":[{"name":"indexed.txt"}]}`)
+		writeFile(t, filepath.Join(baseDir, "plain", "live.txt"), "x")
+
+		// Sorted listings and directories without an index are read live; the index is
+		// hidden from live listings only while it is in use
+		want := map[string]string{"/data/": ".index.json,live.txt", "/data/?sort=name": ".index.json,live.txt", "/plain/": "live.txt"}
+		if enabled {
+			want["/data/"] = "indexed.txt"
+			want["/data/?sort=name"] = "live.txt"
+		}
+		for target, want := range want {
+			if got := strings.Join(entryNames(listing(t, h, target, nil).Entries), ","); got != want {
+				t.Errorf("prebuilt_index %t: listing of %s %s, want %s", enabled, target, got, want)
+			}
+		}
+	}
+}
+
+func TestDeleteProtection(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.DeleteProtection = 5 * time.Minute
+	})
+	session := login(t, h, "alice")
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"old.txt", "dir/old.txt", "dir/fresh.txt", "fresh.txt"} {
+		full := filepath.Join(baseDir, filepath.FromSlash(name))
+		writeFile(t, full, "x")
+		if strings.Contains(name, "old") {
+			if err := os.Chtimes(full, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	remove := func(item string, force bool) *httptest.ResponseRecorder {
+		form := url.Values{"items": {item}, "currentPath": {"/"}}
+		if force {
+			form.Set("force", "1")
+		}
+		return postForm(h, "/delete", form, session)
+	}
+
+	// A directory is locked by any recently modified file below it
+	for _, item := range []string{"/fresh.txt", "/dir"} {
+		if w := remove(item, false); w.Code != http.StatusLocked || !strings.Contains(w.Body.String(), "fresh.txt") {
+			t.Errorf("delete of %s: status %d, want %d: %s", item, w.Code, http.StatusLocked, w.Body)
+		}
+		if _, err := os.Stat(filepath.Join(baseDir, item)); err != nil {
+			t.Errorf("locked %s was deleted: %v", item, err)
+		}
+	}
+	if w := remove("/old.txt", false); w.Code != http.StatusSeeOther {
+		t.Errorf("delete of an old file: status %d: %s", w.Code, w.Body)
+	}
+	if w := remove("/dir", true); w.Code != http.StatusSeeOther {
+		t.Errorf("forced delete: status %d: %s", w.Code, w.Body)
+	}
+	for _, item := range []string{"old.txt", "dir"} {
+		if _, err := os.Stat(filepath.Join(baseDir, item)); !os.IsNotExist(err) {
+			t.Errorf("%s was not deleted: %v", item, err)
+		}
+	}
+}
+
+func TestMarkdownRenderEncodings(t *testing.T) {
+	h := newTestServer(t, nil)
+	utf16 := []byte{0xFF, 0xFE}
+	for _, r := range "# Café\n" {
+		utf16 = append(utf16, byte(r), 0)
+	}
+	files := map[string][]byte{
+		"bom.md":    append([]byte{0xEF, 0xBB, 0xBF}, "# Café\n"...),
+		"utf16.md":  utf16,
+		"latin1.md": []byte("# Caf\xe9\n"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(baseDir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if body := get(h, "/"+name+"?render=1", nil).Body.String(); !strings.Contains(body, "<h1>Café</h1>") {
+			t.Errorf("%s is not rendered as UTF-8: %s", name, body)
+		}
+	}
+}
+
+func TestStatRecursiveSize(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.DirSize.Enabled = true
+	})
+	writeFile(t, filepath.Join(baseDir, "data", "a.txt"), "12345")
+	writeFile(t, filepath.Join(baseDir, "data", "sub", "b.txt"), "123")
+
+	var stat struct {
+		Recursive       *dirsize.Result `json:"recursive"`
+		RecursiveStatus dirsize.Status  `json:"recursiveStatus"`
+	}
+	for deadline := time.Now().Add(5 * time.Second); stat.RecursiveStatus != dirsize.StatusFresh; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("recursive size was not computed: %+v", stat)
+		}
+		getJSON(t, h, "/stat?path=/data", nil, &stat)
+	}
+	if stat.Recursive == nil || stat.Recursive.Size != 8 || stat.Recursive.Files != 2 {
+		t.Errorf("recursive size %+v, want 2 files of 8 bytes", stat.Recursive)
+	}
+}
+
+func TestContactSheet(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.ContactSheet.Enabled = true
+		cfg.ContactSheet.Columns = 2
+		cfg.ContactSheet.CellSize = 64
+	})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 100, 80))); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		writeFile(t, filepath.Join(baseDir, "photos", name), buf.String())
+	}
+	writeFile(t, filepath.Join(baseDir, "docs", "a.txt"), "x")
+
+	w := get(h, "/contact-sheet?path=/photos&format=png", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("contact sheet: status %d, type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	sheet, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := sheet.Bounds().Size(); size.X != 128 || size.Y != 128 {
+		t.Errorf("contact sheet is %v, want 128x128", size)
+	}
+
+	tests := map[string]int{
+		"/contact-sheet?path=/docs":              http.StatusNotFound,
+		"/contact-sheet?path=/photos&columns=x":  http.StatusBadRequest,
+		"/contact-sheet?path=/photos&format=bmp": http.StatusBadRequest,
+		"/contact-sheet?path=../../etc":          http.StatusBadRequest,
+	}
+	for target, want := range tests {
+		if w := get(h, target, nil); w.Code != want {
+			t.Errorf("%s: status %d, want %d", target, w.Code, want)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Tags.Enabled = true
+	})
+	session := login(t, h, "alice")
+	writeFile(t, filepath.Join(baseDir, "docs", "a.pdf"), "a")
+	writeFile(t, filepath.Join(baseDir, "docs", "b.pdf"), "b")
+	fileTags := func(p string) string {
+		var response struct {
+			Tags []string `json:"tags"`
+		}
+		getJSON(t, h, "/tags?path="+p, nil, &response)
+		return strings.Join(response.Tags, ",")
+	}
+
+	if w := postForm(h, "/tag", url.Values{"path": {"/docs/a.pdf"}, "tags": {"invoice, 2024,invoice"}}, session); w.Code != http.StatusSeeOther {
+		t.Fatalf("tag: status %d: %s", w.Code, w.Body)
+	}
+	if got := fileTags("/docs/a.pdf"); got != "2024,invoice" {
+		t.Errorf("tags %s, want 2024,invoice", got)
+	}
+	if got := strings.Join(entryNames(listing(t, h, "/docs/?tag=invoice", nil).Entries), ","); got != "a.pdf" {
+		t.Errorf("listing by tag: %s, want a.pdf", got)
+	}
+	var search searchResponse
+	getJSON(t, h, "/search?tag=2024", nil, &search)
+	if got := resultPaths(search.Results); got != "/docs/a.pdf" {
+		t.Errorf("search by tag: %s, want /docs/a.pdf", got)
+	}
+
+	// The tags follow the file when it is renamed and go away with it
+	if w := postForm(h, "/rename", url.Values{"currentPath": {"/docs"}, "oldName": {"a.pdf"}, "newName": {"c.pdf"}}, session); w.Code != http.StatusSeeOther {
+		t.Fatalf("rename: status %d: %s", w.Code, w.Body)
+	}
+	if got := strings.Join(entryNames(listing(t, h, "/docs/?tag=invoice", nil).Entries), ","); got != "c.pdf" {
+		t.Errorf("listing by tag after the rename: %s, want c.pdf", got)
+	}
+	postForm(h, "/delete", url.Values{"items": {"/docs/c.pdf"}, "currentPath": {"/docs"}}, session)
+	writeFile(t, filepath.Join(baseDir, "docs", "c.pdf"), "new")
+	if got := fileTags("/docs/c.pdf"); got != "" {
+		t.Errorf("tags of a new file at a deleted path: %s", got)
+	}
+}
+
+func TestUploadExtractUnsupported(t *testing.T) {
+	for _, policy := range []string{extract.UnsupportedReject, extract.UnsupportedStore} {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.Upload.Extract.Enabled = true
+			cfg.Upload.Extract.Unsupported = policy
+		})
+		session := login(t, h, "alice")
+		var zipped bytes.Buffer
+		zw := zip.NewWriter(&zipped)
+		zw.Create("a.txt")
+		zw.Close()
+
+		// Named like a tar archive, detected by its content
+		w := postFiles(t, h, "/upload", map[string]string{"currentPath": "/", "extract": "true"}, map[string]string{"fake.tar.gz": zipped.String()}, session)
+		_, err := os.Stat(filepath.Join(baseDir, "fake.tar.gz"))
+		if policy == extract.UnsupportedReject {
+			if w.Code != http.StatusUnsupportedMediaType || !strings.Contains(w.Body.String(), "application/zip") {
+				t.Errorf("%s: status %d, want %d with the detected type: %s", policy, w.Code, http.StatusUnsupportedMediaType, w.Body)
+			}
+			if !os.IsNotExist(err) {
+				t.Errorf("%s: rejected archive was stored: %v", policy, err)
+			}
+		} else if w.Code != http.StatusSeeOther || err != nil {
+			t.Errorf("%s: status %d, stored: %v", policy, w.Code, err)
+		}
+	}
+}
+
+func TestListingURLs(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.PublicURL = "https://files.example.org/share"
+	})
+	writeFile(t, filepath.Join(baseDir, "a b", "50%#1.txt"), "x")
+
+	urls := make(map[string]string)
+	for _, target := range []string{"/", "/a%20b/"} {
+		for _, entry := range listing(t, h, target, nil).Entries {
+			urls[entry.Name] = entry.URL
+		}
+	}
+	want := map[string]string{
+		"a b":       "https://files.example.org/share/a%20b/",
+		"50%#1.txt": "https://files.example.org/share/a%20b/50%25%231.txt",
+	}
+	for name, want := range want {
+		if urls[name] != want {
+			t.Errorf("URL of %s %q, want %q", name, urls[name], want)
+		}
+	}
+}
+
+// postChunk - posts the form values and, unless empty, the chunk to /upload-chunk
+func postChunk(t *testing.T, h http.Handler, fields map[string]string, chunk string, session *http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	if chunk != "" {
+		part, err := mw.CreateFormFile("chunk", "blob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(part, chunk)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "/upload-chunk", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	r.Header.Set("Accept", "application/json")
+	return serve(h, r, session)
+}
+
+// sha256Hex - returns the hex SHA-256 checksum of the content
+func sha256Hex(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return fmt.Sprintf("%x", hash)
+}
+
+func TestChunkedUpload(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Upload.Chunked.Enabled = true
+		cfg.Upload.Chunked.Dir = t.TempDir()
+	})
+	session := login(t, h, "alice")
+	chunks := []string{"first chunk, ", "second chunk"}
+	whole := strings.Join(chunks, "")
+	complete := func(id, checksum string) *httptest.ResponseRecorder {
+		return postChunk(t, h, map[string]string{"id": id, "complete": "1", "total": "2", "name": id + ".txt", "currentPath": "/docs", "checksum": checksum}, "", session)
+	}
+
+	if w := postChunk(t, h, map[string]string{"id": "bad", "index": "0", "checksum": sha256Hex("other")}, chunks[0], session); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("chunk with a wrong checksum: status %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if w := complete("bad", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("assembly without the rejected chunk: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	for _, id := range []string{"good", "corrupt"} {
+		for index, chunk := range chunks {
+			if w := postChunk(t, h, map[string]string{"id": id, "index": fmt.Sprint(index), "checksum": sha256Hex(chunk)}, chunk, session); w.Code != http.StatusOK {
+				t.Fatalf("chunk %d: status %d: %s", index, w.Code, w.Body)
+			}
+		}
+	}
+	if w := complete("corrupt", sha256Hex("other")); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("assembly with a wrong file checksum: status %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "docs", "corrupt.txt")); !os.IsNotExist(err) {
+		t.Errorf("file with a wrong checksum was stored: %v", err)
+	}
+	if w := complete("good", sha256Hex(whole)); w.Code != http.StatusOK {
+		t.Fatalf("assembly: status %d: %s", w.Code, w.Body)
+	}
+	if got, err := os.ReadFile(filepath.Join(baseDir, "docs", "good.txt")); err != nil || string(got) != whole {
+		t.Errorf("assembled file %q, %v, want %q", got, err, whole)
+	}
+}
+
+func TestUploadRequireContentLength(t *testing.T) {
+	for _, required := range []bool{true, false} {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.Upload.RequireContentLength = required
+		})
+		session := login(t, h, "alice")
+		for _, declared := range []bool{true, false} {
+			body, contentType := multipartBody(t, map[string]string{"currentPath": "/"}, map[string]string{"a.txt": "hello"})
+			r := httptest.NewRequest("POST", "/upload", bytes.NewReader(body))
+			r.Header.Set("Content-Type", contentType)
+			if !declared {
+				// Streamed without a declared size
+				r.ContentLength = -1
+			}
+			want := http.StatusSeeOther
+			if required && !declared {
+				want = http.StatusLengthRequired
+			}
+			if w := serve(h, r, session); w.Code != want {
+				t.Errorf("required %t, Content-Length %t: status %d, want %d", required, declared, w.Code, want)
+			}
+		}
+	}
+}
+
+func TestCreateFolderJSON(t *testing.T) {
+	h := newTestServer(t, nil)
+	session := login(t, h, "alice")
+	type result struct {
+		Path   string `json:"path"`
+		URL    string `json:"url"`
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	}
+	create := func(body string) (int, result) {
+		w := postJSON(h, "/create-folder", body, session)
+		var got result
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v: %s", body, err, w.Body)
+		}
+		return w.Code, got
+	}
+
+	status, got := create(`{"currentPath":"/","folderName":"new docs"}`)
+	if status != http.StatusCreated || got.Path != "/new docs" || got.URL != "http://example.com/new%20docs/" {
+		t.Errorf("create: status %d, result %+v", status, got)
+	}
+	if info, err := os.Stat(filepath.Join(baseDir, "new docs")); err != nil || !info.IsDir() {
+		t.Errorf("folder was not created: %v", err)
+	}
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"currentPath":"/","folderName":"new docs"}`, http.StatusConflict},
+		{`{"currentPath":"/","folderName":""}`, http.StatusBadRequest},
+		{`{"currentPath":"/new docs","folderName":".."}`, http.StatusBadRequest},
+		{`{"currentPath":"/","folderName":"a/b"}`, http.StatusBadRequest},
+		{`{"currentPath":"/","folderName":"a\\b"}`, http.StatusBadRequest},
+		{`{"currentPath":"/"`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if status, got := create(tt.body); status != tt.status || got.Status != tt.status || got.Error == "" {
+			t.Errorf("%s: status %d, result %+v, want %d", tt.body, status, got, tt.status)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "a")); !os.IsNotExist(err) {
+		t.Errorf("a name with a separator created a folder: %v", err)
+	}
+}
+
+func TestListingExclude(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.WebServer.ListingExclude = []string{"*.tmp", ".DS_Store", "cache/"}
+	})
+	for _, name := range []string{"a.txt", "b.tmp", ".DS_Store", "cache/c.txt", "docs/d.tmp", "docs/e.txt"} {
+		writeFile(t, filepath.Join(baseDir, filepath.FromSlash(name)), "x")
+	}
+
+	for target, want := range map[string]string{"/": "docs,a.txt", "/docs/": "e.txt"} {
+		if got := strings.Join(entryNames(listing(t, h, target, nil).Entries), ","); got != want {
+			t.Errorf("JSON listing of %s: %s, want %s", target, got, want)
+		}
+	}
+	html := get(h, "/", nil).Body.String()
+	if !strings.Contains(html, "a.txt") || strings.Contains(html, "b.tmp") || strings.Contains(html, ".DS_Store") {
+		t.Error("HTML listing does not apply the exclusions")
+	}
+	// Hidden entries stay reachable directly
+	if w := get(h, "/b.tmp", nil); w.Code != http.StatusOK {
+		t.Errorf("direct access to an excluded file: status %d", w.Code)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Events.Enabled = true
+		cfg.Events.MaxSubscribers = 1
+	})
+	server := httptest.NewServer(h)
+	defer server.Close()
+	session := login(t, h, "alice")
+	subscribe := func(ctx context.Context) *http.Response {
+		r, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.AddCookie(session)
+		response, err := server.Client().Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+	waitSubscribers := func(n int) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); events.Subscribers() != n; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%d subscribers, want %d", events.Subscribers(), n)
+			}
+		}
+	}
+
+	if w := get(h, "/events", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("stream without a session: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := subscribe(ctx)
+	defer stream.Body.Close()
+	if stream.StatusCode != http.StatusOK || stream.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream: status %d, type %q", stream.StatusCode, stream.Header.Get("Content-Type"))
+	}
+	waitSubscribers(1)
+	extra := subscribe(context.Background())
+	extra.Body.Close()
+	if extra.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("subscriber over the limit: status %d, want %d", extra.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	postFiles(t, h, "/upload", map[string]string{"currentPath": "/docs"}, map[string]string{"a.txt": "hello"}, session)
+	lines := bufio.NewScanner(stream.Body)
+	var name, data string
+	for name == "" || data == "" {
+		if !lines.Scan() {
+			t.Fatalf("stream ended before the upload event: %v", lines.Err())
+		}
+		if value, ok := strings.CutPrefix(lines.Text(), "event: "); ok {
+			name = value
+		} else if value, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+			data = value
+		}
+	}
+	var event events.Event
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatal(err)
+	}
+	if name != events.TypeUpload || event.Path != "/docs/a.txt" || event.User != "alice" || event.Size != 5 {
+		t.Errorf("event %s %+v, want the upload of /docs/a.txt", name, event)
+	}
+
+	// A disconnected client frees its subscription
+	cancel()
+	waitSubscribers(0)
+}
+
+func TestUploadAllowedTypes(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Upload.AllowedTypes = []string{"image/*", "text/plain"}
+	})
+	session := login(t, h, "alice")
+	pngHeader := "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"
+
+	tests := []struct {
+		name, content string
+		status        int
+	}{
+		{"photo.png", pngHeader, http.StatusSeeOther},
+		{"notes.txt", "plain text", http.StatusSeeOther},
+		{"photo.jpg", "#!/bin/sh\nrm -rf /\n", http.StatusUnsupportedMediaType},
+		{"photo.jpg", pngHeader, http.StatusUnsupportedMediaType},
+		{"page.txt", "<html><script>alert(1)</script>", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		w := postFiles(t, h, "/upload", map[string]string{"currentPath": "/"}, map[string]string{tt.name: tt.content}, session)
+		if w.Code != tt.status {
+			t.Errorf("upload of %s with %q: status %d, want %d: %s", tt.name, tt.content[:8], w.Code, tt.status, w.Body)
+		}
+		if _, err := os.Stat(filepath.Join(baseDir, tt.name)); (err == nil) != (tt.status == http.StatusSeeOther) {
+			t.Errorf("upload of %s with %q: stored %t", tt.name, tt.content[:8], err == nil)
+		}
+		os.Remove(filepath.Join(baseDir, tt.name))
+	}
+}
+
+func TestRewrites(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Rewrites = []pkg.Rewrite{
+			{Pattern: "/manual", Target: "/docs/manual"},
+			{Pattern: "/latest", Target: "/releases", Resolve: "latest"},
+		}
+	})
+	writeFile(t, filepath.Join(baseDir, "docs", "manual", "index.txt"), "manual")
+	writeFile(t, filepath.Join(baseDir, "releases", "2024-05-01", "notes.txt"), "may")
+	writeFile(t, filepath.Join(baseDir, "releases", "2024-06-01", "notes.txt"), "june")
+
+	for target, want := range map[string]string{"/manual/index.txt": "manual", "/latest/notes.txt": "june"} {
+		if w := get(h, target, nil); w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("%s: status %d, body %q, want %q", target, w.Code, w.Body, want)
+		}
+	}
+	if got := strings.Join(entryNames(listing(t, h, "/latest/", nil).Entries), ","); got != "notes.txt" {
+		t.Errorf("listing of /latest/: %s", got)
+	}
+
+	// A new release becomes the target without a restart
+	writeFile(t, filepath.Join(baseDir, "releases", "2024-07-01", "notes.txt"), "july")
+	if w := get(h, "/latest/notes.txt", nil); w.Body.String() != "july" {
+		t.Errorf("after a new release: body %q, want july", w.Body)
+	}
+	if w := get(h, "/latest/../../etc/passwd", nil); w.Code == http.StatusOK {
+		t.Errorf("traversal through a rewrite: status %d", w.Code)
+	}
+}
+
+func TestChildCounts(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.ChildCount.Enabled = true
+		cfg.WebServer.ListingExclude = []string{"*.tmp"}
+	})
+	for _, name := range []string{"docs/a.txt", "docs/b.txt", "docs/sub/c.txt", "docs/d.tmp", "empty/.keep", "top.txt"} {
+		writeFile(t, filepath.Join(baseDir, filepath.FromSlash(name)), "x")
+	}
+	os.Remove(filepath.Join(baseDir, "empty", ".keep"))
+
+	counts := make(map[string]string)
+	for _, entry := range listing(t, h, "/", nil).Entries {
+		if entry.ChildCount != nil {
+			counts[entry.Name] = fmt.Sprint(*entry.ChildCount)
+		}
+	}
+	if counts["docs"] != "3" || counts["empty"] != "0" || len(counts) != 2 {
+		t.Errorf("child counts %v, want docs 3 and empty 0", counts)
+	}
+	if html := get(h, "/", nil).Body.String(); !strings.Contains(html, "3 items") {
+		t.Error("HTML listing does not show the count")
+	}
+
+	writeFile(t, filepath.Join(baseDir, "docs", "e.txt"), "x")
+	os.Chtimes(filepath.Join(baseDir, "docs"), time.Now().Add(time.Second), time.Now().Add(time.Second))
+	for _, entry := range listing(t, h, "/", nil).Entries {
+		if entry.Name == "docs" && (entry.ChildCount == nil || *entry.ChildCount != 4) {
+			t.Errorf("count after adding a file: %v, want 4", entry.ChildCount)
+		}
+	}
+}
+
+func TestReadOnlyShares(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Shares = []pkg.Share{
+			{Path: "/archive", Deny: []string{"write"}},
+			{Path: "/inbox", Deny: []string{"delete"}},
+		}
+	})
+	session := login(t, h, "alice")
+	writeFile(t, filepath.Join(baseDir, "archive", "old.txt"), "x")
+	writeFile(t, filepath.Join(baseDir, "inbox", "new.txt"), "x")
+
+	upload := func(dir string) int {
+		return postFiles(t, h, "/upload", map[string]string{"currentPath": dir}, map[string]string{"a.txt": "hello"}, session).Code
+	}
+	if code := upload("/docs"); code != http.StatusSeeOther {
+		t.Errorf("upload to /docs: status %d", code)
+	}
+	if code := upload("/inbox"); code != http.StatusSeeOther {
+		t.Errorf("upload to /inbox: status %d", code)
+	}
+	if code := upload("/archive/2024"); code != http.StatusForbidden {
+		t.Errorf("upload to /archive/2024: status %d, want %d", code, http.StatusForbidden)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "archive", "2024")); !os.IsNotExist(err) {
+		t.Errorf("denied upload created its directory: %v", err)
+	}
+
+	for dir, want := range map[string]int{"/docs": http.StatusSeeOther, "/archive": http.StatusForbidden} {
+		if w := postForm(h, "/create-folder", url.Values{"currentPath": {dir}, "folderName": {"new"}}, session); w.Code != want {
+			t.Errorf("create folder in %s: status %d, want %d", dir, w.Code, want)
+		}
+	}
+
+	for item, want := range map[string]int{
+		"/archive/old.txt": http.StatusForbidden,
+		"/inbox/new.txt":   http.StatusForbidden,
+		"/docs/a.txt":      http.StatusSeeOther,
+	} {
+		if w := postForm(h, "/delete", url.Values{"items": {item}, "currentPath": {"/"}}, session); w.Code != want {
+			t.Errorf("delete of %s: status %d, want %d", item, w.Code, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "archive", "old.txt")); err != nil {
+		t.Errorf("file in a read-only share was deleted: %v", err)
+	}
+}
+
+func TestPathTraversal(t *testing.T) {
+	h := newTestServer(t, nil)
+	session := login(t, h, "alice")
+	outside := filepath.Join(filepath.Dir(baseDir), "outside")
+	writeFile(t, filepath.Join(outside, "secret.txt"), "secret")
+	writeFile(t, filepath.Join(baseDir, "docs", "a.txt"), "x")
+	if err := os.Symlink(outside, filepath.Join(baseDir, "escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	// The handler is called directly, as the mux would redirect the cleaned path
+	for _, target := range []string{"/..%2f..%2foutside/secret.txt", "/docs/..%2F..%2Foutside/secret.txt", "/escape/secret.txt"} {
+		w := httptest.NewRecorder()
+		fileHandler(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusBadRequest || strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("GET %s: status %d, want %d", target, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	for _, item := range []string{"../outside/secret.txt", "/docs/../../outside/secret.txt", "/escape/secret.txt"} {
+		if w := postForm(h, "/download", url.Values{"items": {item}}, session); w.Code != http.StatusBadRequest {
+			t.Errorf("download of %s: status %d, want %d", item, w.Code, http.StatusBadRequest)
+		}
+		if w := postForm(h, "/delete", url.Values{"items": {item}, "currentPath": {"/"}}, session); w.Code != http.StatusBadRequest {
+			t.Errorf("delete of %s: status %d, want %d", item, w.Code, http.StatusBadRequest)
+		}
+	}
+	if w := postFiles(t, h, "/upload", map[string]string{"currentPath": "/../outside"}, map[string]string{"b.txt": "x"}, session); w.Code != http.StatusBadRequest {
+		t.Errorf("upload above the base directory: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := postForm(h, "/create-folder", url.Values{"currentPath": {"/../outside"}, "folderName": {"new"}}, session); w.Code != http.StatusBadRequest {
+		t.Errorf("folder above the base directory: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "secret.txt")); err != nil {
+		t.Errorf("file outside the base directory was removed: %v", err)
+	}
+
+	// The base directory itself cannot be deleted
+	for _, item := range []string{"/", "", "/docs/.."} {
+		if w := postForm(h, "/delete", url.Values{"items": {item}, "currentPath": {"/"}}, session); w.Code != http.StatusBadRequest {
+			t.Errorf("delete of %q: status %d, want %d", item, w.Code, http.StatusBadRequest)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "docs", "a.txt")); err != nil {
+		t.Errorf("root deletion removed files: %v", err)
+	}
+}
+
+func TestDownloadFormat(t *testing.T) {
+	for _, configured := range []string{"", "tar.gz"} {
+		t.Run("format="+configured, func(t *testing.T) {
+			h := newTestServer(t, func(cfg *pkg.Config) { cfg.Archive.Format = configured })
+			writeFile(t, filepath.Join(baseDir, "a.txt"), "a")
+			writeFile(t, filepath.Join(baseDir, "docs", "b.txt"), "b")
+			tarNames := func(w *httptest.ResponseRecorder) []string {
+				t.Helper()
+				gz, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("not a gzip stream: %v", err)
+				}
+				var names []string
+				for tr := tar.NewReader(gz); ; {
+					header, err := tr.Next()
+					if err == io.EOF {
+						return names
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					names = append(names, header.Name)
+				}
+			}
+			items := "/download?items=/a.txt&items=/docs/b.txt"
+
+			w := get(h, items, nil)
+			if configured == "" {
+				if w.Header().Get("Content-Type") != "application/zip" || !strings.Contains(w.Header().Get("Content-Disposition"), "files.zip") {
+					t.Errorf("default download: %v", w.Header())
+				}
+				if got := strings.Join(zipNames(t, w), ","); got != "a.txt,docs/b.txt" {
+					t.Errorf("zip entries: %s", got)
+				}
+			} else if w.Header().Get("Content-Type") != "application/gzip" || strings.Join(tarNames(w), ",") != "a.txt,docs/b.txt" {
+				t.Errorf("configured tar.gz download: %v", w.Header())
+			}
+
+			w = get(h, items+"&format=tar.gz", nil)
+			if w.Header().Get("Content-Type") != "application/gzip" || !strings.Contains(w.Header().Get("Content-Disposition"), "files.tar.gz") {
+				t.Errorf("format=tar.gz: %v", w.Header())
+			}
+			if got := strings.Join(tarNames(w), ","); got != "a.txt,docs/b.txt" {
+				t.Errorf("tar entries: %s", got)
+			}
+			if w := get(h, items+"&format=zip", nil); w.Header().Get("Content-Type") != "application/zip" {
+				t.Errorf("format=zip: %v", w.Header())
+			}
+
+			// Single files stay raw whatever the format
+			if w := get(h, "/download?items=/a.txt&format=tar.gz", nil); w.Body.String() != "a" {
+				t.Errorf("single file download: %q", w.Body)
+			}
+			if w := get(h, items+"&format=rar", nil); w.Code != http.StatusBadRequest {
+				t.Errorf("format=rar: status %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestListToken(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Shares = []pkg.Share{{Path: "/public", ListToken: "s3cret"}}
+	})
+	writeFile(t, filepath.Join(baseDir, "public", "sub", "a.txt"), "a")
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/public/?token=s3cret", http.StatusOK},
+		{"/public/sub/?token=s3cret", http.StatusOK},
+		{"/public/?token=wrong", http.StatusForbidden},
+		{"/public/", http.StatusForbidden},
+		// Files are not listings and need no token
+		{"/public/sub/a.txt", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := get(h, tt.target, nil); w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.target, w.Code, tt.status)
+		}
+	}
+	if html := get(h, "/public/?token=s3cret", nil).Body.String(); !strings.Contains(html, "token=s3cret") {
+		t.Error("listing links do not pass the token on")
+	}
+	if w := get(h, "/public/", login(t, h, "alice")); w.Code != http.StatusOK {
+		t.Errorf("listing for a logged in user: status %d", w.Code)
+	}
+
+	w := get(h, "/admin/config", login(t, h, "admin"))
+	if strings.Contains(w.Body.String(), "s3cret") {
+		t.Errorf("config response exposes the list token: %s", w.Body)
+	}
+	if config.Shares[0].ListToken != "s3cret" {
+		t.Errorf("redaction changed the live token to %q", config.Shares[0].ListToken)
+	}
+}
+
+func TestMediaStreaming(t *testing.T) {
+	h := newTestServer(t, nil)
+	writeFile(t, filepath.Join(baseDir, "clip.mp4"), "0123456789")
+	writeFile(t, filepath.Join(baseDir, "song.MP3"), "0123456789")
+
+	w := get(h, "/clip.mp4", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "video/mp4" || w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("video: status %d, headers %v", w.Code, w.Header())
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "inline") || !strings.Contains(disposition, "clip.mp4") {
+		t.Errorf("video disposition %q, want inline", disposition)
+	}
+	if w := get(h, "/song.MP3", nil); w.Header().Get("Content-Type") != "audio/mpeg" {
+		t.Errorf("audio content type %q", w.Header().Get("Content-Type"))
+	}
+
+	r := httptest.NewRequest("GET", "/clip.mp4", nil)
+	r.Header.Set("Range", "bytes=2-5")
+	w = serve(h, r, nil)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" || w.Header().Get("Content-Range") != "bytes 2-5/10" {
+		t.Errorf("range: status %d, body %q, range %q", w.Code, w.Body, w.Header().Get("Content-Range"))
+	}
+	if w.Header().Get("Content-Type") != "video/mp4" {
+		t.Errorf("range content type %q", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestRedactUsernames(t *testing.T) {
+	saved := logger.Logger
+	logFile := filepath.Join(t.TempDir(), "server.log")
+	t.Cleanup(func() {
+		logger.LogSetup(pkg.Logging{LogFile: logFile})
+		logger.Logger = saved
+	})
+
+	for _, redact := range []bool{false, true} {
+		t.Run(fmt.Sprint("redact=", redact), func(t *testing.T) {
+			os.Remove(logFile)
+			logger.LogSetup(pkg.Logging{LogFile: logFile, LogSeverity: "info", RedactUsernames: redact, RedactKey: "key"})
+			h := newTestServer(t, nil)
+			session := login(t, h, "alice")
+			postFiles(t, h, "/upload", map[string]string{"currentPath": "/"}, map[string]string{"a.txt": "x"}, session)
+			postForm(h, "/create-folder", url.Values{"currentPath": {"/"}, "folderName": {"docs"}}, session)
+			postForm(h, "/delete", url.Values{"items": {"/a.txt"}, "currentPath": {"/"}}, session)
+
+			data, err := os.ReadFile(logFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			logged := string(data)
+			for _, message := range []string{"logged in", "Item deleted"} {
+				if !strings.Contains(logged, message) {
+					t.Errorf("log lacks %q", message)
+				}
+			}
+			if pseudonym := logger.User("alice"); redact {
+				if strings.Contains(logged, "alice") || !strings.Contains(logged, pseudonym) {
+					t.Errorf("log is not redacted:\n%s", logged)
+				}
+			} else if !strings.Contains(logged, "User alice logged in") {
+				t.Errorf("log lacks the username:\n%s", logged)
+			}
+		})
+	}
+}
+
+func TestConcurrentSessions(t *testing.T) {
+	h := newTestServer(t, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(user string) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				w := postForm(h, "/login", url.Values{"username": {user}, "password": {testPassword}}, nil)
+				var session *http.Cookie
+				for _, cookie := range w.Result().Cookies() {
+					if cookie.Name == auth.SessionCookieName {
+						session = cookie
+					}
+				}
+				if session == nil {
+					t.Errorf("login of %s: status %d, no session", user, w.Code)
+					return
+				}
+				if w := get(h, "/check-session", session); w.Code != http.StatusOK {
+					t.Errorf("check of a new session: status %d", w.Code)
+				}
+				postForm(h, "/logout", nil, session)
+				if w := get(h, "/check-session", session); w.Code != http.StatusUnauthorized {
+					t.Errorf("check after logout: status %d", w.Code)
+				}
+			}
+		}(testUsers[i%len(testUsers)])
+	}
+	wg.Wait()
+}
+
+func TestRunServerShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+	stop := make(chan os.Signal, 1)
+	result := make(chan error, 1)
+	go func() { result <- runServer(server, func() error { return server.Serve(listener) }, stop) }()
+
+	// A request in progress when the signal arrives is completed
+	response := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + listener.Addr().String() + "/")
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		response <- string(body)
+	}()
+	<-started
+	stop <- syscall.SIGTERM
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if got := <-response; got != "done" {
+		t.Errorf("request in progress: %q, want done", got)
+	}
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("runServer = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runServer did not return after the signal")
+	}
+	if _, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		t.Error("server still accepts connections")
+	}
+
+	// Errors of listen other than a closed server are returned
+	failed := errors.New("address in use")
+	if err := runServer(&http.Server{}, func() error { return failed }, make(chan os.Signal)); err != failed {
+		t.Errorf("runServer = %v, want %v", err, failed)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	h := newTestServer(t, nil)
+	session := login(t, h, "alice")
+	writeFile(t, filepath.Join(baseDir, "a.txt"), "a")
+	writeFile(t, filepath.Join(baseDir, "b.txt"), "b")
+	type summary struct {
+		Results []struct {
+			Status     int    `json:"status"`
+			Error      string `json:"error"`
+			Skipped    bool   `json:"skipped"`
+			RolledBack bool   `json:"rolledBack"`
+		} `json:"results"`
+		Succeeded, Failed, Skipped int
+		RolledBack                 bool `json:"rolledBack"`
+	}
+	batch := func(target, body string) (int, summary) {
+		t.Helper()
+		w := postJSON(h, target, body, session)
+		var got summary
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v: %s", body, err, w.Body)
+		}
+		return w.Code, got
+	}
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(baseDir, filepath.FromSlash(name)))
+		return err == nil
+	}
+
+	status, got := batch("/api/batch", `[
+		{"op":"mkdir","path":"/new"},
+		{"op":"copy","path":"/a.txt","to":"/new/a.txt"},
+		{"op":"move","path":"/missing.txt","to":"/new/m.txt"},
+		{"op":"delete","path":"/b.txt"}
+	]`)
+	if status != http.StatusMultiStatus || got.Succeeded != 3 || got.Failed != 1 || got.Results[2].Status != http.StatusNotFound {
+		t.Errorf("mixed batch: status %d, %+v", status, got)
+	}
+	if !exists("new/a.txt") || !exists("a.txt") || exists("b.txt") {
+		t.Error("mixed batch did not apply the successful operations")
+	}
+
+	writeFile(t, filepath.Join(baseDir, "c.txt"), "c")
+	status, got = batch("/api/batch?transactional=1", `[
+		{"op":"delete","path":"/c.txt"},
+		{"op":"mkdir","path":"/tx"},
+		{"op":"copy","path":"/a.txt","to":"/new/a.txt"},
+		{"op":"delete","path":"/a.txt"}
+	]`)
+	if status != http.StatusMultiStatus || !got.RolledBack || got.Results[2].Status != http.StatusConflict || !got.Results[3].Skipped {
+		t.Errorf("transactional batch: status %d, %+v", status, got)
+	}
+	if !exists("c.txt") || exists("tx") || !exists("a.txt") {
+		t.Error("transactional batch was not rolled back")
+	}
+	// The deleted items are staged outside the served tree and removed afterwards
+	for _, dir := range []string{baseDir, filepath.Dir(baseDir)} {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), ".sfs-batch-") {
+				t.Errorf("staging directory left in %s", dir)
+			}
+		}
+	}
+
+	// A copied symlink must not lead outside base_dir
+	outside := filepath.Join(filepath.Dir(baseDir), "outside")
+	writeFile(t, filepath.Join(outside, "secret.txt"), "secret")
+	if err := os.Symlink(outside, filepath.Join(baseDir, "new", "link")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	status, got = batch("/api/batch", `[{"op":"copy","path":"/new","to":"/copy"}]`)
+	if status != http.StatusMultiStatus || got.Results[0].Status != http.StatusForbidden || exists("copy") {
+		t.Errorf("copy of an escaping symlink: status %d, %+v", status, got)
+	}
+}
+
+func TestSessionRotation(t *testing.T) {
+	for _, rotate := range []bool{true, false} {
+		t.Run(fmt.Sprint("rotate=", rotate), func(t *testing.T) {
+			h := newTestServer(t, func(cfg *pkg.Config) { cfg.Auth.RotateSessionOnLogin = &rotate })
+			loginWith := func(user string, old *http.Cookie) *http.Cookie {
+				t.Helper()
+				w := postForm(h, "/login", url.Values{"username": {user}, "password": {testPassword}}, old)
+				for _, cookie := range w.Result().Cookies() {
+					if cookie.Name == auth.SessionCookieName {
+						return cookie
+					}
+				}
+				t.Fatalf("login of %s: status %d, no session cookie", user, w.Code)
+				return nil
+			}
+			valid := func(session *http.Cookie) bool {
+				return get(h, "/check-session", session).Code == http.StatusOK
+			}
+
+			old := loginWith("alice", nil)
+			renewed := loginWith("alice", old)
+			if renewed.Value == old.Value || !valid(renewed) {
+				t.Fatalf("login with a session: token reused or invalid")
+			}
+			if valid(old) == rotate {
+				t.Errorf("session sent with the login still valid: %t", valid(old))
+			}
+
+			// A planted token, unknown to the server, is never adopted
+			planted := &http.Cookie{Name: auth.SessionCookieName, Value: strings.Repeat("ab", 32)}
+			if fresh := loginWith("alice", planted); fresh.Value == planted.Value || valid(planted) {
+				t.Error("planted token was adopted")
+			}
+
+			bob := loginWith("bob", nil)
+			loginWith("alice", bob)
+			if valid(bob) == rotate {
+				t.Errorf("session of another user sent with the login still valid: %t", valid(bob))
+			}
+		})
+	}
+}
+
+func TestUploadSizeLimit(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) { cfg.WebServer.MaxUploadSize = "1KB" })
+	session := login(t, h, "alice")
+
+	if w := postFiles(t, h, "/upload", map[string]string{"currentPath": "/"}, map[string]string{"small.txt": "x"}, session); w.Code != http.StatusSeeOther {
+		t.Errorf("upload under the limit: status %d: %s", w.Code, w.Body)
+	}
+	w := postFiles(t, h, "/upload", map[string]string{"currentPath": "/"}, map[string]string{"big.txt": strings.Repeat("x", 2048)}, session)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "1KB") {
+		t.Errorf("upload over the limit: status %d: %s", w.Code, w.Body)
+	}
+
+	// Without a declared length the body is cut at the limit
+	body, contentType := multipartBody(t, map[string]string{"currentPath": "/"}, map[string]string{"chunked.txt": strings.Repeat("x", 2048)})
+	r := httptest.NewRequest("POST", "/upload", io.MultiReader(bytes.NewReader(body)))
+	r.ContentLength = -1
+	r.Header.Set("Content-Type", contentType)
+	if w := serve(h, r, session); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("unsized upload over the limit: status %d: %s", w.Code, w.Body)
+	}
+	for _, name := range []string{"big.txt", "chunked.txt"} {
+		if _, err := os.Stat(filepath.Join(baseDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was stored: %v", name, err)
+		}
+	}
+}
+
+func TestRename(t *testing.T) {
+	h := newTestServer(t, nil)
+	session := login(t, h, "alice")
+	writeFile(t, filepath.Join(baseDir, "docs", "a.txt"), "a")
+	writeFile(t, filepath.Join(baseDir, "docs", "b.txt"), "b")
+	writeFile(t, filepath.Join(baseDir, "docs", "sub", "c.txt"), "c")
+	rename := func(oldName, newName string) *httptest.ResponseRecorder {
+		return postForm(h, "/rename", url.Values{"currentPath": {"/docs"}, "oldName": {oldName}, "newName": {newName}}, session)
+	}
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(baseDir, filepath.FromSlash(name)))
+		return string(data)
+	}
+
+	if w := rename("a.txt", "renamed.txt"); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/docs" {
+		t.Errorf("rename of a file: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	if read("docs/renamed.txt") != "a" || read("docs/a.txt") != "" {
+		t.Error("file was not renamed")
+	}
+	if w := rename("sub", "folder"); w.Code != http.StatusSeeOther || read("docs/folder/c.txt") != "c" {
+		t.Errorf("rename of a directory: status %d", w.Code)
+	}
+
+	if w := rename("renamed.txt", "b.txt"); w.Code != http.StatusConflict {
+		t.Errorf("rename onto an existing name: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	if read("docs/b.txt") != "b" || read("docs/renamed.txt") != "a" {
+		t.Error("a conflicting rename changed the files")
+	}
+
+	for _, tt := range []struct{ oldName, newName string }{
+		{"b.txt", "../b.txt"},
+		{"b.txt", "x/b.txt"},
+		{"..", "up"},
+		{"b.txt", ""},
+	} {
+		if w := rename(tt.oldName, tt.newName); w.Code != http.StatusBadRequest {
+			t.Errorf("rename of %q to %q: status %d, want %d", tt.oldName, tt.newName, w.Code, http.StatusBadRequest)
+		}
+	}
+	if w := rename("missing.txt", "found.txt"); w.Code != http.StatusNotFound {
+		t.Errorf("rename of a missing file: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := postForm(h, "/rename", url.Values{"currentPath": {"/docs"}, "oldName": {"b.txt"}, "newName": {"c.txt"}}, nil); w.Code == http.StatusSeeOther && read("docs/c.txt") != "" {
+		t.Error("anonymous rename succeeded")
+	}
+}
+
+func TestURLLengthLimit(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) { cfg.WebServer.MaxURLLength = 64 })
+	writeFile(t, filepath.Join(baseDir, "a.txt"), "a")
+	long := "/" + strings.Repeat("a", 64)
+
+	if w := get(h, "/a.txt", nil); w.Code != http.StatusOK {
+		t.Errorf("short path: status %d", w.Code)
+	}
+	if w := get(h, long[:64], nil); w.Code != http.StatusNotFound {
+		t.Errorf("path at the limit: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := get(h, long, nil); w.Code != http.StatusRequestURITooLong {
+		t.Errorf("path over the limit: status %d, want %d", w.Code, http.StatusRequestURITooLong)
+	}
+	// The path is measured as sent, with its escapes
+	if w := get(h, "/"+strings.Repeat("%C3%A9", 11), nil); w.Code != http.StatusRequestURITooLong {
+		t.Errorf("escaped path over the limit: status %d, want %d", w.Code, http.StatusRequestURITooLong)
+	}
+
+	h = newTestServer(t, func(cfg *pkg.Config) { cfg.WebServer.MaxURLLength = -1 })
+	if w := get(h, "/"+strings.Repeat("a", 10000), nil); w.Code == http.StatusRequestURITooLong {
+		t.Error("path rejected without a limit")
+	}
+}
+
+func TestDownloadUnicodeFilename(t *testing.T) {
+	h := newTestServer(t, nil)
+	writeFile(t, filepath.Join(baseDir, "résumé.pdf"), "pdf")
+
+	w := get(h, "/download?items="+url.QueryEscape("/résumé.pdf"), nil)
+	want := `attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`
+	if w.Code != http.StatusOK || w.Header().Get("Content-Disposition") != want {
+		t.Errorf("download: status %d, Content-Disposition %q, want %q", w.Code, w.Header().Get("Content-Disposition"), want)
+	}
+	_, params, err := mime.ParseMediaType(w.Header().Get("Content-Disposition"))
+	if err != nil || params["filename"] != "résumé.pdf" {
+		t.Errorf("parsed filename %q, %v", params["filename"], err)
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) { cfg.Search.Index = true })
+	session := login(t, h, "alice")
+	writeFile(t, filepath.Join(baseDir, "docs", "report.pdf"), "x")
+	search.SetupIndex(config.Search, baseDir)
+	t.Cleanup(func() { search.SetupIndex(pkg.Search{}, "") })
+	for deadline := time.Now().Add(5 * time.Second); !search.IndexReady(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("index not built")
+		}
+	}
+
+	// Files written behind the server's back appear with the next rebuild only
+	writeFile(t, filepath.Join(baseDir, "docs", "report-external.txt"), "x")
+	postFiles(t, h, "/upload", map[string]string{"currentPath": "/docs"}, map[string]string{"report-upload.txt": "x"}, session)
+	var response searchResponse
+	getJSON(t, h, "/search?q=report", nil, &response)
+	paths := strings.Split(resultPaths(response.Results), ",")
+	sort.Strings(paths)
+	if got := strings.Join(paths, ","); got != "/docs/report-upload.txt,/docs/report.pdf" || response.Truncated {
+		t.Errorf("indexed search: %s, truncated %t", got, response.Truncated)
+	}
+
+	postForm(h, "/delete", url.Values{"items": {"/docs/report.pdf"}, "currentPath": {"/docs"}}, session)
+	getJSON(t, h, "/search?q=report.pdf", nil, &response)
+	if got := resultPaths(response.Results); got != "" {
+		t.Errorf("deleted file still found: %s", got)
+	}
+}
+
+func TestHtpasswdLogin(t *testing.T) {
+	h := newTestServer(t, nil)
+	login(t, h, "alice")
+	for _, credentials := range [][2]string{{"alice", "wrong"}, {"mallory", testPassword}, {"", ""}} {
+		w := postForm(h, "/login", url.Values{"username": {credentials[0]}, "password": {credentials[1]}}, nil)
+		if w.Code == http.StatusSeeOther || !strings.Contains(w.Body.String(), "Authentication failed") {
+			t.Errorf("login of %q with %q: status %d", credentials[0], credentials[1], w.Code)
+		}
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == auth.SessionCookieName {
+				t.Errorf("failed login of %q set a session", credentials[0])
+			}
+		}
+	}
+
+	// Entries changed on disk are picked up on reload, e.g. on SIGHUP
+	hash, err := bcrypt.GenerateFromPassword([]byte("new password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, config.Auth.HtpasswdFile, "alice:"+string(hash)+"\n")
+	if err := auth.ReloadHtpasswd(); err != nil {
+		t.Fatal(err)
+	}
+	if w := postForm(h, "/login", url.Values{"username": {"alice"}, "password": {"new password"}}, nil); w.Code != http.StatusSeeOther {
+		t.Errorf("login with the bcrypt entry: status %d", w.Code)
+	}
+	if w := postForm(h, "/login", url.Values{"username": {"bob"}, "password": {testPassword}}, nil); w.Code == http.StatusSeeOther {
+		t.Error("removed user can still log in")
+	}
+}
+
+func TestDownloadDirectory(t *testing.T) {
+	h := newTestServer(t, nil)
+	for _, name := range []string{"docs/a.txt", "docs/sub/b.txt", "docs/sub/deeper/c.txt", "top.txt"} {
+		writeFile(t, filepath.Join(baseDir, filepath.FromSlash(name)), name)
+	}
+	outside := filepath.Join(filepath.Dir(baseDir), "outside")
+	writeFile(t, filepath.Join(outside, "secret.txt"), "secret")
+	if err := os.Symlink(outside, filepath.Join(baseDir, "docs", "escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	w := get(h, "/download?items=/docs", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("directory download: status %d, type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if got, want := strings.Join(zipNames(t, w), ","), "docs/a.txt,docs/sub/b.txt,docs/sub/deeper/c.txt"; got != want {
+		t.Errorf("entries %s, want %s", got, want)
+	}
+
+	w = get(h, "/download?items=/docs/sub&items=/top.txt", nil)
+	if got, want := strings.Join(zipNames(t, w), ","), "docs/sub/b.txt,docs/sub/deeper/c.txt,top.txt"; got != want {
+		t.Errorf("entries of a directory and a file %s, want %s", got, want)
+	}
+}
+
+func TestZipMember(t *testing.T) {
+	h := newTestServer(t, nil)
+	session := login(t, h, "alice")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, member := range []struct {
+		name, content string
+		method        uint16
+	}{
+		{"docs/readme.txt", "deflated readme", zip.Deflate},
+		{"data.json", `{"stored":true}`, zip.Store},
+		{"../evil.txt", "slipped", zip.Deflate},
+		{"empty/", "", zip.Store},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: member.name, Method: member.method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, member.content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(baseDir, "dist", "release.zip"), buf.String())
+	writeFile(t, filepath.Join(baseDir, "notes.txt"), "not a zip")
+	writeFile(t, filepath.Join(filepath.Dir(baseDir), "outside.zip"), buf.String())
+
+	tests := []struct {
+		archive, entry string
+		status         int
+		body           string
+	}{
+		{"/dist/release.zip", "docs/readme.txt", http.StatusOK, "deflated readme"},
+		{"dist/release.zip", "data.json", http.StatusOK, `{"stored":true}`},
+		{"/dist/release.zip", "missing.txt", http.StatusNotFound, ""},
+		{"/dist/release.zip", "empty/", http.StatusNotFound, ""},
+		{"/dist/release.zip", "../evil.txt", http.StatusBadRequest, ""},
+		{"/dist/release.zip", "/docs/readme.txt", http.StatusBadRequest, ""},
+		{"/dist/release.zip", "docs/./readme.txt", http.StatusBadRequest, ""},
+		{"/dist/release.zip", `docs\readme.txt`, http.StatusBadRequest, ""},
+		{"/dist/release.zip", "", http.StatusBadRequest, ""},
+		{"/../outside.zip", "data.json", http.StatusBadRequest, ""},
+		{"/dist/missing.zip", "data.json", http.StatusNotFound, ""},
+		{"/dist", "data.json", http.StatusNotFound, ""},
+		{"/notes.txt", "data.json", http.StatusUnsupportedMediaType, ""},
+	}
+	for _, tt := range tests {
+		target := "/zip-member?" + url.Values{"archive": {tt.archive}, "entry": {tt.entry}}.Encode()
+		w := get(h, target, session)
+		if w.Code != tt.status {
+			t.Errorf("archive %s entry %q: status %d, want %d", tt.archive, tt.entry, w.Code, tt.status)
+			continue
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("archive %s entry %q: body %q, want %q", tt.archive, tt.entry, w.Body.String(), tt.body)
+		}
+	}
+
+	w := get(h, "/zip-member?archive=/dist/release.zip&entry=data.json", session)
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type %q, want application/json", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment") || !strings.Contains(got, "data.json") {
+		t.Errorf("Content-Disposition %q, want an attachment named data.json", got)
+	}
+
+	// Stored members are served from the archive and support ranges
+	r := httptest.NewRequest("GET", "/zip-member?archive=/dist/release.zip&entry=data.json", nil)
+	r.AddCookie(session)
+	r.Header.Set("Range", "bytes=2-7")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	if rw.Code != http.StatusPartialContent || rw.Body.String() != "stored" {
+		t.Errorf("range of a stored member: status %d body %q, want %d %q", rw.Code, rw.Body.String(), http.StatusPartialContent, "stored")
+	}
+}
+
+func TestDenyResponse(t *testing.T) {
+	tests := []struct {
+		denyResponse            string
+		traversal, access, list int
+	}{
+		{"", http.StatusBadRequest, http.StatusForbidden, http.StatusForbidden},
+		{"403", http.StatusForbidden, http.StatusForbidden, http.StatusForbidden},
+		{"404", http.StatusNotFound, http.StatusNotFound, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		h := newTestServer(t, func(cfg *pkg.Config) {
+			cfg.WebServer.DenyResponse = tt.denyResponse
+			cfg.Shares = []pkg.Share{
+				{Path: "/private", AllowedUsers: []string{"bob"}},
+				{Path: "/archive", Deny: []string{"write"}},
+				{Path: "/public", ListToken: "s3cret"},
+			}
+		})
+		session := login(t, h, "alice")
+		writeFile(t, filepath.Join(filepath.Dir(baseDir), "outside", "secret.txt"), "secret")
+		writeFile(t, filepath.Join(baseDir, "private", "a.txt"), "private")
+		writeFile(t, filepath.Join(baseDir, "public", "b.txt"), "public")
+
+		// The handler is called directly, as the mux would redirect the cleaned path
+		traversal := httptest.NewRecorder()
+		fileHandler(traversal, httptest.NewRequest("GET", "/..%2f..%2foutside/secret.txt", nil))
+
+		checks := []struct {
+			name string
+			w    *httptest.ResponseRecorder
+			want int
+		}{
+			{"traversal", traversal, tt.traversal},
+			{"download beyond the base directory", postForm(h, "/download", url.Values{"items": {"../outside/secret.txt"}}, session), tt.traversal},
+			{"share of another user", get(h, "/private/a.txt", session), tt.access},
+			{"write to a read-only share", postForm(h, "/create-folder", url.Values{"currentPath": {"/archive"}, "folderName": {"new"}}, session), tt.access},
+			{"listing without its token", get(h, "/public/", nil), tt.list},
+		}
+		for _, check := range checks {
+			if check.w.Code != check.want {
+				t.Errorf("deny_response %q: %s: status %d, want %d", tt.denyResponse, check.name, check.w.Code, check.want)
+			}
+			if tt.denyResponse == "404" && check.w.Body.String() != "404 page not found\n" {
+				t.Errorf("deny_response %q: %s: body %q differs from a missing path", tt.denyResponse, check.name, check.w.Body.String())
+			}
+		}
+		if w := get(h, "/public/?token=s3cret", nil); w.Code != http.StatusOK {
+			t.Errorf("deny_response %q: listing with its token: status %d", tt.denyResponse, w.Code)
+		}
+	}
+}
+
+func TestLinkAlternate(t *testing.T) {
+	h := newTestServer(t, func(cfg *pkg.Config) {
+		cfg.Preview.MaxSize = 1
+	})
+	writeFile(t, filepath.Join(baseDir, "docs", "read me.md"), "# Title")
+	writeFile(t, filepath.Join(baseDir, "data.CSV"), "a,b\n1,2\n")
+	writeFile(t, filepath.Join(baseDir, "data.tsv"), "a\tb\n")
+	writeFile(t, filepath.Join(baseDir, "notes.txt"), "plain")
+	writeFile(t, filepath.Join(baseDir, "big.csv"), strings.Repeat("x", 1<<20+1))
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/docs/read%20me.md", `</docs/read%20me.md?render=1>; rel="alternate"; type="text/html"`},
+		{"/data.CSV", `</data.CSV?preview=1>; rel="alternate"; type="text/html"`},
+		{"/data.tsv", `</data.tsv?preview=1>; rel="alternate"; type="text/html"`},
+		{"/notes.txt", ""},
+		{"/big.csv", ""},
+	}
+	for _, tt := range tests {
+		w := get(h, tt.target, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d", tt.target, w.Code)
+			continue
+		}
+		if got := w.Header().Get("Link"); got != tt.want {
+			t.Errorf("GET %s: Link %q, want %q", tt.target, got, tt.want)
+		}
+	}
+
+	// The advertised previews are served as HTML
+	for _, target := range []string{"/docs/read%20me.md?render=1", "/data.CSV?preview=1"} {
+		w := get(h, target, nil)
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+			t.Errorf("GET %s: status %d, Content-Type %q", target, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Description: This file implements the acme package, which obtains and renews certificates automatically via ACME (Let's Encrypt).
+package acme
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"simple_file_server/pkg"
+
+	xacme "golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// defaultCacheDir - directory used to store certificates when none is configured
+const defaultCacheDir = "certs"
+
+// ValidateDomains - checks that every domain is a plain host name suitable for an ACME certificate
+func ValidateDomains(domains []string) error {
+	if len(domains) == 0 {
+		return fmt.Errorf("at least one domain must be specified")
+	}
+	for _, domain := range domains {
+		switch {
+		case domain == "":
+			return fmt.Errorf("empty domain")
+		case strings.Contains(domain, "://"):
+			return fmt.Errorf("domain %q must not contain a scheme", domain)
+		case strings.ContainsAny(domain, ":/ "):
+			return fmt.Errorf("domain %q must not contain a port, path or spaces", domain)
+		case strings.HasPrefix(domain, "*."):
+			return fmt.Errorf("wildcard domain %q is not supported by the HTTP-01 challenge", domain)
+		case net.ParseIP(domain) != nil:
+			return fmt.Errorf("IP address %q cannot be used as an ACME domain", domain)
+		case !strings.Contains(domain, "."):
+			return fmt.Errorf("domain %q is not fully qualified", domain)
+		}
+	}
+	return nil
+}
+
+// NewManager - creates the certificate manager for the configured domains
+func NewManager(config pkg.ACME) (*autocert.Manager, error) {
+	if err := ValidateDomains(config.Domains); err != nil {
+		return nil, err
+	}
+
+	cacheDir := config.CacheDir
+	if cacheDir == "" {
+		cacheDir = defaultCacheDir
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(config.Domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      config.Email,
+	}
+	if config.DirectoryURL != "" {
+		manager.Client = &xacme.Client{DirectoryURL: config.DirectoryURL}
+	}
+	return manager, nil
+}
@@ -0,0 +1,129 @@
+// Description: This file contains helpers for building ZIP and tar archive headers.
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// StoreLevel - compression level storing data without compression
+const StoreLevel = flate.NoCompression
+
+// ParseCompressionLevel - parses "store" or a level from 0 (store) to 9 (best).
+// An empty value selects the default level.
+func ParseCompressionLevel(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "":
+		return flate.DefaultCompression, nil
+	case "store":
+		return StoreLevel, nil
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil || level < flate.NoCompression || level > flate.BestCompression {
+		return 0, fmt.Errorf("invalid compression level %q (expected store or 0-9)", value)
+	}
+	return level, nil
+}
+
+// Formats of the archives built for downloads
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+// ParseArchiveFormat - parses "zip" or "tar.gz" ("tgz" is accepted as well).
+// An empty value selects zip.
+func ParseArchiveFormat(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", ArchiveZip:
+		return ArchiveZip, nil
+	case ArchiveTarGz, "tgz":
+		return ArchiveTarGz, nil
+	}
+	return "", fmt.Errorf("invalid archive format %q (expected zip or tar.gz)", value)
+}
+
+// NewZipWriter - returns a ZIP writer deflating entries at the given level
+func NewZipWriter(w io.Writer, level int) *zip.Writer {
+	zipWriter := zip.NewWriter(w)
+	zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, level)
+	})
+	return zipWriter
+}
+
+// ZipMethod - returns the ZIP method for the level: entries are stored uncompressed
+// at StoreLevel and deflated otherwise
+func ZipMethod(level int) uint16 {
+	if level == StoreLevel {
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+// NewGzipWriter - returns a gzip writer compressing at the given level
+func NewGzipWriter(w io.Writer, level int) (*gzip.Writer, error) {
+	return gzip.NewWriterLevel(w, level)
+}
+
+// zipUnixExtraID - Info-ZIP "ux" extra field carrying the Unix UID and GID
+const zipUnixExtraID = 0x7875
+
+// fileOwner - returns the UID and GID of the file when the platform provides them
+func fileOwner(info os.FileInfo) (uid, gid uint32, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return stat.Uid, stat.Gid, true
+}
+
+// ZipHeader - builds a ZIP header for the file. The Unix mode (external attributes)
+// and modification time are always stored; the owner is stored in the "ux" extra field when includeOwner is set.
+func ZipHeader(info os.FileInfo, name string, includeOwner bool) (*zip.FileHeader, error) {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	if uid, gid, ok := fileOwner(info); includeOwner && ok {
+		extra := make([]byte, 15)
+		binary.LittleEndian.PutUint16(extra[0:], zipUnixExtraID)
+		binary.LittleEndian.PutUint16(extra[2:], 11)
+		extra[4] = 1 // version
+		extra[5] = 4 // UID size
+		binary.LittleEndian.PutUint32(extra[6:], uid)
+		extra[10] = 4 // GID size
+		binary.LittleEndian.PutUint32(extra[11:], gid)
+		header.Extra = append(header.Extra, extra...)
+	}
+	return header, nil
+}
+
+// TarHeader - builds a tar header for the file with its mode and modification time.
+// The owner (UID, GID and names) is kept only when includeOwner is set.
+func TarHeader(info os.FileInfo, name string, includeOwner bool) (*tar.Header, error) {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return nil, err
+	}
+	header.Name = name
+	// FileInfoHeader fills the owner from syscall.Stat_t on Unix
+	if !includeOwner {
+		header.Uid, header.Gid = 0, 0
+		header.Uname, header.Gname = "", ""
+	}
+	return header, nil
+}
@@ -0,0 +1,23 @@
+package pkg
+
+import "testing"
+
+func TestParseArchiveFormat(t *testing.T) {
+	tests := []struct {
+		value, want string
+		ok          bool
+	}{
+		{"", ArchiveZip, true},
+		{"zip", ArchiveZip, true},
+		{"ZIP", ArchiveZip, true},
+		{"tar.gz", ArchiveTarGz, true},
+		{" tgz ", ArchiveTarGz, true},
+		{"rar", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseArchiveFormat(tt.value)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseArchiveFormat(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
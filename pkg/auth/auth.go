@@ -1,10 +1,11 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
 	"time"
 
 	"simple_file_server/pkg"
@@ -16,15 +17,78 @@ import (
 // UserSession - represents a user session
 type UserSession struct {
     Username string
+    Created  time.Time
     Expires  time.Time
 }
 
 // sessions - stores active user sessions
-var sessions = make(map[string]UserSession)
+var sessions = NewSessionStore()
 
 // Configuration for sessions
 const SessionCookieName = "session_token"
-const sessionDuration = time.Hour * 24 // Session duration 1 hour
+
+// config - authentication configuration loaded at startup
+var config pkg.Auth
+
+// Setup - applies the authentication configuration and builds the chain of backends
+func Setup(cfg pkg.Auth) error {
+    if cfg.HtpasswdFile != "" {
+        backend, err := NewHtpasswdBackend(cfg.HtpasswdFile)
+        if err != nil {
+            return fmt.Errorf("error loading htpasswd file: %v", err)
+        }
+        htpasswd = backend
+        RegisterBackend(BackendHtpasswd, backend)
+    }
+    names := cfg.Backends
+    if len(names) == 0 && htpasswd != nil {
+        // Without configured backends the htpasswd file is tried before PAM
+        names = []string{BackendHtpasswd, BackendPAM}
+    }
+    backends, err := NewChain(names)
+    if err != nil {
+        return err
+    }
+    config = cfg
+    chain = backends
+    return nil
+}
+
+// maxFailureDelay - upper bound for the delay applied to failed logins
+const maxFailureDelay = 10 * time.Second
+
+// waitFailureDelay - pads a failed login so that it takes at least the configured
+// delay counted from the start of authentication, independently of how long the
+// backend took to reject it. Only the current request goroutine is blocked.
+func waitFailureDelay(r *http.Request, started time.Time) {
+    delay := config.FailureDelay
+    if delay <= 0 {
+        return
+    }
+    if delay > maxFailureDelay {
+        delay = maxFailureDelay
+    }
+    remaining := delay - time.Since(started)
+    if remaining <= 0 {
+        return
+    }
+    timer := time.NewTimer(remaining)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+    case <-r.Context().Done():
+    }
+}
+
+// IsAdmin - checks whether the user is listed as an administrator
+func IsAdmin(username string) bool {
+    for _, admin := range config.Admins {
+        if admin == username {
+            return true
+        }
+    }
+    return false
+}
 
 // PamAuthenticate - performs user authentication using PAM
 func PamAuthenticate(username, password string) error {
@@ -50,47 +114,111 @@ func PamAuthenticate(username, password string) error {
     return tx.Authenticate(0)
 }
 
-// GenerateSessionToken - generates a random token for the session
+// sessionTokenBytes - random bytes of a session token, hex encoded to twice as many characters
+const sessionTokenBytes = 32
+
+// GenerateSessionToken - generates a random token for the session from crypto/rand
 func GenerateSessionToken() string {
-    return fmt.Sprintf("%d", time.Now().UnixNano())
+    b := make([]byte, sessionTokenBytes)
+    if _, err := rand.Read(b); err != nil {
+        // Without a source of randomness no session can be issued safely
+        panic(fmt.Sprintf("reading random session token: %v", err))
+    }
+    return hex.EncodeToString(b)
 }
 
 // IsValidSessionToken - checks the validity of the session token
 func IsValidSessionToken(token string) bool {
-    session, exists := sessions[token]
-    if (!exists) {
-        return false
-    }
-    if session.Expires.Before(time.Now()) {
-        delete(sessions, token)
-        return false
+    return sessions.Validate(token)
+}
+
+// SessionUsername - returns the user of the request's session, or an empty string when not logged in
+func SessionUsername(r *http.Request) string {
+    session, _ := requestSession(r)
+    return session.Username
+}
+
+// RevokeUserSessions - deletes every session belonging to the user and returns how many were removed
+func RevokeUserSessions(username string) int {
+    return sessions.DeleteUser(username)
+}
+
+// Policies applied when a user reaches max_sessions_per_user
+const (
+    // SessionLimitEvict - the oldest session of the user is ended to make room for the new one
+    SessionLimitEvict = "evict_oldest"
+    // SessionLimitReject - the new login is refused
+    SessionLimitReject = "reject"
+)
+
+// ValidSessionLimitPolicy - checks the configured session limit policy
+func ValidSessionLimitPolicy(policy string) error {
+    switch policy {
+    case "", SessionLimitEvict, SessionLimitReject:
+        return nil
     }
-    return true
+    return fmt.Errorf("unknown session limit policy %q (expected evict_oldest or reject)", policy)
 }
 
 // AuthMiddlewareForActions - protects routes for certain actions
 func AuthMiddlewareForActions(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        cookie, err := r.Cookie(SessionCookieName)
-        if err != nil || !IsValidSessionToken(cookie.Value) {
+        // Извлекаем имя пользователя из сессии
+        session, ok := requestSession(r)
+        if !ok {
             http.Redirect(w, r, "/login", http.StatusSeeOther)
             return
         }
-
-        // Извлекаем имя пользователя из сессии
-        session := sessions[cookie.Value]
         r.Header.Set("X-User", session.Username)
 
-        // Check if the user is trying to perform an action that requires authorization
-        if r.Method == "POST" && (strings.HasPrefix(r.URL.Path, "/upload") ||
-            strings.HasPrefix(r.URL.Path, "/delete") ||
-            strings.HasPrefix(r.URL.Path, "/create-folder")) {
-            // If the request is POST and directed to upload, delete, or create folder, check authorization
-            next.ServeHTTP(w, r)
-        } else {
-            // If it is a GET request or another action that does not require authorization, allow access
-            next.ServeHTTP(w, r)
+        // Actions change state and only accept POST
+        if r.Method != "POST" {
+            w.Header().Set("Allow", "POST")
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// AdminMiddleware - protects routes that are available only to administrators
+func AdminMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        session, ok := requestSession(r)
+        if !ok {
+            http.Redirect(w, r, "/login", http.StatusSeeOther)
+            return
+        }
+        if !IsAdmin(session.Username) {
+            http.Error(w, "Forbidden", http.StatusForbidden)
+            logger.WithRequest(r).Warnf("Admin access denied to %s for user: %s from IP: %s", r.URL.Path, logger.User(session.Username), r.RemoteAddr)
+            return
         }
+        r.Header.Set("X-User", session.Username)
+        next.ServeHTTP(w, r)
+    })
+}
+
+// RevokeSessionsHandler - handles /admin/revoke-sessions, logging the given user out everywhere
+func RevokeSessionsHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != "POST" {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    username := r.FormValue("username")
+    if username == "" {
+        http.Error(w, "Username is required", http.StatusBadRequest)
+        return
+    }
+
+    revoked := RevokeUserSessions(username)
+    logger.WithRequest(r).Infof("Revoked %d sessions of user %s by IP: %s, User: %s", revoked, logger.User(username), r.RemoteAddr, logger.User(r.Header.Get("X-User")))
+    pkg.RenderJSON(w, http.StatusOK, struct {
+        Username string `json:"username"`
+        Revoked  int    `json:"revoked"`
+    }{
+        Username: username,
+        Revoked:  revoked,
     })
 }
 
@@ -105,25 +233,55 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
         username := r.FormValue("username")
         password := r.FormValue("password")
 
-        // Authenticate the user using PAM
-        err := PamAuthenticate(username, password)
+        // Authenticate the user against the configured backends
+        started := time.Now()
+        backend, err := Authenticate(username, password)
         if err != nil {
+            waitFailureDelay(r, started)
             data := struct {
                 Error string
             }{
                 Error: "Authentication failed. Please try again.",
             }
             pkg.RenderTemplate(w, "login.html", data)
-            logger.Logger.Warnf("Authentication failed for user: %s from IP: %s", username, clientIP)
+            logger.WithRequest(r).Warnf("Authentication failed for user: %s from IP: %s", logger.User(username), clientIP)
             return
         }
 
-        // Authentication was successful
+        // Never carry a session over a login: a token planted before it (session
+        // fixation) or left from another user is ended, valid or not. It is ended in
+        // the same step that enforces the session limit, so the session being replaced
+        // does not count and a concurrent login cannot take its place
+        replaced := ""
+        if cookie, err := r.Cookie(SessionCookieName); err == nil && config.RotatesSessionOnLogin() {
+            replaced = cookie.Value
+        }
+
+        // Authentication was successful, create the session within the limit of
+        // concurrent sessions
         sessionToken := GenerateSessionToken()
-        expiresAt := time.Now().Add(sessionDuration)
-        sessions[sessionToken] = UserSession{
+        now := time.Now()
+        expiresAt := now.Add(config.SessionDuration)
+        evicted, ok := sessions.CreateLimited(sessionToken, UserSession{
             Username: username,
+            Created:  now,
             Expires:  expiresAt,
+        }, replaced, config.MaxSessionsPerUser, config.SessionLimitPolicy == SessionLimitReject)
+        if replaced != "" {
+            logger.WithRequest(r).Debugf("Session sent with the login of user %s from IP: %s ended", logger.User(username), clientIP)
+        }
+        if !ok {
+            w.WriteHeader(http.StatusForbidden)
+            pkg.RenderTemplate(w, "login.html", struct {
+                Error string
+            }{
+                Error: "Too many active sessions. Log out on another device and try again.",
+            })
+            logger.WithRequest(r).Warnf("Login rejected for user: %s from IP: %s: session limit reached", logger.User(username), clientIP)
+            return
+        }
+        if evicted > 0 {
+            logger.WithRequest(r).Infof("Ended %d oldest sessions of user %s: session limit reached", evicted, logger.User(username))
         }
 
         // Set the session cookie
@@ -135,7 +293,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
             HttpOnly: true,
         })
 
-        logger.Logger.Infof("User %s logged in successfully from IP: %s", username, clientIP)
+        logger.WithRequest(r).Infof("User %s logged in successfully from IP: %s, Backend: %s", logger.User(username), clientIP, backend)
         http.Redirect(w, r, "/", http.StatusSeeOther)
     } else {
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -148,7 +306,7 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
     // Delete the session
     cookie, err := r.Cookie(SessionCookieName)
     if err == nil {
-        delete(sessions, cookie.Value)
+        sessions.Delete(cookie.Value)
         // Delete the cookie
         http.SetCookie(w, &http.Cookie{
             Name:     SessionCookieName,
@@ -157,7 +315,7 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
             Expires:  time.Now().Add(-1 * time.Hour),
             HttpOnly: true,
         })
-        logger.Logger.Infof("User logged out successfully from IP: %s", clientIP)
+        logger.WithRequest(r).Infof("User logged out successfully from IP: %s", clientIP)
     }
     // Возвращаем пользователя на предыдущую страницу
     referer := r.Referer()
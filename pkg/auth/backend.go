@@ -0,0 +1,99 @@
+// Description: This file implements the authentication backends and the chain trying them in order.
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"simple_file_server/pkg/logger"
+)
+
+// BackendPAM - name of the PAM backend, used when no backend is configured
+const BackendPAM = "pam"
+
+// ErrAuthFailed - no backend accepted the credentials
+var ErrAuthFailed = errors.New("authentication failed")
+
+// Authenticator - checks the credentials of a user
+type Authenticator interface {
+	Authenticate(username, password string) error
+}
+
+// AuthenticatorFunc - adapts a function to the Authenticator interface
+type AuthenticatorFunc func(username, password string) error
+
+// Authenticate - calls f
+func (f AuthenticatorFunc) Authenticate(username, password string) error {
+	return f(username, password)
+}
+
+// Backend - authenticator with the name it is configured and logged with
+type Backend struct {
+	Name string
+	Authenticator
+}
+
+// ChainAuthenticator - tries its backends in order until one accepts the credentials
+type ChainAuthenticator struct {
+	Backends []Backend
+}
+
+// Authenticate - succeeds when one of the backends accepts the credentials
+func (c ChainAuthenticator) Authenticate(username, password string) error {
+	_, err := c.AuthenticateBackend(username, password)
+	return err
+}
+
+// AuthenticateBackend - returns the name of the first backend accepting the credentials,
+// or ErrAuthFailed when every backend rejects them
+func (c ChainAuthenticator) AuthenticateBackend(username, password string) (string, error) {
+	for _, backend := range c.Backends {
+		err := backend.Authenticate(username, password)
+		if err == nil {
+			return backend.Name, nil
+		}
+		logger.Logger.Debugf("Backend %s rejected user %s: %v", backend.Name, logger.User(username), err)
+	}
+	return "", ErrAuthFailed
+}
+
+// backends - available backends by name
+var backends = map[string]Authenticator{
+	BackendPAM: AuthenticatorFunc(PamAuthenticate),
+}
+
+// RegisterBackend - makes a backend available under the name for Auth.Backends
+func RegisterBackend(name string, authenticator Authenticator) {
+	backends[name] = authenticator
+}
+
+// NewChain - builds the chain of the named backends, in order. Without names the
+// chain contains the PAM backend only.
+func NewChain(names []string) (ChainAuthenticator, error) {
+	if len(names) == 0 {
+		names = []string{BackendPAM}
+	}
+	var result ChainAuthenticator
+	seen := make(map[string]bool)
+	for _, name := range names {
+		authenticator, ok := backends[name]
+		if !ok {
+			return ChainAuthenticator{}, fmt.Errorf("unknown authentication backend %q", name)
+		}
+		if seen[name] {
+			return ChainAuthenticator{}, fmt.Errorf("duplicate authentication backend %q", name)
+		}
+		seen[name] = true
+		result.Backends = append(result.Backends, Backend{Name: name, Authenticator: authenticator})
+	}
+	return result, nil
+}
+
+// chain - backends configured in Auth.Backends
+var chain = ChainAuthenticator{Backends: []Backend{{Name: BackendPAM, Authenticator: backends[BackendPAM]}}}
+
+// Authenticate - checks the credentials against the configured backends and returns
+// the name of the backend that accepted them
+func Authenticate(username, password string) (string, error) {
+	return chain.AuthenticateBackend(username, password)
+}
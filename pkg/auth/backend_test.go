@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"simple_file_server/pkg"
+)
+
+// errUnavailable - failure of a backend that could not check the credentials at all
+var errUnavailable = errors.New("backend unavailable")
+
+// fakeBackend - backend accepting a single user and password, or failing with err;
+// every call is recorded in calls
+func fakeBackend(name, username, password string, err error, calls *[]string) Authenticator {
+	return AuthenticatorFunc(func(u, p string) error {
+		*calls = append(*calls, name)
+		if err != nil {
+			return err
+		}
+		if u != username || p != password {
+			return errors.New("invalid credentials")
+		}
+		return nil
+	})
+}
+
+func TestChainAuthenticator(t *testing.T) {
+	tests := []struct {
+		name        string
+		first       error
+		user        string
+		wantBackend string
+		wantCalls   string
+	}{
+		{"first accepts", nil, "alice", "first", "first"},
+		{"first rejects, second accepts", nil, "bob", "second", "first,second"},
+		{"first fails, second accepts", errUnavailable, "bob", "second", "first,second"},
+		{"first fails, second rejects", errUnavailable, "alice", "", "first,second"},
+		{"both reject", nil, "carol", "", "first,second"},
+	}
+	for _, tt := range tests {
+		var calls []string
+		chain := ChainAuthenticator{Backends: []Backend{
+			{Name: "first", Authenticator: fakeBackend("first", "alice", "pw", tt.first, &calls)},
+			{Name: "second", Authenticator: fakeBackend("second", "bob", "pw", nil, &calls)},
+		}}
+		backend, err := chain.AuthenticateBackend(tt.user, "pw")
+		if backend != tt.wantBackend || (err == nil) != (tt.wantBackend != "") {
+			t.Errorf("%s: backend %q, %v, want %q", tt.name, backend, err, tt.wantBackend)
+		}
+		// Errors and rejections alike fall through to the next backend, and the
+		// caller only learns that authentication failed
+		if err != nil && !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, ErrAuthFailed)
+		}
+		if got := strings.Join(calls, ","); got != tt.wantCalls {
+			t.Errorf("%s: backends called %s, want %s", tt.name, got, tt.wantCalls)
+		}
+	}
+}
+
+func TestNewChain(t *testing.T) {
+	tests := []struct {
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{nil, []string{BackendPAM}, false},
+		{[]string{BackendPAM}, []string{BackendPAM}, false},
+		{[]string{"ldap"}, nil, true},
+		{[]string{BackendPAM, BackendPAM}, nil, true},
+	}
+	for _, tt := range tests {
+		chain, err := NewChain(tt.names)
+		var got []string
+		for _, backend := range chain.Backends {
+			got = append(got, backend.Name)
+		}
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NewChain(%v) = %v, %v, want %v", tt.names, got, err, tt.want)
+		}
+	}
+}
+
+func TestSetupBackendOrder(t *testing.T) {
+	savedPAM, savedChain, savedConfig, savedHtpasswd := backends[BackendPAM], chain, config, htpasswd
+	t.Cleanup(func() {
+		backends[BackendPAM], chain, config, htpasswd = savedPAM, savedChain, savedConfig, savedHtpasswd
+		delete(backends, BackendHtpasswd)
+	})
+	var calls []string
+	RegisterBackend(BackendPAM, fakeBackend(BackendPAM, "root", "toor", nil, &calls))
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	writeHtpasswd(t, path, "alice:"+shaHash("secret")+"\n")
+
+	tests := []struct {
+		name      string
+		backends  []string
+		user      string
+		password  string
+		want      string
+		wantCalls string
+	}{
+		{"default htpasswd user", nil, "alice", "secret", BackendHtpasswd, ""},
+		{"default falls back to pam", nil, "root", "toor", BackendPAM, BackendPAM},
+		{"configured pam only", []string{BackendPAM}, "alice", "secret", "", BackendPAM},
+		{"configured pam first", []string{BackendPAM, BackendHtpasswd}, "alice", "secret", BackendHtpasswd, BackendPAM},
+	}
+	for _, tt := range tests {
+		calls = nil
+		if err := Setup(pkg.Auth{HtpasswdFile: path, Backends: tt.backends}); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		backend, _ := Authenticate(tt.user, tt.password)
+		if backend != tt.want {
+			t.Errorf("%s: accepted by %q, want %q", tt.name, backend, tt.want)
+		}
+		if got := strings.Join(calls, ","); got != tt.wantCalls {
+			t.Errorf("%s: pam called %q, want %q", tt.name, got, tt.wantCalls)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Description: This file implements the authentication backend checking users against an Apache-style htpasswd file.
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"simple_file_server/pkg/logger"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BackendHtpasswd - name of the htpasswd backend, available when Auth.HtpasswdFile is set
+const BackendHtpasswd = "htpasswd"
+
+var (
+	// ErrUnknownUser - the user has no entry in the htpasswd file
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrWrongPassword - the password does not match the entry of the user
+	ErrWrongPassword = errors.New("wrong password")
+)
+
+// shaPrefix - marks the base64 encoded SHA-1 hashes written by htpasswd -s
+const shaPrefix = "{SHA}"
+
+// dummyHash - bcrypt hash compared for unknown users, so that they take as long to
+// reject as wrong passwords
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("unknown user"), bcrypt.DefaultCost)
+
+// HtpasswdBackend - checks credentials against the user:hash lines of an htpasswd
+// file. Hashes are bcrypt ($2y$, $2a$, $2b$, htpasswd -B) or SHA-1 ({SHA}, htpasswd -s);
+// entries with other hashes are skipped with a warning. The file is read again when
+// its modification time changes or on Reload.
+type HtpasswdBackend struct {
+	path    string
+	mu      sync.RWMutex
+	users   map[string]string
+	modTime time.Time
+}
+
+// NewHtpasswdBackend - loads the htpasswd file at the path
+func NewHtpasswdBackend(path string) (*HtpasswdBackend, error) {
+	backend := &HtpasswdBackend{path: path}
+	if err := backend.Reload(); err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
+
+// Reload - reads the file again; on failure the entries loaded before are kept. The
+// sessions of users whose entry was changed or removed are ended, so that a new or
+// revoked password takes effect immediately.
+func (b *HtpasswdBackend) Reload() error {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return err
+	}
+	users, err := readHtpasswd(b.path)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	previous := b.users
+	b.users = users
+	b.modTime = info.ModTime()
+	b.mu.Unlock()
+
+	for username, hash := range previous {
+		if users[username] == hash {
+			continue
+		}
+		if ended := RevokeUserSessions(username); ended > 0 {
+			logger.Logger.Infof("Ended %d sessions of user %s: htpasswd entry changed", ended, logger.User(username))
+		}
+	}
+	return nil
+}
+
+// reloadIfChanged - reloads the file when its modification time differs from the
+// loaded one
+func (b *HtpasswdBackend) reloadIfChanged() {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return
+	}
+	b.mu.RLock()
+	changed := !info.ModTime().Equal(b.modTime)
+	b.mu.RUnlock()
+	if !changed {
+		return
+	}
+	if err := b.Reload(); err != nil {
+		logger.Logger.Warnf("Error reloading htpasswd file %s, keeping the previous entries: %v", b.path, err)
+		return
+	}
+	logger.Logger.Infof("Reloaded htpasswd file %s", b.path)
+}
+
+// Authenticate - checks the password against the hash of the user
+func (b *HtpasswdBackend) Authenticate(username, password string) error {
+	b.reloadIfChanged()
+	b.mu.RLock()
+	hash, ok := b.users[username]
+	b.mu.RUnlock()
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return ErrUnknownUser
+	}
+	if !checkHash(hash, password) {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// checkHash - reports whether the password matches the bcrypt or {SHA} hash
+func checkHash(hash, password string) bool {
+	if encoded, ok := strings.CutPrefix(hash, shaPrefix); ok {
+		sum := sha1.Sum([]byte(password))
+		expected := base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(encoded), []byte(expected)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// supportedHash - reports whether the hash is one checkHash understands
+func supportedHash(hash string) bool {
+	if strings.HasPrefix(hash, shaPrefix) {
+		return true
+	}
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
+}
+
+// readHtpasswd - parses the user:hash lines of the file; blank lines and lines
+// starting with # are ignored
+func readHtpasswd(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok || username == "" || hash == "" {
+			return nil, fmt.Errorf("%s:%d: expected user:hash", path, number)
+		}
+		if !supportedHash(hash) {
+			logger.Logger.Warnf("Skipping htpasswd entry of %s at %s:%d: only bcrypt and {SHA} hashes are supported", logger.User(username), path, number)
+			continue
+		}
+		users[username] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// htpasswd - backend of Auth.HtpasswdFile, nil when unset
+var htpasswd *HtpasswdBackend
+
+// ReloadHtpasswd - reads the configured htpasswd file again, e.g. on SIGHUP
+func ReloadHtpasswd() error {
+	if htpasswd == nil {
+		return nil
+	}
+	return htpasswd.Reload()
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// writeHtpasswd - replaces the content of the htpasswd file at the path
+func writeHtpasswd(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// bcryptHash - returns the bcrypt hash of the password at the minimum cost
+func bcryptHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+// shaHash - returns the {SHA} hash of the password written by htpasswd -s
+func shaHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return shaPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestHtpasswdAuthenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	writeHtpasswd(t, path, "# users\n\n"+
+		"carol:"+bcryptHash(t, "secret")+"\n"+
+		"dave:"+shaHash("pw3")+"\n"+
+		"eve:$apr1$salt$hash\n")
+	backend, err := NewHtpasswdBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		username, password string
+		want               error
+	}{
+		{"carol", "secret", nil},
+		{"carol", "wrong", ErrWrongPassword},
+		{"dave", "pw3", nil},
+		{"dave", "pw4", ErrWrongPassword},
+		{"eve", "anything", ErrUnknownUser},
+		{"mallory", "secret", ErrUnknownUser},
+	}
+	for _, tt := range tests {
+		if err := backend.Authenticate(tt.username, tt.password); !errors.Is(err, tt.want) {
+			t.Errorf("Authenticate(%q, %q) = %v, want %v", tt.username, tt.password, err, tt.want)
+		}
+	}
+}
+
+func TestHtpasswdMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	writeHtpasswd(t, path, "carol:"+shaHash("secret")+"\nno separator\n")
+	if _, err := NewHtpasswdBackend(path); err == nil {
+		t.Fatal("NewHtpasswdBackend accepted a line without a hash")
+	}
+}
+
+func TestHtpasswdReloadRevokesSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	writeHtpasswd(t, path, "carol:"+shaHash("secret")+"\ndave:"+shaHash("pw3")+"\nfrank:"+shaHash("pw5")+"\n")
+	backend, err := NewHtpasswdBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	users := []string{"carol", "dave", "frank"}
+	for _, username := range users {
+		for i := 0; i < 2; i++ {
+			sessions.Create(fmt.Sprintf("test-%s-%d", username, i), UserSession{Username: username, Created: now, Expires: now.Add(time.Hour)})
+		}
+	}
+	t.Cleanup(func() {
+		for _, username := range users {
+			sessions.DeleteUser(username)
+		}
+	})
+
+	// The password of carol changes, frank is removed and dave is unchanged
+	writeHtpasswd(t, path, "carol:"+shaHash("new secret")+"\ndave:"+shaHash("pw3")+"\n")
+	if err := backend.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tokens := sessions.UserTokens("carol"); len(tokens) != 0 {
+		t.Errorf("sessions of a user with a changed password survived the reload: %v", tokens)
+	}
+	if tokens := sessions.UserTokens("frank"); len(tokens) != 0 {
+		t.Errorf("sessions of a removed user survived the reload: %v", tokens)
+	}
+	if tokens := sessions.UserTokens("dave"); len(tokens) != 2 {
+		t.Errorf("sessions of an unchanged user: %v, want both kept", tokens)
+	}
+	if err := backend.Authenticate("carol", "new secret"); err != nil {
+		t.Errorf("new password rejected after reload: %v", err)
+	}
+	if err := backend.Authenticate("frank", "pw5"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("removed user: got %v, want %v", err, ErrUnknownUser)
+	}
+}
@@ -0,0 +1,231 @@
+// Description: This file implements the session store, which keeps the active user sessions safe for concurrent requests.
+package auth
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"simple_file_server/pkg/logger"
+)
+
+// SessionStore - active user sessions by token, shared by all requests. The tokens
+// are indexed by username too, so that the sessions of a user are found without
+// scanning every session, e.g. on each login under max_sessions_per_user.
+type SessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]UserSession
+	byUser   map[string]map[string]struct{}
+}
+
+// NewSessionStore - returns an empty session store
+func NewSessionStore() *SessionStore {
+	return &SessionStore{sessions: make(map[string]UserSession), byUser: make(map[string]map[string]struct{})}
+}
+
+// Create - stores the session under the token
+func (s *SessionStore) Create(token string, session UserSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(token)
+	s.insert(token, session)
+}
+
+// insert - adds the session to both maps; the caller must hold mu
+func (s *SessionStore) insert(token string, session UserSession) {
+	s.sessions[token] = session
+	if s.byUser[session.Username] == nil {
+		s.byUser[session.Username] = make(map[string]struct{})
+	}
+	s.byUser[session.Username][token] = struct{}{}
+}
+
+// remove - drops the session of the token from both maps; the caller must hold mu
+func (s *SessionStore) remove(token string) {
+	session, ok := s.sessions[token]
+	if !ok {
+		return
+	}
+	delete(s.sessions, token)
+	tokens := s.byUser[session.Username]
+	delete(tokens, token)
+	if len(tokens) == 0 {
+		delete(s.byUser, session.Username)
+	}
+}
+
+// Get - returns the session of the token unless it does not exist or has expired
+func (s *SessionStore) Get(token string) (UserSession, bool) {
+	s.mu.RLock()
+	session, ok := s.sessions[token]
+	s.mu.RUnlock()
+	if !ok || session.Expires.Before(time.Now()) {
+		return UserSession{}, false
+	}
+	return session, true
+}
+
+// Delete - ends the session of the token
+func (s *SessionStore) Delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(token)
+}
+
+// Validate - reports whether the token belongs to an active session; an expired
+// session is removed
+func (s *SessionStore) Validate(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[token]
+	if !ok {
+		return false
+	}
+	if session.Expires.Before(time.Now()) {
+		s.remove(token)
+		return false
+	}
+	return true
+}
+
+// DeleteUser - ends every session of the user and returns how many were ended
+func (s *SessionStore) DeleteUser(username string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := 0
+	for token := range s.byUser[username] {
+		s.remove(token)
+		deleted++
+	}
+	return deleted
+}
+
+// UserTokens - returns the tokens of the user's active sessions, oldest first,
+// removing the expired ones
+func (s *SessionStore) UserTokens(username string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.userTokens(username, time.Now())
+}
+
+// userTokens - implements UserTokens; the caller must hold mu
+func (s *SessionStore) userTokens(username string, now time.Time) []string {
+	var tokens []string
+	for token := range s.byUser[username] {
+		if s.sessions[token].Expires.Before(now) {
+			s.remove(token)
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return s.sessions[tokens[i]].Created.Before(s.sessions[tokens[j]].Created)
+	})
+	return tokens
+}
+
+// CreateLimited - stores the session under the token unless the user would have more
+// than max active sessions (no limit when max is 0). Over the limit, the login is
+// refused when reject is set and the oldest sessions are ended otherwise. The session
+// under replaced, if any, is ended first whether or not the login succeeds, so it does
+// not count against the limit. The removal, the check and the insert happen under one
+// lock, so concurrent logins cannot exceed the limit.
+// It returns the number of evicted sessions and whether the session was stored.
+func (s *SessionStore) CreateLimited(token string, session UserSession, replaced string, max int, reject bool) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if replaced != "" {
+		s.remove(replaced)
+	}
+	s.remove(token)
+	evicted := 0
+	if max > 0 {
+		tokens := s.userTokens(session.Username, time.Now())
+		if excess := len(tokens) - max + 1; excess > 0 {
+			if reject {
+				return 0, false
+			}
+			for _, old := range tokens[:excess] {
+				s.remove(old)
+			}
+			evicted = excess
+		}
+	}
+	s.insert(token, session)
+	return evicted, true
+}
+
+// DeleteExpired - removes the sessions expired at now and returns their number
+func (s *SessionStore) DeleteExpired(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := 0
+	for token, session := range s.sessions {
+		if session.Expires.Before(now) {
+			s.remove(token)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// stopReaper - closed to stop the running session reaper, which closes reaperDone
+// once it has returned
+var (
+	reaperMu   sync.Mutex
+	stopReaper chan struct{}
+	reaperDone chan struct{}
+)
+
+// StartSessionReaper - removes expired sessions every interval in the background, so
+// that sessions abandoned without logging out do not accumulate. A reaper already
+// running is replaced.
+func StartSessionReaper(interval time.Duration) {
+	reaperMu.Lock()
+	defer reaperMu.Unlock()
+	stopReaperLocked()
+	stop, done := make(chan struct{}), make(chan struct{})
+	stopReaper, reaperDone = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				if removed := sessions.DeleteExpired(now); removed > 0 {
+					logger.Logger.Debugf("Removed %d expired sessions", removed)
+				}
+			}
+		}
+	}()
+}
+
+// StopSessionReaper - stops the session reaper, if running, and waits until it has
+// returned, so no sessions are removed after it
+func StopSessionReaper() {
+	reaperMu.Lock()
+	defer reaperMu.Unlock()
+	stopReaperLocked()
+}
+
+// stopReaperLocked - stops the running reaper and waits for it; reaperMu must be held
+func stopReaperLocked() {
+	if stopReaper != nil {
+		close(stopReaper)
+		<-reaperDone
+		stopReaper, reaperDone = nil, nil
+	}
+}
+
+// requestSession - returns the active session of the request's cookie
+func requestSession(r *http.Request) (UserSession, bool) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return UserSession{}, false
+	}
+	return sessions.Get(cookie.Value)
+}
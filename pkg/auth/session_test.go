@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"simple_file_server/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = logrus.New()
+	logger.Logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestGenerateSessionTokenUnique(t *testing.T) {
+	const goroutines, perGoroutine = 16, 200
+
+	var mu sync.Mutex
+	seen := make(map[string]bool, goroutines*perGoroutine)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perGoroutine; j++ {
+				token := GenerateSessionToken()
+				mu.Lock()
+				if seen[token] {
+					t.Errorf("duplicate token %s", token)
+				}
+				seen[token] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for token := range seen {
+		if len(token) != 64 {
+			t.Fatalf("token %q has length %d, want 64", token, len(token))
+		}
+		for _, c := range token {
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+				t.Fatalf("token %q is not lowercase hex", token)
+			}
+		}
+	}
+}
+
+func TestSessionStoreUserTokens(t *testing.T) {
+	store := NewSessionStore()
+	now := time.Now()
+	store.Create("new", UserSession{Username: "alice", Created: now, Expires: now.Add(time.Hour)})
+	store.Create("old", UserSession{Username: "alice", Created: now.Add(-time.Minute), Expires: now.Add(time.Hour)})
+	store.Create("expired", UserSession{Username: "alice", Created: now.Add(-2 * time.Hour), Expires: now.Add(-time.Hour)})
+	store.Create("other", UserSession{Username: "bob", Created: now, Expires: now.Add(time.Hour)})
+
+	tokens := store.UserTokens("alice")
+	if fmt.Sprint(tokens) != "[old new]" {
+		t.Fatalf("UserTokens = %v, want [old new]", tokens)
+	}
+	if _, ok := store.sessions["expired"]; ok {
+		t.Error("UserTokens kept the expired session")
+	}
+
+	// Reusing a token for another user moves it between the index entries
+	store.Create("old", UserSession{Username: "bob", Created: now, Expires: now.Add(time.Hour)})
+	if _, ok := store.byUser["alice"]["old"]; ok {
+		t.Error("reused token still indexed under its previous user")
+	}
+
+	if deleted := store.DeleteUser("bob"); deleted != 2 {
+		t.Errorf("DeleteUser = %d, want 2", deleted)
+	}
+	if store.Validate("other") {
+		t.Error("session of a deleted user is still valid")
+	}
+	if !store.Validate("new") {
+		t.Error("session of another user was ended")
+	}
+	checkIndex(t, store)
+}
+
+func TestSessionStoreCreateLimited(t *testing.T) {
+	tests := []struct {
+		reject      bool
+		wantOK      bool
+		wantEvicted int
+		want        string
+	}{
+		{false, true, 1, "t2,t3"},
+		{true, false, 0, "t1,t2"},
+	}
+	for _, tt := range tests {
+		store := NewSessionStore()
+		now := time.Now()
+		for i := 1; i <= 3; i++ {
+			session := UserSession{Username: "alice", Created: now.Add(time.Duration(i) * time.Second), Expires: now.Add(time.Hour)}
+			evicted, ok := store.CreateLimited(fmt.Sprintf("t%d", i), session, "", 2, tt.reject)
+			if i < 3 && (!ok || evicted != 0) {
+				t.Fatalf("reject %t: login %d = %d, %t within the limit", tt.reject, i, evicted, ok)
+			}
+			if i == 3 && (ok != tt.wantOK || evicted != tt.wantEvicted) {
+				t.Errorf("reject %t: third login = %d, %t, want %d, %t", tt.reject, evicted, ok, tt.wantEvicted, tt.wantOK)
+			}
+		}
+		if got := strings.Join(store.UserTokens("alice"), ","); got != tt.want {
+			t.Errorf("reject %t: sessions %s, want %s", tt.reject, got, tt.want)
+		}
+	}
+}
+
+func TestSessionStoreCreateLimitedConcurrent(t *testing.T) {
+	const logins, max = 50, 3
+
+	store := NewSessionStore()
+	session := UserSession{Username: "alice", Created: time.Now(), Expires: time.Now().Add(time.Hour)}
+	var wg sync.WaitGroup
+	for i := 0; i < logins; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store.CreateLimited(fmt.Sprintf("t%d", i), session, "", max, true)
+		}(i)
+	}
+	wg.Wait()
+	if got := len(store.UserTokens("alice")); got != max {
+		t.Fatalf("%d concurrent logins left %d sessions, want %d", logins, got, max)
+	}
+}
+
+func TestSessionStoreCreateLimitedRotation(t *testing.T) {
+	const max, rotations = 3, 200
+
+	store := NewSessionStore()
+	newSession := func() UserSession {
+		return UserSession{Username: "alice", Created: time.Now(), Expires: time.Now().Add(time.Hour)}
+	}
+	for i := 0; i < max; i++ {
+		store.CreateLimited(fmt.Sprintf("held%d", i), newSession(), "", max, true)
+	}
+
+	// Holders of a session keep rotating it while fresh logins compete for its
+	// slot: the slot freed by a rotation must never be taken in between
+	var holders, fresh sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < max; i++ {
+		holders.Add(1)
+		go func(worker int) {
+			defer holders.Done()
+			token := fmt.Sprintf("held%d", worker)
+			for j := 0; j < rotations; j++ {
+				next := fmt.Sprintf("rotated%d-%d", worker, j)
+				if _, ok := store.CreateLimited(next, newSession(), token, max, true); !ok {
+					t.Errorf("rotation %d of worker %d was rejected", j, worker)
+					return
+				}
+				token = next
+			}
+		}(i)
+		fresh.Add(1)
+		go func(worker int) {
+			defer fresh.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				store.CreateLimited(fmt.Sprintf("fresh%d-%d", worker, j), newSession(), "", max, true)
+			}
+		}(i)
+	}
+	holders.Wait()
+	close(done)
+	fresh.Wait()
+
+	tokens := store.UserTokens("alice")
+	if len(tokens) != max {
+		t.Fatalf("sessions %v, want %d", tokens, max)
+	}
+	for _, token := range tokens {
+		if !strings.HasPrefix(token, "rotated") {
+			t.Errorf("session %s outlived the rotations", token)
+		}
+	}
+	checkIndex(t, store)
+}
+
+func TestSessionStoreConcurrent(t *testing.T) {
+	store := NewSessionStore()
+	users := []string{"alice", "bob", "carol"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 300; j++ {
+				username := users[(worker+j)%len(users)]
+				token := fmt.Sprintf("%d-%d", worker, j)
+				now := time.Now()
+				expires := now.Add(time.Hour)
+				if j%5 == 0 {
+					expires = now.Add(-time.Second)
+				}
+				store.Create(token, UserSession{Username: username, Created: now, Expires: expires})
+				store.Get(token)
+				store.Validate(token)
+				switch j % 7 {
+				case 0:
+					store.Delete(token)
+				case 3:
+					store.UserTokens(username)
+				case 5:
+					store.DeleteExpired(time.Now())
+				case 6:
+					if j%21 == 6 {
+						store.DeleteUser(username)
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	checkIndex(t, store)
+}
+
+// checkIndex - fails the test unless byUser indexes exactly the stored sessions
+func checkIndex(t *testing.T, store *SessionStore) {
+	t.Helper()
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	indexed := 0
+	for username, tokens := range store.byUser {
+		if len(tokens) == 0 {
+			t.Errorf("empty index entry kept for %s", username)
+		}
+		for token := range tokens {
+			indexed++
+			session, ok := store.sessions[token]
+			if !ok {
+				t.Errorf("index of %s holds the removed token %s", username, token)
+			} else if session.Username != username {
+				t.Errorf("token %s of %s indexed under %s", token, session.Username, username)
+			}
+		}
+	}
+	if indexed != len(store.sessions) {
+		t.Errorf("index holds %d tokens, store %d sessions", indexed, len(store.sessions))
+	}
+}
+
+func TestSessionReaper(t *testing.T) {
+	saved := sessions
+	sessions = NewSessionStore()
+	t.Cleanup(func() {
+		StopSessionReaper()
+		sessions = saved
+	})
+	stored := func(token string) bool {
+		sessions.mu.RLock()
+		defer sessions.mu.RUnlock()
+		_, ok := sessions.sessions[token]
+		return ok
+	}
+	now := time.Now()
+	sessions.Create("expired", UserSession{Username: "alice", Created: now.Add(-time.Hour), Expires: now.Add(-time.Minute)})
+	sessions.Create("active", UserSession{Username: "alice", Created: now, Expires: now.Add(time.Hour)})
+
+	StartSessionReaper(10 * time.Millisecond)
+	for deadline := time.Now().Add(5 * time.Second); stored("expired"); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the reaper did not remove the expired session")
+		}
+	}
+	if !stored("active") {
+		t.Error("the reaper removed an active session")
+	}
+
+	// A stopped reaper leaves expired sessions to the lazy checks
+	StopSessionReaper()
+	StopSessionReaper()
+	sessions.Create("later", UserSession{Username: "bob", Created: now.Add(-time.Hour), Expires: now.Add(-time.Minute)})
+	time.Sleep(50 * time.Millisecond)
+	if !stored("later") {
+		t.Error("the stopped reaper removed a session")
+	}
+}
@@ -0,0 +1,112 @@
+// Description: This file implements the childcount package, which counts and caches the immediate entries of directories.
+package childcount
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"simple_file_server/pkg"
+)
+
+// Defaults applied when the configuration leaves the values unset
+const (
+	defaultLimit = 1000
+	// maxCached - directories whose count is kept
+	maxCached = 10000
+)
+
+// batchSize - entries read from a directory at a time
+const batchSize = 256
+
+// Count - represents the number of immediate entries of a directory
+type Count struct {
+	// N - number of entries, at most the configured limit
+	N int `json:"count"`
+	// More - the directory has more entries than the limit
+	More bool `json:"more,omitempty"`
+}
+
+// entry - cached count with the modification time of the directory it was taken at
+type entry struct {
+	Count
+	modTime time.Time
+}
+
+var (
+	mu    sync.Mutex
+	cache = make(map[string]entry)
+	limit = defaultLimit
+)
+
+// Setup - applies the limit and clears the cache
+func Setup(config pkg.ChildCount) {
+	mu.Lock()
+	defer mu.Unlock()
+	limit = config.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	cache = make(map[string]entry)
+}
+
+// Get - returns the number of entries of the directory, not counting those for which
+// skip returns true. Reading stops at the limit. The count is cached until the
+// modification time of the directory changes, which happens whenever an entry is
+// added, removed or renamed.
+func Get(dir string, skip func(entry os.DirEntry) bool) (Count, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return Count{}, err
+	}
+	mu.Lock()
+	cached, ok := cache[dir]
+	n := limit
+	mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.Count, nil
+	}
+
+	result, err := count(dir, n, skip)
+	if err != nil {
+		return Count{}, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(cache) >= maxCached {
+		cache = make(map[string]entry)
+	}
+	cache[dir] = entry{Count: result, modTime: info.ModTime()}
+	return result, nil
+}
+
+// count - reads the directory in batches until its end or more than n entries
+func count(dir string, n int, skip func(entry os.DirEntry) bool) (Count, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return Count{}, err
+	}
+	defer f.Close()
+
+	var result Count
+	for {
+		entries, err := f.ReadDir(batchSize)
+		for _, e := range entries {
+			if skip != nil && skip(e) {
+				continue
+			}
+			if result.N == n {
+				result.More = true
+				return result, nil
+			}
+			result.N++
+		}
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return Count{}, err
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package childcount
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"simple_file_server/pkg"
+)
+
+func TestGet(t *testing.T) {
+	Setup(pkg.ChildCount{Limit: 3})
+	t.Cleanup(func() { Setup(pkg.ChildCount{}) })
+	dir := t.TempDir()
+	add := func(names ...string) {
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	skipHidden := func(entry os.DirEntry) bool { return strings.HasPrefix(entry.Name(), ".") }
+
+	add("a", "b", ".hidden")
+	if got, err := Get(dir, skipHidden); err != nil || got != (Count{N: 2}) {
+		t.Errorf("Get = %+v, %v, want 2", got, err)
+	}
+
+	// The cached count is kept until the directory changes
+	add("c")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(dir, old, old)
+	if got, _ := Get(dir, skipHidden); got != (Count{N: 3}) {
+		t.Errorf("after adding an entry: %+v, want 3", got)
+	}
+	add("d")
+	os.Chtimes(dir, old, old)
+	if got, _ := Get(dir, skipHidden); got != (Count{N: 3}) {
+		t.Errorf("with an unchanged modification time: %+v, want the cached 3", got)
+	}
+	os.Chtimes(dir, time.Now(), time.Now())
+	if got, _ := Get(dir, skipHidden); got != (Count{N: 3, More: true}) {
+		t.Errorf("over the limit: %+v, want 3+", got)
+	}
+
+	if _, err := Get(filepath.Join(dir, "missing"), nil); err == nil {
+		t.Error("Get of a missing directory succeeded")
+	}
+}
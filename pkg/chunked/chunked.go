@@ -0,0 +1,329 @@
+// Description: This file implements the chunked package, which stores the chunks of an upload and assembles them with integrity checks.
+package chunked
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+// Defaults applied when the configuration leaves the values unset
+const (
+	defaultMaxAge  = 24 * time.Hour
+	defaultWorkers = 4
+	// MaxChunks - largest number of chunks of an upload
+	MaxChunks = 10000
+)
+
+var (
+	// ErrInvalidID - the upload id is not 1 to 64 letters, digits, "-" or "_"
+	ErrInvalidID = errors.New("invalid upload id")
+	// ErrInvalidChecksum - the checksum is not a hex encoded SHA-256 digest
+	ErrInvalidChecksum = errors.New("invalid checksum (expected hex SHA-256)")
+	// ErrChecksum - the content does not match its checksum
+	ErrChecksum = errors.New("checksum mismatch")
+	// ErrMissingChunk - a chunk of the upload was never received
+	ErrMissingChunk = errors.New("missing chunk")
+)
+
+// validID - accepted upload ids, chosen by the client
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+var (
+	dir     string
+	workers = defaultWorkers
+)
+
+// Setup - selects the directory the chunks are kept in and removes the uploads
+// abandoned for longer than max_age in the background
+func Setup(config pkg.Chunked) error {
+	dir = config.Dir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "sfs-chunks")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	workers = config.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	maxAge := config.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultMaxAge
+	}
+	go func() {
+		for {
+			if removed := sweep(maxAge, time.Now()); removed > 0 {
+				logger.Logger.Infof("Removed %d abandoned chunked uploads", removed)
+			}
+			time.Sleep(maxAge / 4)
+		}
+	}()
+	return nil
+}
+
+// ValidID - reports whether the upload id may be used
+func ValidID(id string) bool {
+	return validID.MatchString(id)
+}
+
+// normalizeChecksum - lowercases a hex SHA-256 checksum; an empty value stays empty
+func normalizeChecksum(checksum string) (string, error) {
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if checksum == "" {
+		return "", nil
+	}
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		return "", ErrInvalidChecksum
+	}
+	return checksum, nil
+}
+
+// uploadDir - directory of the chunks of an upload; uploads of different users never mix
+func uploadDir(user, id string) string {
+	return filepath.Join(dir, hex.EncodeToString([]byte(user)), id)
+}
+
+// chunkPath - file of the chunk with the index
+func chunkPath(user, id string, index int) string {
+	return filepath.Join(uploadDir(user, id), strconv.Itoa(index))
+}
+
+// SaveChunk - stores the chunk with the index of an upload and returns its size. When
+// a checksum is given, a chunk not matching it is discarded with ErrChecksum; the
+// checksum is kept and verified again on assembly.
+func SaveChunk(user, id string, index int, r io.Reader, checksum string) (int64, error) {
+	if !ValidID(id) {
+		return 0, ErrInvalidID
+	}
+	if index < 0 || index >= MaxChunks {
+		return 0, fmt.Errorf("chunk index out of range 0-%d", MaxChunks-1)
+	}
+	checksum, err := normalizeChecksum(checksum)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(uploadDir(user, id), 0700); err != nil {
+		return 0, err
+	}
+
+	target := chunkPath(user, id, index)
+	tmp, err := os.CreateTemp(uploadDir(user, id), ".chunk-*")
+	if err != nil {
+		return 0, err
+	}
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && checksum != "" && hex.EncodeToString(hash.Sum(nil)) != checksum {
+		err = ErrChecksum
+	}
+	if err == nil && checksum != "" {
+		err = os.WriteFile(target+".sha256", []byte(checksum), 0600)
+	} else if err == nil {
+		// A chunk sent again without a checksum replaces the earlier one
+		os.Remove(target + ".sha256")
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return written, nil
+}
+
+// Size - returns the total size of the chunks 0 to total-1 of an upload, failing with
+// ErrMissingChunk when one of them was not received
+func Size(user, id string, total int) (int64, error) {
+	if !ValidID(id) {
+		return 0, ErrInvalidID
+	}
+	if total <= 0 || total > MaxChunks {
+		return 0, fmt.Errorf("chunk count out of range 1-%d", MaxChunks)
+	}
+	var size int64
+	for index := 0; index < total; index++ {
+		info, err := os.Stat(chunkPath(user, id, index))
+		if os.IsNotExist(err) {
+			return 0, fmt.Errorf("%w %d", ErrMissingChunk, index)
+		}
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// Open - opens the first chunk of an upload, e.g. to detect the type of the file
+func Open(user, id string) (*os.File, error) {
+	if !ValidID(id) {
+		return nil, ErrInvalidID
+	}
+	file, err := os.Open(chunkPath(user, id, 0))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w 0", ErrMissingChunk)
+	}
+	return file, err
+}
+
+// verifyChunks - checks the chunks that were sent with a checksum, using the
+// configured number of workers
+func verifyChunks(user, id string, total int) error {
+	indexes := make(chan int)
+	errs := make(chan error, total)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < total; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				errs <- verifyChunk(user, id, index)
+			}
+		}()
+	}
+	for index := 0; index < total; index++ {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyChunk - checks a chunk against the checksum it was sent with, if any
+func verifyChunk(user, id string, index int) error {
+	target := chunkPath(user, id, index)
+	expected, err := os.ReadFile(target + ".sha256")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(target)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != string(expected) {
+		return fmt.Errorf("%w of chunk %d", ErrChecksum, index)
+	}
+	return nil
+}
+
+// Assemble - verifies the chunks 0 to total-1 of an upload, concatenates them into
+// dstPath and returns the size of the file. When a checksum is given, the whole file
+// must match it too. The file is written next to dstPath and moved into place only
+// once verified; on success and on a checksum mismatch the chunks are removed.
+func Assemble(user, id string, total int, dstPath, checksum string) (int64, error) {
+	checksum, err := normalizeChecksum(checksum)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := Size(user, id, total); err != nil {
+		return 0, err
+	}
+	if err := verifyChunks(user, id, total); err != nil {
+		if errors.Is(err, ErrChecksum) {
+			Discard(user, id)
+		}
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dstPath), "."+filepath.Base(dstPath)+".part*")
+	if err != nil {
+		return 0, err
+	}
+	hash := sha256.New()
+	out := io.MultiWriter(tmp, hash)
+	var written int64
+	for index := 0; index < total && err == nil; index++ {
+		var file *os.File
+		file, err = os.Open(chunkPath(user, id, index))
+		if err != nil {
+			break
+		}
+		var n int64
+		n, err = io.Copy(out, file)
+		file.Close()
+		written += n
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && checksum != "" && hex.EncodeToString(hash.Sum(nil)) != checksum {
+		Discard(user, id)
+		err = ErrChecksum
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dstPath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	Discard(user, id)
+	return written, nil
+}
+
+// Discard - removes the chunks of an upload
+func Discard(user, id string) {
+	if !ValidID(id) {
+		return
+	}
+	if err := os.RemoveAll(uploadDir(user, id)); err != nil {
+		logger.Logger.Warnf("Error removing chunks of upload %s: %v", id, err)
+	}
+}
+
+// sweep - removes the uploads whose last chunk arrived more than maxAge ago and returns
+// their number
+func sweep(maxAge time.Duration, now time.Time) int {
+	uploads, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+	if err != nil {
+		return 0
+	}
+	removed := 0
+	for _, upload := range uploads {
+		info, err := os.Stat(upload)
+		if err != nil || !info.IsDir() || now.Sub(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.RemoveAll(upload); err != nil {
+			logger.Logger.Warnf("Error removing abandoned chunked upload %s: %v", upload, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
@@ -0,0 +1,109 @@
+package chunked
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sum - returns the hex SHA-256 checksum of the content
+func sum(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+// useDir - keeps the chunks of the test in a new directory
+func useDir(t *testing.T) {
+	t.Helper()
+	saved := dir
+	dir = t.TempDir()
+	t.Cleanup(func() { dir = saved })
+}
+
+// saveChunks - stores the chunks of the upload, each with its checksum
+func saveChunks(t *testing.T, id string, chunks []string) {
+	t.Helper()
+	for index, chunk := range chunks {
+		if _, err := SaveChunk("alice", id, index, strings.NewReader(chunk), sum(chunk)); err != nil {
+			t.Fatalf("chunk %d: %v", index, err)
+		}
+	}
+}
+
+func TestSaveChunkChecksum(t *testing.T) {
+	useDir(t)
+	if _, err := SaveChunk("alice", "up1", 0, strings.NewReader("data"), sum("other")); !errors.Is(err, ErrChecksum) {
+		t.Errorf("wrong checksum: error %v, want %v", err, ErrChecksum)
+	}
+	if _, err := os.Stat(chunkPath("alice", "up1", 0)); !os.IsNotExist(err) {
+		t.Errorf("chunk with a wrong checksum was stored: %v", err)
+	}
+	if _, err := SaveChunk("alice", "up1", 0, strings.NewReader("data"), "xyz"); !errors.Is(err, ErrInvalidChecksum) {
+		t.Errorf("malformed checksum: error %v, want %v", err, ErrInvalidChecksum)
+	}
+	if _, err := SaveChunk("alice", "../up", 0, strings.NewReader("data"), ""); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("invalid id: error %v, want %v", err, ErrInvalidID)
+	}
+	if size, err := SaveChunk("alice", "up1", 0, strings.NewReader("data"), strings.ToUpper(sum("data"))); err != nil || size != 4 {
+		t.Errorf("correct checksum: %d, %v", size, err)
+	}
+}
+
+func TestAssemble(t *testing.T) {
+	useDir(t)
+	chunks := []string{"hello ", "chunked ", "world"}
+	whole := strings.Join(chunks, "")
+	dst := filepath.Join(t.TempDir(), "file.txt")
+
+	saveChunks(t, "ok", chunks)
+	if written, err := Assemble("alice", "ok", len(chunks), dst, sum(whole)); err != nil || written != int64(len(whole)) {
+		t.Fatalf("Assemble = %d, %v", written, err)
+	}
+	if got, err := os.ReadFile(dst); err != nil || string(got) != whole {
+		t.Errorf("assembled file %q, %v, want %q", got, err, whole)
+	}
+	if _, err := os.Stat(uploadDir("alice", "ok")); !os.IsNotExist(err) {
+		t.Errorf("chunks were kept after the assembly: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		prepare  func(id string)
+		checksum string
+		want     error
+	}{
+		{"wrong file checksum", nil, sum("other"), ErrChecksum},
+		{"chunk changed after upload", func(id string) {
+			os.WriteFile(chunkPath("alice", id, 1), []byte("tampered"), 0o600)
+		}, "", ErrChecksum},
+		{"missing chunk", func(id string) {
+			os.Remove(chunkPath("alice", id, 2))
+		}, "", ErrMissingChunk},
+		{"other user", func(id string) {
+			os.MkdirAll(filepath.Dir(uploadDir("bob", id)), 0o700)
+			os.Rename(uploadDir("alice", id), uploadDir("bob", id))
+		}, "", ErrMissingChunk},
+	}
+	for i, tt := range tests {
+		id := "bad" + string(rune('a'+i))
+		dst := filepath.Join(t.TempDir(), "file.txt")
+		saveChunks(t, id, chunks)
+		if tt.prepare != nil {
+			tt.prepare(id)
+		}
+		if _, err := Assemble("alice", id, len(chunks), dst, tt.checksum); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.want)
+		}
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("%s: the destination was written: %v", tt.name, err)
+		}
+		entries, _ := os.ReadDir(filepath.Dir(dst))
+		if len(entries) != 0 {
+			t.Errorf("%s: partial files left: %v", tt.name, entries)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Description: This file contains the proxy-aware client IP resolution.
+package pkg
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// trustedProxies - networks whose X-Forwarded-For / X-Real-IP headers are trusted
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies - parses the trusted proxy addresses (IPs or CIDRs)
+func SetTrustedProxies(proxies []string) error {
+	trustedProxies = nil
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return err
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+	return nil
+}
+
+// isTrustedProxy - checks whether the IP belongs to a trusted proxy
+func isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP - returns the IP of the client. Forwarding headers are honoured only when the
+// request comes from a trusted proxy; X-Forwarded-For is walked from the right, skipping
+// trusted proxies, so clients cannot spoof their address.
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop != "" && !isTrustedProxy(hop) {
+				return hop
+			}
+		}
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+	return ip
+}
@@ -0,0 +1,211 @@
+// Description: This file implements the contactsheet package, which composes the images of a folder into one grid image.
+package contactsheet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // registers the GIF decoder with image.Decode
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+
+	"simple_file_server/pkg/logger"
+)
+
+// Output formats
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// maxPixels - largest image decoded for a thumbnail, bigger images are left out
+const maxPixels = 50 << 20
+
+// maxCached - number of rendered sheets kept in memory
+const maxCached = 32
+
+// ErrNoImages - the folder contains no decodable image
+var ErrNoImages = errors.New("no images in the folder")
+
+// background - color of the cells around thumbnails that do not fill them
+var background = color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}
+
+// Options - represents the layout of a contact sheet
+type Options struct {
+	// Columns - number of thumbnails per row
+	Columns int
+	// Cell - width and height of a thumbnail cell in pixels
+	Cell int
+	// MaxImages - largest number of images on the sheet, the first ones by name are used
+	MaxImages int
+	// Format - FormatJPEG or FormatPNG
+	Format string
+	// Quality - JPEG quality from 1 to 100
+	Quality int
+}
+
+// cached - rendered sheet with the modification time of its folder when rendered
+type cached struct {
+	data    []byte
+	modTime time.Time
+}
+
+var (
+	mu    sync.Mutex
+	cache = make(map[string]cached)
+)
+
+// ValidFormat - checks the configured output format
+func ValidFormat(format string) error {
+	switch format {
+	case FormatJPEG, FormatPNG:
+		return nil
+	}
+	return fmt.Errorf("unknown contact sheet format %q (expected jpeg or png)", format)
+}
+
+// IsImage - reports whether the name has the extension of a supported image format
+func IsImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// Render - returns the encoded contact sheet of the folder and the modification time of
+// the folder. Sheets are cached until the folder is modified, i.e. images are added,
+// removed or renamed.
+func Render(dir string, opts Options) ([]byte, time.Time, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	key := fmt.Sprintf("%s|%d|%d|%d|%s|%d", dir, opts.Columns, opts.Cell, opts.MaxImages, opts.Format, opts.Quality)
+
+	mu.Lock()
+	hit, ok := cache[key]
+	mu.Unlock()
+	if ok && hit.modTime.Equal(info.ModTime()) {
+		return hit.data, hit.modTime, nil
+	}
+
+	sheet, err := Build(dir, opts)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var buf bytes.Buffer
+	if opts.Format == FormatPNG {
+		err = png.Encode(&buf, sheet)
+	} else {
+		err = jpeg.Encode(&buf, sheet, &jpeg.Options{Quality: opts.Quality})
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	mu.Lock()
+	if len(cache) >= maxCached {
+		// Sheets are cheap to rebuild, so any entry can make room
+		for k := range cache {
+			delete(cache, k)
+			break
+		}
+	}
+	cache[key] = cached{data: buf.Bytes(), modTime: info.ModTime()}
+	mu.Unlock()
+	return buf.Bytes(), info.ModTime(), nil
+}
+
+// Build - composes the images of the folder, in name order and at most opts.MaxImages,
+// into a grid of opts.Columns columns of opts.Cell pixel square cells. Thumbnails keep
+// their aspect ratio and are centered in their cell. Files that cannot be decoded are
+// left out; the grid has fewer columns when there are fewer images.
+func Build(dir string, opts Options) (image.Image, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && IsImage(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	thumbs := make([]image.Image, 0, len(names))
+	for _, name := range names {
+		if opts.MaxImages > 0 && len(thumbs) >= opts.MaxImages {
+			break
+		}
+		thumb, err := thumbnail(filepath.Join(dir, name), opts.Cell)
+		if err != nil {
+			logger.Logger.Debugf("Skipping %s in contact sheet: %v", name, err)
+			continue
+		}
+		thumbs = append(thumbs, thumb)
+	}
+	if len(thumbs) == 0 {
+		return nil, ErrNoImages
+	}
+
+	columns := opts.Columns
+	if len(thumbs) < columns {
+		columns = len(thumbs)
+	}
+	rows := (len(thumbs) + columns - 1) / columns
+	sheet := image.NewRGBA(image.Rect(0, 0, columns*opts.Cell, rows*opts.Cell))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	for i, thumb := range thumbs {
+		size := thumb.Bounds().Size()
+		x := (i%columns)*opts.Cell + (opts.Cell-size.X)/2
+		y := (i/columns)*opts.Cell + (opts.Cell-size.Y)/2
+		draw.Draw(sheet, image.Rect(x, y, x+size.X, y+size.Y), thumb, thumb.Bounds().Min, draw.Over)
+	}
+	return sheet, nil
+}
+
+// thumbnail - decodes the image and scales it to fit a square of the given size
+func thumbnail(name string, size int) (image.Image, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("unsupported image size %dx%d", config.Width, config.Height)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := size, size
+	if config.Width > config.Height {
+		height = max(1, config.Height*size/config.Width)
+	} else {
+		width = max(1, config.Width*size/config.Height)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	return dst, nil
+}
@@ -0,0 +1,71 @@
+package contactsheet
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeImage - writes a PNG image of the given size filled with one color
+func writeImage(t *testing.T, name string, width, height int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.png", "b.png", "c.png", "d.png", "e.png"} {
+		writeImage(t, filepath.Join(dir, name), 200, 100)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts          Options
+		width, height int
+	}{
+		{Options{Columns: 2, Cell: 50}, 100, 150},
+		{Options{Columns: 3, Cell: 40, MaxImages: 3}, 120, 40},
+		{Options{Columns: 8, Cell: 64}, 320, 64},
+	}
+	for _, tt := range tests {
+		sheet, err := Build(dir, tt.opts)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.opts, err)
+		}
+		if size := sheet.Bounds().Size(); size.X != tt.width || size.Y != tt.height {
+			t.Errorf("%+v: sheet is %v, want %dx%d", tt.opts, size, tt.width, tt.height)
+		}
+	}
+
+	// Thumbnails keep their aspect ratio and are centered in their cell
+	sheet, _ := Build(dir, Options{Columns: 1, Cell: 50, MaxImages: 1})
+	if r, _, _, _ := sheet.At(25, 5).RGBA(); r == 0xffff {
+		t.Error("the cell is filled above a wide thumbnail")
+	}
+	if r, _, _, _ := sheet.At(25, 25).RGBA(); r != 0xffff {
+		t.Error("the thumbnail is not drawn in the middle of its cell")
+	}
+
+	if _, err := Build(t.TempDir(), Options{Columns: 2, Cell: 50}); !errors.Is(err, ErrNoImages) {
+		t.Errorf("empty folder: error %v, want %v", err, ErrNoImages)
+	}
+}
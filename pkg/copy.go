@@ -0,0 +1,95 @@
+// Description: This file contains the copying of files and directory trees.
+package pkg
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CopyTree - copies the file or directory src to dst, which must not exist, keeping
+// modes and modification times. With link, files are hard linked instead of copied,
+// which is cheap but requires src and dst to be on the same filesystem. Symlinks are
+// copied as links, checked against the symlink policy from their new location: with
+// deny any symlink fails the copy with ErrSymlinkDenied, with contain one resolving
+// outside base fails it with ErrSymlinkEscape. A partially copied tree is removed on
+// failure.
+func CopyTree(src, dst string, link bool, base, policy string) error {
+	realBase := base
+	if policy != SymlinkFollow {
+		var err error
+		if realBase, err = filepath.EvalSymlinks(base); err != nil {
+			return err
+		}
+	}
+	if err := copyEntry(src, dst, link, realBase, policy); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return nil
+}
+
+// copyEntry - copies a single entry, recursing into directories
+func copyEntry(src, dst string, link bool, realBase, policy string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		switch {
+		case policy == SymlinkDeny:
+			return ErrSymlinkDenied
+		case policy != SymlinkFollow && !linkWithin(realBase, dst, target):
+			return ErrSymlinkEscape
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		// Writable until its entries are copied, e.g. for read-only directories
+		if err := os.Mkdir(dst, 0700); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyEntry(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()), link, realBase, policy); err != nil {
+				return err
+			}
+		}
+		if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chtimes(dst, info.ModTime(), info.ModTime())
+	case link:
+		return os.Link(src, dst)
+	default:
+		return copyFile(src, dst, info)
+	}
+}
+
+// copyFile - copies the content of a regular file
+func copyFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,68 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyTree(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(root, "base")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{filepath.Join(base, "src", "sub"), filepath.Join(base, "deep", "er"), outside} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(base, "src", "sub", "a.txt"), []byte("a"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	link := func(target, name string) {
+		if err := os.Symlink(target, filepath.Join(base, filepath.FromSlash(name))); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+	}
+	link("sub/a.txt", "src/inner")
+	link("zzz-later", "src/dangling-sibling")
+	link("../../src", "deep/er/up")
+
+	if err := CopyTree(filepath.Join(base, "src"), filepath.Join(base, "copy"), false, base, SymlinkContain); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(base, "copy", "inner")); err != nil || string(data) != "a" {
+		t.Errorf("copied link: %q, %v", data, err)
+	}
+	if info, err := os.Stat(filepath.Join(base, "copy", "sub", "a.txt")); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("copied file: %v, %v", info, err)
+	}
+
+	// ../../src leads outside base when copied one level up
+	tests := []struct {
+		src, dst, policy string
+		want             error
+	}{
+		{"deep/er", "er-copy", SymlinkContain, ErrSymlinkEscape},
+		{"deep/er", "er-copy", SymlinkDeny, ErrSymlinkDenied},
+		{"src", "denied", SymlinkDeny, ErrSymlinkDenied},
+		{"deep/er", "er-copy", SymlinkFollow, nil},
+		{"deep", "deep-copy", SymlinkContain, nil},
+	}
+	for _, tt := range tests {
+		dst := filepath.Join(base, tt.dst)
+		err := CopyTree(filepath.Join(base, tt.src), dst, false, base, tt.policy)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("CopyTree(%s, %s, %s) = %v, want %v", tt.src, tt.dst, tt.policy, err, tt.want)
+		}
+		if _, statErr := os.Lstat(dst); (statErr == nil) != (tt.want == nil) {
+			t.Errorf("CopyTree(%s, %s, %s): copy left %t", tt.src, tt.dst, tt.policy, statErr == nil)
+		}
+		os.RemoveAll(dst)
+	}
+
+	link(filepath.Join(outside), "escape")
+	if err := CopyTree(filepath.Join(base, "escape"), filepath.Join(base, "escape-copy"), false, base, SymlinkContain); !errors.Is(err, ErrSymlinkEscape) {
+		t.Errorf("copy of an absolute link outside base: %v", err)
+	}
+}
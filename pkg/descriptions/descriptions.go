@@ -0,0 +1,104 @@
+// Description: This file implements the descriptions package, which stores per-file descriptions in a sidecar file.
+package descriptions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+// FileName - name of the sidecar file holding the descriptions of a directory
+const FileName = ".descriptions"
+
+// MaxLength - maximum number of characters in a description
+const MaxLength = 1000
+
+// mu - serializes read-modify-write cycles of the sidecar files
+var mu sync.Mutex
+
+// Load - returns the descriptions of the entries in dir, keyed by entry name.
+// A missing sidecar file yields an empty map.
+func Load(dir string) (map[string]string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return load(dir)
+}
+
+// load - reads the sidecar file; the caller must hold mu
+func load(dir string) (map[string]string, error) {
+	result := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return result, err
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return make(map[string]string), fmt.Errorf("error parsing %s: %v", FileName, err)
+	}
+	return result, nil
+}
+
+// Set - stores the description of the entry name in dir. An empty description
+// removes it; the sidecar file is deleted once no description is left.
+func Set(dir, name, description string) error {
+	if utf8.RuneCountInString(description) > MaxLength {
+		return fmt.Errorf("description exceeds %d characters", MaxLength)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	all, err := load(dir)
+	if err != nil {
+		return err
+	}
+	if description == "" {
+		if _, ok := all[name]; !ok {
+			return nil
+		}
+		delete(all, name)
+	} else {
+		all[name] = description
+	}
+
+	sidecar := filepath.Join(dir, FileName)
+	if len(all) == 0 {
+		if err := os.Remove(sidecar); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that readers never see a partial file
+	tmp, err := os.CreateTemp(dir, FileName+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), sidecar); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Description: This file implements the dirsize package, which computes and caches the recursive size of directories.
+package dirsize
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+// defaultWorkers - number of concurrent computations when the configuration leaves it unset
+const defaultWorkers = 2
+
+// Status - state of the cached size of a directory
+type Status string
+
+const (
+	// StatusFresh - the cached size matches the subtree
+	StatusFresh Status = "fresh"
+	// StatusStale - the subtree changed since the size was computed; it is being recomputed
+	StatusStale Status = "stale"
+	// StatusPending - the size was never computed; it is being computed
+	StatusPending Status = "pending"
+)
+
+// Result - represents the recursive size of a directory
+type Result struct {
+	// Size - total size of the regular files in the subtree, in bytes
+	Size int64 `json:"size"`
+	// Files - number of regular files in the subtree
+	Files int `json:"files"`
+	// Computed - time the size was computed
+	Computed time.Time `json:"computed"`
+}
+
+// entry - cached size of a directory with the state of its subtree when computed
+type entry struct {
+	Result
+	// latest - most recent modification time of the directories in the subtree
+	latest time.Time
+	// dirs - directories of the subtree, checked to detect changes without a walk
+	dirs []string
+}
+
+var (
+	mu      sync.Mutex
+	cache   = make(map[string]*entry)
+	pending = make(map[string]bool)
+	workers = make(chan struct{}, defaultWorkers)
+)
+
+// Setup - bounds the number of concurrent computations and clears the cache
+func Setup(config pkg.DirSize) {
+	mu.Lock()
+	defer mu.Unlock()
+	n := config.Workers
+	if n <= 0 {
+		n = defaultWorkers
+	}
+	workers = make(chan struct{}, n)
+	cache = make(map[string]*entry)
+	pending = make(map[string]bool)
+}
+
+// Lookup - returns the cached size of the directory. A size that is missing or out of date
+// is (re)computed in the background, so the call never walks the subtree; until then the
+// stale size, or a zero Result with StatusPending, is returned.
+func Lookup(dir string) (Result, Status) {
+	mu.Lock()
+	cached := cache[dir]
+	mu.Unlock()
+
+	if cached == nil {
+		schedule(dir)
+		return Result{}, StatusPending
+	}
+	if !changed(cached) {
+		return cached.Result, StatusFresh
+	}
+	schedule(dir)
+	return cached.Result, StatusStale
+}
+
+// changed - reports whether a directory of the subtree was modified, added or removed
+// since the size was computed. Only directories are checked, so a file rewritten in
+// place without touching its directory goes unnoticed.
+func changed(e *entry) bool {
+	for _, dir := range e.dirs {
+		info, err := os.Stat(dir)
+		if err != nil || info.ModTime().After(e.latest) {
+			return true
+		}
+	}
+	return false
+}
+
+// schedule - starts computing the size of the directory unless it is already being computed
+func schedule(dir string) {
+	mu.Lock()
+	if pending[dir] {
+		mu.Unlock()
+		return
+	}
+	pending[dir] = true
+	slots := workers
+	mu.Unlock()
+
+	go func() {
+		slots <- struct{}{}
+		defer func() { <-slots }()
+
+		e, err := compute(dir)
+		mu.Lock()
+		defer mu.Unlock()
+		delete(pending, dir)
+		if err != nil {
+			logger.Logger.Warnf("Error computing the size of %s: %v", dir, err)
+			delete(cache, dir)
+			return
+		}
+		cache[dir] = e
+	}()
+}
+
+// compute - walks the subtree and returns its size. Symlinks are not followed and
+// unreadable directories are left out.
+func compute(dir string) (*entry, error) {
+	e := &entry{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && p != dir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			e.dirs = append(e.dirs, p)
+			if info.ModTime().After(e.latest) {
+				e.latest = info.ModTime()
+			}
+		} else if d.Type().IsRegular() {
+			e.Size += info.Size()
+			e.Files++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.Computed = time.Now().UTC().Truncate(time.Second)
+	return e, nil
+}
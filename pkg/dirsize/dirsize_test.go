@@ -0,0 +1,76 @@
+package dirsize
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = logrus.New()
+	logger.Logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// waitFresh - looks the directory up until its size is computed and up to date
+func waitFresh(t *testing.T, dir string) Result {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if result, status := Lookup(dir); status == StatusFresh {
+			return result
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("size of %s was not computed", dir)
+	return Result{}
+}
+
+func TestLookup(t *testing.T) {
+	Setup(pkg.DirSize{Workers: 1})
+	dir := t.TempDir()
+	write := func(name, content string) {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt", "12345")
+	write("sub/deep/b.txt", "123")
+
+	if _, status := Lookup(dir); status != StatusPending {
+		t.Fatalf("first lookup: status %s, want %s", status, StatusPending)
+	}
+	result := waitFresh(t, dir)
+	if result.Size != 8 || result.Files != 2 {
+		t.Errorf("size %+v, want 2 files of 8 bytes", result)
+	}
+
+	// An unchanged subtree reuses the cached size
+	if again, status := Lookup(dir); status != StatusFresh || again != result {
+		t.Errorf("second lookup = %+v, %s, want the cached %+v", again, status, result)
+	}
+
+	// A file added deep in the subtree makes the size stale until it is recomputed
+	later := time.Now().Add(time.Second)
+	write("sub/deep/c.txt", "1234567")
+	if err := os.Chtimes(filepath.Join(dir, "sub", "deep"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if stale, status := Lookup(dir); status != StatusStale || stale != result {
+		t.Errorf("lookup after a change = %+v, %s, want the stale %+v", stale, status, result)
+	}
+	if result := waitFresh(t, dir); result.Size != 15 || result.Files != 3 {
+		t.Errorf("recomputed size %+v, want 3 files of 15 bytes", result)
+	}
+}
@@ -0,0 +1,100 @@
+// Description: This file implements the dirtemplate package, which loads per-directory listing templates.
+package dirtemplate
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileName - name of the optional listing template inside a directory
+const FileName = ".listing.html"
+
+// maxTemplateSize - largest template file accepted
+const maxTemplateSize = 256 << 10
+
+// cacheEntry - parsed template together with the file state it was parsed from
+type cacheEntry struct {
+	modTime time.Time
+	size    int64
+	tmpl    *template.Template
+}
+
+var (
+	mu        sync.Mutex
+	cache     = make(map[string]cacheEntry)
+	baseFuncs template.FuncMap
+)
+
+// Setup - sets the functions available to directory templates
+func Setup(funcs template.FuncMap) {
+	mu.Lock()
+	defer mu.Unlock()
+	baseFuncs = funcs
+	cache = make(map[string]cacheEntry)
+}
+
+// sandboxFuncs - returns the template functions with file access restricted to dir
+func sandboxFuncs(dir string) template.FuncMap {
+	funcs := template.FuncMap{}
+	for name, fn := range baseFuncs {
+		funcs[name] = fn
+	}
+	// Only entries of the directory itself can be inspected
+	funcs["getFileInfo"] = func(_ string, name string) os.FileInfo {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+			return nil
+		}
+		info, err := os.Lstat(filepath.Join(dir, name))
+		if err != nil {
+			return nil
+		}
+		return info
+	}
+	return funcs
+}
+
+// Lookup - returns the parsed listing template of the directory, or nil when the
+// directory has none. Parsed templates are cached by path and modification time.
+func Lookup(dir string) (*template.Template, error) {
+	templatePath := filepath.Join(dir, FileName)
+	info, err := os.Lstat(templatePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// Symlinks could point at arbitrary files outside the directory
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", templatePath)
+	}
+	if info.Size() > maxTemplateSize {
+		return nil, fmt.Errorf("%s exceeds %d bytes", templatePath, maxTemplateSize)
+	}
+
+	mu.Lock()
+	entry, ok := cache[templatePath]
+	mu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.tmpl, nil
+	}
+
+	content, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(FileName).Funcs(sandboxFuncs(dir)).Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	cache[templatePath] = cacheEntry{modTime: info.ModTime(), size: info.Size(), tmpl: tmpl}
+	mu.Unlock()
+	return tmpl, nil
+}
@@ -0,0 +1,52 @@
+// Description: This file contains the Content-Disposition header of served files.
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ContentDisposition - returns a Content-Disposition header of the type ("attachment"
+// or "inline") naming the file. Names with characters outside printable ASCII carry
+// an ASCII fallback in filename, with the others replaced by "_", and the exact name
+// in filename* (RFC 5987), which current browsers prefer.
+func ContentDisposition(dispositionType, name string) string {
+	fallback, ascii := asciiFilename(name)
+	header := dispositionType + `; filename="` + fallback + `"`
+	if !ascii {
+		header += "; filename*=UTF-8''" + encodeRFC5987(name)
+	}
+	return header
+}
+
+// asciiFilename - returns the name with every character that cannot appear in a quoted
+// ASCII filename replaced by "_", and whether it was kept unchanged
+func asciiFilename(name string) (string, bool) {
+	var b strings.Builder
+	ascii := true
+	for _, r := range name {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == utf8.RuneError {
+			b.WriteByte('_')
+			ascii = false
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), ascii
+}
+
+// encodeRFC5987 - percent-encodes the UTF-8 bytes of the value except the attr-char
+// set of RFC 5987
+func encodeRFC5987(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
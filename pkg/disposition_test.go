@@ -0,0 +1,20 @@
+package pkg
+
+import "testing"
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		kind, name, want string
+	}{
+		{"attachment", "files.zip", `attachment; filename="files.zip"`},
+		{"inline", "my clip.mp4", `inline; filename="my clip.mp4"`},
+		{"attachment", "résumé.pdf", `attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`},
+		{"attachment", "报告 2024.txt", `attachment; filename="__ 2024.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A%202024.txt`},
+		{"attachment", `say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+	}
+	for _, tt := range tests {
+		if got := ContentDisposition(tt.kind, tt.name); got != tt.want {
+			t.Errorf("ContentDisposition(%q, %q) =\n%s\nwant\n%s", tt.kind, tt.name, got, tt.want)
+		}
+	}
+}
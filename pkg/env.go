@@ -0,0 +1,46 @@
+// Description: This file contains the environment variable overrides of the configuration.
+package pkg
+
+import "os"
+
+// EnvPrefix - prefix of the environment variables overriding configuration values
+const EnvPrefix = "SFS_"
+
+// envOverrides - maps environment variable names (without prefix) to configuration fields
+func (c *Config) envOverrides() map[string]*string {
+	return map[string]*string{
+		"PORT":          &c.WebServer.Port,
+		"PROTOCOL":      &c.WebServer.Protocol,
+		"BASE_DIR":      &c.WebServer.BaseDir,
+		"SSL_CERT_FILE": &c.WebServer.SSLCert,
+		"SSL_KEY_FILE":  &c.WebServer.SSLKey,
+		"SECRET":        &c.WebServer.Secret,
+		"LOG_FILE":      &c.Logging.LogFile,
+		"LOG_SEVERITY":  &c.Logging.LogSeverity,
+	}
+}
+
+// ApplyEnv - overrides configuration values with the SFS_* environment variables that are set.
+// Environment variables take precedence over the configuration file; unset ones keep the file value.
+// It returns the names of the applied variables.
+func (c *Config) ApplyEnv() []string {
+	var applied []string
+	for name, field := range c.envOverrides() {
+		if value, ok := os.LookupEnv(EnvPrefix + name); ok {
+			*field = value
+			applied = append(applied, EnvPrefix+name)
+		}
+	}
+	return applied
+}
+
+// HasEnv - reports whether any SFS_* configuration variable is set
+func HasEnv() bool {
+	var c Config
+	for name := range c.envOverrides() {
+		if _, ok := os.LookupEnv(EnvPrefix + name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+// Description: This file implements the events package, a broker fanning out file activity to live subscribers.
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+// Types of the published events
+const (
+	TypeUpload       = "upload"
+	TypeDelete       = "delete"
+	TypeCreateFolder = "create-folder"
+	TypeRename       = "rename"
+)
+
+// Defaults applied when the configuration leaves the values unset
+const (
+	defaultMaxSubscribers = 100
+	// bufferSize - events queued for a subscriber before further ones are dropped
+	bufferSize = 64
+)
+
+// ErrTooManySubscribers - the subscriber limit is reached
+var ErrTooManySubscribers = errors.New("too many subscribers")
+
+// Event - represents a change of the files
+type Event struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	// To - new path of a renamed entry
+	To   string    `json:"to,omitempty"`
+	User string    `json:"user"`
+	Size int64     `json:"size,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+var (
+	mu             sync.Mutex
+	subscribers    = make(map[chan Event]struct{})
+	maxSubscribers = defaultMaxSubscribers
+)
+
+// Setup - applies the subscriber limit
+func Setup(config pkg.Events) {
+	mu.Lock()
+	defer mu.Unlock()
+	maxSubscribers = config.MaxSubscribers
+	if maxSubscribers <= 0 {
+		maxSubscribers = defaultMaxSubscribers
+	}
+}
+
+// Subscribe - registers a subscriber and returns its events and the function ending
+// the subscription, or ErrTooManySubscribers when the limit is reached
+func Subscribe() (<-chan Event, func(), error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(subscribers) >= maxSubscribers {
+		return nil, nil, ErrTooManySubscribers
+	}
+	ch := make(chan Event, bufferSize)
+	subscribers[ch] = struct{}{}
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			delete(subscribers, ch)
+		})
+	}, nil
+}
+
+// Subscribers - returns the number of connected subscribers
+func Subscribers() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return len(subscribers)
+}
+
+// Publish - sends the event to every subscriber without waiting: a subscriber whose
+// queue is full misses the event
+func Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for ch := range subscribers {
+		select {
+		case ch <- event:
+		default:
+			logger.Logger.Debugf("Event %s %s dropped for a slow subscriber", event.Type, event.Path)
+		}
+	}
+}
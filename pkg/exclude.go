@@ -0,0 +1,124 @@
+// Description: This file contains the gitignore-style patterns hiding entries from listings.
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// excludeRule - compiled pattern of the listing exclusions
+type excludeRule struct {
+	re      *regexp.Regexp
+	dirOnly bool
+	negate  bool
+}
+
+// ExcludeRules - compiled listing exclusion patterns; the last matching pattern decides
+type ExcludeRules []excludeRule
+
+// CompileExcludes - compiles gitignore-style patterns: "*.tmp" matches the name at any
+// depth, a pattern containing a slash such as "/build" or "docs/*.bak" matches the path
+// from the base directory, a trailing "/" matches directories only, "**" spans
+// directories and a leading "!" shows entries hidden by an earlier pattern again
+func CompileExcludes(patterns []string) (ExcludeRules, error) {
+	rules := make(ExcludeRules, 0, len(patterns))
+	for _, pattern := range patterns {
+		var rule excludeRule
+		p := strings.TrimSpace(pattern)
+		if strings.HasPrefix(p, "!") {
+			rule.negate = true
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			rule.dirOnly = true
+			p = strings.TrimRight(p, "/")
+		}
+		if p == "" {
+			return nil, fmt.Errorf("empty pattern %q", pattern)
+		}
+		anchored := strings.Contains(p, "/")
+		p = strings.TrimPrefix(p, "/")
+		expr, err := globRegexp(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		if !anchored {
+			expr = "(.*/)?" + expr
+		}
+		rule.re, err = regexp.Compile("^" + expr + "$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// globRegexp - translates a glob to a regular expression matching slash separated paths
+func globRegexp(glob string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String(), nil
+}
+
+// Match - reports whether the slash separated path below the base directory is hidden
+func (rules ExcludeRules) Match(rel string, isDir bool) bool {
+	rel = strings.TrimPrefix(path.Clean("/"+rel), "/")
+	excluded := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(rel) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+// Filter - removes the hidden entries from the listing of the slash separated directory
+func (rules ExcludeRules) Filter(files []os.DirEntry, dir string) []os.DirEntry {
+	if len(rules) == 0 {
+		return files
+	}
+	filtered := make([]os.DirEntry, 0, len(files))
+	for _, file := range files {
+		if !rules.Match(path.Join(dir, file.Name()), file.IsDir()) {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,42 @@
+package pkg
+
+import "testing"
+
+func TestExcludeRules(t *testing.T) {
+	rules, err := CompileExcludes([]string{"*.tmp", ".DS_Store", "Thumbs.db", "/build", "docs/*.bak", "cache/", "logs/**/*.log", "!keep.tmp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"a.tmp", false, true},
+		{"deep/dir/b.tmp", false, true},
+		{"keep.tmp", false, false},
+		{"sub/.DS_Store", false, true},
+		{"Thumbs.db", false, true},
+		{"build", true, true},
+		{"src/build", true, false},
+		{"docs/old.bak", false, true},
+		{"docs/sub/old.bak", false, false},
+		{"cache", true, true},
+		{"cache", false, false},
+		{"logs/a.log", false, true},
+		{"logs/2024/01/a.log", false, true},
+		{"a.tmp.txt", false, false},
+		{"readme.md", false, false},
+	}
+	for _, tt := range tests {
+		if got := rules.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, %t) = %t, want %t", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{"", "!", "[abc"} {
+		if _, err := CompileExcludes([]string{pattern}); err == nil {
+			t.Errorf("pattern %q accepted", pattern)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Description: This file implements the expiry package, which deletes files older than the configured age.
+package expiry
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+// defaultInterval - time between sweeps when the configuration leaves it unset
+const defaultInterval = time.Hour
+
+// Removal - represents a file removed (or, in a dry run, due for removal) by a sweep
+type Removal struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Validate - checks the subtrees and exclusion patterns of the configuration
+func Validate(config pkg.Expiry) error {
+	for _, pattern := range config.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q", pattern)
+		}
+	}
+	for _, p := range config.Paths {
+		if p == "" {
+			return fmt.Errorf("empty expiry path")
+		}
+	}
+	return nil
+}
+
+// Excluded - reports whether the slash separated path relative to the base directory
+// matches one of the patterns, either by its name or as a whole
+func Excluded(rel string, patterns []string) bool {
+	name := path.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Sweep - removes the regular files below the configured subtrees of base (the whole
+// base directory when none is configured) last modified more than maxAge before now.
+// Paths passed to skip are slash separated and start with "/"; skipped directories are
+// left out with their contents. Directories are never removed. With dryRun nothing is
+// removed and the files that would be are returned.
+func Sweep(base string, config pkg.Expiry, now time.Time, skip func(rel string, entry fs.DirEntry) bool) ([]Removal, error) {
+	roots := config.Paths
+	if len(roots) == 0 {
+		roots = []string{"/"}
+	}
+	limit := now.Add(-config.MaxAge)
+	var removed []Removal
+	for _, root := range roots {
+		dir := filepath.Join(base, filepath.FromSlash(path.Clean("/"+root)))
+		err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				// Unreadable directories are skipped instead of ending the sweep
+				if entry != nil && entry.IsDir() && p != dir {
+					return fs.SkipDir
+				}
+				return err
+			}
+			rel, err := filepath.Rel(base, p)
+			if err != nil {
+				return err
+			}
+			rel = path.Join("/", filepath.ToSlash(rel))
+			if rel != "/" && skip != nil && skip(rel, entry) {
+				if entry.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil || !info.ModTime().Before(limit) {
+				return nil
+			}
+			if !config.DryRun {
+				if err := os.Remove(p); err != nil {
+					logger.Logger.Warnf("Error removing expired file %s: %v", rel, err)
+					return nil
+				}
+			}
+			removed = append(removed, Removal{Path: rel, Size: info.Size(), ModTime: info.ModTime()})
+			return nil
+		})
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// Start - sweeps the base directory in the background and logs every removal. Nothing
+// is started when max_age is not set.
+func Start(config pkg.Expiry, base string, skip func(rel string, entry fs.DirEntry) bool) {
+	if config.MaxAge <= 0 {
+		return
+	}
+	interval := config.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	go func() {
+		for {
+			removed, err := Sweep(base, config, time.Now(), skip)
+			if err != nil {
+				logger.Logger.Warnf("Error sweeping expired files: %v", err)
+			}
+			var reclaimed int64
+			for _, r := range removed {
+				reclaimed += r.Size
+				if config.DryRun {
+					logger.Logger.Infof("Expired file would be removed (dry run): %s, modified %s", r.Path, r.ModTime.Format(time.RFC3339))
+				} else {
+					logger.Logger.Infof("Expired file removed: %s, modified %s", r.Path, r.ModTime.Format(time.RFC3339))
+				}
+			}
+			if len(removed) > 0 {
+				logger.Logger.Infof("Expiry sweep: %d files, %s (dry run: %t)", len(removed), pkg.ReadableSize(reclaimed), config.DryRun)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
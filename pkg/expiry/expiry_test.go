@@ -0,0 +1,96 @@
+package expiry
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"simple_file_server/pkg"
+)
+
+// agedTree - creates the files, with slash separated paths and their age, below a new directory
+func agedTree(t *testing.T, files map[string]time.Duration, now time.Time) string {
+	t.Helper()
+	base := t.TempDir()
+	for name, age := range files {
+		full := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(full, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return base
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Now()
+	files := map[string]time.Duration{
+		"old.txt":          48 * time.Hour,
+		"new.txt":          time.Hour,
+		"tmp/old.txt":      48 * time.Hour,
+		"tmp/pinned.txt":   48 * time.Hour,
+		"keep/old.txt":     48 * time.Hour,
+		"tmp/sub/old.bin":  48 * time.Hour,
+		"tmp/sub/new.bin":  time.Minute,
+		"config/settings":  48 * time.Hour,
+		"config/other.txt": 48 * time.Hour,
+	}
+	exclude := []string{"pinned.*", "/config/*"}
+	skip := func(rel string, entry fs.DirEntry) bool {
+		return Excluded(rel, exclude)
+	}
+
+	tests := []struct {
+		name   string
+		config pkg.Expiry
+		want   []string
+	}{
+		{"whole base", pkg.Expiry{MaxAge: 24 * time.Hour}, []string{"/keep/old.txt", "/old.txt", "/tmp/old.txt", "/tmp/sub/old.bin"}},
+		{"subtree", pkg.Expiry{MaxAge: 24 * time.Hour, Paths: []string{"tmp"}}, []string{"/tmp/old.txt", "/tmp/sub/old.bin"}},
+		{"dry run", pkg.Expiry{MaxAge: 24 * time.Hour, Paths: []string{"/tmp/sub"}, DryRun: true}, []string{"/tmp/sub/old.bin"}},
+	}
+	for _, tt := range tests {
+		base := agedTree(t, files, now)
+		removals, err := Sweep(base, tt.config, now, skip)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, removal := range removals {
+			got = append(got, removal.Path)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: removed %v, want %v", tt.name, got, tt.want)
+		}
+		for name := range files {
+			_, err := os.Stat(filepath.Join(base, filepath.FromSlash(name)))
+			removed := false
+			for _, p := range got {
+				removed = removed || p == "/"+name
+			}
+			if exists := err == nil; exists == (removed && !tt.config.DryRun) {
+				t.Errorf("%s: %s exists %t after the sweep", tt.name, name, exists)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(pkg.Expiry{Exclude: []string{"*.tmp"}, Paths: []string{"/tmp"}}); err != nil {
+		t.Errorf("valid configuration: %v", err)
+	}
+	if err := Validate(pkg.Expiry{Exclude: []string{"[x"}}); err == nil {
+		t.Error("invalid exclude pattern accepted")
+	}
+	if err := Validate(pkg.Expiry{Paths: []string{""}}); err == nil {
+		t.Error("empty path accepted")
+	}
+}
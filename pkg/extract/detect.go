@@ -0,0 +1,89 @@
+// Description: This file contains the detection of archives by their content.
+package extract
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Responses to an upload with extraction requested that is not a supported archive
+const (
+	// UnsupportedReject - the upload is refused with 415 Unsupported Media Type
+	UnsupportedReject = "reject"
+	// UnsupportedStore - the upload is stored as a regular file
+	UnsupportedStore = "store"
+)
+
+// blockSize - size of a tar header block
+const blockSize = 512
+
+// gzipMagic - first bytes of a gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ValidUnsupported - checks the configured response to unsupported archives
+func ValidUnsupported(value string) error {
+	switch value {
+	case UnsupportedReject, UnsupportedStore:
+		return nil
+	}
+	return fmt.Errorf("unknown response %q (expected reject or store)", value)
+}
+
+// Detect - identifies a tar or gzip-compressed tar archive by its content, whatever its
+// name. When it is neither, detected describes the content, e.g. "application/zip" or
+// "application/x-gzip (not a tar archive)".
+func Detect(r io.Reader) (ok, gzipped bool, detected string, err error) {
+	head := make([]byte, blockSize)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, false, "", err
+	}
+	head = head[:n]
+
+	if bytes.HasPrefix(head, gzipMagic) {
+		zr, err := gzip.NewReader(io.MultiReader(bytes.NewReader(head), r))
+		if err != nil {
+			return false, false, "application/x-gzip (corrupt)", nil
+		}
+		defer zr.Close()
+		inner := make([]byte, blockSize)
+		m, _ := io.ReadFull(zr, inner)
+		if isTarHeader(inner[:m]) {
+			return true, true, "application/x-tar+gzip", nil
+		}
+		return false, false, "application/x-gzip (not a tar archive)", nil
+	}
+	if isTarHeader(head) {
+		return true, false, "application/x-tar", nil
+	}
+	return false, false, http.DetectContentType(head), nil
+}
+
+// isTarHeader - reports whether the block is a tar header with a valid checksum, which
+// holds for every tar format including the original one without the "ustar" magic
+func isTarHeader(block []byte) bool {
+	if len(block) < blockSize {
+		return false
+	}
+	field := strings.TrimRight(strings.TrimSpace(string(bytes.Trim(block[148:156], "\x00 "))), "\x00")
+	if field == "" {
+		return false
+	}
+	stored, err := strconv.ParseInt(field, 8, 64)
+	if err != nil {
+		return false
+	}
+	var unsigned int64
+	for i, b := range block[:blockSize] {
+		if i >= 148 && i < 156 {
+			b = ' '
+		}
+		unsigned += int64(b)
+	}
+	return stored == unsigned
+}
@@ -0,0 +1,49 @@
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	var plainTar bytes.Buffer
+	tw := tar.NewWriter(&plainTar)
+	tw.WriteHeader(&tar.Header{Name: "a.txt", Mode: 0644, Size: 1})
+	tw.Write([]byte("x"))
+	tw.Close()
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	zw.Create("a.txt")
+	zw.Close()
+
+	var gzippedText bytes.Buffer
+	gz := gzip.NewWriter(&gzippedText)
+	gz.Write([]byte(strings.Repeat("not a tar archive\n", 64)))
+	gz.Close()
+
+	tests := []struct {
+		name     string
+		content  []byte
+		ok       bool
+		gzipped  bool
+		detected string
+	}{
+		{"tar", plainTar.Bytes(), true, false, "application/x-tar"},
+		{"tar.gz", tarGz(t, []entry{{name: "a.txt", content: "x"}}).Bytes(), true, true, "application/x-tar+gzip"},
+		{"zip", zipped.Bytes(), false, false, "application/zip"},
+		{"gzip text", gzippedText.Bytes(), false, false, "application/x-gzip (not a tar archive)"},
+		{"corrupt gzip", []byte{0x1f, 0x8b, 0, 0}, false, false, "application/x-gzip (corrupt)"},
+		{"text", []byte("hello"), false, false, "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		ok, gzipped, detected, err := Detect(bytes.NewReader(tt.content))
+		if err != nil || ok != tt.ok || gzipped != tt.gzipped || detected != tt.detected {
+			t.Errorf("%s: Detect = %t, %t, %q, %v, want %t, %t, %q", tt.name, ok, gzipped, detected, err, tt.ok, tt.gzipped, tt.detected)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Description: This file implements the extract package, which unpacks uploaded tar and tar.gz archives.
+package extract
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"simple_file_server/pkg"
+)
+
+// Policies for symlinks stored in archives
+const (
+	// SymlinksSkip - symlink entries are left out
+	SymlinksSkip = "skip"
+	// SymlinksReject - an archive containing a symlink is rejected
+	SymlinksReject = "reject"
+	// SymlinksAllow - symlinks are created when their target stays inside the extraction directory
+	SymlinksAllow = "allow"
+)
+
+var (
+	// ErrUnsafePath - an entry is absolute or leaves the extraction directory
+	ErrUnsafePath = errors.New("archive entry escapes the destination")
+	// ErrTooLarge - the extracted content exceeds the size limit
+	ErrTooLarge = errors.New("archive exceeds the extraction size limit")
+	// ErrTooManyEntries - the archive has more entries than allowed
+	ErrTooManyEntries = errors.New("archive has too many entries")
+	// ErrSymlink - the archive contains a symlink that the policy does not allow
+	ErrSymlink = errors.New("archive contains a symlink that is not allowed")
+)
+
+// Options - limits and policies applied while extracting
+type Options struct {
+	// MaxSize - largest total size of the extracted files in bytes (0 = unlimited)
+	MaxSize int64
+	// MaxEntries - largest number of entries (0 = unlimited)
+	MaxEntries int
+	// Symlinks - policy for symlink entries: skip, reject or allow
+	Symlinks string
+	// PreserveModes - applies the permission bits stored in the archive (without setuid, setgid and sticky bits)
+	PreserveModes bool
+	// DestinationPolicy - symlink policy checked for existing destination paths, see pkg.SafeDestination
+	DestinationPolicy string
+}
+
+// Result - outcome of an extraction
+type Result struct {
+	Files   int      `json:"files"`
+	Dirs    int      `json:"dirs"`
+	Size    int64    `json:"size"`
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// ValidSymlinks - checks the configured policy for symlink entries
+func ValidSymlinks(policy string) error {
+	switch policy {
+	case SymlinksSkip, SymlinksReject, SymlinksAllow:
+		return nil
+	}
+	return fmt.Errorf("unknown archive symlink policy %q (expected skip, reject or allow)", policy)
+}
+
+// entryPath - validates the name of an entry and returns it as a clean relative path
+func entryPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", ErrUnsafePath
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", ErrUnsafePath
+		}
+	}
+	clean := path.Clean(name)
+	if clean == "." {
+		return "", nil
+	}
+	return clean, nil
+}
+
+// extraction - state of a running extraction, used to roll it back on failure
+type extraction struct {
+	base, rel string
+	opts      Options
+	result    Result
+	created   []string
+}
+
+// Tar - extracts the tar archive read from r (gzip compressed when gzipped is set) into
+// the slash separated directory rel below base. Entries with absolute or ".." paths
+// reject the whole archive; on any error the files and directories created so far are removed.
+func Tar(r io.Reader, gzipped bool, base, rel string, opts Options) (Result, error) {
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return Result{}, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	e := &extraction{base: base, rel: path.Clean("/" + rel), opts: opts}
+	if err := e.run(tar.NewReader(r)); err != nil {
+		e.rollback()
+		return Result{}, err
+	}
+	return e.result, nil
+}
+
+// run - extracts every entry of the archive
+func (e *extraction) run(tr *tar.Reader) error {
+	entries := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		entries++
+		if e.opts.MaxEntries > 0 && entries > e.opts.MaxEntries {
+			return ErrTooManyEntries
+		}
+		name, err := entryPath(header.Name)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = e.dir(name, header)
+		case tar.TypeReg, tar.TypeRegA:
+			err = e.file(name, header, tr)
+		case tar.TypeSymlink:
+			err = e.symlink(name, header)
+		default:
+			// Hard links, devices and FIFOs are never created
+			e.result.Skipped = append(e.result.Skipped, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// destination - resolves the entry below the extraction directory, honoring the
+// symlink policy for paths that already exist
+func (e *extraction) destination(name string) (string, error) {
+	full, err := pkg.SafeDestination(e.base, path.Join(e.rel, name), e.opts.DestinationPolicy)
+	if errors.Is(err, pkg.ErrSymlinkEscape) || errors.Is(err, pkg.ErrSymlinkDenied) {
+		return "", ErrUnsafePath
+	}
+	return full, err
+}
+
+// mkdirAll - creates the directory and its missing parents, remembering them for a rollback
+func (e *extraction) mkdirAll(dir string, mode os.FileMode) error {
+	if info, err := os.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%s exists and is not a directory", dir)
+		}
+		return nil
+	}
+	if err := e.mkdirAll(filepath.Dir(dir), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, mode); err != nil && !os.IsExist(err) {
+		return err
+	}
+	e.created = append(e.created, dir)
+	e.result.Dirs++
+	return nil
+}
+
+// mode - returns the permission bits to apply, or def when modes are not preserved
+func (e *extraction) mode(header *tar.Header, def os.FileMode) os.FileMode {
+	if !e.opts.PreserveModes {
+		return def
+	}
+	return os.FileMode(header.Mode) & os.ModePerm
+}
+
+// dir - creates a directory entry
+func (e *extraction) dir(name string, header *tar.Header) error {
+	full, err := e.destination(name)
+	if err != nil {
+		return err
+	}
+	if err := e.mkdirAll(full, os.ModePerm); err != nil {
+		return err
+	}
+	if e.opts.PreserveModes {
+		// The owner keeps access so that the following entries can be written
+		return os.Chmod(full, e.mode(header, os.ModePerm)|0700)
+	}
+	return nil
+}
+
+// file - writes a regular file entry, enforcing the size limit on the bytes actually read
+func (e *extraction) file(name string, header *tar.Header, r io.Reader) error {
+	if e.opts.MaxSize > 0 && e.result.Size+header.Size > e.opts.MaxSize {
+		return ErrTooLarge
+	}
+	full, err := e.destination(name)
+	if err != nil {
+		return err
+	}
+	if err := e.mkdirAll(filepath.Dir(full), os.ModePerm); err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(full, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, e.mode(header, 0666))
+	if err != nil {
+		return err
+	}
+	e.created = append(e.created, full)
+	written, err := io.Copy(dst, io.LimitReader(r, header.Size))
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if e.opts.PreserveModes {
+		// The mode of an existing file is kept by OpenFile
+		if err := os.Chmod(full, e.mode(header, 0666)); err != nil {
+			return err
+		}
+	}
+	e.result.Files++
+	e.result.Size += written
+	return nil
+}
+
+// symlink - applies the symlink policy to a symlink entry
+func (e *extraction) symlink(name string, header *tar.Header) error {
+	switch e.opts.Symlinks {
+	case SymlinksReject:
+		return ErrSymlink
+	case SymlinksAllow:
+	default:
+		e.result.Skipped = append(e.result.Skipped, name)
+		return nil
+	}
+	// The target must stay inside the extraction directory
+	target := strings.ReplaceAll(header.Linkname, "\\", "/")
+	if target == "" || path.IsAbs(target) || filepath.IsAbs(target) {
+		return ErrSymlink
+	}
+	resolved := path.Join(path.Dir(name), target)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return ErrSymlink
+	}
+	full, err := e.destination(name)
+	if err != nil {
+		return err
+	}
+	if err := e.mkdirAll(filepath.Dir(full), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Symlink(filepath.FromSlash(target), full); err != nil {
+		return err
+	}
+	e.created = append(e.created, full)
+	e.result.Files++
+	return nil
+}
+
+// rollback - removes what the extraction created, newest first; directories are
+// removed only when they are empty
+func (e *extraction) rollback() {
+	for i := len(e.created) - 1; i >= 0; i-- {
+		os.Remove(e.created[i])
+	}
+}
@@ -0,0 +1,112 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// entry - member of a test archive; a non-empty link makes it a symlink
+type entry struct {
+	name, content, link string
+}
+
+func tarGz(t *testing.T, entries []entry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.content))}
+		switch {
+		case e.link != "":
+			header = &tar.Header{Name: e.name, Typeflag: tar.TypeSymlink, Linkname: e.link}
+		case e.name[len(e.name)-1] == '/':
+			header = &tar.Header{Name: e.name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestTar(t *testing.T) {
+	base := t.TempDir()
+	archive := tarGz(t, []entry{
+		{name: "docs/"},
+		{name: "docs/a.txt", content: "alpha"},
+		{name: "docs/sub/b.txt", content: "beta"},
+		{name: "./c.txt", content: "gamma"},
+	})
+	result, err := Tar(archive, true, base, "/target", Options{Symlinks: SymlinksSkip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != 3 || result.Size != 14 {
+		t.Errorf("result %+v, want 3 files of 14 bytes", result)
+	}
+	for name, want := range map[string]string{"docs/a.txt": "alpha", "docs/sub/b.txt": "beta", "c.txt": "gamma"} {
+		got, err := os.ReadFile(filepath.Join(base, "target", filepath.FromSlash(name)))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", name, got, err, want)
+		}
+	}
+}
+
+func TestTarRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+		opts    Options
+		want    error
+	}{
+		{"absolute path", []entry{{name: "ok.txt", content: "x"}, {name: "/etc/passwd", content: "x"}}, Options{}, ErrUnsafePath},
+		{"parent path", []entry{{name: "ok.txt", content: "x"}, {name: "docs/../../evil.txt", content: "x"}}, Options{}, ErrUnsafePath},
+		{"size limit", []entry{{name: "ok.txt", content: "xxx"}, {name: "big.txt", content: "xxx"}}, Options{MaxSize: 5}, ErrTooLarge},
+		{"entry limit", []entry{{name: "ok.txt", content: "x"}, {name: "two.txt", content: "x"}}, Options{MaxEntries: 1}, ErrTooManyEntries},
+		{"rejected symlink", []entry{{name: "ok.txt", content: "x"}, {name: "link", link: "ok.txt"}}, Options{Symlinks: SymlinksReject}, ErrSymlink},
+		{"escaping symlink", []entry{{name: "ok.txt", content: "x"}, {name: "link", link: "../../etc"}}, Options{Symlinks: SymlinksAllow}, ErrSymlink},
+	}
+	for _, tt := range tests {
+		base := t.TempDir()
+		if _, err := Tar(tarGz(t, tt.entries), true, base, "/", tt.opts); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.want)
+		}
+		// The entries extracted before the failure are rolled back
+		if _, err := os.Lstat(filepath.Join(base, "ok.txt")); !os.IsNotExist(err) {
+			t.Errorf("%s: ok.txt was not rolled back: %v", tt.name, err)
+		}
+	}
+}
+
+func TestTarSymlinks(t *testing.T) {
+	entries := []entry{{name: "a.txt", content: "x"}, {name: "link", link: "a.txt"}}
+	for _, policy := range []string{SymlinksSkip, SymlinksAllow} {
+		base := t.TempDir()
+		result, err := Tar(tarGz(t, entries), true, base, "/", Options{Symlinks: policy})
+		if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		target, err := os.Readlink(filepath.Join(base, "link"))
+		if policy == SymlinksSkip && (err == nil || len(result.Skipped) != 1) {
+			t.Errorf("%s: link created or not reported as skipped: %+v", policy, result)
+		}
+		if policy == SymlinksAllow && target != "a.txt" {
+			t.Errorf("%s: link target %q, %v, want a.txt", policy, target, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Description: This file implements the favorites package, which keeps the pinned directories of every user.
+package favorites
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+// defaultMaxPerUser - number of favorites kept per user when the configuration leaves it unset
+const defaultMaxPerUser = 50
+
+var (
+	mu         sync.Mutex
+	favorites  = make(map[string][]string) // user -> paths, in the order they were pinned
+	file       string
+	maxPerUser int
+)
+
+// Setup - configures the limit and loads persisted favorites if a file is configured
+func Setup(config pkg.Favorites) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	maxPerUser = config.MaxPerUser
+	if maxPerUser <= 0 {
+		maxPerUser = defaultMaxPerUser
+	}
+	file = config.File
+	favorites = make(map[string][]string)
+
+	if file == "" {
+		return
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Logger.Warnf("Error reading favorites file: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &favorites); err != nil {
+		logger.Logger.Warnf("Error parsing favorites file: %v", err)
+		favorites = make(map[string][]string)
+	}
+}
+
+// List - returns the favorites of the user
+func List(user string) []string {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]string(nil), favorites[user]...)
+}
+
+// IsFavorite - reports whether the path is pinned by the user
+func IsFavorite(user, path string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return indexOf(favorites[user], path) >= 0
+}
+
+// Pinned - reports whether any user pinned the path
+func Pinned(path string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, list := range favorites {
+		if indexOf(list, path) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Toggle - pins the path for the user, or unpins it when it is already pinned.
+// It returns whether the path is pinned afterwards; the oldest favorite is dropped
+// when the limit is reached.
+func Toggle(user, path string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	list := favorites[user]
+	pinned := false
+	if i := indexOf(list, path); i >= 0 {
+		list = append(list[:i:i], list[i+1:]...)
+	} else {
+		list = append(list, path)
+		if len(list) > maxPerUser {
+			list = list[len(list)-maxPerUser:]
+		}
+		pinned = true
+	}
+	if len(list) == 0 {
+		delete(favorites, user)
+	} else {
+		favorites[user] = list
+	}
+	if file != "" {
+		persist()
+	}
+	return pinned
+}
+
+// indexOf - returns the position of path in list, or -1
+func indexOf(list []string, path string) int {
+	for i, p := range list {
+		if p == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// persist - writes the favorites to the configured file; the caller must hold mu
+func persist() {
+	data, err := json.Marshal(favorites)
+	if err != nil {
+		logger.Logger.Warnf("Error encoding favorites: %v", err)
+		return
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		logger.Logger.Warnf("Error writing favorites file: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+// Description: This file implements the ftp package, which exposes the base directory read-only over FTP.
+package ftp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/spf13/afero"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+// errNoTLS - TLS was requested while no certificate is configured
+var errNoTLS = errors.New("TLS is not configured")
+
+// Options - represents what the FTP server shares with the web server
+type Options struct {
+	// Base - directory served as the FTP root
+	Base string
+	// CertFile, KeyFile - certificate offered to clients requesting AUTH TLS, optional
+	CertFile string
+	KeyFile  string
+	// Authenticate - checks the credentials of a login and returns the accepting backend
+	Authenticate func(user, password string) (string, error)
+	// Visible - reports whether the user may see the slash separated path below Base
+	Visible func(p, user string) bool
+	// SymlinkPolicy - symlink_policy of the web server; symlinks resolving outside Base
+	// are followed only with follow
+	SymlinkPolicy string
+}
+
+// driver - implements ftpserver.MainDriver
+type driver struct {
+	config  pkg.FTP
+	options Options
+}
+
+// ParsePortRange - parses a passive port range such as "30000-30009"; an empty value
+// returns nil, leaving the ports to the system
+func ParsePortRange(value string) (*ftpserver.PortRange, error) {
+	if value == "" {
+		return nil, nil
+	}
+	start, end, found := strings.Cut(value, "-")
+	if !found {
+		end = start
+	}
+	first, err1 := strconv.Atoi(strings.TrimSpace(start))
+	last, err2 := strconv.Atoi(strings.TrimSpace(end))
+	if err1 != nil || err2 != nil || first <= 0 || last > 65535 || first > last {
+		return nil, fmt.Errorf("invalid passive port range %q", value)
+	}
+	return &ftpserver.PortRange{Start: first, End: last}, nil
+}
+
+// Start - listens on the configured port and serves FTP in the background. Clients
+// must log in; files are served read-only and hidden paths do not exist for them.
+func Start(config pkg.FTP, options Options) error {
+	server := ftpserver.NewFtpServer(&driver{config: config, options: options})
+	if err := server.Listen(); err != nil {
+		return err
+	}
+	go func() {
+		if err := server.Serve(); err != nil {
+			logger.Logger.Errorf("FTP server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// GetSettings - returns the listening address and the passive port range
+func (d *driver) GetSettings() (*ftpserver.Settings, error) {
+	ports, err := ParsePortRange(d.config.PassivePorts)
+	if err != nil {
+		return nil, err
+	}
+	return &ftpserver.Settings{
+		ListenAddr:               ":" + d.config.Port,
+		PublicHost:               d.config.PublicHost,
+		PassiveTransferPortRange: ports,
+		IdleTimeout:              int(d.config.IdleTimeout.Seconds()),
+		DisableSite:              true,
+	}, nil
+}
+
+// ClientConnected - returns the welcome message
+func (d *driver) ClientConnected(cc ftpserver.ClientContext) (string, error) {
+	logger.Logger.Infof("FTP connection from IP: %s", cc.RemoteAddr())
+	return "Simple File Server", nil
+}
+
+// ClientDisconnected - logs the end of the connection
+func (d *driver) ClientDisconnected(cc ftpserver.ClientContext) {
+	logger.Logger.Infof("FTP disconnection from IP: %s", cc.RemoteAddr())
+}
+
+// AuthUser - checks the credentials and returns the filesystem of the user
+func (d *driver) AuthUser(cc ftpserver.ClientContext, user, pass string) (ftpserver.ClientDriver, error) {
+	backend, err := d.options.Authenticate(user, pass)
+	if err != nil {
+		logger.Logger.Warnf("Failed FTP login from IP: %s, User: %s", cc.RemoteAddr(), logger.User(user))
+		return nil, errors.New("authentication failed")
+	}
+	logger.Logger.Infof("FTP login from IP: %s, User: %s, Backend: %s", cc.RemoteAddr(), logger.User(user), backend)
+	base := afero.NewReadOnlyFs(afero.NewBasePathFs(afero.NewOsFs(), d.options.Base))
+	return &userFs{Fs: base, base: d.options.Base, policy: d.options.SymlinkPolicy, user: user, visible: d.options.Visible}, nil
+}
+
+// GetTLSConfig - returns the certificate of the web server for AUTH TLS
+func (d *driver) GetTLSConfig() (*tls.Config, error) {
+	if d.options.CertFile == "" || d.options.KeyFile == "" {
+		return nil, errNoTLS
+	}
+	cert, err := tls.LoadX509KeyPair(d.options.CertFile, d.options.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+// userFs - read-only view of the base directory for a user, hiding the paths the user
+// may not see as if they did not exist
+type userFs struct {
+	afero.Fs
+	base    string
+	policy  string
+	user    string
+	visible func(p, user string) bool
+}
+
+// check - fails with os.ErrNotExist when the user may not see the path or when it
+// leaves the base directory, like the web server: afero.BasePathFs only prefixes the
+// name, so symlinks are checked with pkg.ResolvePath
+func (f *userFs) check(name string) error {
+	p := path.Clean("/" + filepath.ToSlash(name))
+	if f.visible != nil && !f.visible(p, f.user) {
+		return &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if _, err := pkg.ResolvePath(f.base, p); err != nil {
+		if errors.Is(err, pkg.ErrSymlinkEscape) && f.policy == pkg.SymlinkFollow {
+			return nil
+		}
+		logger.Logger.Warnf("FTP path rejected: %s (%v), User: %s", p, err, logger.User(f.user))
+		return &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return nil
+}
+
+// Open - opens the file when the user may see it
+func (f *userFs) Open(name string) (afero.File, error) {
+	if err := f.check(name); err != nil {
+		return nil, err
+	}
+	return f.Fs.Open(name)
+}
+
+// OpenFile - opens the file when the user may see it
+func (f *userFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if err := f.check(name); err != nil {
+		return nil, err
+	}
+	return f.Fs.OpenFile(name, flag, perm)
+}
+
+// Stat - returns the file info when the user may see the file
+func (f *userFs) Stat(name string) (os.FileInfo, error) {
+	if err := f.check(name); err != nil {
+		return nil, err
+	}
+	return f.Fs.Stat(name)
+}
+
+// ReadDir - lists the directory without the entries the user may not see; it
+// implements ftpserver.ClientDriverExtensionFileList
+func (f *userFs) ReadDir(name string) ([]os.FileInfo, error) {
+	if err := f.check(name); err != nil {
+		return nil, err
+	}
+	infos, err := afero.ReadDir(f.Fs, name)
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Clean("/" + filepath.ToSlash(name))
+	visible := infos[:0]
+	for _, info := range infos {
+		if f.visible == nil || f.visible(path.Join(dir, info.Name()), f.user) {
+			visible = append(visible, info)
+		}
+	}
+	return visible, nil
+}
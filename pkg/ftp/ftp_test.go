@@ -0,0 +1,141 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/sirupsen/logrus"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = logrus.New()
+	logger.Logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// clientContext - connection of a test client; only RemoteAddr is used by the driver
+type clientContext struct {
+	ftpserver.ClientContext
+}
+
+func (clientContext) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2121}
+}
+
+// testFs - logs alice in on a driver serving a new base directory and returns the
+// filesystem of her session with the base directory
+func testFs(t *testing.T, policy string) (*userFs, string) {
+	t.Helper()
+	base := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "alpha", "docs/b.txt": "beta", "private/c.txt": "secret"} {
+		full := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := &driver{options: Options{
+		Base: base,
+		Authenticate: func(user, password string) (string, error) {
+			if user == "alice" && password == "password" {
+				return "htpasswd", nil
+			}
+			return "", errors.New("invalid credentials")
+		},
+		Visible: func(p, user string) bool {
+			return p != "/private" && !strings.HasPrefix(p, "/private/")
+		},
+		SymlinkPolicy: policy,
+	}}
+	if _, err := d.AuthUser(clientContext{}, "alice", "wrong"); err == nil {
+		t.Fatal("login with a wrong password accepted")
+	}
+	client, err := d.AuthUser(clientContext{}, "alice", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.(*userFs), base
+}
+
+func TestList(t *testing.T) {
+	fs, _ := testFs(t, pkg.SymlinkDeny)
+	infos, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	if want := []string{"a.txt", "docs"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("listing = %v, want %v", names, want)
+	}
+	if _, err := fs.ReadDir("/private"); !os.IsNotExist(err) {
+		t.Errorf("listing of a hidden directory: %v, want not exist", err)
+	}
+}
+
+func TestRetrieve(t *testing.T) {
+	fs, base := testFs(t, pkg.SymlinkDeny)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(base, "escape.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := fs.Open("/docs/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || string(content) != "beta" {
+		t.Errorf("retrieve /docs/b.txt = %q, %v", content, err)
+	}
+
+	for _, name := range []string{"/private/c.txt", "../../etc/passwd", "/escape.txt"} {
+		if _, err := fs.Open(name); err == nil {
+			t.Errorf("retrieve %s succeeded", name)
+		}
+	}
+	if _, err := fs.OpenFile("/new.txt", os.O_WRONLY|os.O_CREATE, 0o644); err == nil {
+		t.Error("store on the read-only filesystem succeeded")
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		value string
+		want  *ftpserver.PortRange
+		ok    bool
+	}{
+		{"", nil, true},
+		{"30000-30009", &ftpserver.PortRange{Start: 30000, End: 30009}, true},
+		{"2121", &ftpserver.PortRange{Start: 2121, End: 2121}, true},
+		{"30009-30000", nil, false},
+		{"0-10", nil, false},
+		{"a-b", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := ParsePortRange(tt.value)
+		if (err == nil) != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePortRange(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
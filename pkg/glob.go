@@ -0,0 +1,66 @@
+// Description: This file contains the expansion of name patterns used by glob downloads.
+package pkg
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrInvalidGlob - the pattern is malformed or contains a path separator
+	ErrInvalidGlob = errors.New("invalid glob pattern")
+	// ErrTooManyMatches - the pattern matches more files than allowed
+	ErrTooManyMatches = errors.New("too many files match the pattern")
+)
+
+// ValidGlob - checks that the pattern is a well-formed name pattern. Patterns match
+// single names only, so separators and ".." are rejected and cannot leave the directory.
+func ValidGlob(pattern string) error {
+	if pattern == "" || pattern == "." || pattern == ".." || strings.ContainsAny(pattern, `/\`) {
+		return ErrInvalidGlob
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return ErrInvalidGlob
+	}
+	return nil
+}
+
+// FilterByGlob - keeps the entries whose name matches the pattern
+func FilterByGlob(files []os.DirEntry, pattern string) []os.DirEntry {
+	filtered := make([]os.DirEntry, 0, len(files))
+	for _, file := range files {
+		if ok, _ := path.Match(pattern, file.Name()); ok {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
+
+// GlobFiles - returns the slash separated paths, relative to dir, of the files whose
+// name matches the pattern, walking dir within the bounds of opts (see WalkFiles); the
+// directories that were not entered are returned as well. Entries for which skip returns
+// true are left out. ErrTooManyMatches is returned once more than max files match
+// (zero or less means no limit).
+func GlobFiles(dir, pattern string, opts WalkOptions, max int, skip func(rel string, entry fs.DirEntry) bool) ([]string, []string, error) {
+	if err := ValidGlob(pattern); err != nil {
+		return nil, nil, err
+	}
+	matches := make([]string, 0)
+	truncated, err := WalkFiles(dir, opts, skip, func(rel, full string) error {
+		if ok, _ := path.Match(pattern, path.Base(rel)); !ok {
+			return nil
+		}
+		if max > 0 && len(matches) >= max {
+			return ErrTooManyMatches
+		}
+		matches = append(matches, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return matches, truncated, nil
+}
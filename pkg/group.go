@@ -0,0 +1,140 @@
+// Description: This file contains the grouping of directory listings by file type or extension.
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Listing groupings
+const (
+	GroupNone      = "none"
+	GroupType      = "type"
+	GroupExtension = "extension"
+)
+
+// typeGroups - labels of the type groups, in display order; entries matching no
+// extension below fall into the last one
+var typeGroups = []string{"Folders", "Images", "Documents", "Audio", "Video", "Archives", "Others"}
+
+// typeExtensions - maps extensions to their type group
+var typeExtensions = map[string]string{
+	".jpg": "Images", ".jpeg": "Images", ".png": "Images", ".gif": "Images", ".bmp": "Images", ".svg": "Images", ".webp": "Images",
+	".txt": "Documents", ".md": "Documents", ".pdf": "Documents", ".doc": "Documents", ".docx": "Documents", ".odt": "Documents",
+	".xls": "Documents", ".xlsx": "Documents", ".ods": "Documents", ".csv": "Documents", ".tsv": "Documents", ".ppt": "Documents", ".pptx": "Documents",
+	".mp3": "Audio", ".wav": "Audio", ".aac": "Audio", ".flac": "Audio", ".ogg": "Audio",
+	".mp4": "Video", ".avi": "Video", ".mov": "Video", ".mkv": "Video", ".webm": "Video",
+	".zip": "Archives", ".rar": "Archives", ".7z": "Archives", ".tar": "Archives", ".gz": "Archives",
+}
+
+// FileGroup - labeled group of directory entries passed to the listing template
+type FileGroup struct {
+	Label string
+	Files []os.DirEntry
+}
+
+// EntryGroup - labeled group of the JSON listing
+type EntryGroup struct {
+	Label   string         `json:"label"`
+	Entries []ListingEntry `json:"entries"`
+}
+
+// ValidGroupBy - checks the configured grouping
+func ValidGroupBy(by string) error {
+	switch by {
+	case "", GroupNone, GroupType, GroupExtension:
+		return nil
+	}
+	return fmt.Errorf("unknown grouping %q (expected none, type or extension)", by)
+}
+
+// groupLabel - returns the label of the group the entry belongs to
+func groupLabel(name string, isDir bool, by string) string {
+	if isDir {
+		return "Folders"
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	if by == GroupExtension {
+		if ext == "" || ext == "." {
+			return "Others"
+		}
+		return strings.ToUpper(ext[1:])
+	}
+	if group, ok := typeExtensions[ext]; ok {
+		return group
+	}
+	return "Others"
+}
+
+// groupOrder - returns the sort rank of a label: folders first and others last,
+// type groups in their display order, extension groups alphabetically in between
+func groupOrder(label string) int {
+	for i, group := range typeGroups {
+		if group == label {
+			if label == "Others" {
+				return len(typeGroups) + 1
+			}
+			return i
+		}
+	}
+	return len(typeGroups)
+}
+
+// sortedLabels - returns the labels ordered for display
+func sortedLabels(labels []string) []string {
+	sort.SliceStable(labels, func(i, j int) bool {
+		oi, oj := groupOrder(labels[i]), groupOrder(labels[j])
+		if oi != oj {
+			return oi < oj
+		}
+		return labels[i] < labels[j]
+	})
+	return labels
+}
+
+// GroupFiles - splits the entries into labeled groups keeping their order within a
+// group. Without grouping a single unlabeled group holds every entry.
+func GroupFiles(files []os.DirEntry, by string) []FileGroup {
+	if by == "" || by == GroupNone {
+		return []FileGroup{{Files: files}}
+	}
+	byLabel := make(map[string][]os.DirEntry)
+	var labels []string
+	for _, file := range files {
+		label := groupLabel(file.Name(), file.IsDir(), by)
+		if _, ok := byLabel[label]; !ok {
+			labels = append(labels, label)
+		}
+		byLabel[label] = append(byLabel[label], file)
+	}
+	groups := make([]FileGroup, 0, len(labels))
+	for _, label := range sortedLabels(labels) {
+		groups = append(groups, FileGroup{Label: label, Files: byLabel[label]})
+	}
+	return groups
+}
+
+// GroupEntries - splits JSON listing entries into labeled groups like GroupFiles.
+// It returns nil without grouping.
+func GroupEntries(entries []ListingEntry, by string) []EntryGroup {
+	if by == "" || by == GroupNone {
+		return nil
+	}
+	byLabel := make(map[string][]ListingEntry)
+	var labels []string
+	for _, entry := range entries {
+		label := groupLabel(entry.Name, entry.IsDir, by)
+		if _, ok := byLabel[label]; !ok {
+			labels = append(labels, label)
+		}
+		byLabel[label] = append(byLabel[label], entry)
+	}
+	groups := make([]EntryGroup, 0, len(labels))
+	for _, label := range sortedLabels(labels) {
+		groups = append(groups, EntryGroup{Label: label, Entries: byLabel[label]})
+	}
+	return groups
+}
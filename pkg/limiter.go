@@ -0,0 +1,45 @@
+// Description: This file contains the keyed concurrency limiter used to cap parallel requests.
+package pkg
+
+import "sync"
+
+// ConcurrencyLimiter - limits the number of concurrent operations per key (e.g. client IP)
+type ConcurrencyLimiter struct {
+	mu     sync.Mutex
+	max    int
+	active map[string]int
+}
+
+// NewConcurrencyLimiter - creates a limiter allowing max concurrent operations per key.
+// A max of zero or less disables the limit.
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{max: max, active: make(map[string]int)}
+}
+
+// Acquire - reserves a slot for the key, returning false when the limit is reached
+func (l *ConcurrencyLimiter) Acquire(key string) bool {
+	if l.max <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[key] >= l.max {
+		return false
+	}
+	l.active[key]++
+	return true
+}
+
+// Release - frees a slot previously reserved with Acquire
+func (l *ConcurrencyLimiter) Release(key string) {
+	if l.max <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[key] <= 1 {
+		delete(l.active, key)
+		return
+	}
+	l.active[key]--
+}
@@ -0,0 +1,204 @@
+// Description: This file contains helpers for building directory listings.
+package pkg
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page size limits of the paginated JSON listing
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 1000
+)
+
+// ListingEntry - represents a directory entry in the JSON listing
+type ListingEntry struct {
+	Name    string    `json:"name"`
+	IsDir   bool      `json:"isDir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	// Description - optional description of the entry
+	Description string `json:"description,omitempty"`
+	// Metadata - values of the sidecar metadata file of the entry
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// URL - absolute URL of the entry, with a trailing slash for directories
+	URL string `json:"url,omitempty"`
+	// Tags - user-defined tags of the entry
+	Tags []string `json:"tags,omitempty"`
+	// RecursiveSize - total size of a directory's subtree, when known and dir_size.in_listing is set
+	RecursiveSize *int64 `json:"recursiveSize,omitempty"`
+	// ChildCount - number of immediate entries of a directory when child_count is enabled;
+	// ChildCountMore - the directory has more entries than child_count.limit
+	ChildCount     *int `json:"childCount,omitempty"`
+	ChildCountMore bool `json:"childCountMore,omitempty"`
+}
+
+// ListingResponse - represents the JSON listing of a directory
+type ListingResponse struct {
+	Path       string         `json:"path"`
+	Entries    []ListingEntry `json:"entries"`
+	Pagination *Pagination    `json:"pagination,omitempty"`
+	// Groups - the entries split into labeled sections when group_by is set
+	Groups []EntryGroup `json:"groups,omitempty"`
+	// ServerTime - time the listing was produced, RefreshInterval - suggested polling interval in seconds
+	ServerTime      time.Time `json:"server_time"`
+	RefreshInterval int       `json:"refresh_interval,omitempty"`
+}
+
+// Pagination - describes the page of a paginated listing
+type Pagination struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"perPage"`
+	Total      int `json:"total"`
+	TotalPages int `json:"totalPages"`
+}
+
+// ParsePagination - reads the page and perPage query parameters. It returns nil when
+// neither is present; invalid values fall back to the defaults and perPage is capped at MaxPerPage.
+func ParsePagination(query url.Values) *Pagination {
+	if !query.Has("page") && !query.Has("perPage") {
+		return nil
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(query.Get("perPage"))
+	if err != nil || perPage < 1 {
+		perPage = DefaultPerPage
+	}
+	if perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+	return &Pagination{Page: page, PerPage: perPage}
+}
+
+// Paginate - returns the entries of the requested page and fills in the totals.
+// A page past the end is clamped to the last page.
+func (p *Pagination) Paginate(entries []ListingEntry) []ListingEntry {
+	p.Total = len(entries)
+	p.TotalPages = (p.Total + p.PerPage - 1) / p.PerPage
+	if p.TotalPages == 0 {
+		p.TotalPages = 1
+	}
+	if p.Page > p.TotalPages {
+		p.Page = p.TotalPages
+	}
+	start := (p.Page - 1) * p.PerPage
+	end := start + p.PerPage
+	if end > p.Total {
+		end = p.Total
+	}
+	return entries[start:end]
+}
+
+// NewListingEntries - converts directory entries into listing entries
+func NewListingEntries(files []os.DirEntry) []ListingEntry {
+	entries := make([]ListingEntry, 0, len(files))
+	for _, file := range files {
+		entry := ListingEntry{
+			Name:  file.Name(),
+			IsDir: file.IsDir(),
+		}
+		if info, err := file.Info(); err == nil {
+			if !file.IsDir() {
+				entry.Size = info.Size()
+			}
+			entry.ModTime = info.ModTime()
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// ParseExtensions - parses a comma separated list of extensions ("pdf,.TXT") into
+// lower-case extensions with a leading dot
+func ParseExtensions(value string) []string {
+	var exts []string
+	for _, ext := range strings.Split(value, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || ext == "." {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+// FilterByExtension - keeps the files whose extension is in exts (case-insensitive).
+// Directories are kept only when includeDirs is set.
+func FilterByExtension(files []os.DirEntry, exts []string, includeDirs bool) []os.DirEntry {
+	filtered := make([]os.DirEntry, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() {
+			if includeDirs {
+				filtered = append(filtered, file)
+			}
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(file.Name()))
+		for _, allowed := range exts {
+			if ext == allowed {
+				filtered = append(filtered, file)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// ExcludeName - removes the entry with the given name from the listing
+func ExcludeName(files []os.DirEntry, name string) []os.DirEntry {
+	filtered := make([]os.DirEntry, 0, len(files))
+	for _, file := range files {
+		if file.Name() != name {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
+
+// FilterByPrefix - keeps at most limit entries whose name starts with prefix.
+// A limit of zero or less means no limit.
+func FilterByPrefix(files []os.DirEntry, prefix string, ignoreCase bool, limit int) []os.DirEntry {
+	if ignoreCase {
+		prefix = strings.ToLower(prefix)
+	}
+	filtered := make([]os.DirEntry, 0)
+	for _, file := range files {
+		if limit > 0 && len(filtered) >= limit {
+			break
+		}
+		name := file.Name()
+		if ignoreCase {
+			name = strings.ToLower(name)
+		}
+		if strings.HasPrefix(name, prefix) {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
+
+// FindNameFold - returns the name of the entry in dir that equals name ignoring case,
+// or an empty string when there is none
+func FindNameFold(dir, name string) (string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		if strings.EqualFold(file.Name(), name) {
+			return file.Name(), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,142 @@
+// Description: This file implements the access log middleware and request scoped log fields.
+package logger
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxHeaderFieldLength - maximum length of a header value copied into a log field
+const maxHeaderFieldLength = 128
+
+// headerFields - maps request header names to log field names
+var headerFields map[string]string
+
+var (
+	// accessFormat - format of the access log entries
+	accessFormat = FormatJSON
+	// accessLogger - destination of json and text access entries
+	accessLogger *logrus.Logger
+	// accessWriter - destination of common and combined access lines
+	accessWriter io.Writer
+	// accessTLS - adds the connection details of HTTPS requests to access entries
+	accessTLS bool
+)
+
+// statusRecorder - captures the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.size += n
+	return n, err
+}
+
+// Flush - keeps streaming responses working through the recorder
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// sanitizeHeaderValue - drops control characters and truncates the value
+func sanitizeHeaderValue(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value)
+	if len(value) > maxHeaderFieldLength {
+		value = value[:maxHeaderFieldLength]
+	}
+	return value
+}
+
+// RequestFields - returns the configured request headers as log fields
+func RequestFields(r *http.Request) logrus.Fields {
+	fields := logrus.Fields{}
+	for header, field := range headerFields {
+		if value := r.Header.Get(header); value != "" {
+			fields[field] = sanitizeHeaderValue(value)
+		}
+	}
+	return fields
+}
+
+// TLSFields - returns the negotiated TLS version, cipher suite and SNI server name of
+// the request as log fields, or nil for plain HTTP
+func TLSFields(r *http.Request) logrus.Fields {
+	if r.TLS == nil {
+		return nil
+	}
+	fields := logrus.Fields{
+		"tls_version": tls.VersionName(r.TLS.Version),
+		"tls_cipher":  tls.CipherSuiteName(r.TLS.CipherSuite),
+	}
+	if r.TLS.ServerName != "" {
+		fields["tls_server_name"] = sanitizeHeaderValue(r.TLS.ServerName)
+	}
+	return fields
+}
+
+// WithRequest - returns a log entry carrying the configured request header fields
+func WithRequest(r *http.Request) *logrus.Entry {
+	return Logger.WithFields(RequestFields(r))
+}
+
+// AccessLog - logs every request with its status, size and duration
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		// X-User is set by the authentication middleware only, never by the client
+		r.Header.Del("X-User")
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		if accessFormat == FormatCommon || accessFormat == FormatCombined {
+			if _, err := io.WriteString(accessWriter, FormatCLF(r, rec.status, rec.size, start, accessFormat == FormatCombined)); err != nil {
+				Logger.Warnf("Error writing access log: %v", err)
+			}
+			return
+		}
+
+		target := accessLogger
+		if target == nil {
+			target = Logger
+		}
+		entry := target.WithFields(RequestFields(r))
+		if accessTLS {
+			entry = entry.WithFields(TLSFields(r))
+		}
+		entry.WithFields(logrus.Fields{
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"status":   rec.status,
+			"size":     rec.size,
+			"duration": time.Since(start).String(),
+			"ip":       r.RemoteAddr,
+		}).Info("access")
+	})
+}
@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestAccessLogHeaderFields(t *testing.T) {
+	var hook *test.Hook
+	Logger, hook = test.NewNullLogger()
+	headerFields = map[string]string{"X-Request-Id": "request_id", "X-Tenant": "tenant"}
+	defer func() { headerFields = nil }()
+
+	var user string
+	handler := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user = r.Header.Get("X-User")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	r := httptest.NewRequest("GET", "/docs/", nil)
+	r.Header.Set("X-Request-ID", "abc\n123")
+	r.Header.Set("X-Tenant", strings.Repeat("t", 2*maxHeaderFieldLength))
+	r.Header.Set("X-User", "spoofed")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if user != "" {
+		t.Errorf("X-User from the client reached the handler: %q", user)
+	}
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("no access entry logged")
+	}
+	if got := entry.Data["request_id"]; got != "abc123" {
+		t.Errorf("request_id field %q, want %q", got, "abc123")
+	}
+	if got, _ := entry.Data["tenant"].(string); len(got) != maxHeaderFieldLength {
+		t.Errorf("tenant field has length %d, want %d", len(got), maxHeaderFieldLength)
+	}
+	if got := entry.Data["status"]; got != http.StatusCreated {
+		t.Errorf("status field %v, want %d", got, http.StatusCreated)
+	}
+}
+
+func TestAccessLogTLSFields(t *testing.T) {
+	var hook *test.Hook
+	Logger, hook = test.NewNullLogger()
+	accessTLS = true
+	defer func() { accessTLS = false }()
+	handler := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, secure := range []bool{true, false} {
+		hook.Reset()
+		server := httptest.NewUnstartedServer(handler)
+		if secure {
+			server.StartTLS()
+			// The test certificate is valid for example.com, sent as the SNI server name
+			server.Client().Transport.(*http.Transport).TLSClientConfig.ServerName = "example.com"
+		} else {
+			server.Start()
+		}
+		response, err := server.Client().Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		// Closing waits for the handler, which logs after the response is sent
+		server.Close()
+
+		entry := hook.LastEntry()
+		if entry == nil {
+			t.Fatal("no access entry logged")
+		}
+		want := map[string]any{"tls_version": nil, "tls_cipher": nil, "tls_server_name": nil}
+		if secure {
+			want = map[string]any{
+				"tls_version":     tls.VersionName(response.TLS.Version),
+				"tls_cipher":      tls.CipherSuiteName(response.TLS.CipherSuite),
+				"tls_server_name": "example.com",
+			}
+		}
+		for field, value := range want {
+			if got := entry.Data[field]; got != value {
+				t.Errorf("TLS %t: field %s = %v, want %v", secure, field, got, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Description: This file implements the Common and Combined Log Format access logs.
+package logger
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple_file_server/pkg"
+)
+
+// Access log formats
+const (
+	FormatJSON     = "json"
+	FormatText     = "text"
+	FormatCommon   = "common"
+	FormatCombined = "combined"
+)
+
+// clfTimeFormat - time layout of the %t field
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// clfField - returns the value, or "-" when it is empty
+func clfField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// clfQuote - escapes quotes, backslashes and control characters of a quoted field
+func clfQuote(value string) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// FormatCLF - formats a request as a Common Log Format line, with the referer and
+// user agent appended for the Combined Log Format. The user is taken from the
+// X-User header set by the authentication middleware.
+func FormatCLF(r *http.Request, status, size int, t time.Time, combined bool) string {
+	sizeField := "-"
+	if size > 0 {
+		sizeField = strconv.Itoa(size)
+	}
+	line := fmt.Sprintf("%s - %s [%s] \"%s\" %d %s",
+		clfField(pkg.ClientIP(r)),
+		clfField(strings.ReplaceAll(clfQuote(User(r.Header.Get("X-User"))), " ", "_")),
+		t.Format(clfTimeFormat),
+		clfQuote(r.Method+" "+r.RequestURI+" "+r.Proto),
+		status,
+		sizeField,
+	)
+	if combined {
+		line += fmt.Sprintf(" \"%s\" \"%s\"", clfField(clfQuote(r.Referer())), clfField(clfQuote(r.UserAgent())))
+	}
+	return line + "\n"
+}
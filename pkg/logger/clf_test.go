@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFormatCLF(t *testing.T) {
+	stamp := time.Date(2024, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600))
+	r := httptest.NewRequest("GET", "/docs/a%20b.txt?x=1", nil)
+	r.RemoteAddr = "203.0.113.9:4711"
+	r.Header.Set("X-User", "jane doe")
+	r.Header.Set("Referer", "http://example.com/")
+	r.Header.Set("User-Agent", "curl \"8\"\n")
+
+	tests := []struct {
+		name     string
+		size     int
+		combined bool
+		want     string
+	}{
+		{"common", 2326, false, `203.0.113.9 - jane_doe [10/Oct/2024:13:55:36 -0700] "GET /docs/a%20b.txt?x=1 HTTP/1.1" 200 2326` + "\n"},
+		{"empty body", 0, false, `203.0.113.9 - jane_doe [10/Oct/2024:13:55:36 -0700] "GET /docs/a%20b.txt?x=1 HTTP/1.1" 200 -` + "\n"},
+		{"combined", 10, true, `203.0.113.9 - jane_doe [10/Oct/2024:13:55:36 -0700] "GET /docs/a%20b.txt?x=1 HTTP/1.1" 200 10 "http://example.com/" "curl \"8\"\x0a"` + "\n"},
+	}
+	for _, tt := range tests {
+		if got := FormatCLF(r, 200, tt.size, stamp, tt.combined); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+
+	anonymous := httptest.NewRequest("GET", "/", nil)
+	anonymous.RemoteAddr = "203.0.113.9:4711"
+	if got, want := FormatCLF(anonymous, 404, 0, stamp, true), `203.0.113.9 - - [10/Oct/2024:13:55:36 -0700] "GET / HTTP/1.1" 404 - "-" "-"`+"\n"; got != want {
+		t.Errorf("anonymous:\n got %q\nwant %q", got, want)
+	}
+}
@@ -3,7 +3,9 @@ package logger
 
 import (
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
 	"simple_file_server/pkg"
 	"syscall"
 
@@ -73,6 +75,10 @@ func LogSetup(config pkg.Logging) {
 		default: notifyLevel = logrus.InfoLevel
 	}
 	Logger.SetFormatter(&logrus.JSONFormatter{})
+	headerFields = make(map[string]string, len(config.HeaderFields))
+	for header, field := range config.HeaderFields {
+		headerFields[http.CanonicalHeaderKey(header)] = field
+	}
 	Logger.SetLevel(notifyLevel)
 	Logger.Printf("Logger set minimum severity is '%s'", notifyLevel.String())
 	
@@ -81,6 +87,60 @@ func LogSetup(config pkg.Logging) {
 		Logger.Fatalf("Failed to open or create log file: %v", err)
 	}
 
+	setupRedaction(config)
+	setupAccessLog(config)
+
 	// Ensure correct permissions for rotated files
 	syscall.Umask(0022)
 }
+
+// setupAccessLog - selects the access log format and opens the separate access log
+// file. JSON entries go to the operational log unless access_log_file is set; the
+// other formats always use a separate file (access.log next to log_file by default).
+func setupAccessLog(config pkg.Logging) {
+	accessFormat = config.AccessFormat
+	if accessFormat == "" {
+		accessFormat = FormatJSON
+	}
+	accessLogger = nil
+	accessWriter = nil
+	accessTLS = config.AccessLogTLS
+
+	switch accessFormat {
+	case FormatJSON, FormatText, FormatCommon, FormatCombined:
+	default:
+		Logger.Fatalf("Unknown access log format: %s (expected json, text, common or combined)", accessFormat)
+	}
+
+	file := config.AccessLogFile
+	if file == "" {
+		if accessFormat == FormatJSON {
+			return
+		}
+		file = filepath.Join(filepath.Dir(config.LogFile), "access.log")
+	}
+	if err := checkFilePermissions(file); err != nil {
+		Logger.Fatalf("Access log file permissions check failed: %v", err)
+	}
+	writer := &lumberjack.Logger{
+		Filename:   file,
+		MaxSize:    config.LogMaxSize,
+		MaxBackups: config.LogMaxFiles,
+		MaxAge:     config.LogMaxAge,
+		Compress:   true,
+	}
+
+	switch accessFormat {
+	case FormatCommon, FormatCombined:
+		accessWriter = writer
+	default:
+		accessLogger = logrus.New()
+		accessLogger.SetOutput(writer)
+		if accessFormat == FormatText {
+			accessLogger.SetFormatter(&logrus.TextFormatter{DisableColors: true, FullTimestamp: true})
+		} else {
+			accessLogger.SetFormatter(&logrus.JSONFormatter{})
+		}
+	}
+	Logger.Printf("Access log (%s format) written to %s", accessFormat, file)
+}
@@ -0,0 +1,44 @@
+// Description: This file contains the redaction of usernames in the logs.
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"simple_file_server/pkg"
+)
+
+// redactKey - HMAC key of the pseudonyms; nil when usernames are logged as they are
+var redactKey []byte
+
+// setupRedaction - enables the redaction of usernames with the configured key, or a
+// random one when it is empty
+func setupRedaction(config pkg.Logging) {
+	redactKey = nil
+	if !config.RedactUsernames {
+		return
+	}
+	if config.RedactKey != "" {
+		redactKey = []byte(config.RedactKey)
+		return
+	}
+	redactKey = make([]byte, 32)
+	if _, err := rand.Read(redactKey); err != nil {
+		Logger.Fatalf("Failed to generate the username redaction key: %v", err)
+	}
+	Logger.Warnf("logging.redact_key is not set: redacted usernames change on every restart")
+}
+
+// User - returns the username to log: with redact_usernames a pseudonym derived from
+// it with HMAC-SHA256, so the entries of a user can be correlated without revealing
+// the name. An empty username (anonymous) stays empty.
+func User(username string) string {
+	if redactKey == nil || username == "" {
+		return username
+	}
+	mac := hmac.New(sha256.New, redactKey)
+	mac.Write([]byte(username))
+	return "user-" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
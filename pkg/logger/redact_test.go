@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+
+	"simple_file_server/pkg"
+)
+
+func TestUser(t *testing.T) {
+	t.Cleanup(func() { setupRedaction(pkg.Logging{}) })
+
+	setupRedaction(pkg.Logging{})
+	if got := User("alice"); got != "alice" {
+		t.Errorf("User without redaction = %q, want alice", got)
+	}
+
+	setupRedaction(pkg.Logging{RedactUsernames: true, RedactKey: "key"})
+	alice := User("alice")
+	if !strings.HasPrefix(alice, "user-") || strings.Contains(alice, "alice") {
+		t.Errorf("User(alice) = %q, want a pseudonym", alice)
+	}
+	if User("alice") != alice || User("bob") == alice {
+		t.Error("pseudonyms are not stable per user")
+	}
+	if got := User(""); got != "" {
+		t.Errorf("anonymous user = %q, want empty", got)
+	}
+
+	setupRedaction(pkg.Logging{RedactUsernames: true, RedactKey: "other"})
+	if User("alice") == alice {
+		t.Error("the pseudonym does not depend on the key")
+	}
+}
@@ -0,0 +1,33 @@
+// Description: This file contains the content types of audio and video files streamed to browsers.
+package pkg
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// mediaTypes - content types of the audio and video extensions, independent of the
+// mime.types files of the host, which often lack or mislabel them
+var mediaTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".webm": "video/webm",
+	".ogv":  "video/ogg",
+	".mov":  "video/quicktime",
+	".mkv":  "video/x-matroska",
+	".avi":  "video/x-msvideo",
+}
+
+// MediaType - returns the content type of an audio or video file by its extension
+func MediaType(name string) (string, bool) {
+	contentType, ok := mediaTypes[strings.ToLower(filepath.Ext(name))]
+	return contentType, ok
+}
@@ -0,0 +1,21 @@
+package pkg
+
+import "testing"
+
+func TestMediaType(t *testing.T) {
+	tests := []struct {
+		name, want string
+		ok         bool
+	}{
+		{"movie.mp4", "video/mp4", true},
+		{"Clip.WEBM", "video/webm", true},
+		{"song.mp3", "audio/mpeg", true},
+		{"notes.txt", "", false},
+		{"mp4", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := MediaType(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("MediaType(%q) = %q, %t, want %q, %t", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
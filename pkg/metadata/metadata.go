@@ -0,0 +1,117 @@
+// Description: This file implements the metadata package, which reads sidecar metadata files of directory entries.
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// maxSidecarSize - largest sidecar file parsed
+const maxSidecarSize = 64 << 10
+
+// Load - finds the sidecar files (entry name + suffix, e.g. "photo.jpg.meta.json") among
+// the entries of dir and parses them as JSON, or as YAML for .yaml/.yml suffixes.
+// It returns the metadata keyed by entry name and the entries without the sidecars.
+// Sidecars that cannot be parsed are reported through warn and left in the listing.
+func Load(dir string, files []os.DirEntry, suffix string, warn func(format string, args ...interface{})) (map[string]map[string]string, []os.DirEntry) {
+	names := make(map[string]bool, len(files))
+	for _, file := range files {
+		names[file.Name()] = true
+	}
+
+	result := make(map[string]map[string]string)
+	remaining := make([]os.DirEntry, 0, len(files))
+	for _, file := range files {
+		name := file.Name()
+		owner := strings.TrimSuffix(name, suffix)
+		if file.IsDir() || owner == name || owner == "" || !names[owner] {
+			remaining = append(remaining, file)
+			continue
+		}
+		values, err := parse(filepath.Join(dir, name), suffix)
+		if err != nil {
+			warn("Error reading metadata %s: %v", filepath.Join(dir, name), err)
+			remaining = append(remaining, file)
+			continue
+		}
+		result[owner] = values
+	}
+	return result, remaining
+}
+
+// parse - reads a sidecar file into flat string values
+func parse(name, suffix string) (map[string]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxSidecarSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSidecarSize {
+		return nil, fmt.Errorf("sidecar exceeds %d bytes", maxSidecarSize)
+	}
+
+	raw := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(suffix)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		err = json.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		values[key] = format(value)
+	}
+	return values, nil
+}
+
+// format - renders a metadata value as text; nested values are shown as JSON
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[interface{}]interface{}, map[string]interface{}, []interface{}:
+		if data, err := json.Marshal(normalize(v)); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// normalize - converts YAML maps with interface keys into JSON encodable maps
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalize(item)
+		}
+		return m
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	}
+	return value
+}
@@ -0,0 +1,57 @@
+// Description: This file implements the metrics package, which keeps runtime counters of the server.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	activeDownloads int64
+	totalDownloads  int64
+	startTime       = time.Now()
+)
+
+// DownloadStarted - marks the start of a download and returns the function marking its end.
+// The returned function must be deferred so the counter drops even if the client disconnects.
+func DownloadStarted() func() {
+	atomic.AddInt64(&activeDownloads, 1)
+	atomic.AddInt64(&totalDownloads, 1)
+	var done int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&done, 0, 1) {
+			atomic.AddInt64(&activeDownloads, -1)
+		}
+	}
+}
+
+// ActiveDownloads - returns the number of downloads in flight
+func ActiveDownloads() int64 {
+	return atomic.LoadInt64(&activeDownloads)
+}
+
+// TotalDownloads - returns the number of downloads started since the server started
+func TotalDownloads() int64 {
+	return atomic.LoadInt64(&totalDownloads)
+}
+
+// Uptime - returns the time elapsed since the server started
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
+
+// Handler - exposes the counters in the Prometheus text format
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP sfs_active_downloads Number of downloads in flight.")
+	fmt.Fprintln(w, "# TYPE sfs_active_downloads gauge")
+	fmt.Fprintf(w, "sfs_active_downloads %d\n", ActiveDownloads())
+	fmt.Fprintln(w, "# HELP sfs_downloads_total Number of downloads started.")
+	fmt.Fprintln(w, "# TYPE sfs_downloads_total counter")
+	fmt.Fprintf(w, "sfs_downloads_total %d\n", TotalDownloads())
+	fmt.Fprintln(w, "# HELP sfs_uptime_seconds Time since the server started.")
+	fmt.Fprintln(w, "# TYPE sfs_uptime_seconds gauge")
+	fmt.Fprintf(w, "sfs_uptime_seconds %.0f\n", Uptime().Seconds())
+}
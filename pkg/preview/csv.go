@@ -0,0 +1,91 @@
+// Description: This file implements the preview package, which renders previews of data files.
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// candidateDelimiters - delimiters considered by DetectDelimiter, in order of preference
+var candidateDelimiters = []rune{',', '\t', ';', '|'}
+
+// Table - represents the parsed rows of a CSV/TSV file
+type Table struct {
+	Header    []string
+	Rows      [][]string
+	Truncated bool
+}
+
+// IsTabular - checks whether the file can be previewed as a table
+func IsTabular(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".tsv":
+		return true
+	}
+	return false
+}
+
+// DetectDelimiter - picks the delimiter for the file. TSV files always use tabs;
+// otherwise the candidate occurring most often in the first line of the sample wins.
+func DetectDelimiter(name string, sample []byte) rune {
+	if strings.ToLower(filepath.Ext(name)) == ".tsv" {
+		return '\t'
+	}
+	line := sample
+	if i := bytes.IndexByte(sample, '\n'); i >= 0 {
+		line = sample[:i]
+	}
+	best, bestCount := ',', 0
+	for _, delimiter := range candidateDelimiters {
+		if count := bytes.Count(line, []byte(string(delimiter))); count > bestCount {
+			best, bestCount = delimiter, count
+		}
+	}
+	return best
+}
+
+// ParseTable - reads at most maxRows data rows after the header. The delimiter is
+// detected from the content when it is zero.
+func ParseTable(name string, r io.Reader, delimiter rune, maxRows int) (*Table, error) {
+	reader := bufio.NewReader(r)
+	if delimiter == 0 {
+		sample, _ := reader.Peek(4096)
+		delimiter = DetectDelimiter(name, sample)
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = delimiter
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+
+	table := &Table{}
+	header, err := csvReader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return table, nil
+		}
+		return nil, err
+	}
+	table.Header = header
+
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(table.Rows) >= maxRows {
+			table.Truncated = true
+			break
+		}
+		table.Rows = append(table.Rows, record)
+	}
+	return table, nil
+}
@@ -0,0 +1,105 @@
+// Description: This file implements reading text files and computing unified diffs between them.
+package preview
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+var (
+	// ErrNotText - the file is binary or in an encoding that cannot be decoded
+	ErrNotText = errors.New("file is not text")
+	// ErrTooLarge - the file exceeds the size limit
+	ErrTooLarge = errors.New("file is too large")
+)
+
+// DiffLine - represents a line of a unified diff with its kind for rendering:
+// "header", "hunk", "add", "del" or "context"
+type DiffLine struct {
+	Kind string
+	Text string
+}
+
+// ReadText - reads a regular file of at most maxSize bytes as text, decoded to UTF-8 by DecodeText
+func ReadText(name string, maxSize int64) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", ErrNotText
+	}
+	if info.Size() > maxSize {
+		return "", ErrTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > maxSize {
+		return "", ErrTooLarge
+	}
+	return DecodeText(data)
+}
+
+// UnifiedDiff - returns the unified diff between the texts with the given number of context lines.
+// The result is empty when the texts are equal.
+func UnifiedDiff(a, b, nameA, nameB string, context int) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(a),
+		B:        splitLines(b),
+		FromFile: nameA,
+		ToFile:   nameB,
+		Context:  context,
+	})
+}
+
+// splitLines - splits the text into lines keeping their line endings. A missing final
+// newline is added so that the last line diffs like the others.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// DiffLines - splits a unified diff into classified lines
+func DiffLines(diff string) []DiffLine {
+	var lines []DiffLine
+	if diff == "" {
+		return lines
+	}
+	inHunk := false
+	for _, text := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		kind := "context"
+		switch {
+		case strings.HasPrefix(text, "@@"):
+			kind = "hunk"
+			inHunk = true
+		case !inHunk:
+			// File names precede the first hunk
+			kind = "header"
+		case strings.HasPrefix(text, "+"):
+			kind = "add"
+		case strings.HasPrefix(text, "-"):
+			kind = "del"
+		}
+		lines = append(lines, DiffLine{Kind: kind, Text: text})
+	}
+	return lines
+}
@@ -0,0 +1,93 @@
+// Description: This file implements the detection and transcoding of text encodings for previews.
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Fallback encodings for text that has no BOM and is not valid UTF-8
+const (
+	// EncodingNone - such text is rejected as not being text
+	EncodingNone = "none"
+	// EncodingLatin1 - such text is decoded as ISO-8859-1
+	EncodingLatin1 = "latin1"
+	// EncodingWindows1252 - such text is decoded as Windows-1252
+	EncodingWindows1252 = "windows-1252"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// fallback - decoder of text that has no BOM and is not valid UTF-8, nil to reject it
+var fallback encoding.Encoding = charmap.ISO8859_1
+
+// ValidFallbackEncoding - checks the configured fallback encoding
+func ValidFallbackEncoding(name string) error {
+	switch name {
+	case EncodingNone, EncodingLatin1, EncodingWindows1252:
+		return nil
+	}
+	return fmt.Errorf("unknown fallback encoding %q (expected none, latin1 or windows-1252)", name)
+}
+
+// SetFallbackEncoding - selects how text without a BOM that is not valid UTF-8 is decoded
+func SetFallbackEncoding(name string) error {
+	if err := ValidFallbackEncoding(name); err != nil {
+		return err
+	}
+	switch name {
+	case EncodingNone:
+		fallback = nil
+	case EncodingLatin1:
+		fallback = charmap.ISO8859_1
+	case EncodingWindows1252:
+		fallback = charmap.Windows1252
+	}
+	return nil
+}
+
+// DecodeText - converts the content of a text file to UTF-8. A UTF-8 BOM is stripped and
+// UTF-16 (LE or BE) with a BOM is transcoded. Content without a BOM is returned as is when
+// it is valid UTF-8 and decoded with the fallback encoding otherwise. Content containing
+// NUL bytes without a UTF-16 BOM is binary and returns ErrNotText.
+func DecodeText(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		data = data[len(bomUTF8):]
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), data)
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), data)
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", ErrNotText
+	}
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	if fallback == nil {
+		return "", ErrNotText
+	}
+	return decodeWith(fallback, data)
+}
+
+// decodeWith - decodes the data with the encoding, failing with ErrNotText
+func decodeWith(enc encoding.Encoding, data []byte) (string, error) {
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", ErrNotText
+	}
+	if bytes.IndexByte(decoded, 0) >= 0 {
+		return "", ErrNotText
+	}
+	return string(decoded), nil
+}
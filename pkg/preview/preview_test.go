@@ -0,0 +1,155 @@
+package preview
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsTabular(t *testing.T) {
+	tests := map[string]bool{
+		"data.csv":     true,
+		"DATA.TSV":     true,
+		"notes.txt":    false,
+		"csv":          false,
+		"table.csv.gz": false,
+	}
+	for name, want := range tests {
+		if got := IsTabular(name); got != want {
+			t.Errorf("IsTabular(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		want   rune
+	}{
+		{"a.csv", "a,b,c\n1,2,3\n", ','},
+		{"a.csv", "a;b;c\n1,2;3\n", ';'},
+		{"a.csv", "a|b|c", '|'},
+		{"a.csv", "a\tb\n", '\t'},
+		{"a.csv", "single\n", ','},
+		{"a.tsv", "a,b,c\n", '\t'},
+	}
+	for _, tt := range tests {
+		if got := DetectDelimiter(tt.name, []byte(tt.sample)); got != tt.want {
+			t.Errorf("DetectDelimiter(%q, %q) = %q, want %q", tt.name, tt.sample, got, tt.want)
+		}
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	input := "name;note\n\"Smith; J\";\"said \"\"hi\"\"\"\nshort\nc;d\n"
+	table, err := ParseTable("people.csv", strings.NewReader(input), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"name", "note"}; !reflect.DeepEqual(table.Header, want) {
+		t.Errorf("header = %q, want %q", table.Header, want)
+	}
+	want := [][]string{{"Smith; J", `said "hi"`}, {"short"}, {"c", "d"}}
+	if !reflect.DeepEqual(table.Rows, want) || table.Truncated {
+		t.Errorf("rows = %q (truncated %v), want %q", table.Rows, table.Truncated, want)
+	}
+
+	table, err = ParseTable("people.csv", strings.NewReader(input), ';', 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.Rows) != 2 || !table.Truncated {
+		t.Errorf("maxRows 2: %d rows, truncated %v", len(table.Rows), table.Truncated)
+	}
+
+	table, err = ParseTable("empty.csv", strings.NewReader(""), 0, 10)
+	if err != nil || table.Header != nil || table.Rows != nil {
+		t.Errorf("empty file: %+v, %v", table, err)
+	}
+}
+
+func TestReadText(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"plain.txt":   []byte("hello\n"),
+		"bom.txt":     append([]byte{0xEF, 0xBB, 0xBF}, "hello\n"...),
+		"utf16.txt":   {0xFF, 0xFE, 'h', 0, 'i', 0},
+		"utf16be.txt": {0xFE, 0xFF, 0, 'h', 0, 'i'},
+		"binary.bin":  {'a', 0, 'b'},
+		"large.txt":   []byte(strings.Repeat("x", 64)),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{"plain.txt", "hello\n", nil},
+		{"bom.txt", "hello\n", nil},
+		{"utf16.txt", "hi", nil},
+		{"utf16be.txt", "hi", nil},
+		{"binary.bin", "", ErrNotText},
+		{"large.txt", "", ErrTooLarge},
+		{".", "", ErrNotText},
+	}
+	for _, tt := range tests {
+		got, err := ReadText(filepath.Join(dir, tt.name), 32)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("ReadText(%q) = %q, %v, want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFallbackEncoding(t *testing.T) {
+	t.Cleanup(func() { SetFallbackEncoding(EncodingLatin1) })
+	latin1 := []byte("caf\xe9")
+
+	if err := SetFallbackEncoding(EncodingLatin1); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := DecodeText(latin1); err != nil || got != "café" {
+		t.Errorf("latin1: %q, %v", got, err)
+	}
+	if err := SetFallbackEncoding(EncodingNone); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeText(latin1); !errors.Is(err, ErrNotText) {
+		t.Errorf("none: error = %v, want %v", err, ErrNotText)
+	}
+	if err := SetFallbackEncoding("utf-32"); err == nil {
+		t.Error("unknown fallback encoding accepted")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	if diff, err := UnifiedDiff("same\n", "same\n", "a", "b", 3); err != nil || diff != "" {
+		t.Errorf("equal texts: %q, %v", diff, err)
+	}
+
+	diff, err := UnifiedDiff("one\ntwo\nthree", "one\n2\nthree\n", "a.txt", "b.txt", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- a.txt\n+++ b.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n"
+	if diff != want {
+		t.Fatalf("diff = %q, want %q", diff, want)
+	}
+
+	var kinds []string
+	for _, line := range DiffLines(diff) {
+		kinds = append(kinds, line.Kind)
+	}
+	wantKinds := []string{"header", "header", "hunk", "context", "del", "add", "context"}
+	if !reflect.DeepEqual(kinds, wantKinds) {
+		t.Errorf("kinds = %v, want %v", kinds, wantKinds)
+	}
+}
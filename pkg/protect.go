@@ -0,0 +1,39 @@
+// Description: This file contains the check protecting recently modified files from deletion.
+package pkg
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RecentlyModified - returns up to limit files at or below root modified after since,
+// so that a deletion can be refused before anything is removed. Directories themselves
+// are not checked, only the files they contain.
+func RecentlyModified(root string, since time.Time, limit int) ([]string, error) {
+	var found []string
+	err := filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if info.ModTime().After(since) {
+			found = append(found, p)
+			if limit > 0 && len(found) >= limit {
+				return filepath.SkipAll
+			}
+		}
+		return nil
+	})
+	return found, err
+}
@@ -0,0 +1,109 @@
+// Description: This file implements the quota package, which limits the bytes a user may upload per time window.
+package quota
+
+import (
+	"sync"
+	"time"
+
+	"simple_file_server/pkg"
+)
+
+// defaultWindow - accounting window when the configuration leaves it unset
+const defaultWindow = 24 * time.Hour
+
+// usage - bytes uploaded by a user in the current window
+type usage struct {
+	start time.Time
+	used  int64
+}
+
+var (
+	mu     sync.Mutex
+	limits map[string]int64
+	limit  int64
+	window time.Duration
+	usages = make(map[string]*usage)
+)
+
+// Setup - applies the quota configuration; sizes are given in megabytes
+func Setup(config pkg.Quota) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	limit = int64(config.MaxSize) << 20
+	limits = make(map[string]int64, len(config.Users))
+	for user, size := range config.Users {
+		limits[user] = int64(size) << 20
+	}
+	window = config.Window
+	if window <= 0 {
+		window = defaultWindow
+	}
+	usages = make(map[string]*usage)
+}
+
+// limitOf - returns the quota of the user in bytes (0 = unlimited); the caller must hold mu
+func limitOf(user string) int64 {
+	if userLimit, ok := limits[user]; ok {
+		return userLimit
+	}
+	return limit
+}
+
+// current - returns the usage of the user, starting a new window when the previous
+// one has ended; the caller must hold mu
+func current(user string, now time.Time) *usage {
+	u, ok := usages[user]
+	if !ok || now.Sub(u.start) >= window {
+		u = &usage{start: now}
+		usages[user] = u
+	}
+	return u
+}
+
+// Reserve - books size bytes for the user if they fit in the remaining quota.
+// It returns false, along with the bytes still available, when they do not.
+func Reserve(user string, size int64) (bool, int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	userLimit := limitOf(user)
+	if userLimit <= 0 {
+		return true, 0
+	}
+	u := current(user, time.Now())
+	remaining := userLimit - u.used
+	if size > remaining {
+		if remaining < 0 {
+			remaining = 0
+		}
+		return false, remaining
+	}
+	u.used += size
+	return true, remaining - size
+}
+
+// Refund - returns reserved bytes that were not written, e.g. of a failed file
+func Refund(user string, size int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if u, ok := usages[user]; ok && limitOf(user) > 0 {
+		u.used -= size
+		if u.used < 0 {
+			u.used = 0
+		}
+	}
+}
+
+// Usage - returns the bytes uploaded by the user in the current window and the quota (0 = unlimited)
+func Usage(user string) (int64, int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	userLimit := limitOf(user)
+	if userLimit <= 0 {
+		return 0, 0
+	}
+	return current(user, time.Now()).used, userLimit
+}
@@ -0,0 +1,123 @@
+// Description: This file implements the recent package, which keeps a bounded feed of recent uploads.
+package recent
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+// defaultSize - number of events kept when the configuration leaves it unset
+const defaultSize = 50
+
+// Event - represents a single upload in the feed
+type Event struct {
+	Path string    `json:"path"`
+	User string    `json:"user"`
+	Size int64     `json:"size"`
+	Time time.Time `json:"time"`
+}
+
+var (
+	mu     sync.Mutex
+	events []Event // ring buffer, oldest first once full
+	next   int
+	size   int
+	file   string
+)
+
+// Setup - configures the feed size and loads persisted events if a file is configured
+func Setup(config pkg.Recent) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	size = config.Size
+	if size <= 0 {
+		size = defaultSize
+	}
+	file = config.File
+	events = make([]Event, 0, size)
+	next = 0
+
+	if file == "" {
+		return
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Logger.Warnf("Error reading recent uploads file: %v", err)
+		}
+		return
+	}
+	var stored []Event
+	if err := json.Unmarshal(data, &stored); err != nil {
+		logger.Logger.Warnf("Error parsing recent uploads file: %v", err)
+		return
+	}
+	for _, event := range stored {
+		add(event)
+	}
+}
+
+// Add - records an upload, evicting the oldest event when the feed is full
+func Add(event Event) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	add(event)
+	if file != "" {
+		persist()
+	}
+}
+
+// add - appends to the ring buffer; the caller must hold mu
+func add(event Event) {
+	if size == 0 {
+		return
+	}
+	if len(events) < size {
+		events = append(events, event)
+		return
+	}
+	events[next] = event
+	next = (next + 1) % size
+}
+
+// ordered - returns the events oldest first; the caller must hold mu
+func ordered() []Event {
+	result := make([]Event, 0, len(events))
+	result = append(result, events[next:]...)
+	return append(result, events[:next]...)
+}
+
+// persist - writes the feed to the configured file; the caller must hold mu
+func persist() {
+	data, err := json.Marshal(ordered())
+	if err != nil {
+		logger.Logger.Warnf("Error encoding recent uploads: %v", err)
+		return
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		logger.Logger.Warnf("Error writing recent uploads file: %v", err)
+	}
+}
+
+// Last - returns up to n most recent events, newest first. n <= 0 returns all events.
+func Last(n int) []Event {
+	mu.Lock()
+	defer mu.Unlock()
+
+	all := ordered()
+	if n <= 0 || n > len(all) {
+		n = len(all)
+	}
+	result := make([]Event, 0, n)
+	for i := len(all) - 1; i >= len(all)-n; i-- {
+		result = append(result, all[i])
+	}
+	return result
+}
@@ -0,0 +1,158 @@
+// Description: This file contains the resolver checking destinations against the symlink policy.
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Symlink policies for write destinations
+const (
+	// SymlinkContain - symlinks are followed only when they resolve inside the base directory
+	SymlinkContain = "contain"
+	// SymlinkFollow - symlinks are followed wherever they point
+	SymlinkFollow = "follow"
+	// SymlinkDeny - destinations must not pass through any symlink
+	SymlinkDeny = "deny"
+)
+
+var (
+	// ErrSymlinkEscape - the destination resolves outside the base directory
+	ErrSymlinkEscape = errors.New("path escapes the base directory through a symlink")
+	// ErrSymlinkDenied - the destination passes through a symlink while symlinks are denied
+	ErrSymlinkDenied = errors.New("path passes through a symlink")
+)
+
+// ValidSymlinkPolicy - checks the configured symlink policy
+func ValidSymlinkPolicy(policy string) error {
+	switch policy {
+	case SymlinkContain, SymlinkFollow, SymlinkDeny:
+		return nil
+	}
+	return fmt.Errorf("unknown symlink policy %q (expected contain, follow or deny)", policy)
+}
+
+// within - reports whether p is base or below it
+func within(base, p string) bool {
+	return p == base || strings.HasPrefix(p, base+string(filepath.Separator))
+}
+
+// linkWithin - reports whether a symlink created at link with the target would resolve
+// inside realBase. For a target that does not exist (yet), e.g. a sibling still to be
+// copied, its nearest existing parent is resolved.
+func linkWithin(realBase, link, target string) bool {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(link), target)
+	}
+	current, rest := filepath.Clean(target), ""
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			return within(realBase, filepath.Join(resolved, rest))
+		}
+		parent := filepath.Dir(current)
+		if !os.IsNotExist(err) || parent == current {
+			return false
+		}
+		rest = filepath.Join(filepath.Base(current), rest)
+		current = parent
+	}
+}
+
+// SafeDestination - joins the slash separated rel to base and checks every existing
+// component of the result against the symlink policy, including the final one, which
+// a write would otherwise follow. Components that do not exist yet are accepted.
+func SafeDestination(base, rel, policy string) (string, error) {
+	full := filepath.Join(base, filepath.FromSlash(path.Clean("/"+rel)))
+	if policy == SymlinkFollow {
+		return full, nil
+	}
+	realBase, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return "", err
+	}
+
+	relPath, err := filepath.Rel(base, full)
+	if err != nil || relPath == "." {
+		return full, err
+	}
+	current := base
+	for _, part := range strings.Split(relPath, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if policy == SymlinkDeny {
+			return "", ErrSymlinkDenied
+		}
+		resolved, err := filepath.EvalSymlinks(current)
+		if err != nil {
+			// A dangling link would be created wherever it points
+			return "", ErrSymlinkEscape
+		}
+		if !within(realBase, resolved) {
+			return "", ErrSymlinkEscape
+		}
+	}
+	return full, nil
+}
+
+// ErrPathTraversal - the path leaves the base directory through ".." segments
+var ErrPathTraversal = errors.New("path escapes the base directory")
+
+// ResolvePath - returns the location of the user-supplied slash separated path below
+// base. Paths containing ".." segments or NUL bytes are rejected with ErrPathTraversal
+// instead of being cleaned, so that attempts can be logged; absolute paths are taken
+// from base. When the path, or for a path that does not exist yet its nearest existing
+// parent, resolves through a symlink to a location outside base, ErrSymlinkEscape is
+// returned with the location.
+func ResolvePath(base, reqPath string) (string, error) {
+	if strings.ContainsRune(reqPath, 0) {
+		return "", ErrPathTraversal
+	}
+	for _, segment := range strings.Split(reqPath, "/") {
+		if segment == ".." {
+			return "", ErrPathTraversal
+		}
+	}
+	full := filepath.Join(base, filepath.FromSlash(path.Clean("/"+reqPath)))
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	absFull, err := filepath.Abs(full)
+	if err != nil {
+		return "", err
+	}
+	if !within(absBase, absFull) {
+		return "", ErrPathTraversal
+	}
+
+	realBase, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return "", err
+	}
+	for current := absFull; ; current = filepath.Dir(current) {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			if !within(realBase, resolved) {
+				return full, ErrSymlinkEscape
+			}
+			return full, nil
+		}
+		if !os.IsNotExist(err) || !within(absBase, current) || current == absBase {
+			return full, nil
+		}
+	}
+}
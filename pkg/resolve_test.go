@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// symlinkTree - creates a base directory holding a file, a directory, symlinks to both
+// and symlinks to a file and a directory outside of it. It returns the base.
+func symlinkTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	base := filepath.Join(root, "base")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{filepath.Join(base, "dir"), outside} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(base, "dir", "file.txt"), filepath.Join(outside, "secret.txt")} {
+		if err := os.WriteFile(file, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"link-dir":     "dir",
+		"link-file":    filepath.Join("dir", "file.txt"),
+		"escape-dir":   outside,
+		"escape-file":  filepath.Join(outside, "secret.txt"),
+		"dangling":     filepath.Join(root, "missing"),
+		"dir/up-inner": "..",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(base, filepath.FromSlash(name))); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+	}
+	return base
+}
+
+func TestResolvePath(t *testing.T) {
+	base := symlinkTree(t)
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr error
+	}{
+		{"dir/file.txt", "dir/file.txt", nil},
+		{"/dir/file.txt", "dir/file.txt", nil},
+		{"", "", nil},
+		{"dir/new/deeper.txt", "dir/new/deeper.txt", nil},
+		{"link-dir/file.txt", "link-dir/file.txt", nil},
+		{"link-file", "link-file", nil},
+		{"dir/up-inner/dir", "dir/up-inner/dir", nil},
+		{"../base/dir", "", ErrPathTraversal},
+		{"dir/../../outside", "", ErrPathTraversal},
+		{"dir/file.txt\x00.png", "", ErrPathTraversal},
+		{"escape-file", "escape-file", ErrSymlinkEscape},
+		{"escape-dir", "escape-dir", ErrSymlinkEscape},
+		{"escape-dir/secret.txt", "escape-dir/secret.txt", ErrSymlinkEscape},
+		{"escape-dir/new/file.txt", "escape-dir/new/file.txt", ErrSymlinkEscape},
+	}
+	for _, tt := range tests {
+		full, err := ResolvePath(base, tt.path)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("ResolvePath(%q) error = %v, want %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr == ErrPathTraversal {
+			continue
+		}
+		if want := filepath.Join(base, filepath.FromSlash(tt.want)); full != want {
+			t.Errorf("ResolvePath(%q) = %q, want %q", tt.path, full, want)
+		}
+	}
+}
+
+func TestSafeDestination(t *testing.T) {
+	base := symlinkTree(t)
+
+	tests := []struct {
+		rel    string
+		policy string
+		want   error
+	}{
+		{"dir/new.txt", SymlinkContain, nil},
+		{"dir/file.txt", SymlinkDeny, nil},
+		{"missing/a/b.txt", SymlinkDeny, nil},
+		{"link-dir/new.txt", SymlinkContain, nil},
+		{"link-dir/new.txt", SymlinkDeny, ErrSymlinkDenied},
+		{"link-file", SymlinkContain, nil},
+		{"link-file", SymlinkDeny, ErrSymlinkDenied},
+		{"escape-dir/new.txt", SymlinkContain, ErrSymlinkEscape},
+		{"escape-file", SymlinkContain, ErrSymlinkEscape},
+		{"dangling", SymlinkContain, ErrSymlinkEscape},
+		{"escape-dir/new.txt", SymlinkDeny, ErrSymlinkDenied},
+		{"escape-dir/new.txt", SymlinkFollow, nil},
+		{"dangling", SymlinkFollow, nil},
+		{"../outside/secret.txt", SymlinkContain, nil},
+	}
+	for _, tt := range tests {
+		full, err := SafeDestination(base, tt.rel, tt.policy)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("SafeDestination(%q, %s) error = %v, want %v", tt.rel, tt.policy, err, tt.want)
+			continue
+		}
+		if err == nil && !within(base, full) {
+			t.Errorf("SafeDestination(%q, %s) = %q, outside the base", tt.rel, tt.policy, full)
+		}
+	}
+}
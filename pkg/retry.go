@@ -0,0 +1,83 @@
+// Description: This file contains the retry with backoff of transient filesystem errors.
+package pkg
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// maxRetryDelay - upper bound of the delay between two attempts
+const maxRetryDelay = 2 * time.Second
+
+var (
+	retryAttempts = 1
+	retryDelay    time.Duration
+)
+
+// SetRetry - configures the number of attempts of filesystem operations and the delay
+// before the first retry, which doubles with every further attempt
+func SetRetry(attempts int, delay time.Duration) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	retryAttempts = attempts
+	retryDelay = delay
+}
+
+// IsTransient - reports whether the error is a transient filesystem error worth retrying,
+// as returned by network filesystems (NFS, SMB) under load or after a failover
+func IsTransient(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.ESTALE) ||
+		errors.Is(err, syscall.EINTR)
+}
+
+// withRetry - runs op until it succeeds, fails with a permanent error or the attempts
+// are exhausted, waiting with exponential backoff between attempts
+func withRetry(op func() error) error {
+	delay := retryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = op()
+		if err == nil || !IsTransient(err) || attempt >= retryAttempts {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// Stat - os.Stat retrying transient errors
+func Stat(name string) (os.FileInfo, error) {
+	var info os.FileInfo
+	err := withRetry(func() (err error) {
+		info, err = os.Stat(name)
+		return err
+	})
+	return info, err
+}
+
+// ReadDir - os.ReadDir retrying transient errors
+func ReadDir(name string) ([]os.DirEntry, error) {
+	var entries []os.DirEntry
+	err := withRetry(func() (err error) {
+		entries, err = os.ReadDir(name)
+		return err
+	})
+	return entries, err
+}
+
+// Open - os.Open retrying transient errors
+func Open(name string) (*os.File, error) {
+	var file *os.File
+	err := withRetry(func() (err error) {
+		file, err = os.Open(name)
+		return err
+	})
+	return file, err
+}
@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestWithRetry(t *testing.T) {
+	t.Cleanup(func() { SetRetry(1, 0) })
+	SetRetry(3, 0)
+
+	tests := []struct {
+		name     string
+		errs     []error
+		want     error
+		attempts int
+	}{
+		{"success", []error{nil}, nil, 1},
+		{"transient then success", []error{syscall.ESTALE, &os.PathError{Op: "stat", Err: syscall.EAGAIN}, nil}, nil, 3},
+		{"permanent", []error{os.ErrNotExist}, os.ErrNotExist, 1},
+		{"exhausted", []error{syscall.EINTR, syscall.EINTR, syscall.EINTR, nil}, syscall.EINTR, 3},
+	}
+	for _, tt := range tests {
+		attempts := 0
+		err := withRetry(func() error {
+			err := tt.errs[attempts]
+			attempts++
+			return err
+		})
+		if !errors.Is(err, tt.want) || attempts != tt.attempts {
+			t.Errorf("%s: %v after %d attempts, want %v after %d", tt.name, err, attempts, tt.want, tt.attempts)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := map[error]bool{
+		syscall.EAGAIN:                         true,
+		fmt.Errorf("open: %w", syscall.ESTALE): true,
+		syscall.ENOENT:                         false,
+		os.ErrPermission:                       false,
+	}
+	for err, want := range tests {
+		if got := IsTransient(err); got != want {
+			t.Errorf("IsTransient(%v) = %t, want %t", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Description: This file implements the rewrite package, which maps URL paths to other paths below the base directory.
+package rewrite
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"simple_file_server/pkg"
+)
+
+// Resolvers picking the subdirectory of the target a rule points to
+const (
+	// ResolveNone - the target itself
+	ResolveNone = ""
+	// ResolveLatest - the subdirectory with the greatest name, e.g. the newest of 2024-05-01, 2024-06-01
+	ResolveLatest = "latest"
+	// ResolveNewest - the most recently modified subdirectory
+	ResolveNewest = "newest"
+)
+
+// ErrNoSubdirectory - a dynamic target has no subdirectory to point to
+var ErrNoSubdirectory = errors.New("no subdirectory to resolve to")
+
+// Rule - compiled rewrite rule
+type Rule struct {
+	pattern string
+	target  string
+	resolve string
+}
+
+// Compile - validates the configured rules. Patterns and targets are slash separated
+// paths from the base directory; they are cleaned, so targets cannot leave it.
+func Compile(rules []pkg.Rewrite) ([]Rule, error) {
+	compiled := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.Pattern, "/") || !strings.HasPrefix(rule.Target, "/") {
+			return nil, fmt.Errorf("pattern %q and target %q must start with /", rule.Pattern, rule.Target)
+		}
+		switch rule.Resolve {
+		case ResolveNone, ResolveLatest, ResolveNewest:
+		default:
+			return nil, fmt.Errorf("unknown resolver %q (expected latest or newest)", rule.Resolve)
+		}
+		pattern := path.Clean(rule.Pattern)
+		if pattern == "/" {
+			return nil, fmt.Errorf("pattern %q would rewrite every path", rule.Pattern)
+		}
+		compiled = append(compiled, Rule{pattern: pattern, target: path.Clean(rule.Target), resolve: rule.Resolve})
+	}
+	return compiled, nil
+}
+
+// Apply - rewrites the URL path with the first rule whose pattern is the path or one
+// of its parent directories; the rest of the path is appended to the target. It returns
+// the rewritten path and whether a rule matched. The result is cleaned and stays below
+// the base directory.
+func Apply(rules []Rule, base, urlPath string) (string, bool, error) {
+	cleaned := path.Clean("/" + urlPath)
+	for _, rule := range rules {
+		rest, ok := strings.CutPrefix(cleaned, rule.pattern)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			continue
+		}
+		target := rule.target
+		if rule.resolve != ResolveNone {
+			sub, err := resolve(filepath.Join(base, filepath.FromSlash(target)), rule.resolve)
+			if err != nil {
+				return "", true, err
+			}
+			target = path.Join(target, sub)
+		}
+		rewritten := path.Clean(target + rest)
+		if strings.HasSuffix(urlPath, "/") && rewritten != "/" {
+			rewritten += "/"
+		}
+		return rewritten, true, nil
+	}
+	return urlPath, false, nil
+}
+
+// resolve - returns the name of the subdirectory of dir selected by the resolver
+func resolve(dir, resolver string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var best string
+	var bestInfo os.FileInfo
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		switch resolver {
+		case ResolveLatest:
+			if entry.Name() > best {
+				best = entry.Name()
+			}
+		case ResolveNewest:
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			if bestInfo == nil || info.ModTime().After(bestInfo.ModTime()) {
+				best, bestInfo = entry.Name(), info
+			}
+		}
+	}
+	if best == "" {
+		return "", ErrNoSubdirectory
+	}
+	return best, nil
+}
@@ -0,0 +1,73 @@
+package rewrite
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"simple_file_server/pkg"
+)
+
+func TestCompile(t *testing.T) {
+	invalid := []pkg.Rewrite{
+		{Pattern: "latest", Target: "/releases"},
+		{Pattern: "/latest", Target: "releases"},
+		{Pattern: "/", Target: "/releases"},
+		{Pattern: "/latest", Target: "/releases", Resolve: "oldest"},
+	}
+	for _, rule := range invalid {
+		if _, err := Compile([]pkg.Rewrite{rule}); err == nil {
+			t.Errorf("Compile(%+v) succeeded", rule)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	base := t.TempDir()
+	for _, dir := range []string{"docs/manual", "releases/2024-05-01", "releases/2024-06-01", "releases/.hidden", "builds/a", "builds/b", "empty"} {
+		if err := os.MkdirAll(filepath.Join(base, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// builds/a is older by name but the most recently modified
+	now := time.Now()
+	os.Chtimes(filepath.Join(base, "builds", "b"), now.Add(-time.Hour), now.Add(-time.Hour))
+	os.Chtimes(filepath.Join(base, "builds", "a"), now, now)
+
+	rules, err := Compile([]pkg.Rewrite{
+		{Pattern: "/manual", Target: "/docs/manual"},
+		{Pattern: "/latest", Target: "/releases", Resolve: ResolveLatest},
+		{Pattern: "/nightly", Target: "/builds", Resolve: ResolveNewest},
+		{Pattern: "/escape", Target: "/../../etc"},
+		{Pattern: "/none", Target: "/empty", Resolve: ResolveLatest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		urlPath, want string
+		matched       bool
+	}{
+		{"/manual", "/docs/manual", true},
+		{"/manual/", "/docs/manual/", true},
+		{"/manual/index.html", "/docs/manual/index.html", true},
+		{"/manuals/a.txt", "/manuals/a.txt", false},
+		{"/latest/", "/releases/2024-06-01/", true},
+		{"/latest/notes.txt", "/releases/2024-06-01/notes.txt", true},
+		{"/nightly/", "/builds/a/", true},
+		{"/escape/passwd", "/etc/passwd", true},
+	}
+	for _, tt := range tests {
+		got, matched, err := Apply(rules, base, tt.urlPath)
+		if err != nil || got != tt.want || matched != tt.matched {
+			t.Errorf("Apply(%q) = %q, %t, %v, want %q, %t", tt.urlPath, got, matched, err, tt.want, tt.matched)
+		}
+	}
+
+	if _, matched, err := Apply(rules, base, "/none/"); !matched || !errors.Is(err, ErrNoSubdirectory) {
+		t.Errorf("Apply of a target without subdirectories: %t, %v, want %v", matched, err, ErrNoSubdirectory)
+	}
+}
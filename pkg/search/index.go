@@ -0,0 +1,257 @@
+// Description: This file implements the in-memory search index, kept up to date by the write handlers and rebuilt periodically.
+package search
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+// defaultRebuildInterval - time between full rebuilds when the configuration leaves it unset
+const defaultRebuildInterval = time.Hour
+
+var (
+	indexMu sync.RWMutex
+	// indexRoot - base directory the index covers; empty while the index is disabled
+	indexRoot string
+	// indexReady - the first build has completed
+	indexReady bool
+	// entries - indexed entries by slash separated path
+	entries map[string]Result
+	// byName - paths of the entries by lowercase name
+	byName map[string]map[string]struct{}
+	// rebuilding - a full rebuild is running; pending collects the paths updated meanwhile
+	rebuilding bool
+	pending    []string
+	// stopIndex - closed to stop the rebuilds of the running index
+	stopIndex chan struct{}
+)
+
+// SetupIndex - builds the index of baseDir in the background and rebuilds it every
+// rebuild_interval, when enabled. Searches walk the directories until the first build
+// has completed. An index already running is replaced, or dropped when disabled.
+func SetupIndex(config pkg.Search, baseDir string) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if stopIndex != nil {
+		close(stopIndex)
+		stopIndex = nil
+	}
+	indexRoot, indexReady, entries, byName, rebuilding, pending = "", false, nil, nil, false, nil
+	if !config.Index {
+		return
+	}
+	interval := config.RebuildInterval
+	if interval <= 0 {
+		interval = defaultRebuildInterval
+	}
+	indexRoot = baseDir
+	stop := make(chan struct{})
+	stopIndex = stop
+	go func() {
+		for {
+			rebuild(stop)
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// IndexReady - reports whether searches can be answered from the index
+func IndexReady() bool {
+	indexMu.RLock()
+	defer indexMu.RUnlock()
+	return indexReady
+}
+
+// rebuild - walks the whole base directory into fresh maps and swaps them in, then
+// applies the updates made while it ran. The result is dropped when the index was
+// replaced meanwhile, i.e. stop is closed.
+func rebuild(stop chan struct{}) {
+	indexMu.Lock()
+	if stopIndex != stop {
+		indexMu.Unlock()
+		return
+	}
+	root := indexRoot
+	rebuilding = true
+	pending = nil
+	indexMu.Unlock()
+
+	started := time.Now()
+	freshEntries := make(map[string]Result)
+	freshNames := make(map[string]map[string]struct{})
+	err := walkInto(root, "/", freshEntries, freshNames)
+
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if stopIndex != stop {
+		return
+	}
+	rebuilding = false
+	if err != nil {
+		logger.Logger.Errorf("Error building the search index: %v", err)
+		pending = nil
+		return
+	}
+	entries, byName = freshEntries, freshNames
+	for _, p := range pending {
+		refresh(p)
+	}
+	pending = nil
+	indexReady = true
+	logger.Logger.Infof("Search index built: %d entries in %s", len(entries), time.Since(started).Round(time.Millisecond))
+}
+
+// walkInto - adds the entry at the slash separated path and everything below it
+func walkInto(root, urlPath string, toEntries map[string]Result, toNames map[string]map[string]struct{}) error {
+	start := filepath.Join(root, filepath.FromSlash(urlPath))
+	return filepath.WalkDir(start, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped instead of failing the build
+			if entry != nil && entry.IsDir() && p != start {
+				return fs.SkipDir
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		entryPath := path.Join("/", filepath.ToSlash(rel))
+		if entryPath == "/" {
+			return nil
+		}
+		result := Result{Path: entryPath, Name: entry.Name(), IsDir: entry.IsDir()}
+		if info, err := entry.Info(); err == nil {
+			if !entry.IsDir() {
+				result.Size = info.Size()
+			}
+			result.ModTime = info.ModTime()
+		}
+		add(toEntries, toNames, result)
+		return nil
+	})
+}
+
+// add - stores the entry in the maps
+func add(toEntries map[string]Result, toNames map[string]map[string]struct{}, result Result) {
+	toEntries[result.Path] = result
+	key := strings.ToLower(result.Name)
+	if toNames[key] == nil {
+		toNames[key] = make(map[string]struct{})
+	}
+	toNames[key][result.Path] = struct{}{}
+}
+
+// remove - drops the entry at the path and everything below it; the caller must hold indexMu
+func remove(p string) {
+	for entryPath, result := range entries {
+		if entryPath != p && !strings.HasPrefix(entryPath, p+"/") {
+			continue
+		}
+		delete(entries, entryPath)
+		key := strings.ToLower(result.Name)
+		delete(byName[key], entryPath)
+		if len(byName[key]) == 0 {
+			delete(byName, key)
+		}
+	}
+}
+
+// refresh - replaces the entries at and below the path with their current state on
+// disk; the caller must hold indexMu
+func refresh(p string) {
+	remove(p)
+	full := filepath.Join(indexRoot, filepath.FromSlash(p))
+	if _, err := os.Lstat(full); err != nil {
+		return
+	}
+	if err := walkInto(indexRoot, p, entries, byName); err != nil {
+		logger.Logger.Warnf("Error updating the search index for %s: %v", p, err)
+	}
+}
+
+// Update - brings the index in line with the disk for the slash separated paths after
+// they were created, changed or removed; a directory is updated with its contents.
+// For a rename, pass the old and the new path.
+func Update(paths ...string) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if indexRoot == "" {
+		return
+	}
+	for _, p := range paths {
+		p = path.Clean("/" + p)
+		if p == "/" {
+			continue
+		}
+		if rebuilding {
+			pending = append(pending, p)
+		}
+		if entries != nil {
+			refresh(p)
+		}
+	}
+}
+
+// FindIndexed - returns the indexed entries below the directory urlPath whose name
+// matches the query, like Find. Entries for which skip returns true are left out, as
+// is everything below a skipped directory.
+func FindIndexed(urlPath, query string, skip func(p, name string, isDir bool) bool) []Result {
+	indexMu.RLock()
+	defer indexMu.RUnlock()
+
+	prefix := strings.TrimSuffix(path.Clean("/"+urlPath), "/") + "/"
+	skipped := make(map[string]bool)
+	// hidden - checks the entry and its directories below urlPath
+	var hidden func(p string) bool
+	hidden = func(p string) bool {
+		if p == "/" || !strings.HasPrefix(p, prefix) {
+			return false
+		}
+		if value, ok := skipped[p]; ok {
+			return value
+		}
+		result, ok := entries[p]
+		value := hidden(path.Dir(p)) || (ok && skip != nil && skip(p, result.Name, result.IsDir))
+		skipped[p] = value
+		return value
+	}
+
+	results := make([]Result, 0)
+	collect := func(p string, score int) {
+		if !strings.HasPrefix(p, prefix) || hidden(p) {
+			return
+		}
+		result := entries[p]
+		result.Score = score
+		results = append(results, result)
+	}
+	if query == "" {
+		for p := range entries {
+			collect(p, ScoreNone)
+		}
+		return results
+	}
+	for name, paths := range byName {
+		score := Score(name, query)
+		if score == ScoreNone {
+			continue
+		}
+		for p := range paths {
+			collect(p, score)
+		}
+	}
+	return results
+}
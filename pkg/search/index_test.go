@@ -0,0 +1,89 @@
+package search
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = logrus.New()
+	logger.Logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// indexedPaths - returns the sorted paths of the indexed matches of the query below urlPath
+func indexedPaths(urlPath, query string, skip func(p, name string, isDir bool) bool) string {
+	var paths []string
+	for _, result := range FindIndexed(urlPath, query, skip) {
+		paths = append(paths, result.Path)
+	}
+	sort.Strings(paths)
+	return strings.Join(paths, ",")
+}
+
+func TestIndex(t *testing.T) {
+	base := t.TempDir()
+	write := func(name string) {
+		full := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"docs/report.pdf", "docs/old/report-2023.txt", "private/report.txt", "notes.txt"} {
+		write(name)
+	}
+
+	SetupIndex(pkg.Search{Index: true, RebuildInterval: time.Hour}, base)
+	t.Cleanup(func() { SetupIndex(pkg.Search{}, "") })
+	for deadline := time.Now().Add(5 * time.Second); !IndexReady(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("index not built")
+		}
+	}
+
+	if got, want := indexedPaths("/", "report", nil), "/docs/old/report-2023.txt,/docs/report.pdf,/private/report.txt"; got != want {
+		t.Errorf("indexed results %s, want %s", got, want)
+	}
+	if got := indexedPaths("/docs", "REPORT", nil); got != "/docs/old/report-2023.txt,/docs/report.pdf" {
+		t.Errorf("results below /docs: %s", got)
+	}
+	private := func(p, name string, isDir bool) bool { return p == "/private" }
+	if got := indexedPaths("/", "report", private); strings.Contains(got, "/private") {
+		t.Errorf("skipped directory in the results: %s", got)
+	}
+
+	// Updates are applied without waiting for the next rebuild
+	write("docs/report-new.md")
+	Update("/docs/report-new.md")
+	os.RemoveAll(filepath.Join(base, "docs", "old"))
+	Update("/docs/old")
+	if err := os.Rename(filepath.Join(base, "notes.txt"), filepath.Join(base, "report-notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	Update("/notes.txt", "/report-notes.txt")
+	if got, want := indexedPaths("/", "report", nil), "/docs/report-new.md,/docs/report.pdf,/private/report.txt,/report-notes.txt"; got != want {
+		t.Errorf("results after the updates %s, want %s", got, want)
+	}
+	if got := indexedPaths("/", "notes.txt", nil); got != "/report-notes.txt" {
+		t.Errorf("renamed entry: %s", got)
+	}
+
+	// A disabled index is dropped
+	SetupIndex(pkg.Search{}, "")
+	if IndexReady() || indexedPaths("/", "report", nil) != "" {
+		t.Error("disabled index still answers")
+	}
+}
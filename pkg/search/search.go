@@ -0,0 +1,134 @@
+// Description: This file implements the search package, which finds and ranks files by name.
+package search
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Scores of a name match, higher is more relevant
+const (
+	ScoreNone      = 0
+	ScoreSubstring = 1
+	ScorePrefix    = 2
+	ScoreExact     = 3
+)
+
+// errScanLimit - stops the walk once enough entries were scanned
+var errScanLimit = errors.New("scan limit reached")
+
+// Result - represents a matching file or folder
+type Result struct {
+	Path    string    `json:"path"`
+	Name    string    `json:"name"`
+	IsDir   bool      `json:"isDir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Score   int       `json:"score"`
+	// URL - absolute URL of the entry
+	URL string `json:"url,omitempty"`
+	// Tags - user-defined tags of the entry
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Score - rates how well the name matches the query, ignoring case:
+// exact match, then prefix match, then substring match
+func Score(name, query string) int {
+	name, query = strings.ToLower(name), strings.ToLower(query)
+	switch {
+	case query == "":
+		return ScoreNone
+	case name == query:
+		return ScoreExact
+	case strings.HasPrefix(name, query):
+		return ScorePrefix
+	case strings.Contains(name, query):
+		return ScoreSubstring
+	}
+	return ScoreNone
+}
+
+// Rank - orders the results by score, then by modification time (newest first) and
+// path, and keeps at most limit results. A limit of zero or less means no limit.
+func Rank(results []Result, limit int) []Result {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if !results[i].ModTime.Equal(results[j].ModTime) {
+			return results[i].ModTime.After(results[j].ModTime)
+		}
+		return results[i].Path < results[j].Path
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Find - walks dir (the directory urlPath) and returns the entries whose name matches
+// the query, with slash separated paths. Names are returned with their original case.
+// An empty query matches every entry. At most maxScanned entries are visited; truncated
+// reports whether the walk stopped early. Entries for which skip returns true are left
+// out, directories with their contents.
+func Find(dir, urlPath, query string, maxScanned int, skip func(p string, entry fs.DirEntry) bool) (results []Result, truncated bool, err error) {
+	results = make([]Result, 0)
+	scanned := 0
+	err = filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped instead of failing the search
+			if entry != nil && entry.IsDir() && p != dir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		scanned++
+		if maxScanned > 0 && scanned > maxScanned {
+			truncated = true
+			return errScanLimit
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		entryPath := path.Join(urlPath, filepath.ToSlash(rel))
+		if skip != nil && skip(entryPath, entry) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		score := Score(entry.Name(), query)
+		if score == ScoreNone && query != "" {
+			return nil
+		}
+		result := Result{
+			Path:  entryPath,
+			Name:  entry.Name(),
+			IsDir: entry.IsDir(),
+			Score: score,
+		}
+		if info, err := entry.Info(); err == nil {
+			if !entry.IsDir() {
+				result.Size = info.Size()
+			}
+			result.ModTime = info.ModTime()
+		}
+		results = append(results, result)
+		return nil
+	})
+	if errors.Is(err, errScanLimit) {
+		err = nil
+	}
+	return results, truncated, err
+}
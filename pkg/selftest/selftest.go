@@ -0,0 +1,84 @@
+// Description: This file implements the selftest package, which checks at startup that the server can work with its base directory and templates.
+package selftest
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+)
+
+// Check - represents the outcome of a single startup check
+type Check struct {
+	// Name - what was checked, e.g. "list base directory"
+	Name string
+	// Err - the failure, nil when the check passed
+	Err error
+}
+
+// Page - template rendered by the self-test with the data it is given
+type Page struct {
+	Name string
+	Data interface{}
+}
+
+// Run - lists baseDir, writes and removes a temp file in it when writable and renders
+// the pages, returning the outcome of every check. All checks run even when one fails.
+func Run(baseDir string, writable bool, templates *template.Template, pages []Page) []Check {
+	checks := []Check{{Name: "list base directory", Err: listDir(baseDir)}}
+	if writable {
+		checks = append(checks, Check{Name: "write to base directory", Err: writeFile(baseDir)})
+	}
+	for _, page := range pages {
+		checks = append(checks, Check{Name: "render " + page.Name, Err: render(templates, page)})
+	}
+	return checks
+}
+
+// Failed - returns the checks that did not pass
+func Failed(checks []Check) []Check {
+	var failed []Check
+	for _, check := range checks {
+		if check.Err != nil {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// listDir - reads the entries of the directory
+func listDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	_, err = os.ReadDir(dir)
+	return err
+}
+
+// writeFile - creates, writes and removes a hidden temp file in the directory
+func writeFile(dir string) error {
+	file, err := os.CreateTemp(dir, ".sfs-selftest-*")
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString("self-test\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if removeErr := os.Remove(file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// render - executes the template, discarding the output
+func render(templates *template.Template, page Page) error {
+	if templates == nil || templates.Lookup(page.Name) == nil {
+		return fmt.Errorf("template %q not found", page.Name)
+	}
+	return templates.ExecuteTemplate(io.Discard, page.Name, page.Data)
+}
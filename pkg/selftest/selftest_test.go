@@ -0,0 +1,64 @@
+package selftest
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// names - returns the names of the checks, marking failed ones with "!"
+func names(checks []Check) string {
+	var result []string
+	for _, check := range checks {
+		name := check.Name
+		if check.Err != nil {
+			name = "!" + name
+		}
+		result = append(result, name)
+	}
+	return strings.Join(result, ",")
+}
+
+func TestRun(t *testing.T) {
+	templates := template.Must(template.New("").Parse(`{{define "index.html"}}{{.Title}}{{end}}{{define "broken.html"}}{{.Missing.Field}}{{end}}`))
+	pages := []Page{{Name: "index.html", Data: struct{ Title string }{"files"}}}
+
+	base := t.TempDir()
+	checks := Run(base, true, templates, pages)
+	if got := names(checks); got != "list base directory,write to base directory,render index.html" {
+		t.Errorf("writable base: %s", got)
+	}
+	if entries, _ := os.ReadDir(base); len(entries) != 0 {
+		t.Errorf("the write check left %d entries", len(entries))
+	}
+
+	// A read-only share skips the write check
+	if got := names(Run(base, false, templates, pages)); got != "list base directory,render index.html" {
+		t.Errorf("base without writes: %s", got)
+	}
+
+	broken := append(pages, Page{Name: "broken.html", Data: struct{}{}}, Page{Name: "login.html"})
+	checks = Run(filepath.Join(base, "missing"), true, templates, broken)
+	if got := names(checks); got != "!list base directory,!write to base directory,render index.html,!render broken.html,!render login.html" {
+		t.Errorf("missing base and broken templates: %s", got)
+	}
+	if len(Failed(checks)) != 4 {
+		t.Errorf("Failed = %d checks, want 4", len(Failed(checks)))
+	}
+}
+
+func TestRunReadOnly(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions do not apply to root")
+	}
+	base := t.TempDir()
+	if err := os.Chmod(base, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(base, 0o755) })
+	if got := names(Run(base, true, nil, nil)); got != "list base directory,!write to base directory" {
+		t.Errorf("read-only base: %s", got)
+	}
+}
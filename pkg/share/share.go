@@ -0,0 +1,153 @@
+// Description: This file implements the share package, which applies per-share access policies.
+package share
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"simple_file_server/pkg"
+)
+
+// Write operations that shares can deny
+const (
+	OpUpload = "upload"
+	OpCreate = "create"
+	OpDelete = "delete"
+	// OpWrite - every write operation
+	OpWrite = "write"
+)
+
+// Decision - result of checking a path against the share policies
+type Decision int
+
+const (
+	// Allow - the request may proceed
+	Allow Decision = iota
+	// Login - the share requires an authenticated user
+	Login
+	// Deny - the user is not allowed to access the share
+	Deny
+)
+
+// policy - share with its normalized path
+type policy struct {
+	pkg.Share
+	prefix string
+}
+
+var (
+	policies      []policy
+	caseSensitive = true
+)
+
+// Setup - validates the shares and prepares them for lookups. Paths are relative
+// to the base directory; nested shares are allowed and the most specific one wins.
+func Setup(shares []pkg.Share, isCaseSensitive bool) error {
+	caseSensitive = isCaseSensitive
+	seen := make(map[string]bool)
+	result := make([]policy, 0, len(shares))
+	for _, s := range shares {
+		if s.Path == "" {
+			return fmt.Errorf("share path is required")
+		}
+		for _, op := range s.Deny {
+			switch op {
+			case OpUpload, OpCreate, OpDelete, OpWrite:
+			default:
+				return fmt.Errorf("unknown operation %q in deny of share %s (expected upload, create, delete or write)", op, s.Path)
+			}
+		}
+		prefix := normalize(s.Path)
+		if seen[prefix] {
+			return fmt.Errorf("duplicate share path: %s", s.Path)
+		}
+		seen[prefix] = true
+		result = append(result, policy{Share: s, prefix: prefix})
+	}
+	// Longest prefix first so that nested shares override their parents
+	sort.Slice(result, func(i, j int) bool {
+		return len(result[i].prefix) > len(result[j].prefix)
+	})
+	policies = result
+	return nil
+}
+
+// normalize - cleans the path and folds its case on case-insensitive filesystems
+func normalize(p string) string {
+	p = path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))
+	if !caseSensitive {
+		p = strings.ToLower(p)
+	}
+	return p
+}
+
+// Lookup - returns the share containing the path, if any
+func Lookup(p string) (pkg.Share, bool) {
+	p = normalize(p)
+	for _, s := range policies {
+		if s.prefix == "/" || p == s.prefix || strings.HasPrefix(p, s.prefix+"/") {
+			return s.Share, true
+		}
+	}
+	return pkg.Share{}, false
+}
+
+// Authorize - checks whether the user (empty when not logged in) may access the path.
+// Paths outside of every share keep the default behavior and are allowed.
+func Authorize(p, user string) Decision {
+	s, ok := Lookup(p)
+	if !ok {
+		return Allow
+	}
+	if (s.RequireAuth || len(s.AllowedUsers) > 0) && user == "" {
+		return Login
+	}
+	if len(s.AllowedUsers) == 0 {
+		return Allow
+	}
+	for _, allowed := range s.AllowedUsers {
+		if allowed == user {
+			return Allow
+		}
+	}
+	return Deny
+}
+
+// denies - reports whether the share refuses the operation
+func (s policy) denies(op string) bool {
+	for _, denied := range s.Deny {
+		if denied == op || denied == OpWrite {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteDenied - reports whether a share containing the path refuses the operation.
+// Unlike access, denied operations are inherited by nested shares. With subtree, the
+// shares below the path count too, e.g. for deleting a directory that contains a
+// read-only share.
+func WriteDenied(p, op string, subtree bool) bool {
+	p = normalize(p)
+	for _, s := range policies {
+		inside := s.prefix == "/" || p == s.prefix || strings.HasPrefix(p, s.prefix+"/")
+		below := subtree && (p == "/" || strings.HasPrefix(s.prefix, p+"/"))
+		if (inside || below) && s.denies(op) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListTokenValid - reports whether the token unlocks the listing of the directory:
+// the share containing it has no list_token or the token matches it
+func ListTokenValid(p, token string) bool {
+	s, ok := Lookup(p)
+	if !ok || s.ListToken == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(s.ListToken), []byte(token)) == 1
+}
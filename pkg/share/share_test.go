@@ -0,0 +1,70 @@
+package share
+
+import (
+	"testing"
+
+	"simple_file_server/pkg"
+)
+
+func TestWriteDenied(t *testing.T) {
+	err := Setup([]pkg.Share{
+		{Path: "/archive", Deny: []string{OpWrite}},
+		{Path: "/inbox", Deny: []string{OpDelete}},
+		{Path: "/archive/open"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Setup(nil, true) })
+
+	tests := []struct {
+		path    string
+		op      string
+		subtree bool
+		denied  bool
+	}{
+		{"/archive", OpUpload, false, true},
+		{"/archive/2024/a.txt", OpCreate, false, true},
+		{"/archive/open", OpUpload, false, true},
+		{"/archives", OpUpload, false, false},
+		{"/inbox", OpUpload, false, false},
+		{"/inbox/a.txt", OpDelete, false, true},
+		{"/docs", OpDelete, false, false},
+		{"/", OpDelete, false, false},
+		{"/", OpDelete, true, true},
+		{"/", OpUpload, true, true},
+		{"/docs", OpDelete, true, false},
+	}
+	for _, tt := range tests {
+		if got := WriteDenied(tt.path, tt.op, tt.subtree); got != tt.denied {
+			t.Errorf("WriteDenied(%q, %s, %t) = %t, want %t", tt.path, tt.op, tt.subtree, got, tt.denied)
+		}
+	}
+
+	if err := Setup([]pkg.Share{{Path: "/a", Deny: []string{"rename"}}}, true); err == nil {
+		t.Error("Setup accepted an unknown operation")
+	}
+}
+
+func TestListTokenValid(t *testing.T) {
+	if err := Setup([]pkg.Share{{Path: "/public", ListToken: "s3cret"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Setup(nil, true) })
+
+	tests := []struct {
+		path, token string
+		valid       bool
+	}{
+		{"/public", "s3cret", true},
+		{"/public/sub", "s3cret", true},
+		{"/public", "wrong", false},
+		{"/public", "", false},
+		{"/docs", "", true},
+	}
+	for _, tt := range tests {
+		if got := ListTokenValid(tt.path, tt.token); got != tt.valid {
+			t.Errorf("ListTokenValid(%q, %q) = %t, want %t", tt.path, tt.token, got, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Description: This file contains the parsing of human readable sizes in the configuration.
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits - multipliers of the size suffixes; units are binary, so "1MB" is 1 MiB
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// ParseSize - parses a size such as "250MB", "1.5G" or "512KB" into bytes. A plain
+// number is taken as megabytes; an empty value returns def.
+func ParseSize(value string, def int64) (int64, error) {
+	original := value
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return def, nil
+	}
+	multiplier := int64(1 << 20)
+	for _, unit := range sizeUnits {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, multiplier = strings.TrimSpace(number), unit.multiplier
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 100, 250MB or 2GB)", original)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// FormatSize - formats a size in bytes with the largest unit dividing it, e.g. "250MB"
+func FormatSize(size int64) string {
+	for _, unit := range sizeUnits[:4] {
+		if size >= unit.multiplier && size%unit.multiplier == 0 {
+			return strconv.FormatInt(size/unit.multiplier, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(size, 10) + "B"
+}
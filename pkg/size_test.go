@@ -0,0 +1,31 @@
+package pkg
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		ok    bool
+	}{
+		{"", 7, true},
+		{"100", 100 << 20, true},
+		{"250MB", 250 << 20, true},
+		{"250 mb", 250 << 20, true},
+		{"1.5G", 3 << 29, true},
+		{"512KB", 512 << 10, true},
+		{"10B", 10, true},
+		{"0", 0, false},
+		{"-5MB", 0, false},
+		{"lots", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.value, 7)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+	if got := FormatSize(250 << 20); got != "250MB" {
+		t.Errorf("FormatSize = %q, want 250MB", got)
+	}
+}
@@ -0,0 +1,74 @@
+// Description: This file contains the content type checks of uploaded files.
+package pkg
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLength - bytes examined to detect the content type
+const sniffLength = 512
+
+// signatureTypes - content types recognized by their signature, by extension. A file
+// with one of these extensions must contain that type; other extensions cannot be
+// verified reliably (e.g. JSON sniffs as text/plain, SVG as text/xml).
+var signatureTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".ico":  "image/x-icon",
+	".pdf":  "application/pdf",
+	".zip":  "application/zip",
+	".gz":   "application/x-gzip",
+	".tgz":  "application/x-gzip",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".wav":  "audio/wave",
+	".ogg":  "application/ogg",
+}
+
+// SniffType - detects the content type from the first bytes of the content, without
+// parameters such as the charset
+func SniffType(r io.Reader) (string, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
+		return "application/octet-stream", nil
+	}
+	return mediaType, nil
+}
+
+// MatchType - reports whether the content type is one of the patterns, which are
+// types such as "application/pdf" or wildcards such as "image/*"
+func MatchType(contentType string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == contentType || pattern == "*/*" {
+			return true
+		}
+		if major, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(contentType, major+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtensionMismatch - returns the type the extension of the name promises and whether
+// the detected content type contradicts it, e.g. a ".jpg" containing a script
+func ExtensionMismatch(name, contentType string) (string, bool) {
+	expected, ok := signatureTypes[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return "", false
+	}
+	return expected, expected != contentType
+}
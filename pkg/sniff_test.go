@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSniffType(t *testing.T) {
+	tests := map[string]string{
+		"\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR": "image/png",
+		"%PDF-1.7\n":                            "application/pdf",
+		"#!/bin/sh\nrm -rf /\n":                 "text/plain",
+		"<html><script>alert(1)</script>":       "text/html",
+		"":                                      "text/plain",
+	}
+	for content, want := range tests {
+		if got, err := SniffType(strings.NewReader(content)); err != nil || got != want {
+			t.Errorf("SniffType(%q) = %q, %v, want %q", content, got, err, want)
+		}
+	}
+}
+
+func TestMatchType(t *testing.T) {
+	patterns := []string{"image/*", " Application/PDF "}
+	tests := map[string]bool{
+		"image/png":       true,
+		"application/pdf": true,
+		"text/plain":      false,
+		"imagex/png":      false,
+	}
+	for contentType, want := range tests {
+		if got := MatchType(contentType, patterns); got != want {
+			t.Errorf("MatchType(%q) = %t, want %t", contentType, got, want)
+		}
+	}
+	if !MatchType("text/plain", []string{"*/*"}) {
+		t.Error("*/* does not match every type")
+	}
+}
+
+func TestExtensionMismatch(t *testing.T) {
+	tests := []struct {
+		name, contentType string
+		mismatch          bool
+	}{
+		{"photo.JPG", "image/jpeg", false},
+		{"photo.jpg", "text/plain", true},
+		{"doc.pdf", "text/html", true},
+		{"data.json", "text/plain", false},
+		{"noext", "application/octet-stream", false},
+	}
+	for _, tt := range tests {
+		if _, mismatch := ExtensionMismatch(tt.name, tt.contentType); mismatch != tt.mismatch {
+			t.Errorf("ExtensionMismatch(%q, %q) = %t, want %t", tt.name, tt.contentType, mismatch, tt.mismatch)
+		}
+	}
+}
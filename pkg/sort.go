@@ -0,0 +1,114 @@
+// Description: This file contains the sorting of directory listings.
+package pkg
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Sort keys and orders of the listing
+const (
+	SortName    = "name"
+	SortSize    = "size"
+	SortModTime = "modtime"
+	OrderAsc    = "asc"
+	OrderDesc   = "desc"
+)
+
+// SortOrder - column and direction the listing is sorted by
+type SortOrder struct {
+	By    string
+	Order string
+}
+
+// DefaultSort - sort order of listings without a sort parameter or cookie
+var DefaultSort = SortOrder{By: SortName, Order: OrderAsc}
+
+// ParseSort - validates the sort key and order; an empty order means ascending
+func ParseSort(by, order string) (SortOrder, bool) {
+	by, order = strings.ToLower(by), strings.ToLower(order)
+	if order == "" {
+		order = OrderAsc
+	}
+	switch by {
+	case SortName, SortSize, SortModTime:
+	default:
+		return SortOrder{}, false
+	}
+	if order != OrderAsc && order != OrderDesc {
+		return SortOrder{}, false
+	}
+	return SortOrder{By: by, Order: order}, true
+}
+
+// ParseSortValue - parses the "key:order" form used by String
+func ParseSortValue(value string) (SortOrder, bool) {
+	by, order, _ := strings.Cut(value, ":")
+	return ParseSort(by, order)
+}
+
+// String - returns the compact "key:order" form, e.g. "modtime:desc"
+func (s SortOrder) String() string {
+	return s.By + ":" + s.Order
+}
+
+// Toggle - returns the order selected by clicking the column header of the key: the
+// reverse order for the current key, ascending for any other
+func (s SortOrder) Toggle(by string) SortOrder {
+	if s.By == by && s.Order == OrderAsc {
+		return SortOrder{By: by, Order: OrderDesc}
+	}
+	return SortOrder{By: by, Order: OrderAsc}
+}
+
+// SortFiles - sorts the entries in place, directories first. Entries with equal keys
+// are ordered by name; entries whose info cannot be read count as empty.
+func SortFiles(files []os.DirEntry, s SortOrder) {
+	sizes := make(map[string]int64, len(files))
+	modTimes := make(map[string]int64, len(files))
+	if s.By != SortName {
+		for _, file := range files {
+			if info, err := file.Info(); err == nil {
+				if !file.IsDir() {
+					sizes[file.Name()] = info.Size()
+				}
+				modTimes[file.Name()] = info.ModTime().UnixNano()
+			}
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.IsDir() != b.IsDir() {
+			return a.IsDir()
+		}
+		var cmp int
+		switch s.By {
+		case SortSize:
+			cmp = compareInt(sizes[a.Name()], sizes[b.Name()])
+		case SortModTime:
+			cmp = compareInt(modTimes[a.Name()], modTimes[b.Name()])
+		}
+		if cmp == 0 {
+			cmp = strings.Compare(strings.ToLower(a.Name()), strings.ToLower(b.Name()))
+		}
+		if cmp == 0 {
+			cmp = strings.Compare(a.Name(), b.Name())
+		}
+		if s.Order == OrderDesc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+}
+
+// compareInt - returns -1, 0 or 1 as a is less than, equal to or greater than b
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		by, order string
+		want      SortOrder
+		ok        bool
+	}{
+		{"name", "", SortOrder{SortName, OrderAsc}, true},
+		{"SIZE", "Desc", SortOrder{SortSize, OrderDesc}, true},
+		{"modtime", "asc", SortOrder{SortModTime, OrderAsc}, true},
+		{"owner", "asc", SortOrder{}, false},
+		{"name", "up", SortOrder{}, false},
+	}
+	for _, tt := range tests {
+		if got, ok := ParseSort(tt.by, tt.order); got != tt.want || ok != tt.ok {
+			t.Errorf("ParseSort(%q, %q) = %v, %t, want %v, %t", tt.by, tt.order, got, ok, tt.want, tt.ok)
+		}
+	}
+	if got, ok := ParseSortValue("size:desc"); !ok || got.String() != "size:desc" {
+		t.Errorf("ParseSortValue round trip: %v, %t", got, ok)
+	}
+	if got := DefaultSort.Toggle(SortName); got != (SortOrder{SortName, OrderDesc}) {
+		t.Errorf("toggle of the current key: %v", got)
+	}
+	if got := (SortOrder{SortName, OrderDesc}).Toggle(SortSize); got != (SortOrder{SortSize, OrderAsc}) {
+		t.Errorf("toggle of another key: %v", got)
+	}
+}
+
+func TestSortFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for _, file := range []struct {
+		name string
+		size int
+		age  time.Duration
+	}{{"b.txt", 30, 2 * time.Hour}, {"A.txt", 10, time.Hour}, {"c.txt", 20, 3 * time.Hour}} {
+		path := filepath.Join(dir, file.name)
+		if err := os.WriteFile(path, make([]byte, file.size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(-file.age)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"zdir", "adir"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		order SortOrder
+		want  string
+	}{
+		{SortOrder{SortName, OrderAsc}, "adir,zdir,A.txt,b.txt,c.txt"},
+		{SortOrder{SortName, OrderDesc}, "zdir,adir,c.txt,b.txt,A.txt"},
+		{SortOrder{SortSize, OrderAsc}, "adir,zdir,A.txt,c.txt,b.txt"},
+		{SortOrder{SortSize, OrderDesc}, "zdir,adir,b.txt,c.txt,A.txt"},
+		{SortOrder{SortModTime, OrderAsc}, "c.txt,b.txt,A.txt"},
+		{SortOrder{SortModTime, OrderDesc}, "A.txt,b.txt,c.txt"},
+	}
+	for _, tt := range tests {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		SortFiles(files, tt.order)
+		var names []string
+		for _, file := range files {
+			names = append(names, file.Name())
+		}
+		got := strings.Join(names, ",")
+		if tt.order.By == SortModTime {
+			// The directories' times are not controlled; only the files are compared
+			got = strings.Join(names[2:], ",")
+		}
+		if got != tt.want {
+			t.Errorf("SortFiles(%v) = %s, want %s", tt.order, got, tt.want)
+		}
+	}
+}
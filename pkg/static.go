@@ -0,0 +1,37 @@
+// Description: This file contains the file system wrapper used to serve static assets.
+package pkg
+
+import (
+	"net/http"
+	"os"
+	"path"
+)
+
+// NoListingFileSystem - wraps an http.FileSystem and hides directories without an index.html,
+// so http.FileServer answers 404 instead of generating a directory listing
+type NoListingFileSystem struct {
+	FS http.FileSystem
+}
+
+// Open - opens the named file, refusing directories that have no index.html
+func (nfs NoListingFileSystem) Open(name string) (http.File, error) {
+	f, err := nfs.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		index, err := nfs.FS.Open(path.Join(name, "index.html"))
+		if err != nil {
+			f.Close()
+			return nil, os.ErrNotExist
+		}
+		index.Close()
+	}
+	return f, nil
+}
@@ -1,26 +1,482 @@
 // Description: This file contains the struct definitions for the configuration file.
 package pkg
 
+import (
+	"net/url"
+	"time"
+)
+
 // Config - represents the configuration file
 type Config struct {
 	WebServer WebServer `yaml:"web-server"`
-   	Logging Logging `yaml:"logging"`    
+	Logging   Logging   `yaml:"logging"`
+	Webhook   Webhook   `yaml:"webhook"`
+	Auth      Auth      `yaml:"auth"`
+	Recent    Recent    `yaml:"recent"`
+	Favorites Favorites `yaml:"favorites"`
+	Tags      Tags      `yaml:"tags"`
+	Upload    Upload    `yaml:"upload"`
+	Archive   Archive   `yaml:"archive"`
+	Preview   Preview   `yaml:"preview"`
+	PWA       PWA       `yaml:"pwa"`
+	Shares    []Share   `yaml:"shares"`
+	Search    Search    `yaml:"search"`
+	Expiry    Expiry    `yaml:"expiry"`
+	FTP       FTP       `yaml:"ftp"`
+	DirSize   DirSize   `yaml:"dir_size"`
+	Events    Events    `yaml:"events"`
+	// Rewrites - URL paths served from other paths, e.g. /latest/ from the newest release
+	Rewrites []Rewrite `yaml:"rewrites"`
+	// ChildCount - number of immediate entries shown for the directories of a listing
+	ChildCount ChildCount `yaml:"child_count"`
+	// ContactSheet - grid image of the thumbnails of an image folder
+	ContactSheet ContactSheet `yaml:"contact_sheet"`
+	// SelfTest - checks of the base directory and templates run at startup
+	SelfTest SelfTest `yaml:"self_test"`
+}
+
+// redactedValue - placeholder for sensitive values in exported configuration
+const redactedValue = "[REDACTED]"
+
+// Redacted - returns a copy of the configuration with sensitive fields masked
+func (c Config) Redacted() Config {
+	redact := func(v string) string {
+		if v == "" {
+			return ""
+		}
+		return redactedValue
+	}
+	c.WebServer.SSLKey = redact(c.WebServer.SSLKey)
+	c.WebServer.Secret = redact(c.WebServer.Secret)
+	c.Logging.RedactKey = redact(c.Logging.RedactKey)
+	if u, err := url.Parse(c.Webhook.URL); err == nil {
+		c.Webhook.URL = u.Redacted()
+	} else {
+		c.Webhook.URL = redact(c.Webhook.URL)
+	}
+	// The shares are copied so that the live configuration keeps its tokens
+	c.Shares = append([]Share(nil), c.Shares...)
+	for i := range c.Shares {
+		c.Shares[i].ListToken = redact(c.Shares[i].ListToken)
+	}
+	return c
 }
 
-// 
 type WebServer struct {
 	Port     string `yaml:"port"`
 	Protocol string `yaml:"protocol"`
 	SSLCert  string `yaml:"ssl_cert_file,omitempty"`
 	SSLKey   string `yaml:"ssl_key_file,omitempty"`
 	BaseDir  string `yaml:"base_dir"`
+	Secret   string `yaml:"secret,omitempty"`
+	// PublicURL - external base URL of the server, e.g. https://files.example.com, used for
+	// absolute links such as QR codes (defaults to the protocol and the request host)
+	PublicURL string `yaml:"public_url"`
+	// MaxUploadSize - largest upload request, e.g. "250MB"; a plain number is in megabytes (default 100)
+	MaxUploadSize string `yaml:"max_upload_size"`
+	// AutocompleteLimit - maximum number of entries returned for a prefix query
+	AutocompleteLimit int `yaml:"autocomplete_limit"`
+	// StaticListing - allows directory listings under /static/
+	StaticListing bool `yaml:"static_listing"`
+	// DirectoryTemplates - renders a directory with its own .listing.html when present
+	DirectoryTemplates bool `yaml:"directory_templates"`
+	// Metrics - exposes runtime counters at /metrics in the Prometheus text format
+	Metrics bool `yaml:"metrics"`
+	// TrustedProxies - proxies (IPs or CIDRs) whose forwarding headers identify the client
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// MaxTreeDepth - maximum nesting accepted by /create-tree
+	MaxTreeDepth int  `yaml:"max_tree_depth"`
+	ACME         ACME `yaml:"acme"`
+	// FilesystemCaseSensitive - set to false on case-insensitive filesystems to reject
+	// names differing only by case (defaults to true)
+	FilesystemCaseSensitive *bool `yaml:"filesystem_case_sensitive"`
+	// CollapseSlashes - collapses repeated slashes in request paths, redirecting to the canonical path
+	CollapseSlashes bool `yaml:"collapse_slashes"`
+	// DenyResponse - status of every response to a path refused by the traversal guard or
+	// an access rule: 403 or 404 (default: 400 for traversal, 403 for access rules)
+	DenyResponse string `yaml:"deny_response"`
+	// MaxURLLength - longest request path accepted, in bytes as sent (default 8192, -1 for no limit)
+	MaxURLLength int `yaml:"max_url_length"`
+	// Descriptions - shows per-file descriptions stored in a .descriptions file and enables /describe
+	Descriptions bool `yaml:"descriptions"`
+	// SymlinkPolicy - handling of symlinks in write destinations: contain (default), follow or deny
+	SymlinkPolicy string `yaml:"symlink_policy"`
+	// ReadmeDepth - directories rendering their README.md: all (default), root or a maximum depth
+	ReadmeDepth string `yaml:"readme_depth"`
+	// FSRetry - retries of filesystem reads failing with transient errors (NFS, SMB)
+	FSRetry FSRetry `yaml:"fs_retry"`
+	// MetadataSuffix - suffix of sidecar metadata files shown with their entry, e.g. ".meta.json"
+	MetadataSuffix string `yaml:"metadata_suffix"`
+	// ListingExclude - gitignore-style patterns of entries hidden from listings and searches
+	ListingExclude []string `yaml:"listing_exclude"`
+	// GroupBy - groups the listing into labeled sections: none (default), type or extension
+	GroupBy string `yaml:"group_by"`
+	// Banner - announcement in Markdown shown above listings and on the login page
+	Banner string `yaml:"banner"`
+	// RefreshInterval - polling interval suggested to clients of the JSON listing (0 = none)
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	// PrebuiltIndex - serves a directory's .index.json as its JSON listing when present
+	PrebuiltIndex bool `yaml:"prebuilt_index"`
+	// DeleteProtection - files modified within this window can only be deleted with force=1 (0 = off)
+	DeleteProtection time.Duration `yaml:"delete_protection"`
+}
+
+// FSRetry - represents the retry policy of transient filesystem errors
+type FSRetry struct {
+	// Attempts - total number of attempts of an operation (1 disables retries)
+	Attempts int `yaml:"attempts"`
+	// Delay - delay before the first retry, doubled for every further one
+	Delay time.Duration `yaml:"delay"`
+}
+
+// IsCaseSensitive - reports whether file names are treated as case-sensitive
+func (ws WebServer) IsCaseSensitive() bool {
+	return ws.FilesystemCaseSensitive == nil || *ws.FilesystemCaseSensitive
+}
+
+// ACME - represents the automatic certificate management configuration
+type ACME struct {
+	Enabled  bool     `yaml:"enabled"`
+	Domains  []string `yaml:"domains"`
+	CacheDir string   `yaml:"cache_dir"`
+	Email    string   `yaml:"email"`
+	// HTTPPort - port serving the HTTP-01 challenge
+	HTTPPort string `yaml:"http_port"`
+	// DirectoryURL - ACME directory, defaults to Let's Encrypt production
+	DirectoryURL string `yaml:"directory_url"`
 }
 
 // Logging - represents the logging configuration
 type Logging struct {
-	LogFile string `yaml:"log_file"`
+	LogFile     string `yaml:"log_file"`
 	LogSeverity string `yaml:"log_severity"`
-	LogMaxSize int `yaml:"log_max_size"`
-	LogMaxFiles int `yaml:"log_max_files"`
-	LogMaxAge int `yaml:"log_max_age"`
-}
\ No newline at end of file
+	LogMaxSize  int    `yaml:"log_max_size"`
+	LogMaxFiles int    `yaml:"log_max_files"`
+	LogMaxAge   int    `yaml:"log_max_age"`
+	// HeaderFields - request headers copied into access and audit log fields (header: field)
+	HeaderFields map[string]string `yaml:"header_fields"`
+	// AccessFormat - format of the access log: json (default), text, common or combined
+	AccessFormat string `yaml:"access_format"`
+	// AccessLogFile - separate access log file (defaults to access.log next to log_file
+	// for the text, common and combined formats)
+	AccessLogFile string `yaml:"access_log_file"`
+	// AccessLogTLS - adds the negotiated TLS version, cipher suite and server name to
+	// json and text access entries of HTTPS requests
+	AccessLogTLS bool `yaml:"access_log_tls"`
+	// RedactUsernames - logs pseudonyms (HMAC of the name) instead of usernames
+	RedactUsernames bool `yaml:"redact_usernames"`
+	// RedactKey - HMAC key of the pseudonyms; random on every start when empty
+	RedactKey string `yaml:"redact_key"`
+}
+
+// Webhook - represents the upload notification webhook configuration
+type Webhook struct {
+	URL        string        `yaml:"url"`
+	Timeout    time.Duration `yaml:"timeout"`
+	Retries    int           `yaml:"retries"`
+	RetryDelay time.Duration `yaml:"retry_delay"`
+}
+
+// Auth - represents the authentication and authorization configuration
+type Auth struct {
+	Admins []string `yaml:"admins"`
+	// Backends - authentication backends tried in order until one accepts (default: pam,
+	// or htpasswd then pam when HtpasswdFile is set)
+	Backends []string `yaml:"backends"`
+	// HtpasswdFile - Apache-style file of user:hash lines enabling the htpasswd backend,
+	// which is tried first when Backends is empty
+	HtpasswdFile string `yaml:"htpasswd_file"`
+	// SessionDuration - lifetime of a session from the login (default 24h)
+	SessionDuration time.Duration `yaml:"session_duration"`
+	// FailureDelay - minimum duration of a failed login response (capped at 10s)
+	FailureDelay time.Duration `yaml:"failure_delay"`
+	// MaxSessionsPerUser - concurrent sessions a user may have (0 = unlimited)
+	MaxSessionsPerUser int `yaml:"max_sessions_per_user"`
+	// SessionLimitPolicy - what happens on a login beyond the limit: evict_oldest (default) or reject
+	SessionLimitPolicy string `yaml:"session_limit_policy"`
+	// SessionReapInterval - how often expired sessions are removed (default 10m)
+	SessionReapInterval time.Duration `yaml:"session_reap_interval"`
+	// RotateSessionOnLogin - ends the session of the cookie sent with a login (default true)
+	RotateSessionOnLogin *bool `yaml:"rotate_session_on_login"`
+}
+
+// RotatesSessionOnLogin - reports whether a login ends the session it was sent with
+func (a Auth) RotatesSessionOnLogin() bool {
+	return a.RotateSessionOnLogin == nil || *a.RotateSessionOnLogin
+}
+
+// Recent - represents the recent uploads feed configuration
+type Recent struct {
+	// Size - number of uploads kept in the feed
+	Size int `yaml:"size"`
+	// File - optional file persisting the feed across restarts
+	File string `yaml:"file"`
+}
+
+// ContactSheet - represents the generated thumbnail sheets of image folders
+type ContactSheet struct {
+	// Enabled - serves /contact-sheet
+	Enabled bool `yaml:"enabled"`
+	// Columns - default number of thumbnails per row
+	Columns int `yaml:"columns"`
+	// CellSize - default width and height of a thumbnail cell in pixels
+	CellSize int `yaml:"cell_size"`
+	// MaxImages - largest number of images on a sheet
+	MaxImages int `yaml:"max_images"`
+	// Format - image format of the sheet: jpeg (default) or png
+	Format string `yaml:"format"`
+	// Quality - JPEG quality from 1 to 100
+	Quality int `yaml:"quality"`
+}
+
+// DirSize - represents the computation of the recursive size of directories
+type DirSize struct {
+	// Enabled - adds the recursive size of directories to /stat
+	Enabled bool `yaml:"enabled"`
+	// InListing - also shows the recursive size of the directories of a listing
+	InListing bool `yaml:"in_listing"`
+	// Workers - number of sizes computed concurrently
+	Workers int `yaml:"workers"`
+}
+
+// FTP - represents the read-only FTP access to the base directory
+type FTP struct {
+	// Enabled - starts the FTP server
+	Enabled bool `yaml:"enabled"`
+	// Port - port of the FTP control connection
+	Port string `yaml:"port"`
+	// PassivePorts - port range of passive data connections, e.g. "30000-30009" (any port when empty)
+	PassivePorts string `yaml:"passive_ports"`
+	// PublicHost - IP address announced for passive connections, e.g. behind NAT
+	PublicHost string `yaml:"public_host"`
+	// IdleTimeout - inactivity before a client is disconnected
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+}
+
+// Expiry - represents the automatic deletion of old files
+type Expiry struct {
+	// MaxAge - files last modified longer ago are deleted, disabled when zero
+	MaxAge time.Duration `yaml:"max_age"`
+	// Interval - time between sweeps
+	Interval time.Duration `yaml:"interval"`
+	// Paths - subtrees of base_dir swept, the whole base_dir when empty
+	Paths []string `yaml:"paths"`
+	// Exclude - name or path patterns of files and directories that never expire
+	Exclude []string `yaml:"exclude"`
+	// DryRun - only logs the files that would be deleted
+	DryRun bool `yaml:"dry_run"`
+}
+
+// Tags - represents the configuration of the user-defined tags of files
+type Tags struct {
+	// Enabled - allows tagging files and filtering listings and searches by tag
+	Enabled bool `yaml:"enabled"`
+	// File - index holding the tags, keyed by path (default: .tags.json in base_dir)
+	File string `yaml:"file"`
+}
+
+// Favorites - represents the configuration of the per-user pinned directories
+type Favorites struct {
+	// MaxPerUser - number of favorites kept per user
+	MaxPerUser int `yaml:"max_per_user"`
+	// File - optional file persisting the favorites across restarts
+	File string `yaml:"file"`
+}
+
+// Upload - represents the upload handling configuration
+type Upload struct {
+	// FieldName - multipart field carrying the uploaded files
+	FieldName string `yaml:"field_name"`
+	// AnyField - accepts files from every multipart field
+	AnyField bool `yaml:"any_field"`
+	// MaxConcurrentPerIP - parallel uploads allowed from one client IP (0 = unlimited)
+	MaxConcurrentPerIP int `yaml:"max_concurrent_per_ip"`
+	// AllowedTypes - content types uploaded files must contain, detected from their first
+	// bytes, e.g. "image/*" or "application/pdf"; files whose extension promises another
+	// type are rejected too (empty = no check)
+	AllowedTypes []string `yaml:"allowed_types"`
+	// RequireContentLength - rejects uploads without a Content-Length header with 411
+	RequireContentLength bool `yaml:"require_content_length"`
+	// ContinueOnError - keeps saving the remaining files when one file of an upload fails
+	ContinueOnError bool `yaml:"continue_on_error"`
+	// Quota - bytes a user may upload per time window
+	Quota Quota `yaml:"quota"`
+	// Links - signed upload links for users without an account
+	Links UploadLinks `yaml:"links"`
+	// TempDir - directory receiving the temp files of large uploads (defaults to the system temp directory)
+	TempDir string `yaml:"temp_dir"`
+	// TempSweep - removal of temp files left behind by abandoned uploads
+	TempSweep TempSweep `yaml:"temp_sweep"`
+	// Extract - extraction of uploaded archives requested with the extract form value
+	Extract Extract `yaml:"extract"`
+	// Chunked - uploads sent in chunks to /upload-chunk and assembled on completion
+	Chunked Chunked `yaml:"chunked"`
+}
+
+// ChildCount - represents the entry counts of the directories of a listing
+type ChildCount struct {
+	// Enabled - counts the entries of every listed directory, costing a directory read each
+	Enabled bool `yaml:"enabled"`
+	// Limit - entries counted at most per directory, larger ones show as "limit+" (defaults to 1000)
+	Limit int `yaml:"limit"`
+}
+
+// Rewrite - represents a rule serving the URL paths below Pattern from Target
+type Rewrite struct {
+	// Pattern - URL path whose subtree is rewritten, e.g. /latest
+	Pattern string `yaml:"pattern"`
+	// Target - path below base_dir serving the pattern, e.g. /releases
+	Target string `yaml:"target"`
+	// Resolve - subdirectory of Target to use: none (default), latest (greatest name) or newest (last modified)
+	Resolve string `yaml:"resolve"`
+}
+
+// Events - represents the server-sent event stream of file activity
+type Events struct {
+	// Enabled - enables /events
+	Enabled bool `yaml:"enabled"`
+	// MaxSubscribers - concurrent streams allowed (defaults to 100)
+	MaxSubscribers int `yaml:"max_subscribers"`
+	// KeepAlive - interval of the comments keeping idle streams open (defaults to 30s)
+	KeepAlive time.Duration `yaml:"keep_alive"`
+}
+
+// Chunked - represents chunked uploads with optional checksums
+type Chunked struct {
+	// Enabled - enables /upload-chunk
+	Enabled bool `yaml:"enabled"`
+	// Dir - directory keeping the chunks until assembly (defaults to sfs-chunks in the temp directory)
+	Dir string `yaml:"dir"`
+	// MaxAge - time after the last chunk after which an unfinished upload is removed (defaults to 24h)
+	MaxAge time.Duration `yaml:"max_age"`
+	// Workers - chunks verified in parallel on assembly (defaults to 4)
+	Workers int `yaml:"workers"`
+}
+
+// Extract - represents the extraction of uploaded tar and tar.gz archives
+type Extract struct {
+	// Enabled - allows uploads to request extraction
+	Enabled bool `yaml:"enabled"`
+	// MaxSize - largest total size of the extracted files of an archive in megabytes (defaults to 1024)
+	MaxSize int `yaml:"max_size"`
+	// MaxEntries - largest number of entries of an archive (defaults to 10000)
+	MaxEntries int `yaml:"max_entries"`
+	// Symlinks - symlink entries: skip (default), reject or allow (inside the extracted tree only)
+	Symlinks string `yaml:"symlinks"`
+	// PreserveModes - applies the permission bits stored in the archive
+	PreserveModes bool `yaml:"preserve_modes"`
+	// Unsupported - files that are not a tar archive: reject (default, 415) or store them as uploaded
+	Unsupported string `yaml:"unsupported"`
+}
+
+// TempSweep - represents the cleanup of stale upload temp files
+type TempSweep struct {
+	// MaxAge - age after which a temp file is removed (0 disables the sweeper)
+	MaxAge time.Duration `yaml:"max_age"`
+	// Interval - time between sweeps (defaults to 10m)
+	Interval time.Duration `yaml:"interval"`
+}
+
+// UploadLinks - represents the configuration of signed upload links
+type UploadLinks struct {
+	// Enabled - enables /upload-link to create links and /drop to use them
+	Enabled bool `yaml:"enabled"`
+	// DefaultExpiry - lifetime of a link created without an expiry (defaults to 24h)
+	DefaultExpiry time.Duration `yaml:"default_expiry"`
+	// MaxExpiry - longest lifetime a link may be given (defaults to 168h)
+	MaxExpiry time.Duration `yaml:"max_expiry"`
+	// MaxSize - size limit in megabytes of links created without one (0 = unlimited)
+	MaxSize int `yaml:"max_size"`
+}
+
+// Quota - represents the per-user upload quota
+type Quota struct {
+	// MaxSize - megabytes a user may upload per window (0 = unlimited)
+	MaxSize int `yaml:"max_size"`
+	// Window - length of the accounting window (defaults to 24h)
+	Window time.Duration `yaml:"window"`
+	// Users - per-user quotas in megabytes overriding max_size (0 = unlimited)
+	Users map[string]int `yaml:"users"`
+}
+
+// Archive - represents the configuration of downloaded archives
+type Archive struct {
+	// IncludeOwnership - stores the UID and GID of files in archives
+	IncludeOwnership bool `yaml:"include_ownership"`
+	// CompressionLevel - "store" or 0 (no compression) to 9 (smallest output); empty for the default
+	CompressionLevel string `yaml:"compression_level"`
+	// Format - format of multi-file downloads: zip (default) or tar.gz
+	Format string `yaml:"format"`
+	// MaxGlobMatches - largest number of files a glob download may archive (default 1000)
+	MaxGlobMatches int `yaml:"max_glob_matches"`
+	// MaxDepth - deepest directory level walked when archiving a tree (default 32)
+	MaxDepth int `yaml:"max_depth"`
+	// FollowSymlinks - enters symlinked directories inside the base directory while archiving a tree
+	FollowSymlinks bool `yaml:"follow_symlinks"`
+}
+
+// Preview - represents the file preview configuration
+type Preview struct {
+	// MaxSize - largest file previewed, in megabytes
+	MaxSize int `yaml:"max_size"`
+	// MaxRows - number of table rows shown for CSV/TSV files
+	MaxRows int `yaml:"max_rows"`
+	// Delimiter - CSV delimiter, detected from the content when empty
+	Delimiter string `yaml:"delimiter"`
+	// FallbackEncoding - encoding of text without a BOM that is not valid UTF-8:
+	// latin1 (default), windows-1252 or none to refuse previewing it
+	FallbackEncoding string `yaml:"fallback_encoding"`
+}
+
+// PWA - represents the web app manifest configuration
+type PWA struct {
+	Name            string    `yaml:"name" json:"name"`
+	ShortName       string    `yaml:"short_name" json:"short_name"`
+	ThemeColor      string    `yaml:"theme_color" json:"theme_color"`
+	BackgroundColor string    `yaml:"background_color" json:"background_color"`
+	Icons           []PWAIcon `yaml:"icons" json:"icons"`
+	// ServiceWorker - registers a pass-through service worker from the listing page
+	ServiceWorker bool `yaml:"service_worker" json:"-"`
+}
+
+// PWAIcon - represents an icon of the web app manifest
+type PWAIcon struct {
+	Src   string `yaml:"src" json:"src"`
+	Sizes string `yaml:"sizes" json:"sizes"`
+	Type  string `yaml:"type" json:"type"`
+}
+
+// Share - access policy for a directory tree below the base directory
+type Share struct {
+	// Path - directory relative to the base directory, e.g. /private
+	Path string `yaml:"path"`
+	// RequireAuth - only logged-in users may browse and download
+	RequireAuth bool `yaml:"require_auth"`
+	// AllowedUsers - restricts the share to these users (implies require_auth)
+	AllowedUsers []string `yaml:"allowed_users"`
+	// Deny - write operations refused in the share and below: upload, create, delete or write (all)
+	Deny []string `yaml:"deny"`
+	// ListToken - token anonymous visitors must pass as ?token= to list the share's directories
+	ListToken string `yaml:"list_token"`
+}
+
+// SelfTest - represents the checks run at startup before serving requests
+type SelfTest struct {
+	// Enabled - lists the base directory, writes and removes a temp file in it unless
+	// uploads to it are denied, and renders the templates, logging the results
+	Enabled bool `yaml:"enabled"`
+	// AbortOnFailure - exits when a check fails instead of only logging it
+	AbortOnFailure bool `yaml:"abort_on_failure"`
+}
+
+// Search - represents the configuration of the file name search
+type Search struct {
+	// MaxResults - maximum number of results returned after ranking
+	MaxResults int `yaml:"max_results"`
+	// MaxScanned - maximum number of entries visited by a single search
+	MaxScanned int `yaml:"max_scanned"`
+	// Index - answers searches from an in-memory index of the names instead of walking the tree
+	Index bool `yaml:"index"`
+	// RebuildInterval - time between full rebuilds of the index (default 1h)
+	RebuildInterval time.Duration `yaml:"rebuild_interval"`
+}
@@ -0,0 +1,68 @@
+package pkg
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactedShares(t *testing.T) {
+	c := Config{Shares: []Share{{Path: "/public", ListToken: "list-secret"}, {Path: "/open"}}}
+	redacted := c.Redacted()
+	if redacted.Shares[0].ListToken != redactedValue || redacted.Shares[1].ListToken != "" {
+		t.Errorf("redacted shares: %+v", redacted.Shares)
+	}
+	if c.Shares[0].ListToken != "list-secret" {
+		t.Errorf("redaction changed the live token to %q", c.Shares[0].ListToken)
+	}
+}
+
+// setSecrets - assigns a distinct value to every string field whose name suggests a
+// secret, in nested structs and the first element of struct slices, and returns them
+func setSecrets(v reflect.Value, prefix string, values map[string]string) {
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		name := prefix + field.Name
+		switch value.Kind() {
+		case reflect.Struct:
+			setSecrets(value, name+".", values)
+		case reflect.Slice:
+			if value.Type().Elem().Kind() == reflect.Struct {
+				value.Set(reflect.MakeSlice(value.Type(), 1, 1))
+				setSecrets(value.Index(0), name+"[0].", values)
+			}
+		case reflect.String:
+			lower := strings.ToLower(field.Name)
+			for _, word := range []string{"secret", "key", "token", "password"} {
+				if strings.Contains(lower, word) {
+					values[name] = "value-of-" + name
+					value.SetString(values[name])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestRedactedMasksSecrets(t *testing.T) {
+	var c Config
+	secrets := make(map[string]string)
+	setSecrets(reflect.ValueOf(&c).Elem(), "", secrets)
+	if len(secrets) == 0 {
+		t.Fatal("no secret-bearing fields found")
+	}
+
+	data, err := json.Marshal(c.Redacted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range secrets {
+		if strings.Contains(string(data), value) {
+			t.Errorf("Redacted() exposes %s", name)
+		}
+	}
+	if c.Logging.RedactKey != secrets["Logging.RedactKey"] {
+		t.Errorf("redaction changed the live configuration")
+	}
+}
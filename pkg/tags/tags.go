@@ -0,0 +1,198 @@
+// Description: This file implements the tags package, which keeps user-defined tags of files in an index file.
+package tags
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+// FileName - name of the index file in the base directory when the configuration leaves it unset
+const FileName = ".tags.json"
+
+// Limits of the tags of a file
+const (
+	MaxTags   = 20
+	MaxLength = 50
+)
+
+var (
+	mu    sync.Mutex
+	index = make(map[string][]string) // slash separated path -> sorted tags
+	file  string
+	base  string
+)
+
+// Setup - loads the index and drops the paths that no longer exist, e.g. files removed
+// outside of the server
+func Setup(config pkg.Tags, baseDir string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	base = baseDir
+	file = config.File
+	if file == "" {
+		file = filepath.Join(baseDir, FileName)
+	}
+	index = make(map[string][]string)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Logger.Warnf("Error reading tags file: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		logger.Logger.Warnf("Error parsing tags file: %v", err)
+		index = make(map[string][]string)
+		return
+	}
+	pruned := 0
+	for p := range index {
+		if _, err := os.Lstat(filepath.Join(base, filepath.FromSlash(p))); os.IsNotExist(err) {
+			delete(index, p)
+			pruned++
+		}
+	}
+	if pruned > 0 {
+		logger.Logger.Infof("Dropped the tags of %d missing files", pruned)
+		persist()
+	}
+}
+
+// key - normalizes a path to the form used in the index
+func key(p string) string {
+	return path.Clean("/" + p)
+}
+
+// Parse - splits a comma separated list of tags, trimming spaces, dropping empty and
+// duplicate tags and sorting the result
+func Parse(value string) ([]string, error) {
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > MaxLength {
+			return nil, fmt.Errorf("tag exceeds %d characters", MaxLength)
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	if len(result) > MaxTags {
+		return nil, fmt.Errorf("more than %d tags", MaxTags)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Get - returns the tags of the path
+func Get(p string) []string {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]string(nil), index[key(p)]...)
+}
+
+// Has - reports whether the path carries the tag
+func Has(p, tag string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, t := range index[key(p)] {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Set - replaces the tags of the path; no tags remove it from the index
+func Set(p string, tags []string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(tags) == 0 {
+		delete(index, key(p))
+	} else {
+		index[key(p)] = tags
+	}
+	return persist()
+}
+
+// Rename - moves the tags of the path and of everything below it to the new path
+func Rename(oldPath, newPath string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	oldPath, newPath = key(oldPath), key(newPath)
+	changed := false
+	for p, tags := range index {
+		if p != oldPath && !strings.HasPrefix(p, oldPath+"/") {
+			continue
+		}
+		delete(index, p)
+		index[newPath+strings.TrimPrefix(p, oldPath)] = tags
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return persist()
+}
+
+// Remove - drops the tags of the path and of everything below it
+func Remove(p string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	p = key(p)
+	changed := false
+	for tagged := range index {
+		if tagged == p || strings.HasPrefix(tagged, p+"/") {
+			delete(index, tagged)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return persist()
+}
+
+// persist - writes the index to its file, replacing it atomically; the caller must hold mu
+func persist() error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
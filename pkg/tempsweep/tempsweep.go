@@ -0,0 +1,101 @@
+// Description: This file implements the tempsweep package, which removes temp files left behind by abandoned uploads.
+package tempsweep
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+// Pattern - name pattern of the temp files written while parsing multipart uploads
+const Pattern = "multipart-*"
+
+// defaultInterval - time between sweeps when the configuration leaves it unset
+const defaultInterval = 10 * time.Minute
+
+var (
+	mu     sync.Mutex
+	active = make(map[int]time.Time) // upload id -> start
+	nextID int
+)
+
+// Begin - registers an upload in progress. Temp files created after the start of the
+// oldest upload in progress are never swept. The returned function ends the upload.
+func Begin() func() {
+	mu.Lock()
+	defer mu.Unlock()
+	id := nextID
+	nextID++
+	active[id] = time.Now()
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(active, id)
+	}
+}
+
+// cutoff - returns the time before which temp files may be removed: maxAge before now,
+// or the start of the oldest upload in progress when that is earlier
+func cutoff(now time.Time, maxAge time.Duration) time.Time {
+	mu.Lock()
+	defer mu.Unlock()
+	limit := now.Add(-maxAge)
+	for _, start := range active {
+		if start.Before(limit) {
+			limit = start
+		}
+	}
+	return limit
+}
+
+// Sweep - removes the temp upload files in dir last modified more than maxAge ago
+// and returns the number of removed files and the bytes reclaimed
+func Sweep(dir string, maxAge time.Duration, now time.Time) (int, int64, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, Pattern))
+	if err != nil {
+		return 0, 0, err
+	}
+	limit := cutoff(now, maxAge)
+	removed := 0
+	var reclaimed int64
+	for _, match := range matches {
+		info, err := os.Lstat(match)
+		if err != nil || !info.Mode().IsRegular() || !info.ModTime().Before(limit) {
+			continue
+		}
+		if err := os.Remove(match); err != nil {
+			logger.Logger.Warnf("Error removing stale upload temp file %s: %v", match, err)
+			continue
+		}
+		removed++
+		reclaimed += info.Size()
+	}
+	return removed, reclaimed, nil
+}
+
+// Start - sweeps the temp directory in the background. Nothing is started when
+// max_age is not set.
+func Start(config pkg.TempSweep, dir string) {
+	if config.MaxAge <= 0 {
+		return
+	}
+	interval := config.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	go func() {
+		for {
+			removed, reclaimed, err := Sweep(dir, config.MaxAge, time.Now())
+			if err != nil {
+				logger.Logger.Warnf("Error sweeping upload temp files in %s: %v", dir, err)
+			} else if removed > 0 {
+				logger.Logger.Infof("Removed %d stale upload temp files in %s, reclaimed %s", removed, dir, pkg.ReadableSize(reclaimed))
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,62 @@
+package tempsweep
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSweep(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	files := map[string]time.Duration{
+		"multipart-old":   2 * time.Hour,
+		"multipart-fresh": time.Minute,
+		"other-old":       2 * time.Hour,
+	}
+	for name, age := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, reclaimed, err := Sweep(dir, time.Hour, now)
+	if err != nil || removed != 1 || reclaimed != 4 {
+		t.Fatalf("Sweep = %d, %d, %v, want 1 file of 4 bytes", removed, reclaimed, err)
+	}
+	for name := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists == (name == "multipart-old") {
+			t.Errorf("%s exists %t after the sweep", name, exists)
+		}
+	}
+}
+
+func TestSweepKeepsFilesOfUploadsInProgress(t *testing.T) {
+	dir := t.TempDir()
+	end := Begin()
+	defer end()
+
+	// Written by the upload in progress, but older than the maximum age by the sweep's clock
+	path := filepath.Join(dir, "multipart-active")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	written := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, written, written); err != nil {
+		t.Fatal(err)
+	}
+	if removed, _, err := Sweep(dir, time.Hour, written.Add(2*time.Hour)); err != nil || removed != 0 {
+		t.Fatalf("Sweep removed %d files, %v, during an upload", removed, err)
+	}
+
+	end()
+	if removed, _, err := Sweep(dir, time.Hour, written.Add(2*time.Hour)); err != nil || removed != 1 {
+		t.Fatalf("Sweep removed %d files, %v, after the upload ended", removed, err)
+	}
+}
@@ -1,19 +1,124 @@
-// Description: This file contains the RenderTemplate function which is used to render the templates.
+// Description: This file contains the RenderTemplate and RenderJSON functions which are used to render responses, and formatting helpers.
 package pkg
 
 import (
+    "encoding/json"
+    "errors"
+    "fmt"
     "html/template"
     "net/http"
     "log"
+    "strconv"
+    "strings"
+    "syscall"
 )
 
 var Templates *template.Template
 
+// RequiredTemplates - templates the server cannot start without
+var RequiredTemplates = []string{"index.html", "login.html"}
+
+// MissingTemplates - returns the names of the templates not defined in t
+func MissingTemplates(t *template.Template, names []string) []string {
+    var missing []string
+    for _, name := range names {
+        if t.Lookup(name) == nil {
+            missing = append(missing, name)
+        }
+    }
+    return missing
+}
+
 // RenderTemplate - renders the template with the provided data
 func RenderTemplate(w http.ResponseWriter, tmpl string, data interface{}) {
+    if Templates.Lookup(tmpl) == nil {
+        http.Error(w, "Error rendering template", http.StatusInternalServerError)
+        log.Printf("Error rendering template: template %q not found", tmpl)
+        return
+    }
     err := Templates.ExecuteTemplate(w, tmpl, data)
     if err != nil {
         http.Error(w, "Error rendering template", http.StatusInternalServerError)
         log.Println("Error rendering template:", err)
     }
 }
+
+// ReadableSize - formats a size in bytes in a human readable form
+func ReadableSize(size int64) string {
+    const unit = 1024
+    if size < unit {
+        return fmt.Sprintf("%d B", size)
+    }
+    div, exp := int64(unit), 0
+    for n := size / unit; n >= unit; n /= unit {
+        div *= unit
+        exp++
+    }
+    return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// IsDiskFull - checks whether the error was caused by a lack of disk space
+func IsDiskFull(err error) bool {
+    return errors.Is(err, syscall.ENOSPC)
+}
+
+// WantsJSON - checks whether the client asked for a JSON response
+func WantsJSON(r *http.Request) bool {
+    if r.URL.Query().Get("format") == "json" {
+        return true
+    }
+    return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// RenderJSON - writes the data as a JSON response with the given status code
+func RenderJSON(w http.ResponseWriter, status int, data interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    if err := json.NewEncoder(w).Encode(data); err != nil {
+        log.Println("Error encoding JSON:", err)
+    }
+}
+
+// CollapseSlashes - replaces runs of slashes in a URL path with a single slash, keeping a trailing one
+func CollapseSlashes(p string) string {
+    if !strings.Contains(p, "//") {
+        return p
+    }
+    var b strings.Builder
+    b.Grow(len(p))
+    for i := 0; i < len(p); i++ {
+        if p[i] == '/' && i > 0 && p[i-1] == '/' {
+            continue
+        }
+        b.WriteByte(p[i])
+    }
+    return b.String()
+}
+
+// ParseReadmeDepth - parses the readme_depth setting: "all" (or empty) renders READMEs
+// everywhere and returns -1, "root" only in the base directory (0), a number up to that
+// directory depth
+func ParseReadmeDepth(value string) (int, error) {
+    switch strings.ToLower(strings.TrimSpace(value)) {
+    case "", "all":
+        return -1, nil
+    case "root", "only-root":
+        return 0, nil
+    }
+    depth, err := strconv.Atoi(strings.TrimSpace(value))
+    if err != nil || depth < 0 {
+        return 0, fmt.Errorf("invalid readme_depth %q (expected all, root or a non-negative number)", value)
+    }
+    return depth, nil
+}
+
+// PathDepth - returns the number of directories in the slash separated URL path ("/" is 0)
+func PathDepth(p string) int {
+    depth := 0
+    for _, part := range strings.Split(p, "/") {
+        if part != "" {
+            depth++
+        }
+    }
+    return depth
+}
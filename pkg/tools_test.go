@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMissingTemplates(t *testing.T) {
+	templates := template.Must(template.New("").Parse(`{{define "index.html"}}index{{end}}`))
+	if got := MissingTemplates(templates, RequiredTemplates); len(got) != 1 || got[0] != "login.html" {
+		t.Errorf("MissingTemplates = %v, want [login.html]", got)
+	}
+	template.Must(templates.Parse(`{{define "login.html"}}login{{end}}`))
+	if got := MissingTemplates(templates, RequiredTemplates); len(got) != 0 {
+		t.Errorf("MissingTemplates = %v, want none", got)
+	}
+}
+
+func TestRenderTemplateMissing(t *testing.T) {
+	saved := Templates
+	t.Cleanup(func() { Templates = saved })
+	Templates = template.Must(template.New("").Parse(`{{define "index.html"}}Hello {{.}}{{end}}`))
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	w := httptest.NewRecorder()
+	RenderTemplate(w, "index.html", "world")
+	if w.Code != http.StatusOK || w.Body.String() != "Hello world" {
+		t.Errorf("render: status %d, body %q", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	RenderTemplate(w, "upload.html", nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("missing template: status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if !strings.Contains(logged.String(), `"upload.html" not found`) {
+		t.Errorf("missing template name not logged: %q", logged.String())
+	}
+}
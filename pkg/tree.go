@@ -0,0 +1,55 @@
+// Description: This file contains the directory tree description used to bulk-create folders.
+package pkg
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DirNode - represents a directory and its nested subdirectories
+type DirNode struct {
+	Name     string    `json:"name"`
+	Children []DirNode `json:"children,omitempty"`
+}
+
+// ValidName - checks that the name is a single path component
+func ValidName(name string) error {
+	switch {
+	case name == "" || name == "." || name == "..":
+		return fmt.Errorf("invalid name: %q", name)
+	case strings.ContainsAny(name, "/\\\x00"):
+		return fmt.Errorf("name must not contain path separators: %q", name)
+	}
+	return nil
+}
+
+// TreePaths - flattens the tree into slash separated relative paths, parents first.
+// Every name is validated and the depth and number of directories are limited.
+func TreePaths(nodes []DirNode, maxDepth, maxCount int) ([]string, error) {
+	var paths []string
+	var walk func(nodes []DirNode, parent string, depth int) error
+	walk = func(nodes []DirNode, parent string, depth int) error {
+		if depth > maxDepth {
+			return fmt.Errorf("tree exceeds the maximum depth of %d", maxDepth)
+		}
+		for _, node := range nodes {
+			if err := ValidName(node.Name); err != nil {
+				return err
+			}
+			p := path.Join(parent, node.Name)
+			paths = append(paths, p)
+			if len(paths) > maxCount {
+				return fmt.Errorf("tree exceeds the maximum of %d directories", maxCount)
+			}
+			if err := walk(node.Children, p, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(nodes, "", 1); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
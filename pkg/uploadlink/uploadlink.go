@@ -0,0 +1,88 @@
+// Description: This file implements the uploadlink package, which signs and verifies time-limited upload links.
+package uploadlink
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalid - the token is malformed or its signature does not match
+	ErrInvalid = errors.New("invalid upload token")
+	// ErrExpired - the token is past its expiry
+	ErrExpired = errors.New("upload token expired")
+)
+
+// Token - permissions embedded in an upload link
+type Token struct {
+	// Path - folder the link uploads into, relative to the base directory
+	Path string `json:"p"`
+	// Expires - time after which the link is rejected
+	Expires time.Time `json:"e"`
+	// MaxSize - largest total upload in bytes (0 = unlimited)
+	MaxSize int64 `json:"s,omitempty"`
+	// User - user who created the link
+	User string `json:"u,omitempty"`
+}
+
+// key - HMAC key signing the tokens
+var key []byte
+
+// Setup - sets the signing secret. Without a secret a random key is generated,
+// so links stop working when the server restarts. It reports whether a key was generated.
+func Setup(secret string) (bool, error) {
+	if secret != "" {
+		key = []byte(secret)
+		return false, nil
+	}
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sign - returns the signature of the encoded payload
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Sign - encodes the token as "payload.signature", both base64url encoded
+func Sign(t Token) (string, error) {
+	t.Path = path.Clean("/" + t.Path)
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + sign(payload), nil
+}
+
+// Verify - checks the signature and expiry of the token and returns its permissions
+func Verify(token string, now time.Time) (Token, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(payload))) {
+		return Token{}, ErrInvalid
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Token{}, ErrInvalid
+	}
+	var t Token
+	if err := json.Unmarshal(data, &t); err != nil || t.Path == "" {
+		return Token{}, ErrInvalid
+	}
+	if !now.Before(t.Expires) {
+		return Token{}, ErrExpired
+	}
+	return t, nil
+}
@@ -0,0 +1,67 @@
+// Description: This file contains the builder of canonical absolute URLs to files.
+package pkg
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EscapePath - percent-encodes every segment of the slash separated path, so that names
+// containing "?", "#", "%" or spaces stay part of the path. Slashes, including a
+// trailing one marking a directory, are kept.
+func EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// fromTrustedProxy - reports whether the request was forwarded by a trusted proxy
+func fromTrustedProxy(r *http.Request) bool {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return isTrustedProxy(ip)
+}
+
+// requestOrigin - returns the scheme and host the client used to reach the server.
+// X-Forwarded-Proto and X-Forwarded-Host are honoured only from trusted proxies.
+func requestOrigin(r *http.Request) (scheme, host string) {
+	scheme, host = "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if fromTrustedProxy(r) {
+		if proto := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0]); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwarded := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Host"), ",")[0]); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return scheme, host
+}
+
+// FileURL - returns the absolute URL of the slash separated item path. basePath is the
+// address the server is published under: an absolute URL such as
+// https://files.example.com/share provides the scheme, host and path prefix, while a
+// path prefix such as /share or an empty value keeps the scheme and host of the request.
+// The item path is escaped segment by segment.
+func FileURL(r *http.Request, basePath, itemPath string) string {
+	scheme, host := requestOrigin(r)
+	prefix := basePath
+	if base, err := url.Parse(basePath); err == nil && base.Scheme != "" && base.Host != "" {
+		scheme, host, prefix = base.Scheme, base.Host, base.EscapedPath()
+	} else {
+		prefix = EscapePath(basePath)
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.HasPrefix(itemPath, "/") {
+		itemPath = "/" + itemPath
+	}
+	return scheme + "://" + host + prefix + EscapePath(itemPath)
+}
@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFileURL(t *testing.T) {
+	if err := SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name     string
+		basePath string
+		item     string
+		remote   string
+		tls      bool
+		headers  map[string]string
+		want     string
+	}{
+		{"plain", "", "/docs/a.txt", "", false, nil, "http://example.com/docs/a.txt"},
+		{"special characters", "", "/a b/50%?#x.txt", "", false, nil, "http://example.com/a%20b/50%25%3F%23x.txt"},
+		{"unicode and directory", "", "/отчёты/", "", false, nil, "http://example.com/%D0%BE%D1%82%D1%87%D1%91%D1%82%D1%8B/"},
+		{"relative item", "", "a.txt", "", false, nil, "http://example.com/a.txt"},
+		{"base path", "/share/", "/a b.txt", "", false, nil, "http://example.com/share/a%20b.txt"},
+		{"escaped base path", "/my files", "/a.txt", "", false, nil, "http://example.com/my%20files/a.txt"},
+		{"absolute base", "https://files.example.org/share", "/a.txt", "", false, nil, "https://files.example.org/share/a.txt"},
+		{"tls", "", "/a.txt", "", true, nil, "https://example.com/a.txt"},
+		{"trusted proxy", "", "/a.txt", "10.1.2.3:4000", false, map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "public.example.com, internal"}, "https://public.example.com/a.txt"},
+		{"untrusted proxy", "", "/a.txt", "192.0.2.1:4000", false, map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example.com"}, "http://example.com/a.txt"},
+		{"absolute base behind proxy", "https://files.example.org", "/a.txt", "10.1.2.3:4000", false, map[string]string{"X-Forwarded-Host": "public.example.com"}, "https://files.example.org/a.txt"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.remote != "" {
+			r.RemoteAddr = tt.remote
+		}
+		if tt.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		for name, value := range tt.headers {
+			r.Header.Set(name, value)
+		}
+		if got := FileURL(r, tt.basePath, tt.item); got != tt.want {
+			t.Errorf("%s: FileURL(%q, %q) = %q, want %q", tt.name, tt.basePath, tt.item, got, tt.want)
+		}
+	}
+}
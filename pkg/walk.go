@@ -0,0 +1,138 @@
+// Description: This file contains the bounded directory walk used to build archives.
+package pkg
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// WalkOptions - bounds of a directory walk
+type WalkOptions struct {
+	// MaxDepth - deepest subdirectory level entered; 0 walks only the files of the directory itself
+	MaxDepth int
+	// FollowSymlinks - enters symlinked directories, which may form cycles
+	FollowSymlinks bool
+	// Root - when set, symlinks resolving outside of it are skipped
+	Root string
+}
+
+// walker - state of a running walk
+type walker struct {
+	opts      WalkOptions
+	realRoot  string
+	visited   map[string]bool
+	ancestors map[string]bool
+	skip      func(rel string, entry fs.DirEntry) bool
+	fn        func(rel, full string) error
+	truncated []string
+}
+
+// WalkFiles - calls fn with the slash separated path relative to dir and the full path
+// of every regular file below dir, in lexical order. Entries for which skip returns true
+// are left out, directories with their contents. Directories beyond MaxDepth, directories
+// already visited (symlink cycles and duplicates, tracked by their real path) and
+// unreadable subdirectories are not entered; they are returned with the reason so that
+// the caller can report the truncated walk.
+func WalkFiles(dir string, opts WalkOptions, skip func(rel string, entry fs.DirEntry) bool, fn func(rel, full string) error) ([]string, error) {
+	w := &walker{opts: opts, visited: make(map[string]bool), ancestors: make(map[string]bool), skip: skip, fn: fn}
+	if opts.Root != "" {
+		realRoot, err := filepath.EvalSymlinks(opts.Root)
+		if err != nil {
+			return nil, err
+		}
+		w.realRoot = realRoot
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	w.visited[realDir] = true
+	w.ancestors[realDir] = true
+	if err := w.walk(dir, "", 0, true); err != nil {
+		return nil, err
+	}
+	return w.truncated, nil
+}
+
+// note - records a directory that was not entered
+func (w *walker) note(rel, reason string) {
+	w.truncated = append(w.truncated, fmt.Sprintf("%s: %s", rel, reason))
+}
+
+// walk - visits the entries of dir, found at the given depth
+func (w *walker) walk(dir, rel string, depth int, top bool) error {
+	entries, err := ReadDir(dir)
+	if err != nil {
+		if top {
+			return err
+		}
+		w.note(rel, "unreadable")
+		return nil
+	}
+	for _, entry := range entries {
+		childRel := path.Join(rel, entry.Name())
+		full := filepath.Join(dir, entry.Name())
+		if w.skip != nil && w.skip(childRel, entry) {
+			continue
+		}
+
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			info, err := os.Stat(full)
+			if err != nil {
+				continue
+			}
+			if w.realRoot != "" {
+				resolved, err := filepath.EvalSymlinks(full)
+				if err != nil || !within(w.realRoot, resolved) {
+					continue
+				}
+			}
+			if info.IsDir() && !w.opts.FollowSymlinks {
+				continue
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				continue
+			}
+			isDir = info.IsDir()
+		} else if !isDir && !entry.Type().IsRegular() {
+			continue
+		}
+
+		if !isDir {
+			if err := w.fn(childRel, full); err != nil {
+				return err
+			}
+			continue
+		}
+		if depth+1 > w.opts.MaxDepth {
+			if w.opts.MaxDepth > 0 {
+				w.note(childRel, "depth limit reached")
+			}
+			continue
+		}
+		realPath, err := filepath.EvalSymlinks(full)
+		if err != nil {
+			continue
+		}
+		if w.ancestors[realPath] {
+			w.note(childRel, "symlink cycle detected")
+			continue
+		}
+		if w.visited[realPath] {
+			w.note(childRel, "already visited through another path")
+			continue
+		}
+		w.visited[realPath] = true
+		w.ancestors[realPath] = true
+		err = w.walk(full, childRel, depth+1, false)
+		delete(w.ancestors, realPath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// walkTree - creates the files, with slash separated paths, and the symlinks, by name
+// and target, below a new directory and returns it
+func walkTree(t *testing.T, files []string, links map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, file := range files {
+		full := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+	}
+	return dir
+}
+
+// collect - walks dir and returns the relative paths of the files and the truncation notes
+func collect(t *testing.T, dir string, opts WalkOptions, skip func(string, fs.DirEntry) bool) ([]string, []string) {
+	t.Helper()
+	var files []string
+	truncated, err := WalkFiles(dir, opts, skip, func(rel, full string) error {
+		if full != filepath.Join(dir, filepath.FromSlash(rel)) {
+			t.Errorf("full path %q does not match %q", full, rel)
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files, truncated
+}
+
+func TestWalkFilesOrderAndDepth(t *testing.T) {
+	dir := walkTree(t, []string{"b.txt", "a/z.txt", "a/b/c.txt", "a/b/d/e.txt", "c.txt"}, nil)
+
+	tests := []struct {
+		maxDepth  int
+		files     []string
+		truncated []string
+	}{
+		{0, []string{"b.txt", "c.txt"}, nil},
+		{1, []string{"a/z.txt", "b.txt", "c.txt"}, []string{"a/b: depth limit reached"}},
+		{2, []string{"a/b/c.txt", "a/z.txt", "b.txt", "c.txt"}, []string{"a/b/d: depth limit reached"}},
+		{3, []string{"a/b/c.txt", "a/b/d/e.txt", "a/z.txt", "b.txt", "c.txt"}, nil},
+	}
+	for _, tt := range tests {
+		files, truncated := collect(t, dir, WalkOptions{MaxDepth: tt.maxDepth}, nil)
+		if !reflect.DeepEqual(files, tt.files) {
+			t.Errorf("MaxDepth %d: files = %v, want %v", tt.maxDepth, files, tt.files)
+		}
+		if !reflect.DeepEqual(truncated, tt.truncated) {
+			t.Errorf("MaxDepth %d: truncated = %v, want %v", tt.maxDepth, truncated, tt.truncated)
+		}
+	}
+}
+
+func TestWalkFilesSkip(t *testing.T) {
+	dir := walkTree(t, []string{"keep.txt", ".hidden/file.txt", "sub/.secret", "sub/file.txt"}, nil)
+	skip := func(rel string, entry fs.DirEntry) bool {
+		return strings.HasPrefix(entry.Name(), ".")
+	}
+	files, _ := collect(t, dir, WalkOptions{MaxDepth: 5}, skip)
+	if want := []string{"keep.txt", "sub/file.txt"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+}
+
+func TestWalkFilesSymlinks(t *testing.T) {
+	outside := walkTree(t, []string{"secret.txt", "dir/secret.txt"}, nil)
+	dir := walkTree(t, []string{"sub/file.txt"}, map[string]string{
+		"sub/loop":   "..",
+		"alias":      "sub",
+		"link.txt":   filepath.Join("sub", "file.txt"),
+		"escape.txt": filepath.Join(outside, "secret.txt"),
+		"escape-dir": filepath.Join(outside, "dir"),
+		"dangling":   filepath.Join(outside, "missing"),
+	})
+
+	files, truncated := collect(t, dir, WalkOptions{MaxDepth: 5, Root: dir}, nil)
+	if want := []string{"link.txt", "sub/file.txt"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("without FollowSymlinks: files = %v, want %v", files, want)
+	}
+	if truncated != nil {
+		t.Errorf("without FollowSymlinks: truncated = %v", truncated)
+	}
+
+	files, truncated = collect(t, dir, WalkOptions{MaxDepth: 5, Root: dir, FollowSymlinks: true}, nil)
+	if want := []string{"alias/file.txt", "link.txt"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("with FollowSymlinks: files = %v, want %v", files, want)
+	}
+	wantTruncated := []string{"alias/loop: symlink cycle detected", "sub: already visited through another path"}
+	if !reflect.DeepEqual(truncated, wantTruncated) {
+		t.Errorf("with FollowSymlinks: truncated = %v, want %v", truncated, wantTruncated)
+	}
+
+	// Without a root, symlinks outside the directory are followed
+	files, _ = collect(t, dir, WalkOptions{MaxDepth: 5}, nil)
+	if want := []string{"escape.txt", "link.txt", "sub/file.txt"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("without Root: files = %v, want %v", files, want)
+	}
+}
@@ -0,0 +1,122 @@
+// Description: This file implements the webhook package, which notifies external services about completed uploads.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"simple_file_server/pkg"
+	"simple_file_server/pkg/logger"
+)
+
+// SignatureHeader - header carrying the HMAC-SHA256 signature of the payload
+const SignatureHeader = "X-Signature-SHA256"
+
+// Defaults used when the configuration leaves the values unset
+const (
+	defaultTimeout    = 5 * time.Second
+	defaultRetries    = 3
+	defaultRetryDelay = 2 * time.Second
+)
+
+// UploadEvent - represents the payload sent after a successful upload
+type UploadEvent struct {
+	Path      string    `json:"path"`
+	URL       string    `json:"url"`
+	Size      int64     `json:"size"`
+	User      string    `json:"user"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+var (
+	config pkg.Webhook
+	secret string
+	client *http.Client
+)
+
+// Setup - configures the webhook with the loaded configuration and server secret
+func Setup(cfg pkg.Webhook, serverSecret string) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Retries <= 0 {
+		cfg.Retries = defaultRetries
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = defaultRetryDelay
+	}
+	config = cfg
+	secret = serverSecret
+	client = &http.Client{Timeout: cfg.Timeout}
+}
+
+// Sign - returns the hex encoded HMAC-SHA256 of the body using the given secret
+func Sign(body []byte, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NotifyUpload - sends the upload event to the configured webhook in the background.
+// Delivery failures are only logged and never affect the upload itself.
+func NotifyUpload(event UploadEvent) {
+	if config.URL == "" {
+		return
+	}
+	go func() {
+		if err := deliver(event); err != nil {
+			logger.Logger.Warnf("Webhook delivery failed for %s: %v", event.Path, err)
+		}
+	}()
+}
+
+// deliver - posts the event, retrying with a fixed delay on failure
+func deliver(event UploadEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding payload: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= config.Retries; attempt++ {
+		lastErr = post(body)
+		if lastErr == nil {
+			logger.Logger.Debugf("Webhook delivered for %s on attempt %d", event.Path, attempt)
+			return nil
+		}
+		logger.Logger.Debugf("Webhook attempt %d/%d failed: %v", attempt, config.Retries, lastErr)
+		if attempt < config.Retries {
+			time.Sleep(config.RetryDelay)
+		}
+	}
+	return lastErr
+}
+
+// post - performs a single signed delivery attempt
+func post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(body, secret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
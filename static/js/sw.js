@@ -0,0 +1,13 @@
+// Minimal service worker making the file manager installable as a PWA.
+// Requests are passed through to the network; nothing is cached.
+self.addEventListener('install', function(event) {
+    self.skipWaiting();
+});
+
+self.addEventListener('activate', function(event) {
+    event.waitUntil(self.clients.claim());
+});
+
+self.addEventListener('fetch', function(event) {
+    event.respondWith(fetch(event.request));
+});